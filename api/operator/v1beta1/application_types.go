@@ -17,6 +17,7 @@ limitations under the License.
 package v1beta1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -44,17 +45,28 @@ type ApplicationSpec struct {
 	QoSProfile QoSProfile `json:"qoSProfile,omitempty"`
 
 	StatusLink string `json:"statusLink,omitempty"`
+
+	// Zones where the application is onboarded
+	Zones []string `json:"zones,omitempty"`
+
+	// ForbiddenZones, zones where no new instance of the application can be
+	// created, the existing ones are kept
+	ForbiddenZones []string `json:"forbiddenZones,omitempty"`
 }
 
 type ComponentSpecRef struct {
 	ArtefactId string `json:"artefactId"`
+	// ComponentName, unique within the application
+	ComponentName string `json:"componentName,omitempty"`
 }
 
 type AppMetaData struct {
-	AccessToken     string `json:"accessToken,omitempty"`
-	Name            string `json:"name,omitempty"`
-	MobilitySupport bool   `json:"mobilitySupport,omitempty"`
-	Version         string `json:"version,omitempty"`
+	// AccessTokenSecretRef, key of a Secret in the Application namespace holding
+	// the access token of the application
+	AccessTokenSecretRef *corev1.SecretKeySelector `json:"accessTokenSecretRef,omitempty"`
+	Name                 string                    `json:"name,omitempty"`
+	MobilitySupport      bool                      `json:"mobilitySupport,omitempty"`
+	Version              string                    `json:"version,omitempty"`
 }
 
 type QoSProfile struct {
@@ -74,9 +86,34 @@ const (
 	ApplicationStateRemoved    ApplicationState = "REMOVED"
 )
 
+// ApplicationZoneStatus is the onboarding state of the application in a zone.
+type ApplicationZoneStatus struct {
+	ZoneId string `json:"zoneId"`
+	// State, empty while the zone follows the state of the application
+	State          ApplicationState `json:"state,omitempty"`
+	LastUpdateTime metav1.Time      `json:"lastUpdateTime,omitempty"`
+}
+
 // ApplicationStatus defines the observed state of Application.
 type ApplicationStatus struct {
 	State ApplicationState `json:"state,omitempty"`
+	// Zones, onboarding state of the application in each zone
+	Zones []ApplicationZoneStatus `json:"zones,omitempty"`
+	// LastSyncTime, when the state was last polled from the partner OP
+	LastSyncTime *metav1.Time `json:"lastSyncTime,omitempty"`
+	// HeldByPartner, the partner OP answered a poll with the application, which
+	// isn't polled anymore as the partner doesn't report its onboarding state
+	HeldByPartner bool `json:"heldByPartner,omitempty"`
+	// Attempts, number of failed requests to the partner OP since the last successful one
+	Attempts int32 `json:"attempts,omitempty"`
+	// LastError, error of the last failed request to the partner OP
+	LastError string `json:"lastError,omitempty"`
+	// ObservedGeneration, the spec generation last sent to the partner OP
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// ForbiddenZones, the forbidden zones last sent to the partner OP
+	ForbiddenZones []string `json:"forbiddenZones,omitempty"`
+	// Conditions, Ready, Reconciling and Stalled while the partner OP rejects the resource
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -69,13 +69,33 @@ const (
 	ApplicationInstanceStateTerminating ApplicationInstanceState = "TERMINATING"
 )
 
+// conditions
+const (
+	// ApplicationInstanceConditionDegraded is True while the partner OP notifies the
+	// zone of a guest ApplicationInstance as not available, in its zone status or
+	// its zone availability
+	ApplicationInstanceConditionDegraded = "Degraded"
+
+	ApplicationInstanceReasonZoneAvailable    = "ZoneAvailable"
+	ApplicationInstanceReasonZoneNotAvailable = "ZoneNotAvailable"
+)
+
 // ApplicationInstanceStatus defines the observed state of ApplicationInstance.
 type ApplicationInstanceStatus struct {
-	State           ApplicationInstanceState `json:"state,omitempty"`
-	Conditions      []metav1.Condition       `json:"conditions,omitempty"`
-	ErrorMsg        string                   `json:"errorMsg,omitempty"`
-	AccessPointInfo []AccessPointInfo        `json:"accessPointInfo,omitempty"`
-	AppInstanceId   string                   `json:"appInstanceId,omitempty"`
+	State ApplicationInstanceState `json:"state,omitempty"`
+	// LastSyncTime, when the state was last polled from the partner OP
+	LastSyncTime *metav1.Time `json:"lastSyncTime,omitempty"`
+	// Attempts, number of failed requests to the partner OP since the last successful one
+	Attempts int32 `json:"attempts,omitempty"`
+	// LastError, error of the last failed request to the partner OP
+	LastError string `json:"lastError,omitempty"`
+	// ObservedGeneration, the spec generation last reconciled
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Conditions, Ready, Reconciling and Stalled, Degraded while the zone is not available
+	Conditions      []metav1.Condition `json:"conditions,omitempty"`
+	ErrorMsg        string             `json:"errorMsg,omitempty"`
+	AccessPointInfo []AccessPointInfo  `json:"accessPointInfo,omitempty"`
+	AppInstanceId   string             `json:"appInstanceId,omitempty"`
 }
 
 type AccessPointInfo struct {
@@ -114,13 +134,13 @@ type ApplicationInstanceList struct {
 
 const (
 	// ReadyCondition indicates whether the ApplicationInstance is ready for use.
-	ApplicationInstanceConditionReady = "Ready"
+	ApplicationInstanceConditionReady = ConditionReady
 
 	// ReconcilingCondition indicates whether the ApplicationInstance is being reconciled.
-	ApplicationInstanceConditionReconciling = "Reconciling"
+	ApplicationInstanceConditionReconciling = ConditionReconciling
 
 	// StalledCondition indicates whether the ApplicationInstance reconciliation is blocked due to errors.
-	ApplicationInstanceConditionStalled = "Stalled"
+	ApplicationInstanceConditionStalled = ConditionStalled
 )
 
 const (
@@ -128,8 +148,8 @@ const (
 	ApplicationInstanceReasonResourcesAllocated = "ResourcesAllocated" // if its not ready, then is synching
 
 	// Reasons for ConditionReconciling
-	ApplicationInstanceReasonSyncInProgress = "SyncInProgress" // In progress
-	ApplicationInstanceReasonSyncCompleted  = "SyncCompleted"  // completed
+	ApplicationInstanceReasonSyncInProgress = ReasonSyncInProgress // In progress
+	ApplicationInstanceReasonSyncCompleted  = ReasonSyncCompleted  // completed
 
 	// Reasons for ConditionStalled
 	ApplicationInstanceReasonInvalidOperation   = "InvalidOperation"     // Invalid Data provided, such as invalid type
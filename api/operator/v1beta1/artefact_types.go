@@ -99,6 +99,16 @@ const (
 type ArtefactStatus struct {
 	// Important: Run "make" to regenerate code after modifying this file
 	State ArtefactState `json:"state,omitempty"`
+	// LastSyncTime, when the state was last polled from the partner OP
+	LastSyncTime *metav1.Time `json:"lastSyncTime,omitempty"`
+	// Attempts, number of failed requests to the partner OP since the last successful one
+	Attempts int32 `json:"attempts,omitempty"`
+	// LastError, error of the last failed request to the partner OP
+	LastError string `json:"lastError,omitempty"`
+	// ObservedGeneration, the spec generation last reconciled
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Conditions, Ready, Reconciling and Stalled while the partner OP rejects the resource
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 // +kubebuilder:object:root=true
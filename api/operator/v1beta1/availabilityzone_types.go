@@ -17,6 +17,7 @@ limitations under the License.
 package v1beta1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -36,6 +37,10 @@ type AvailabilityZoneSpec struct {
 
 	// ZoneId Human readable name of the zone.
 	ZoneId ZoneIdentifier `json:"zoneId,omitempty"`
+
+	// Capacity, compute, memory and GPU of the zone that can be reserved by the
+	// resource pools, e.g. cpu: "64", memory: 256Gi. Resources not listed are not limited.
+	Capacity corev1.ResourceList `json:"capacity,omitempty"`
 }
 
 // GeoLocation Latitude,Longitude as decimal fraction up to 4 digit precision
@@ -69,6 +74,11 @@ type AvailabilityZoneStatus struct {
 
 	// To be considered, for now these are just placeholder samples
 	Latency string `json:"latency,omitempty"`
+
+	// ObservedGeneration, the spec generation last reconciled
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Conditions, Ready once the zone is set up
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 // +kubebuilder:object:root=true
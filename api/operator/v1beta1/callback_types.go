@@ -0,0 +1,137 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// labels
+const (
+	// CallbackFederationLabel, name of the Federation the callback is sent through
+	CallbackFederationLabel = "opg.ewbi.nby.one/callback-federation"
+	// CallbackSourceLabel, uid of the resource the callback notifies about
+	CallbackSourceLabel = "opg.ewbi.nby.one/callback-source"
+)
+
+// CallbackKind, the partner callback link the notification is sent to
+type CallbackKind string
+
+const (
+	CallbackKindApplication         CallbackKind = "Application"
+	CallbackKindApplicationInstance CallbackKind = "ApplicationInstance"
+	CallbackKindArtefact            CallbackKind = "Artefact"
+	CallbackKindFile                CallbackKind = "File"
+	CallbackKindResourcePool        CallbackKind = "ResourcePool"
+)
+
+// NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
+
+// CallbackSpec defines a notification pending delivery to the guest OP.
+type CallbackSpec struct {
+	// Important: Run "make" to regenerate code after modifying this file
+
+	// FederationName, Federation whose partner statusLink receives the callback
+	FederationName string `json:"federationName"`
+
+	// Sequence orders the callbacks of a Federation, they are delivered one at a time
+	// from the lowest sequence
+	Sequence int64 `json:"sequence"`
+
+	// Kind of callback, selects the partner callback link
+	// +kubebuilder:validation:Enum=Application;ApplicationInstance;Artefact;File;ResourcePool
+	Kind CallbackKind `json:"kind"`
+
+	// SourceName, name of the resource the callback notifies about
+	SourceName string `json:"sourceName,omitempty"`
+
+	// SourceUID, uid of the resource the callback notifies about
+	SourceUID types.UID `json:"sourceUID,omitempty"`
+
+	// Payload, request body of the callback
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:validation:Type=object
+	Payload runtime.RawExtension `json:"payload"`
+}
+
+type CallbackState string
+
+const (
+	CallbackStatePending   CallbackState = "PENDING"
+	CallbackStateDelivered CallbackState = "DELIVERED"
+	// CallbackStateFailed, the partner rejected the callback and it won't be retried
+	CallbackStateFailed CallbackState = "FAILED"
+	// CallbackStateExpired, the callback wasn't delivered before its max age
+	CallbackStateExpired CallbackState = "EXPIRED"
+)
+
+// CallbackStatus defines the observed state of Callback.
+type CallbackStatus struct {
+	State CallbackState `json:"state,omitempty"`
+	// Attempts, number of delivery attempts
+	Attempts int32 `json:"attempts,omitempty"`
+	// LastAttemptTime, when the callback was last sent
+	LastAttemptTime *metav1.Time `json:"lastAttemptTime,omitempty"`
+	// NextAttemptTime, when the callback is sent again after a failed attempt
+	NextAttemptTime *metav1.Time `json:"nextAttemptTime,omitempty"`
+	// LastStatusCode, status code of the partner response to the last attempt
+	LastStatusCode int32 `json:"lastStatusCode,omitempty"`
+	// LastError, error of the last attempt
+	LastError string `json:"lastError,omitempty"`
+	// CompletionTime, when the callback was delivered, failed or expired
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+	// ObservedGeneration, the spec generation last reconciled
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Conditions, Ready once delivered, Stalled if it failed or expired
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:shortName=cb
+// +kubebuilder:printcolumn:name="Federation",type=string,JSONPath=`.spec.federationName`
+// +kubebuilder:printcolumn:name="Kind",type=string,JSONPath=`.spec.kind`
+// +kubebuilder:printcolumn:name="Source",type=string,JSONPath=`.spec.sourceName`
+// +kubebuilder:printcolumn:name="State",type=string,JSONPath=`.status.state`
+// +kubebuilder:printcolumn:name="Attempts",type=integer,JSONPath=`.status.attempts`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// Callback is the Schema for the callbacks API, a notification of the host
+// persisted until it is delivered to the guest OP.
+type Callback struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   CallbackSpec   `json:"spec,omitempty"`
+	Status CallbackStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// CallbackList contains a list of Callback.
+type CallbackList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Callback `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Callback{}, &CallbackList{})
+}
@@ -0,0 +1,43 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+// Conditions of the resources synced with the partner OP, they follow the
+// Ready/Reconciling/Stalled convention of kstatus so that kubectl wait and
+// GitOps health checks can tell when a resource is usable
+
+const (
+	// ConditionReady indicates the resource is usable, e.g. it was accepted by the partner OP
+	ConditionReady = "Ready"
+	// ConditionReconciling indicates the resource is being synced and its state may still change
+	ConditionReconciling = "Reconciling"
+	// ConditionStalled indicates the partner OP rejected the last request for the resource,
+	// or the resource reached a failure state, it is not retried until the resource changes
+	ConditionStalled = "Stalled"
+)
+
+const (
+	// Reasons for ConditionReady and ConditionReconciling
+	ReasonSyncInProgress     = "SyncInProgress"     // the resource is not in its final state yet
+	ReasonSyncCompleted      = "SyncCompleted"      // the resource reached its final state
+	ReasonPartnerUnavailable = "PartnerUnavailable" // the request failed and is retried, e.g. 5xx
+
+	// Reasons for ConditionStalled
+	ReasonPartnerRejected     = "PartnerRejected"     // the request is invalid or conflicts with the partner OP state
+	ReasonPartnerUnauthorized = "PartnerUnauthorized" // the partner OP rejected the credentials
+	ReasonFailed              = "Failed"              // the resource reached a failure state
+)
@@ -17,6 +17,7 @@ limitations under the License.
 package v1beta1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -33,6 +34,12 @@ const (
 	ExternalIdLabel          = "opg.ewbi.nby.one/id"
 )
 
+// annotations
+const (
+	// RetryAnnotation, set on a failed guest resource to send its request to the partner OP again
+	RetryAnnotation = "opg.ewbi.nby.one/retry"
+)
+
 // fields
 const (
 	FederationStatusContextIDField = ".status.federationContextId"
@@ -67,8 +74,67 @@ type FederationSpec struct {
 	// the OP offered for this Federation
 	AcceptedAvailabilityZones []string `json:"acceptedAvailabilityZones,omitempty"`
 
+	// ZoneSelection, policy the GuestOP follows to choose which of the offered
+	// AvailabilityZones are accepted. When set, AcceptedAvailabilityZones is kept
+	// in sync with it, otherwise a federation created without zones accepts the
+	// first offered one
+	ZoneSelection *ZoneSelection `json:"zoneSelection,omitempty"`
+
+	// SuppressInstallsInUnavailableZones, when true the GuestOP holds the new
+	// ApplicationInstances placed in a zone the partner OP notified as not available,
+	// in ZonesStatus or ZonesAvailability, until the zone is available again
+	SuppressInstallsInUnavailableZones bool `json:"suppressInstallsInUnavailableZones,omitempty"`
+
 	// Federation GuestPartner creds for the client to register (temporary, to be replaced by e.g. keycloack)
 	GuestPartnerCredentials FederationCredentials `json:"guestPartnerCredentials,omitempty"`
+
+	// GuestPartnerApiRoot, base URL of the partner OP federation API, e.g. http://ip:8080
+	// Defaults to GuestPartnerCredentials.TokenUrl, which must be set apart when
+	// the OAuth2 client credentials are enabled
+	GuestPartnerApiRoot string `json:"guestPartnerApiRoot,omitempty"`
+
+	// TLS, certificates used in the requests to the partner OP, both the federation
+	// API requests of the GuestOP and the callbacks of the HostOP
+	TLS *FederationTLS `json:"tls,omitempty"`
+
+	// RequestTimeout, limit for each request to the partner OP, both the federation
+	// API requests of the GuestOP and the callbacks of the HostOP.
+	// Defaults to the --opg-request-timeout of the operator
+	RequestTimeout *metav1.Duration `json:"requestTimeout,omitempty"`
+}
+
+type FederationTLS struct {
+	// ClientCertificateSecretRef, kubernetes.io/tls Secret in the Federation namespace
+	// holding the client certificate (tls.crt) and key (tls.key) presented to the partner
+	ClientCertificateSecretRef *corev1.LocalObjectReference `json:"clientCertificateSecretRef,omitempty"`
+
+	// CASecretRef, key of a Secret in the Federation namespace holding the PEM CA bundle
+	// the partner certificate is verified with, instead of the system CAs
+	CASecretRef *corev1.SecretKeySelector `json:"caSecretRef,omitempty"`
+}
+
+type ZoneSelectionPolicy string
+
+const (
+	// ZoneSelectionPolicyAll accepts every offered zone
+	ZoneSelectionPolicyAll ZoneSelectionPolicy = "All"
+	// ZoneSelectionPolicyList accepts the offered zones listed in ZoneIds
+	ZoneSelectionPolicyList ZoneSelectionPolicy = "List"
+	// ZoneSelectionPolicyGeography accepts the offered zones whose GeographyDetails
+	// match any of the Geography values
+	ZoneSelectionPolicyGeography ZoneSelectionPolicy = "Geography"
+)
+
+type ZoneSelection struct {
+	// +kubebuilder:validation:Enum=All;List;Geography
+	Policy ZoneSelectionPolicy `json:"policy"`
+
+	// ZoneIds, zones to accept with the List policy, e.g. ["zone-1", "zone-2"]
+	ZoneIds []string `json:"zoneIds,omitempty"`
+
+	// Geography, case insensitive values looked up in the offered zones
+	// GeographyDetails with the Geography policy, e.g. ["madrid", "urban"]
+	Geography []string `json:"geography,omitempty"`
 }
 
 type Origin struct {
@@ -102,6 +168,11 @@ type FederationCredentials struct {
 
 	// TokenUrl, e.g. http://ip:5555/cb
 	TokenUrl string `json:"tokenUrl,omitempty"`
+
+	// ClientSecretRef, key of a Secret in the Federation namespace holding the client secret.
+	// When set, OAuth2 client credentials tokens are requested to TokenUrl and sent as
+	// bearer tokens, otherwise only the client id is sent
+	ClientSecretRef *corev1.SecretKeySelector `json:"clientSecretRef,omitempty"`
 }
 
 type FederationState string
@@ -123,6 +194,80 @@ type FederationStatus struct {
 	// OfferedAvailabilityZones, GuestOP offered AvailabilityZones
 	// for this Federation
 	OfferedAvailabilityZones []ZoneDetails `json:"offeredAvailabilityZones,omitempty"`
+
+	// AcceptedAvailabilityZones, AvailabilityZones currently subscribed
+	// at the partner OP for this Federation
+	AcceptedAvailabilityZones []string `json:"acceptedAvailabilityZones,omitempty"`
+
+	// ZonesStatus, status notified by the partner OP for each offered zone
+	ZonesStatus []ZoneStatus `json:"zonesStatus,omitempty"`
+
+	// ZonesAvailability, resource availability notified by the partner OP
+	// for each accepted zone through the availability zone notification link
+	ZonesAvailability []ZoneAvailability `json:"zonesAvailability,omitempty"`
+
+	// EdgeDiscoveryServiceEndpoint, partner OP edge discovery service endpoint
+	// notified through the partner status link
+	EdgeDiscoveryServiceEndpoint *ServiceEndpoint `json:"edgeDiscoveryServiceEndpoint,omitempty"`
+
+	// LcmServiceEndpoint, partner OP application LCM service endpoint
+	// notified through the partner status link
+	LcmServiceEndpoint *ServiceEndpoint `json:"lcmServiceEndpoint,omitempty"`
+
+	// PartnerNetworkCodes, partner OP mobile and fixed network codes
+	// notified through the partner status link
+	PartnerNetworkCodes *NetworkCodes `json:"partnerNetworkCodes,omitempty"`
+
+	// SyncedOriginOP, OriginOP network codes last acknowledged by the partner OP,
+	// the guest compares it with the spec to push code changes
+	SyncedOriginOP *Origin `json:"syncedOriginOP,omitempty"`
+	// ObservedGeneration, the spec generation last reconciled
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Conditions, Ready, Reconciling and Stalled while the partner OP rejects the resource
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+type ZoneStatus struct {
+	ZoneId string `json:"zoneId"`
+
+	// State, zone status notified by the partner OP, same values as the federation state
+	State FederationState `json:"state"`
+
+	LastUpdateTime metav1.Time `json:"lastUpdateTime,omitempty"`
+}
+
+type ZoneAvailabilityState string
+
+const (
+	ZoneAvailabilityStateAvailable    ZoneAvailabilityState = "AVAILABLE"
+	ZoneAvailabilityStateNotAvailable ZoneAvailabilityState = "NOT_AVAILABLE"
+)
+
+type ZoneAvailability struct {
+	ZoneId string `json:"zoneId"`
+
+	// State, NOT_AVAILABLE when the partner OP notified no compute resources
+	// available for the GuestOP in the zone
+	State ZoneAvailabilityState `json:"state"`
+
+	// LastTransitionTime, last time the state changed
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+
+	// LastUpdateTime, last time the partner OP notified the zone availability
+	LastUpdateTime metav1.Time `json:"lastUpdateTime,omitempty"`
+}
+
+type ServiceEndpoint struct {
+	Fqdn          string   `json:"fqdn,omitempty"`
+	Ipv4Addresses []string `json:"ipv4Addresses,omitempty"`
+	Ipv6Addresses []string `json:"ipv6Addresses,omitempty"`
+	Port          int      `json:"port"`
+}
+
+type NetworkCodes struct {
+	FixedNetworkCodes []string `json:"fixedNetworkCodes,omitempty"`
+
+	MobileNetworkCodes MobileNetworkCodes `json:"mobileNetworkCodes,omitempty"`
 }
 
 type ZoneDetails struct {
@@ -17,6 +17,7 @@ limitations under the License.
 package v1beta1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -62,12 +63,13 @@ type Repo struct {
 	// Repo's URL
 	URL string `json:"url,omitempty"`
 
-	// Password field required to access private repos
-	Password string `json:"password,omitempty"`
+	// PasswordSecretRef, key of a Secret in the File namespace holding
+	// the password required to access private repos
+	PasswordSecretRef *corev1.SecretKeySelector `json:"passwordSecretRef,omitempty"`
 
-	// Repo's Access token, the spec doesn't clarify
-	// if it should either Password or Token
-	Token string `json:"token,omitempty"`
+	// TokenSecretRef, key of a Secret in the File namespace holding the repo's
+	// Access token, the spec doesn't clarify if it should either Password or Token
+	TokenSecretRef *corev1.SecretKeySelector `json:"tokenSecretRef,omitempty"`
 
 	UserName string `json:"username,omitempty"`
 }
@@ -110,6 +112,16 @@ const (
 // FileStatus defines the observed state of File.
 type FileStatus struct {
 	State FileState `json:"state,omitempty"`
+	// LastSyncTime, when the state was last polled from the partner OP
+	LastSyncTime *metav1.Time `json:"lastSyncTime,omitempty"`
+	// Attempts, number of failed requests to the partner OP since the last successful one
+	Attempts int32 `json:"attempts,omitempty"`
+	// LastError, error of the last failed request to the partner OP
+	LastError string `json:"lastError,omitempty"`
+	// ObservedGeneration, the spec generation last reconciled
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Conditions, Ready, Reconciling and Stalled while the partner OP rejects the resource
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -0,0 +1,131 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// finalizers
+const (
+	ResourcePoolFinalizer = "resourcepool.opg.ewbi.finalizer.nby.one"
+)
+
+// NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
+
+// ResourcePoolSpec defines the desired state of ResourcePool.
+type ResourcePoolSpec struct {
+	// Important: Run "make" to regenerate code after modifying this file
+
+	// AppProviderID identifies the ISV the resources are reserved for
+	// e.g. "provider-id-67890"
+	AppProviderId string `json:"appProviderId,omitempty"`
+
+	// poolId is stored in the id label
+
+	// ZoneId of the partner OP zone where the resources are reserved
+	ZoneId string `json:"zoneId,omitempty"`
+
+	// PoolName ISV defined name of the resource pool
+	PoolName string `json:"poolName,omitempty"`
+
+	// ReserveDuration, time period for which the resources are reserved
+	ReserveDuration ReserveDuration `json:"reserveDuration,omitempty"`
+
+	// Flavours to be reserved
+	Flavours []PoolFlavour `json:"flavours,omitempty"`
+
+	// If defined, Link where the guest orchestrator expects to receive the callBacks with
+	// ResourcePool status updates
+	CallBackLink string `json:"callBackLink,omitempty"`
+}
+
+type ReserveDuration struct {
+	Days   int32 `json:"days,omitempty"`
+	Months int32 `json:"months,omitempty"`
+	Years  int32 `json:"years,omitempty"`
+}
+
+type PoolFlavour struct {
+	FlavourId string `json:"flavourId"`
+	// NumFlavour, total number of flavours to be reserved
+	NumFlavour int32 `json:"numFlavour"`
+	// MinNumFlavour, the reservation fails when fewer flavours can be reserved
+	MinNumFlavour int32 `json:"minNumFlavour,omitempty"`
+	// Resources, compute, memory and GPU of a single flavour,
+	// e.g. cpu: "2", memory: 4Gi, nvidia.com/gpu: "1"
+	Resources corev1.ResourceList `json:"resources,omitempty"`
+}
+
+type ResourcePoolState string
+
+const (
+	ResourcePoolStatePending  ResourcePoolState = "PENDING"
+	ResourcePoolStateReserved ResourcePoolState = "RESERVED"
+	ResourcePoolStateExpired  ResourcePoolState = "EXPIRED"
+	ResourcePoolStateFailed   ResourcePoolState = "FAILED"
+)
+
+type GrantedFlavour struct {
+	FlavourId  string `json:"flavourId"`
+	NumFlavour int32  `json:"numFlavour"`
+}
+
+// ResourcePoolStatus defines the observed state of ResourcePool.
+type ResourcePoolStatus struct {
+	State ResourcePoolState `json:"state,omitempty"`
+	// ReservationTime, when the resources were reserved
+	ReservationTime *metav1.Time `json:"reservationTime,omitempty"`
+	// ExpirationTime, when the reservation ends
+	ExpirationTime *metav1.Time `json:"expirationTime,omitempty"`
+	// GrantedFlavours, flavours reserved by the partner OP
+	GrantedFlavours []GrantedFlavour `json:"grantedFlavours,omitempty"`
+	// Reserved, total compute, memory and GPU of the granted flavours
+	Reserved corev1.ResourceList `json:"reserved,omitempty"`
+	// ObservedGeneration, the spec generation last reserved on the host or
+	// sent to the partner OP on the guest
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Conditions, Ready, Reconciling and Stalled while the partner OP rejects the resource
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:shortName=respool
+
+// ResourcePool is the Schema for the resourcepools API.
+type ResourcePool struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ResourcePoolSpec   `json:"spec,omitempty"`
+	Status ResourcePoolStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ResourcePoolList contains a list of ResourcePool.
+type ResourcePoolList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ResourcePool `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ResourcePool{}, &ResourcePoolList{})
+}
@@ -21,8 +21,9 @@ limitations under the License.
 package v1beta1
 
 import (
-	"k8s.io/apimachinery/pkg/apis/meta/v1"
-	runtime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
@@ -69,6 +70,11 @@ func (in *AccessPoints) DeepCopy() *AccessPoints {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AppMetaData) DeepCopyInto(out *AppMetaData) {
 	*out = *in
+	if in.AccessTokenSecretRef != nil {
+		in, out := &in.AccessTokenSecretRef, &out.AccessTokenSecretRef
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AppMetaData.
@@ -87,7 +93,7 @@ func (in *Application) DeepCopyInto(out *Application) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Application.
@@ -186,9 +192,13 @@ func (in *ApplicationInstanceSpec) DeepCopy() *ApplicationInstanceSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ApplicationInstanceStatus) DeepCopyInto(out *ApplicationInstanceStatus) {
 	*out = *in
+	if in.LastSyncTime != nil {
+		in, out := &in.LastSyncTime, &out.LastSyncTime
+		*out = (*in).DeepCopy()
+	}
 	if in.Conditions != nil {
 		in, out := &in.Conditions, &out.Conditions
-		*out = make([]v1.Condition, len(*in))
+		*out = make([]metav1.Condition, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
@@ -252,8 +262,18 @@ func (in *ApplicationSpec) DeepCopyInto(out *ApplicationSpec) {
 		*out = make([]ComponentSpecRef, len(*in))
 		copy(*out, *in)
 	}
-	out.MetaData = in.MetaData
+	in.MetaData.DeepCopyInto(&out.MetaData)
 	out.QoSProfile = in.QoSProfile
+	if in.Zones != nil {
+		in, out := &in.Zones, &out.Zones
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ForbiddenZones != nil {
+		in, out := &in.ForbiddenZones, &out.ForbiddenZones
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ApplicationSpec.
@@ -269,6 +289,29 @@ func (in *ApplicationSpec) DeepCopy() *ApplicationSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ApplicationStatus) DeepCopyInto(out *ApplicationStatus) {
 	*out = *in
+	if in.Zones != nil {
+		in, out := &in.Zones, &out.Zones
+		*out = make([]ApplicationZoneStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.LastSyncTime != nil {
+		in, out := &in.LastSyncTime, &out.LastSyncTime
+		*out = (*in).DeepCopy()
+	}
+	if in.ForbiddenZones != nil {
+		in, out := &in.ForbiddenZones, &out.ForbiddenZones
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ApplicationStatus.
@@ -281,13 +324,29 @@ func (in *ApplicationStatus) DeepCopy() *ApplicationStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ApplicationZoneStatus) DeepCopyInto(out *ApplicationZoneStatus) {
+	*out = *in
+	in.LastUpdateTime.DeepCopyInto(&out.LastUpdateTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ApplicationZoneStatus.
+func (in *ApplicationZoneStatus) DeepCopy() *ApplicationZoneStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ApplicationZoneStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Artefact) DeepCopyInto(out *Artefact) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Artefact.
@@ -365,6 +424,17 @@ func (in *ArtefactSpec) DeepCopy() *ArtefactSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ArtefactStatus) DeepCopyInto(out *ArtefactStatus) {
 	*out = *in
+	if in.LastSyncTime != nil {
+		in, out := &in.LastSyncTime, &out.LastSyncTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ArtefactStatus.
@@ -382,7 +452,7 @@ func (in *AvailabilityZone) DeepCopyInto(out *AvailabilityZone) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
+	in.Spec.DeepCopyInto(&out.Spec)
 	in.Status.DeepCopyInto(&out.Status)
 }
 
@@ -439,6 +509,13 @@ func (in *AvailabilityZoneList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AvailabilityZoneSpec) DeepCopyInto(out *AvailabilityZoneSpec) {
 	*out = *in
+	if in.Capacity != nil {
+		in, out := &in.Capacity, &out.Capacity
+		*out = make(v1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AvailabilityZoneSpec.
@@ -459,6 +536,13 @@ func (in *AvailabilityZoneStatus) DeepCopyInto(out *AvailabilityZoneStatus) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AvailabilityZoneStatus.
@@ -471,6 +555,115 @@ func (in *AvailabilityZoneStatus) DeepCopy() *AvailabilityZoneStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Callback) DeepCopyInto(out *Callback) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Callback.
+func (in *Callback) DeepCopy() *Callback {
+	if in == nil {
+		return nil
+	}
+	out := new(Callback)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *Callback) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CallbackList) DeepCopyInto(out *CallbackList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]Callback, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CallbackList.
+func (in *CallbackList) DeepCopy() *CallbackList {
+	if in == nil {
+		return nil
+	}
+	out := new(CallbackList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *CallbackList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CallbackSpec) DeepCopyInto(out *CallbackSpec) {
+	*out = *in
+	in.Payload.DeepCopyInto(&out.Payload)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CallbackSpec.
+func (in *CallbackSpec) DeepCopy() *CallbackSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(CallbackSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CallbackStatus) DeepCopyInto(out *CallbackStatus) {
+	*out = *in
+	if in.LastAttemptTime != nil {
+		in, out := &in.LastAttemptTime, &out.LastAttemptTime
+		*out = (*in).DeepCopy()
+	}
+	if in.NextAttemptTime != nil {
+		in, out := &in.NextAttemptTime, &out.NextAttemptTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CallbackStatus.
+func (in *CallbackStatus) DeepCopy() *CallbackStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(CallbackStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CommandLine) DeepCopyInto(out *CommandLine) {
 	*out = *in
@@ -598,6 +791,11 @@ func (in *Federation) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *FederationCredentials) DeepCopyInto(out *FederationCredentials) {
 	*out = *in
+	if in.ClientSecretRef != nil {
+		in, out := &in.ClientSecretRef, &out.ClientSecretRef
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FederationCredentials.
@@ -647,7 +845,7 @@ func (in *FederationSpec) DeepCopyInto(out *FederationSpec) {
 	*out = *in
 	in.InitialDate.DeepCopyInto(&out.InitialDate)
 	in.OriginOP.DeepCopyInto(&out.OriginOP)
-	out.Partner = in.Partner
+	in.Partner.DeepCopyInto(&out.Partner)
 	if in.OfferedAvailabilityZones != nil {
 		in, out := &in.OfferedAvailabilityZones, &out.OfferedAvailabilityZones
 		*out = make([]ZoneDetails, len(*in))
@@ -658,7 +856,22 @@ func (in *FederationSpec) DeepCopyInto(out *FederationSpec) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
-	out.GuestPartnerCredentials = in.GuestPartnerCredentials
+	if in.ZoneSelection != nil {
+		in, out := &in.ZoneSelection, &out.ZoneSelection
+		*out = new(ZoneSelection)
+		(*in).DeepCopyInto(*out)
+	}
+	in.GuestPartnerCredentials.DeepCopyInto(&out.GuestPartnerCredentials)
+	if in.TLS != nil {
+		in, out := &in.TLS, &out.TLS
+		*out = new(FederationTLS)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RequestTimeout != nil {
+		in, out := &in.RequestTimeout, &out.RequestTimeout
+		*out = new(metav1.Duration)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FederationSpec.
@@ -679,6 +892,52 @@ func (in *FederationStatus) DeepCopyInto(out *FederationStatus) {
 		*out = make([]ZoneDetails, len(*in))
 		copy(*out, *in)
 	}
+	if in.AcceptedAvailabilityZones != nil {
+		in, out := &in.AcceptedAvailabilityZones, &out.AcceptedAvailabilityZones
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ZonesStatus != nil {
+		in, out := &in.ZonesStatus, &out.ZonesStatus
+		*out = make([]ZoneStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ZonesAvailability != nil {
+		in, out := &in.ZonesAvailability, &out.ZonesAvailability
+		*out = make([]ZoneAvailability, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.EdgeDiscoveryServiceEndpoint != nil {
+		in, out := &in.EdgeDiscoveryServiceEndpoint, &out.EdgeDiscoveryServiceEndpoint
+		*out = new(ServiceEndpoint)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.LcmServiceEndpoint != nil {
+		in, out := &in.LcmServiceEndpoint, &out.LcmServiceEndpoint
+		*out = new(ServiceEndpoint)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PartnerNetworkCodes != nil {
+		in, out := &in.PartnerNetworkCodes, &out.PartnerNetworkCodes
+		*out = new(NetworkCodes)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SyncedOriginOP != nil {
+		in, out := &in.SyncedOriginOP, &out.SyncedOriginOP
+		*out = new(Origin)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FederationStatus.
@@ -691,13 +950,38 @@ func (in *FederationStatus) DeepCopy() *FederationStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FederationTLS) DeepCopyInto(out *FederationTLS) {
+	*out = *in
+	if in.ClientCertificateSecretRef != nil {
+		in, out := &in.ClientCertificateSecretRef, &out.ClientCertificateSecretRef
+		*out = new(v1.LocalObjectReference)
+		**out = **in
+	}
+	if in.CASecretRef != nil {
+		in, out := &in.CASecretRef, &out.CASecretRef
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FederationTLS.
+func (in *FederationTLS) DeepCopy() *FederationTLS {
+	if in == nil {
+		return nil
+	}
+	out := new(FederationTLS)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *File) DeepCopyInto(out *File) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
-	out.Status = in.Status
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new File.
@@ -753,7 +1037,7 @@ func (in *FileList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *FileSpec) DeepCopyInto(out *FileSpec) {
 	*out = *in
-	out.Repo = in.Repo
+	in.Repo.DeepCopyInto(&out.Repo)
 	out.Image = in.Image
 }
 
@@ -770,6 +1054,17 @@ func (in *FileSpec) DeepCopy() *FileSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *FileStatus) DeepCopyInto(out *FileStatus) {
 	*out = *in
+	if in.LastSyncTime != nil {
+		in, out := &in.LastSyncTime, &out.LastSyncTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FileStatus.
@@ -782,6 +1077,21 @@ func (in *FileStatus) DeepCopy() *FileStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GrantedFlavour) DeepCopyInto(out *GrantedFlavour) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GrantedFlavour.
+func (in *GrantedFlavour) DeepCopy() *GrantedFlavour {
+	if in == nil {
+		return nil
+	}
+	out := new(GrantedFlavour)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Image) DeepCopyInto(out *Image) {
 	*out = *in
@@ -818,6 +1128,27 @@ func (in *MobileNetworkCodes) DeepCopy() *MobileNetworkCodes {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NetworkCodes) DeepCopyInto(out *NetworkCodes) {
+	*out = *in
+	if in.FixedNetworkCodes != nil {
+		in, out := &in.FixedNetworkCodes, &out.FixedNetworkCodes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	in.MobileNetworkCodes.DeepCopyInto(&out.MobileNetworkCodes)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkCodes.
+func (in *NetworkCodes) DeepCopy() *NetworkCodes {
+	if in == nil {
+		return nil
+	}
+	out := new(NetworkCodes)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *OS) DeepCopyInto(out *OS) {
 	*out = *in
@@ -857,7 +1188,7 @@ func (in *Origin) DeepCopy() *Origin {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Partner) DeepCopyInto(out *Partner) {
 	*out = *in
-	out.CallbackCredentials = in.CallbackCredentials
+	in.CallbackCredentials.DeepCopyInto(&out.CallbackCredentials)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Partner.
@@ -870,6 +1201,28 @@ func (in *Partner) DeepCopy() *Partner {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PoolFlavour) DeepCopyInto(out *PoolFlavour) {
+	*out = *in
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = make(v1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PoolFlavour.
+func (in *PoolFlavour) DeepCopy() *PoolFlavour {
+	if in == nil {
+		return nil
+	}
+	out := new(PoolFlavour)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *QoSProfile) DeepCopyInto(out *QoSProfile) {
 	*out = *in
@@ -888,6 +1241,16 @@ func (in *QoSProfile) DeepCopy() *QoSProfile {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Repo) DeepCopyInto(out *Repo) {
 	*out = *in
+	if in.PasswordSecretRef != nil {
+		in, out := &in.PasswordSecretRef, &out.PasswordSecretRef
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TokenSecretRef != nil {
+		in, out := &in.TokenSecretRef, &out.TokenSecretRef
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Repo.
@@ -900,6 +1263,170 @@ func (in *Repo) DeepCopy() *Repo {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReserveDuration) DeepCopyInto(out *ReserveDuration) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReserveDuration.
+func (in *ReserveDuration) DeepCopy() *ReserveDuration {
+	if in == nil {
+		return nil
+	}
+	out := new(ReserveDuration)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourcePool) DeepCopyInto(out *ResourcePool) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourcePool.
+func (in *ResourcePool) DeepCopy() *ResourcePool {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourcePool)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ResourcePool) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourcePoolList) DeepCopyInto(out *ResourcePoolList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ResourcePool, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourcePoolList.
+func (in *ResourcePoolList) DeepCopy() *ResourcePoolList {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourcePoolList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ResourcePoolList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourcePoolSpec) DeepCopyInto(out *ResourcePoolSpec) {
+	*out = *in
+	out.ReserveDuration = in.ReserveDuration
+	if in.Flavours != nil {
+		in, out := &in.Flavours, &out.Flavours
+		*out = make([]PoolFlavour, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourcePoolSpec.
+func (in *ResourcePoolSpec) DeepCopy() *ResourcePoolSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourcePoolSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourcePoolStatus) DeepCopyInto(out *ResourcePoolStatus) {
+	*out = *in
+	if in.ReservationTime != nil {
+		in, out := &in.ReservationTime, &out.ReservationTime
+		*out = (*in).DeepCopy()
+	}
+	if in.ExpirationTime != nil {
+		in, out := &in.ExpirationTime, &out.ExpirationTime
+		*out = (*in).DeepCopy()
+	}
+	if in.GrantedFlavours != nil {
+		in, out := &in.GrantedFlavours, &out.GrantedFlavours
+		*out = make([]GrantedFlavour, len(*in))
+		copy(*out, *in)
+	}
+	if in.Reserved != nil {
+		in, out := &in.Reserved, &out.Reserved
+		*out = make(v1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourcePoolStatus.
+func (in *ResourcePoolStatus) DeepCopy() *ResourcePoolStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourcePoolStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServiceEndpoint) DeepCopyInto(out *ServiceEndpoint) {
+	*out = *in
+	if in.Ipv4Addresses != nil {
+		in, out := &in.Ipv4Addresses, &out.Ipv4Addresses
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Ipv6Addresses != nil {
+		in, out := &in.Ipv6Addresses, &out.Ipv6Addresses
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceEndpoint.
+func (in *ServiceEndpoint) DeepCopy() *ServiceEndpoint {
+	if in == nil {
+		return nil
+	}
+	out := new(ServiceEndpoint)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Zone) DeepCopyInto(out *Zone) {
 	*out = *in
@@ -915,6 +1442,23 @@ func (in *Zone) DeepCopy() *Zone {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ZoneAvailability) DeepCopyInto(out *ZoneAvailability) {
+	*out = *in
+	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+	in.LastUpdateTime.DeepCopyInto(&out.LastUpdateTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ZoneAvailability.
+func (in *ZoneAvailability) DeepCopy() *ZoneAvailability {
+	if in == nil {
+		return nil
+	}
+	out := new(ZoneAvailability)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ZoneDetails) DeepCopyInto(out *ZoneDetails) {
 	*out = *in
@@ -929,3 +1473,44 @@ func (in *ZoneDetails) DeepCopy() *ZoneDetails {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ZoneSelection) DeepCopyInto(out *ZoneSelection) {
+	*out = *in
+	if in.ZoneIds != nil {
+		in, out := &in.ZoneIds, &out.ZoneIds
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Geography != nil {
+		in, out := &in.Geography, &out.Geography
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ZoneSelection.
+func (in *ZoneSelection) DeepCopy() *ZoneSelection {
+	if in == nil {
+		return nil
+	}
+	out := new(ZoneSelection)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ZoneStatus) DeepCopyInto(out *ZoneStatus) {
+	*out = *in
+	in.LastUpdateTime.DeepCopyInto(&out.LastUpdateTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ZoneStatus.
+func (in *ZoneStatus) DeepCopy() *ZoneStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ZoneStatus)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -1,23 +1,41 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	_ "github.com/lib/pq"
 	"github.com/sirupsen/logrus"
 	log "github.com/sirupsen/logrus"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	_ "modernc.org/sqlite"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/neonephos-katalis/opg-ewbi-operator/api/ewbi/server"
 	"github.com/neonephos-katalis/opg-ewbi-operator/internal/config"
+	"github.com/neonephos-katalis/opg-ewbi-operator/pkg/auth"
 	"github.com/neonephos-katalis/opg-ewbi-operator/pkg/handler"
+	"github.com/neonephos-katalis/opg-ewbi-operator/pkg/metastore"
 	opgv1beta1 "github.com/neonephos-katalis/opg-ewbi-operator/api/operator/v1beta1"
 )
 
+const jwksRequestTimeout = 10 * time.Second
+
 func main() {
 	conf := config.GetConf()
 
@@ -71,24 +89,224 @@ func main() {
 	// Validate request and return errors using the expected models.ProblemDetails format
 	e.Use(server.Validator())
 
-	scheme := runtime.NewScheme()
-	utilruntime.Must(opgv1beta1.AddToScheme(scheme))
+	metaStoreClient, err := newMetastoreClient(context.Background(), conf)
+	if err != nil {
+		log.WithError(err).
+			Fatal("failed to create metastore client")
+	}
+
+	authenticator, err := newAuthenticator(conf)
+	if err != nil {
+		log.WithError(err).
+			Fatal("failed to create authenticator")
+	}
 
-	config := ctrl.GetConfigOrDie()
-	k8sClient, err := client.New(config, client.Options{
-		Scheme: scheme,
-	})
+	deviceAuthenticator, err := newDeviceAuthenticator(conf.DeviceAuth)
 	if err != nil {
 		log.WithError(err).
-			Fatal("failed to create k8sclient")
+			Fatal("failed to create device authenticator")
 	}
 
-	h := handler.NewServer(conf.Camara.ApiRoot, k8sClient, conf.Controller.Namespace)
+	h := handler.NewServer(conf.Camara.ApiRoot, metaStoreClient, authenticator, deviceAuthenticator)
 	server.RegisterHandlers(e, h)
 	e.Use(handler.AuthMiddleware(h))
 
-	if err := e.Start(conf.Camara.HostAgentAddr); err != nil {
+	if conf.TLS.CertFile == "" {
+		if err := e.Start(conf.Camara.HostAgentAddr); err != nil {
+			log.WithError(err).
+				Fatal("failed to run server")
+		}
+		return
+	}
+
+	tlsConfig, err := newTLSConfig(conf.TLS)
+	if err != nil {
+		log.WithError(err).
+			Fatal("failed to configure TLS")
+	}
+	s := &http.Server{
+		Addr:      conf.Camara.HostAgentAddr,
+		Handler:   e,
+		TLSConfig: tlsConfig,
+	}
+	if err := s.ListenAndServeTLS(conf.TLS.CertFile, conf.TLS.KeyFile); err != nil {
 		log.WithError(err).
 			Fatal("failed to run server")
 	}
 }
+
+// newMetastoreClient returns the metastore client of the configured backend,
+// the resources are kept in the namespace of the controller
+func newMetastoreClient(ctx context.Context, conf config.Config) (metastore.Client, error) {
+	namespace := conf.Controller.Namespace
+	switch conf.Metastore.Backend {
+	case config.MetastoreBackendKubernetes:
+		scheme := runtime.NewScheme()
+		utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+		utilruntime.Must(opgv1beta1.AddToScheme(scheme))
+
+		restConfig := ctrl.GetConfigOrDie()
+		k8sClient, err := client.New(restConfig, client.Options{
+			Scheme: scheme,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := metastore.LabelApplicationInstances(ctx, k8sClient, namespace); err != nil {
+			return nil, err
+		}
+		if !conf.Metastore.Cache {
+			return metastore.NewK8sClient(k8sClient, namespace), nil
+		}
+		cache, err := newMetastoreCache(ctx, restConfig, scheme, namespace)
+		if err != nil {
+			return nil, err
+		}
+		return metastore.NewK8sClient(k8sClient, namespace, metastore.WithCache(cache)), nil
+	case config.MetastoreBackendMemory:
+		objs, err := loadMetastoreObjects(conf.Metastore.ObjectsFile)
+		if err != nil {
+			return nil, err
+		}
+		log.Warn("the resources are kept in memory, they are lost on restart")
+		return metastore.NewMemoryClient(namespace, objs...)
+	case config.MetastoreBackendPostgres:
+		db, objs, err := openMetastoreDB(conf.Metastore, "postgres")
+		if err != nil {
+			return nil, err
+		}
+		return metastore.NewPostgresClient(ctx, db, namespace, objs...)
+	case config.MetastoreBackendSQLite:
+		db, objs, err := openMetastoreDB(conf.Metastore, "sqlite")
+		if err != nil {
+			return nil, err
+		}
+		return metastore.NewSQLiteClient(ctx, db, namespace, objs...)
+	default:
+		return nil, fmt.Errorf("unsupported metastore backend '%s'", conf.Metastore.Backend)
+	}
+}
+
+// openMetastoreDB opens the database of the SQL metastore backends with driverName,
+// and decodes the objects it is seeded with
+func openMetastoreDB(conf config.Metastore, driverName string) (*sql.DB, []client.Object, error) {
+	if conf.Dsn == "" {
+		return nil, nil, fmt.Errorf("METASTORE_DSN is required with the %s metastore backend", conf.Backend)
+	}
+	objs, err := loadMetastoreObjects(conf.ObjectsFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	db, err := sql.Open(driverName, conf.Dsn)
+	if err != nil {
+		return nil, nil, err
+	}
+	return db, objs, nil
+}
+
+// loadMetastoreObjects decodes the manifests of objectsFile, if set
+func loadMetastoreObjects(objectsFile string) ([]client.Object, error) {
+	if objectsFile == "" {
+		return nil, nil
+	}
+	f, err := os.Open(objectsFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return metastore.DecodeObjects(f)
+}
+
+// newMetastoreCache starts the informers cache of the metastore resources of
+// namespace and waits for its initial sync
+func newMetastoreCache(ctx context.Context, restConfig *rest.Config, scheme *runtime.Scheme, namespace string) (cache.Cache, error) {
+	c, err := metastore.NewCache(ctx, restConfig, scheme, namespace)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		if err := c.Start(ctx); err != nil {
+			log.WithError(err).
+				Fatal("metastore cache stopped")
+		}
+	}()
+	if !c.WaitForCacheSync(ctx) {
+		return nil, errors.New("metastore cache did not sync")
+	}
+	return c, nil
+}
+
+func newTLSConfig(conf config.TLS) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if conf.ClientCaFile == "" {
+		return tlsConfig, nil
+	}
+	caBundle, err := os.ReadFile(conf.ClientCaFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caBundle) {
+		return nil, fmt.Errorf("no CA certificates found in %s", conf.ClientCaFile)
+	}
+	tlsConfig.ClientCAs = pool
+	tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	return tlsConfig, nil
+}
+
+func newAuthenticator(conf config.Config) (handler.Authenticator, error) {
+	switch conf.Auth.Mode {
+	case config.AuthModeInsecureHeader:
+		log.Warn("INSECURE: trusting the X-Client-ID header to authenticate requests")
+		return handler.NewInsecureHeaderAuthenticator(), nil
+	case config.AuthModeMTLS:
+		if conf.TLS.CertFile == "" || conf.TLS.ClientCaFile == "" {
+			return nil, errors.New("TLS_CERT_FILE, TLS_KEY_FILE and TLS_CLIENT_CA_FILE are required with the mtls auth mode")
+		}
+		return handler.NewCertAuthenticator(conf.Auth.CertClientIdField)
+	case config.AuthModeJWT:
+		if conf.Auth.JwksFile == "" && conf.Auth.JwksUrl == "" {
+			return nil, errors.New("AUTH_JWKS_FILE or AUTH_JWKS_URL is required with the jwt auth mode")
+		}
+		keys, err := newKeySet(conf.Auth.JwksFile, conf.Auth.JwksUrl, conf.Auth.JwksRefreshInterval)
+		if err != nil {
+			return nil, err
+		}
+		return handler.NewJWTAuthenticator(auth.NewValidator(keys, conf.Auth.Issuer, conf.Auth.Audience)), nil
+	default:
+		return nil, fmt.Errorf("unsupported auth mode '%s'", conf.Auth.Mode)
+	}
+}
+
+func newDeviceAuthenticator(conf config.DeviceAuth) (handler.DeviceAuthenticator, error) {
+	switch conf.Mode {
+	case "":
+		return nil, nil
+	case config.DeviceAuthModeJWT:
+		if conf.JwksFile == "" && conf.JwksUrl == "" {
+			return nil, errors.New("DEVICE_AUTH_JWKS_FILE or DEVICE_AUTH_JWKS_URL is required with the jwt device auth mode")
+		}
+		keys, err := newKeySet(conf.JwksFile, conf.JwksUrl, conf.JwksRefreshInterval)
+		if err != nil {
+			return nil, err
+		}
+		return handler.NewJWTDeviceAuthenticator(auth.NewValidator(keys, conf.Issuer, conf.Audience)), nil
+	case config.DeviceAuthModeHTTP:
+		if conf.Url == "" {
+			return nil, errors.New("DEVICE_AUTH_URL is required with the http device auth mode")
+		}
+		return handler.NewHTTPDeviceAuthenticator(conf.Url, &http.Client{Timeout: conf.Timeout}), nil
+	default:
+		return nil, fmt.Errorf("unsupported device auth mode '%s'", conf.Mode)
+	}
+}
+
+// newKeySet loads the JWKS in jwksFile, or else served at jwksUrl.
+func newKeySet(jwksFile, jwksUrl string, refreshInterval time.Duration) (*auth.KeySet, error) {
+	if jwksFile != "" {
+		return auth.NewFileKeySet(jwksFile)
+	}
+	return auth.NewURLKeySet(
+		context.Background(), jwksUrl, &http.Client{Timeout: jwksRequestTimeout}, refreshInterval,
+	)
+}
@@ -20,16 +20,19 @@ import (
 	"crypto/tls"
 	"flag"
 	"os"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	"sigs.k8s.io/controller-runtime/pkg/metrics/filters"
@@ -68,6 +71,8 @@ func main() {
 	var opgInsecureSkipVerify bool
 	var tlsOpts []func(*tls.Config)
 	var monitoredNamespace string
+	var callbackInitialBackoff, callbackMaxBackoff, callbackMaxAge, callbackRetention time.Duration
+	var resyncPeriod, opgRequestTimeout time.Duration
 	flag.StringVar(&metricsAddr, "metrics-bind-address", "0", "The address the metrics endpoint binds to. "+
 		"Use :8443 for HTTPS or :8080 for HTTP, or leave as 0 to disable the metrics service.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
@@ -80,6 +85,20 @@ func main() {
 		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
 	flag.BoolVar(&opgInsecureSkipVerify, "opg-insecure-skip-verify", false,
 		"If set, the CA certificates verification is skipped for OPG Clients requests.")
+	flag.DurationVar(&opgRequestTimeout, "opg-request-timeout", opg.DefaultRequestTimeout,
+		"Limit for each OPG Clients request to a partner OP, unless its Federation sets spec.requestTimeout. "+
+			"0 disables it.")
+	flag.DurationVar(&callbackInitialBackoff, "callback-initial-backoff", controller.DefaultCallbackInitialBackoff,
+		"Delay before retrying a failed callback to a federation partner, doubled on every attempt.")
+	flag.DurationVar(&callbackMaxBackoff, "callback-max-backoff", controller.DefaultCallbackMaxBackoff,
+		"Maximum delay between the delivery attempts of a callback.")
+	flag.DurationVar(&callbackMaxAge, "callback-max-age", controller.DefaultCallbackMaxAge,
+		"Callbacks not delivered to the federation partner within this age expire.")
+	flag.DurationVar(&callbackRetention, "callback-retention", controller.DefaultCallbackRetention,
+		"Delivered, failed and expired callbacks are deleted after this period.")
+	flag.DurationVar(&resyncPeriod, "resync-period", controller.DefaultResyncPeriod,
+		"Interval between the polls of the partner OP for the state of the guest resources "+
+			"until it is final, in case a callback is lost. 0 disables the polling.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -160,20 +179,29 @@ func main() {
 		Cache: cache.Options{DefaultNamespaces: map[string]cache.Config{
 			monitoredNamespace: {},
 		}},
+		// repo and application credentials are read on demand, no need to watch every Secret
+		Client: client.Options{Cache: &client.CacheOptions{
+			DisableFor: []client.Object{&corev1.Secret{}},
+		}},
 	})
 
-	opgClientOpts := []opg.OPGClientsMapOpt{}
+	if err != nil {
+		setupLog.Error(err, "unable to start manager")
+		os.Exit(1)
+	}
+
+	// client secrets are read straight from the API server, so rotated secrets are
+	// used on the next token request without caching every Secret of the namespace
+	opgClientOpts := []opg.OPGClientsMapOpt{
+		opg.WithSecretReader(mgr.GetAPIReader()),
+		opg.WithRequestTimeout(opgRequestTimeout),
+	}
 	if opgInsecureSkipVerify {
 		setupLog.Info("INSECURE: disabling CA cert verification in https requests to federation partners")
 		opgClientOpts = append(opgClientOpts, opg.WithInsecureSkipVerify())
 	}
 	opgClients := opg.NewOPGClientsMap(opgClientOpts...)
 
-	if err != nil {
-		setupLog.Error(err, "unable to start manager")
-		os.Exit(1)
-	}
-
 	if err = (&controller.FederationReconciler{
 		Client:                 mgr.GetClient(),
 		Scheme:                 mgr.GetScheme(),
@@ -186,6 +214,7 @@ func main() {
 		Client:                 mgr.GetClient(),
 		Scheme:                 mgr.GetScheme(),
 		OPGClientsMapInterface: opgClients,
+		ResyncPeriod:           resyncPeriod,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, unableToCreateControllerMsg, "controller", "File")
 		os.Exit(1)
@@ -194,6 +223,7 @@ func main() {
 		Client:                 mgr.GetClient(),
 		Scheme:                 mgr.GetScheme(),
 		OPGClientsMapInterface: opgClients,
+		ResyncPeriod:           resyncPeriod,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, unableToCreateControllerMsg, "controller", "Artefact")
 		os.Exit(1)
@@ -202,6 +232,7 @@ func main() {
 		Client:                 mgr.GetClient(),
 		Scheme:                 mgr.GetScheme(),
 		OPGClientsMapInterface: opgClients,
+		ResyncPeriod:           resyncPeriod,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, unableToCreateControllerMsg, "controller", "Application")
 		os.Exit(1)
@@ -210,6 +241,7 @@ func main() {
 		Client:                 mgr.GetClient(),
 		Scheme:                 mgr.GetScheme(),
 		OPGClientsMapInterface: opgClients,
+		ResyncPeriod:           resyncPeriod,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, unableToCreateControllerMsg, "controller", "ApplicationInstance")
 		os.Exit(1)
@@ -221,6 +253,26 @@ func main() {
 		setupLog.Error(err, unableToCreateControllerMsg, "controller", "AvailabilityZone")
 		os.Exit(1)
 	}
+	if err = (&controller.ResourcePoolReconciler{
+		Client:                 mgr.GetClient(),
+		Scheme:                 mgr.GetScheme(),
+		OPGClientsMapInterface: opgClients,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, unableToCreateControllerMsg, "controller", "ResourcePool")
+		os.Exit(1)
+	}
+	if err = (&controller.CallbackReconciler{
+		Client:                 mgr.GetClient(),
+		Scheme:                 mgr.GetScheme(),
+		OPGClientsMapInterface: opgClients,
+		InitialBackoff:         callbackInitialBackoff,
+		MaxBackoff:             callbackMaxBackoff,
+		MaxAge:                 callbackMaxAge,
+		Retention:              callbackRetention,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, unableToCreateControllerMsg, "controller", "Callback")
+		os.Exit(1)
+	}
 	// +kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -1,6 +1,8 @@
 package config
 
 import (
+	"time"
+
 	log "github.com/sirupsen/logrus"
 
 	"github.com/kelseyhightower/envconfig"
@@ -16,9 +18,89 @@ type Controller struct {
 	Namespace string `split_words:"true" required:"true"`
 }
 
+// Auth modes of the API server
+const (
+	// AuthModeJWT validates the bearer tokens against the JWKS
+	AuthModeJWT = "jwt"
+	// AuthModeMTLS takes the client id from the verified client certificate
+	AuthModeMTLS = "mtls"
+	// AuthModeInsecureHeader trusts the X-Client-ID header, for development only
+	AuthModeInsecureHeader = "insecure-header"
+)
+
+type Auth struct {
+	Mode                string        `default:"jwt"`
+	JwksFile            string        `split_words:"true"`
+	JwksUrl             string        `split_words:"true"`
+	JwksRefreshInterval time.Duration `split_words:"true" default:"1h"`
+	Issuer              string
+	Audience            string
+	// CertClientIdField, client certificate field holding the client id with
+	// the mtls mode, one of cn, dns (first DNS SAN) or uri (first URI SAN)
+	CertClientIdField string `split_words:"true" default:"cn"`
+}
+
+// Device authentication modes of the roaming users, AuthenticateDevice
+// answers 501 when no mode is set
+const (
+	// DeviceAuthModeJWT validates signed device tokens against the JWKS
+	DeviceAuthModeJWT = "jwt"
+	// DeviceAuthModeHTTP posts the device tokens to a validation service
+	DeviceAuthModeHTTP = "http"
+)
+
+type DeviceAuth struct {
+	Mode                string
+	JwksFile            string        `split_words:"true"`
+	JwksUrl             string        `split_words:"true"`
+	JwksRefreshInterval time.Duration `split_words:"true" default:"1h"`
+	Issuer              string
+	Audience            string
+	// Url of the validation service with the http mode
+	Url     string
+	Timeout time.Duration `default:"5s"`
+}
+
+// TLS enables HTTPS serving when CertFile and KeyFile are set,
+// client certificates are required and verified against ClientCaFile if set
+type TLS struct {
+	CertFile     string `split_words:"true"`
+	KeyFile      string `split_words:"true"`
+	ClientCaFile string `split_words:"true"`
+}
+
+// Metastore backends, where the API server keeps the resources
+const (
+	// MetastoreBackendKubernetes keeps them as custom resources of the namespace
+	MetastoreBackendKubernetes = "kubernetes"
+	// MetastoreBackendMemory keeps them in memory, they are lost on restart
+	MetastoreBackendMemory = "memory"
+	// MetastoreBackendPostgres keeps them in the PostgreSQL database of Dsn
+	MetastoreBackendPostgres = "postgres"
+	// MetastoreBackendSQLite keeps them in the SQLite database of Dsn
+	MetastoreBackendSQLite = "sqlite"
+)
+
+// Metastore configures how the API server reads and writes the resources
+type Metastore struct {
+	Backend string `default:"kubernetes"`
+	// Cache, read the resources from an informers cache of the namespace
+	// instead of listing them from the API server on every request
+	Cache bool `default:"true"`
+	// Dsn of the database with the postgres and sqlite backends
+	Dsn string
+	// ObjectsFile, manifests of the resources the memory, postgres and sqlite backends
+	// are seeded with, e.g. the host federations
+	ObjectsFile string `split_words:"true"`
+}
+
 type Config struct {
 	Camara
 	Controller
+	Auth
+	DeviceAuth
+	TLS
+	Metastore
 }
 
 func process(prefix string, spec interface{}) {
@@ -34,5 +116,17 @@ func GetConf() Config {
 	var controller Controller
 	process("controller", &controller)
 
-	return Config{camara, controller}
+	var auth Auth
+	process("auth", &auth)
+
+	var deviceAuth DeviceAuth
+	process("device_auth", &deviceAuth)
+
+	var tls TLS
+	process("tls", &tls)
+
+	var metastore Metastore
+	process("metastore", &metastore)
+
+	return Config{camara, controller, auth, deviceAuth, tls, metastore}
 }
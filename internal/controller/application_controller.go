@@ -18,10 +18,14 @@ package controller
 
 import (
 	"context"
-	"errors"
+	"net/http"
+	"slices"
+	"time"
 
 	opgmodels "github.com/neonephos-katalis/opg-ewbi-operator/api/ewbi/models"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -43,11 +47,15 @@ type ApplicationReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
 	opg.OPGClientsMapInterface
+	// ResyncPeriod, interval between the polls of the partner OP for the state of
+	// the guest Applications until it is final, 0 disables polling
+	ResyncPeriod time.Duration
 }
 
 // +kubebuilder:rbac:groups=opg.ewbi.nby.one,resources=applications,verbs=*,namespace=foo
 // +kubebuilder:rbac:groups=opg.ewbi.nby.one,resources=applications/status,verbs=get;update;patch,namespace=foo
 // +kubebuilder:rbac:groups=opg.ewbi.nby.one,resources=applications/finalizers,verbs=update,namespace=foo
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get,namespace=foo
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -58,7 +66,16 @@ type ApplicationReconciler struct {
 //
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.19.4/pkg/reconcile
-func (r *ApplicationReconciler) Reconcile(
+func (r *ApplicationReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	res, err := r.reconcile(ctx, req)
+	if upErr := updateConditions(ctx, r.Client, req.NamespacedName, &v1beta1.Application{}, setApplicationConditions); upErr != nil && err == nil {
+		return ctrl.Result{}, upErr
+	}
+	return res, err
+}
+
+// reconcile syncs the Application, its conditions are set afterwards from the resulting state
+func (r *ApplicationReconciler) reconcile(
 	ctx context.Context,
 	req ctrl.Request,
 ) (ctrl.Result, error) {
@@ -112,12 +129,7 @@ func (r *ApplicationReconciler) Reconcile(
 		if isGuest {
 			if err := r.handleExternalAppDeletion(ctx, &a, feder); err != nil {
 				log.Error(err, "error deleting app")
-				a.Status.State = v1beta1.ApplicationStateFailed
-				upErr := r.Status().Update(ctx, a.DeepCopy())
-				if upErr != nil {
-					log.Error(upErr, errorUpdatingResourceStatusMsg)
-				}
-				return ctrl.Result{}, err
+				return opgResult(err)
 			}
 		}
 		// if external app is correctly deleted, we can remove the finalizer
@@ -134,22 +146,38 @@ func (r *ApplicationReconciler) Reconcile(
 
 	// if federation is guest, send OPG API request
 	if isGuest {
+		if _, ok := a.Annotations[v1beta1.RetryAnnotation]; ok {
+			failed := a.Status.State == v1beta1.ApplicationStateFailed || partnerRejected(a.Status.Conditions, a.Generation)
+			return ctrl.Result{}, handleRetryAnnotation(ctx, r.Client, &a, failed, func() {
+				resetApplicationStatus(&a)
+			})
+		}
 		if a.Status.State == "" {
 			if err := r.handleExternalAppCreation(ctx, &a, feder); err != nil {
-				log.Info("error creating app")
-				a.Status.State = v1beta1.ApplicationStateFailed
-				upErr := r.Status().Update(ctx, a.DeepCopy())
-				if upErr != nil {
-					log.Error(upErr, errorUpdatingResourceStatusMsg)
-				}
-				return ctrl.Result{}, nil
+				log.Error(err, "error creating app")
+				return opgResult(err)
 			}
+		} else if rejected := partnerRejected(a.Status.Conditions, a.Generation); !rejected &&
+			a.Status.ObservedGeneration > 0 && a.Generation > a.Status.ObservedGeneration {
+			if err := r.handleExternalAppUpdate(ctx, &a, feder); err != nil {
+				log.Error(err, "error updating app")
+				return opgResult(err)
+			}
+		} else if forbid, allow := applicationZoneForbidChanges(&a); !rejected &&
+			a.Status.State != v1beta1.ApplicationStateFailed && len(forbid)+len(allow) > 0 {
+			if err := r.handleExternalAppZoneForbids(ctx, &a, feder, forbid, allow); err != nil {
+				log.Error(err, "error forbidding app zones")
+				return opgResult(err)
+			}
+		} else if r.ResyncPeriod > 0 && !applicationStateFinal(a.Status.State) && !a.Status.HeldByPartner {
+			return r.resyncExternalApp(ctx, &a, feder)
 		} else {
 			log.Info("+++++++++++++++++++ App status is ", "state", a.Status.State)
 		}
 	} else {
 		if a.Status.State == "" {
 			a.Status.State = v1beta1.ApplicationStatePending
+			syncApplicationZones(&a, metav1.Now())
 			log.Info("Initialized new CR state", "state", a.Status.State)
 			upErr := r.Status().Update(ctx, a.DeepCopy())
 			if upErr != nil {
@@ -158,19 +186,113 @@ func (r *ApplicationReconciler) Reconcile(
 			}
 		} else {
 			log.Info("New CR state", "state", a.Status.State)
-			if err := r.handleExternalAppCallback(ctx, &a, feder); err != nil {
-				log.Error(err, "error handling app callback")
-				a.Status.State = v1beta1.ApplicationStateFailed
-				upErr := r.Status().Update(ctx, a.DeepCopy())
-				if upErr != nil {
+			if syncApplicationZones(&a, metav1.Now()) {
+				if upErr := r.Status().Update(ctx, &a); upErr != nil {
 					log.Error(upErr, errorUpdatingResourceStatusMsg)
+					return ctrl.Result{}, upErr
 				}
 			}
+			if err := r.handleExternalAppCallback(ctx, &a, feder); err != nil {
+				log.Error(err, "error queueing app callback")
+				return ctrl.Result{}, err
+			}
 		}
 	}
 	return ctrl.Result{}, nil
 }
 
+// resyncExternalApp polls the partner OP for the App, in case its callback was lost.
+// The partner doesn't report the onboarding state of applications, an Application it
+// no longer holds is set as failed. Once the partner answers with the App, polling it
+// again tells nothing new and its state is left to the callbacks.
+func (r *ApplicationReconciler) resyncExternalApp(
+	ctx context.Context, a *v1beta1.Application, feder *v1beta1.Federation,
+) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	now := metav1.Now()
+	if wait := resyncDelay(r.ResyncPeriod, a.Status.LastSyncTime, now.Time); wait > 0 {
+		return ctrl.Result{RequeueAfter: wait}, nil
+	}
+
+	res, err := r.GetOPGClient(
+		feder.Labels[v1beta1.ExternalIdLabel],
+		guestPartnerApiRoot(feder),
+		opgCredentials(feder, feder.Spec.GuestPartnerCredentials),
+	).ViewApplicationWithResponse(
+		ctx,
+		feder.Status.FederationContextId,
+		a.Labels[v1beta1.ExternalIdLabel],
+	)
+	out := opg.Classify(res, err)
+	logOPGResponse(log, out)
+	switch {
+	case out.Outcome == opg.OutcomeSuccess:
+		log.Info("External application held by the partner OP, no longer polled", "state", a.Status.State)
+		a.Status.HeldByPartner = true
+	case out.StatusCode == http.StatusNotFound:
+		a.Status.State = v1beta1.ApplicationStateFailed
+		// the partner OP no longer holds the app, a retry onboards it again
+		a.Status.ObservedGeneration = 0
+	}
+	a.Status.LastSyncTime = &now
+	// the app being held doesn't clear the rejection of its last update
+	if out.Outcome != opg.OutcomeSuccess || !partnerRejected(a.Status.Conditions, a.Generation) {
+		setPartnerConditions(&a.Status.Conditions, a.Generation, out)
+	}
+	if upErr := r.Status().Update(ctx, a); upErr != nil {
+		log.Error(upErr, errorUpdatingResourceStatusMsg)
+		return ctrl.Result{}, upErr
+	}
+	return resyncResult(r.ResyncPeriod, applicationStateFinal(a.Status.State) || a.Status.HeldByPartner), nil
+}
+
+// resetApplicationStatus clears the failed step of a retried Application. An app the
+// partner OP never accepted is onboarded again, otherwise its rejected update or zone
+// forbids are sent again and an app the partner failed is polled again.
+func resetApplicationStatus(a *v1beta1.Application) {
+	if a.Status.ObservedGeneration == 0 {
+		a.Status = v1beta1.ApplicationStatus{Conditions: a.Status.Conditions}
+	} else {
+		a.Status.Attempts = 0
+		a.Status.LastError = ""
+		if a.Status.State == v1beta1.ApplicationStateFailed {
+			a.Status.State = v1beta1.ApplicationStatePending
+			a.Status.HeldByPartner = false
+			a.Status.LastSyncTime = nil
+		}
+	}
+	meta.RemoveStatusCondition(&a.Status.Conditions, v1beta1.ConditionStalled)
+}
+
+// applicationStateFinal returns whether the Application no longer changes without a callback
+func applicationStateFinal(state v1beta1.ApplicationState) bool {
+	switch state {
+	case v1beta1.ApplicationStateOnboarded, v1beta1.ApplicationStateFailed, v1beta1.ApplicationStateRemoved:
+		return true
+	}
+	return false
+}
+
+// setApplicationConditions sets the conditions and observedGeneration of the Application from its state
+func setApplicationConditions(a *v1beta1.Application) bool {
+	changed := false
+	if !IsGuestResource(a.Labels) {
+		// on the guest it is the generation last sent to the partner OP
+		changed = a.Status.ObservedGeneration != a.Generation
+		a.Status.ObservedGeneration = a.Generation
+	}
+	phase := syncInProgress
+	switch {
+	case a.Status.State == v1beta1.ApplicationStateFailed:
+		phase = syncFailed
+	case a.Status.ObservedGeneration < a.Generation:
+		// the spec changes weren't sent to the partner OP yet
+	case a.Status.State == v1beta1.ApplicationStateOnboarded:
+		phase = syncCompleted
+	}
+	return setSyncConditions(&a.Status.Conditions, a.Generation, phase, string(a.Status.State)) || changed
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *ApplicationReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
@@ -183,6 +305,11 @@ func (r *ApplicationReconciler) handleExternalAppCreation(
 	ctx context.Context, a *v1beta1.Application, feder *v1beta1.Federation,
 ) error {
 	log := log.FromContext(ctx)
+	accessToken, err := resolveSecretKeyRef(ctx, r.Client, a.Namespace, a.Spec.MetaData.AccessTokenSecretRef)
+	if err != nil {
+		log.Error(err, "error getting application access token")
+		return err
+	}
 	numUsers := int(a.Spec.QoSProfile.UsersPerAppInst)
 	multiUserClients := opgmodels.AppQoSProfileMultiUserClients(a.Spec.QoSProfile.MultiUserClients)
 	components := opgmodels.AppComponentSpecs{}
@@ -198,14 +325,22 @@ func (r *ApplicationReconciler) handleExternalAppCreation(
 		}{
 			ArtefactId: c.ArtefactId,
 		}
+		if c.ComponentName != "" {
+			newComponent.ComponentName = &c.ComponentName
+		}
 		components = append(components, newComponent)
 	}
 
+	var deploymentZones *[]opgmodels.ZoneIdentifier
+	if len(a.Spec.Zones) > 0 {
+		deploymentZones = &a.Spec.Zones
+	}
+
 	appReqBody := opgmodels.OnboardApplicationJSONRequestBody{
-		// AppDeploymentZones:    &[]opgmodels.ZoneIdentifier{},
-		AppId: a.Labels[v1beta1.ExternalIdLabel],
+		AppDeploymentZones: deploymentZones,
+		AppId:              a.Labels[v1beta1.ExternalIdLabel],
 		AppMetaData: opgmodels.AppMetaData{
-			AccessToken: a.Spec.MetaData.AccessToken,
+			AccessToken: accessToken,
 			// AppDescription:  new(string),
 			AppName: a.Spec.MetaData.Name,
 			// Category:        &"",
@@ -226,63 +361,193 @@ func (r *ApplicationReconciler) handleExternalAppCreation(
 
 	res, err := r.GetOPGClient(
 		feder.Labels[v1beta1.ExternalIdLabel],
-		feder.Spec.GuestPartnerCredentials.TokenUrl,
-		feder.Spec.GuestPartnerCredentials.ClientId,
+		guestPartnerApiRoot(feder),
+		opgCredentials(feder, feder.Spec.GuestPartnerCredentials),
 	).OnboardApplicationWithResponse(
-		context.TODO(),
+		ctx,
 		feder.Status.FederationContextId,
 		appReqBody)
-
-	if err != nil {
-		log.Error(err, "error creating app")
-		return err
+	out := opg.Classify(res, err)
+	// this should be deleted when API returns a 400 for this case
+	if out.StatusCode == http.StatusInternalServerError && out.Detail() == "artefact not found" {
+		out.Outcome = opg.OutcomePermanent
 	}
-
-	statusCode := res.StatusCode()
-	switch {
-	case statusCode >= 200 && statusCode < 300:
-		log.Info("APPLICATIONS - Status code 2xx received from OPG API", "status", statusCode)
-
+	logOPGResponse(log, out)
+	switch out.Outcome {
+	case opg.OutcomeSuccess:
 		a.Status.State = v1beta1.ApplicationStatePending
+		a.Status.ObservedGeneration = a.Generation
+		syncApplicationZones(a, metav1.Now())
+		now := metav1.Now()
+		a.Status.LastSyncTime = &now
 		log.Info("Created external application", "state", a.Status.State)
-	case statusCode == 400:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON400)
-		log.Info("Couldn't be created", "Detail", res.ApplicationproblemJSON400.Detail)
-		return errors.New(*res.ApplicationproblemJSON400.Detail)
-	case statusCode == 401:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON401)
-		a.Status.State = v1beta1.ApplicationStateFailed
-	case statusCode == 404:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON404)
-		a.Status.State = v1beta1.ApplicationStateFailed
-	case statusCode == 409:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON409)
-		a.Status.State = v1beta1.ApplicationStateFailed
-	case statusCode == 422:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON422)
+	case opg.OutcomePermanent:
 		a.Status.State = v1beta1.ApplicationStateFailed
-	case statusCode == 500:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON500)
-		a.Status.State = v1beta1.ApplicationStateFailed
-		// this should be deleted when API returns a 400 for this case
-		if *res.ApplicationproblemJSON500.Detail == "artefact not found" {
-			return errors.New(*res.ApplicationproblemJSON500.Detail)
+	}
+	recordAttempt(&a.Status.Attempts, &a.Status.LastError, out)
+	setPartnerConditions(&a.Status.Conditions, a.Generation, out)
+	if upErr := r.Status().Update(ctx, a); upErr != nil {
+		log.Error(upErr, errorUpdatingResourceStatusMsg)
+		return upErr
+	}
+	return out.Err()
+}
+
+// handleExternalAppUpdate sends the component specs and QoS profile of the
+// app to the partner OP when its spec changed since it was last sent
+func (r *ApplicationReconciler) handleExternalAppUpdate(
+	ctx context.Context, a *v1beta1.Application, feder *v1beta1.Federation,
+) error {
+	log := log.FromContext(ctx)
+
+	components := make([]opgmodels.UpdateApplicationJSONBody_AppComponentSpecs_Item, 0, len(a.Spec.ComponentSpecs))
+	for _, c := range a.Spec.ComponentSpecs {
+		componentName := c.ComponentName
+		if componentName == "" {
+			componentName = c.ArtefactId
 		}
-	case statusCode == 503:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON503)
-		a.Status.State = v1beta1.ApplicationStateFailed
-	case statusCode == 520:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON520)
-		a.Status.State = v1beta1.ApplicationStateFailed
-	default:
-		a.Status.State = v1beta1.ApplicationStatePending
+		components = append(components, opgmodels.UpdateApplicationJSONBody_AppComponentSpecs_Item{
+			ArtefactId:    &c.ArtefactId,
+			ComponentName: componentName,
+		})
+	}
+	numUsers := int(a.Spec.QoSProfile.UsersPerAppInst)
+	latencyConstraints := opgmodels.UpdateApplicationJSONBodyAppUpdQoSProfileLatencyConstraints(a.Spec.QoSProfile.LatencyConstraints)
+	multiUserClients := opgmodels.UpdateApplicationJSONBodyAppUpdQoSProfileMultiUserClients(a.Spec.QoSProfile.MultiUserClients)
+	appReqBody := opgmodels.UpdateApplicationJSONRequestBody{
+		AppComponentSpecs: &components,
+		AppUpdQoSProfile: &opgmodels.UpdateApplicationJSONBody_AppUpdQoSProfile{
+			AppProvisioning:     &a.Spec.QoSProfile.Provisioning,
+			LatencyConstraints:  &latencyConstraints,
+			MobilitySupport:     &a.Spec.MetaData.MobilitySupport,
+			MultiUserClients:    &multiUserClients,
+			NoOfUsersPerAppInst: &numUsers,
+		},
+	}
+
+	res, err := r.GetOPGClient(
+		feder.Labels[v1beta1.ExternalIdLabel],
+		guestPartnerApiRoot(feder),
+		opgCredentials(feder, feder.Spec.GuestPartnerCredentials),
+	).UpdateApplicationWithResponse(
+		ctx,
+		feder.Status.FederationContextId,
+		a.Labels[v1beta1.ExternalIdLabel],
+		appReqBody)
+	out := opg.Classify(res, err)
+	if out.Outcome == opg.OutcomeSuccess {
+		log.Info("Updated external application", "generation", a.Generation)
+		out = r.handleExternalAppZonesUpdate(ctx, a, feder)
 	}
-	upErr := r.Status().Update(ctx, a)
-	if upErr != nil {
+	logOPGResponse(log, out)
+	if out.Outcome == opg.OutcomeSuccess {
+		a.Status.ObservedGeneration = a.Generation
+	}
+	// a rejected update leaves the app as it was at the partner OP, it is Stalled
+	// and isn't sent again until the spec changes or the app is retried
+	recordAttempt(&a.Status.Attempts, &a.Status.LastError, out)
+	setPartnerConditions(&a.Status.Conditions, a.Generation, out)
+	if upErr := r.Status().Update(ctx, a); upErr != nil {
+		log.Error(upErr, errorUpdatingResourceStatusMsg)
+		return upErr
+	}
+	return out.Err()
+}
+
+// handleExternalAppZonesUpdate onboards the app on the zones added to its spec
+// and deboards it from the removed ones. An app that never had zones in its spec
+// is onboarded on every zone of the federation, emptying them deboards it from
+// all the zones it was onboarded on. The status zones are updated after every
+// request, so that the ones done aren't sent again when a later one fails.
+func (r *ApplicationReconciler) handleExternalAppZonesUpdate(
+	ctx context.Context, a *v1beta1.Application, feder *v1beta1.Federation,
+) *opg.Response {
+	log := log.FromContext(ctx)
+	opgClient := r.GetOPGClient(
+		feder.Labels[v1beta1.ExternalIdLabel],
+		guestPartnerApiRoot(feder),
+		opgCredentials(feder, feder.Spec.GuestPartnerCredentials),
+	)
+	zoneIds := applicationZoneIds(a.Status.Zones)
+
+	if added := sliceDifference(a.Spec.Zones, zoneIds); len(added) > 0 {
+		res, err := opgClient.OnboardExistingAppNewZonesWithResponse(
+			ctx,
+			feder.Status.FederationContextId,
+			a.Labels[v1beta1.ExternalIdLabel],
+			added)
+		if out := opg.Classify(res, err); out.Outcome != opg.OutcomeSuccess {
+			log.Info("error onboarding app on new zones", "zones", added)
+			return out
+		}
+		log.Info("Onboarded external application on new zones", "zones", added)
+		now := metav1.Now()
+		for _, zoneId := range added {
+			a.Status.Zones = append(a.Status.Zones, v1beta1.ApplicationZoneStatus{
+				ZoneId:         zoneId,
+				LastUpdateTime: now,
+			})
+		}
+	}
+
+	for _, zoneId := range sliceDifference(zoneIds, a.Spec.Zones) {
+		res, err := opgClient.DeboardApplicationWithResponse(
+			ctx,
+			feder.Status.FederationContextId,
+			a.Labels[v1beta1.ExternalIdLabel],
+			zoneId)
+		// a zone the partner no longer knows about is already deboarded
+		out := opg.Classify(res, err, opg.WithOutcome(http.StatusNotFound, opg.OutcomeSuccess))
+		if out.Outcome != opg.OutcomeSuccess {
+			log.Info("error deboarding app from zone", "zone", zoneId)
+			return out
+		}
+		log.Info("Deboarded external application from zone", "zone", zoneId)
+		a.Status.Zones = slices.DeleteFunc(a.Status.Zones, func(z v1beta1.ApplicationZoneStatus) bool {
+			return z.ZoneId == zoneId
+		})
+	}
+	return &opg.Response{Outcome: opg.OutcomeSuccess}
+}
+
+// handleExternalAppZoneForbids forbids or allows the instantiation of the app
+// in the partner OP zones whose forbid flag changed since it was last sent
+func (r *ApplicationReconciler) handleExternalAppZoneForbids(
+	ctx context.Context, a *v1beta1.Application, feder *v1beta1.Federation, forbid, allow []string,
+) error {
+	log := log.FromContext(ctx)
+
+	appReqBody := make(opgmodels.LockUnlockApplicationZoneJSONRequestBody, 0, len(forbid)+len(allow))
+	for _, zoneId := range forbid {
+		appReqBody = append(appReqBody, map[string]interface{}{"zoneId": zoneId, "forbid": true})
+	}
+	for _, zoneId := range allow {
+		appReqBody = append(appReqBody, map[string]interface{}{"zoneId": zoneId, "forbid": false})
+	}
+
+	res, err := r.GetOPGClient(
+		feder.Labels[v1beta1.ExternalIdLabel],
+		guestPartnerApiRoot(feder),
+		opgCredentials(feder, feder.Spec.GuestPartnerCredentials),
+	).LockUnlockApplicationZoneWithResponse(
+		ctx,
+		feder.Status.FederationContextId,
+		a.Labels[v1beta1.ExternalIdLabel],
+		appReqBody)
+	out := opg.Classify(res, err)
+	logOPGResponse(log, out)
+	if out.Outcome == opg.OutcomeSuccess {
+		// otherwise the forbidden zones are sent again on the next change
+		log.Info("Updated external application forbidden zones", "forbid", forbid, "allow", allow)
+		a.Status.ForbiddenZones = slices.Clone(a.Spec.ForbiddenZones)
+	}
+	recordAttempt(&a.Status.Attempts, &a.Status.LastError, out)
+	setPartnerConditions(&a.Status.Conditions, a.Generation, out)
+	if upErr := r.Status().Update(ctx, a); upErr != nil {
 		log.Error(upErr, errorUpdatingResourceStatusMsg)
 		return upErr
 	}
-	return nil
+	return out.Err()
 }
 
 func (r *ApplicationReconciler) handleExternalAppCallback(
@@ -294,59 +559,15 @@ func (r *ApplicationReconciler) handleExternalAppCallback(
 		log.Info("No callback StatusLink configured in Federation, skipping App callback")
 		return nil
 	}
-	log.Info("Sending App callback to Guest",
+	log.Info("Queueing App callback to Guest",
 		"appId", a.Labels[v1beta1.ExternalIdLabel],
 		"state", a.Status.State,
 		"statusLink", feder.Spec.Partner.StatusLink)
 	callbackBody := opgmodels.AppStatusCallbackLinkJSONRequestBody{
-		AppId: a.Labels[v1beta1.ExternalIdLabel],
-		StatusInfo: []struct {
-			OnboardStatusInfo opgmodels.AppStatusCallbackLinkJSONBodyStatusInfoOnboardStatusInfo `json:"onboardStatusInfo"`
-			ZoneId            opgmodels.ZoneIdentifier                                           `json:"zoneId"`
-		}{
-			{
-				OnboardStatusInfo: opgmodels.AppStatusCallbackLinkJSONBodyStatusInfoOnboardStatusInfo(a.Status.State),
-				ZoneId:            "zone-es-madrid-001",
-			},
-		},
-	}
-	// Get callback client (pointing to Guest's callback URL via Federation.spec.partner.statusLink)
-	res, err := r.GetOPGClient(
-		feder.Labels[v1beta1.ExternalIdLabel],
-		feder.Spec.Partner.StatusLink,
-		feder.Spec.Partner.CallbackCredentials.ClientId,
-	).AppStatusCallbackLinkWithResponse(
-		context.TODO(),
-		feder.Spec.Partner.CallbackCredentials.ClientId,
-		callbackBody)
-	if err != nil {
-		log.Error(err, "error sending App callback")
-		a.Status.State = v1beta1.ApplicationStateFailed
-		return err
-	}
-	statusCode := res.StatusCode()
-	switch {
-	case statusCode >= 200 && statusCode < 300:
-		log.Info("Successfully sent App callback to Guest", "status", statusCode)
-	case statusCode == 400:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON400)
-		a.Status.State = v1beta1.ApplicationStateFailed
-	case statusCode == 401:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON401)
-		a.Status.State = v1beta1.ApplicationStateFailed
-	case statusCode == 404:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON404)
-		a.Status.State = v1beta1.ApplicationStateFailed
-	default:
-		log.Info("############# App callback returned unexpected status", "status", statusCode, "body", string(res.Body))
-		a.Status.State = v1beta1.ApplicationStatePending
-	}
-	upErr := r.Status().Update(ctx, a)
-	if upErr != nil {
-		log.Error(upErr, errorUpdatingResourceStatusMsg)
-		return upErr
+		AppId:      a.Labels[v1beta1.ExternalIdLabel],
+		StatusInfo: appStatusCallbackInfo(a),
 	}
-	return nil
+	return enqueueCallback(ctx, r.Client, feder, a, v1beta1.CallbackKindApplication, callbackBody)
 }
 
 func (r *ApplicationReconciler) handleExternalAppDeletion(
@@ -354,61 +575,108 @@ func (r *ApplicationReconciler) handleExternalAppDeletion(
 ) error {
 	log := log.FromContext(ctx)
 	log.Info("Deleting external app")
-	// we should delete the app
+	// we should delete the app, an app the partner OP doesn't know about is already deleted
 	res, err := r.GetOPGClient(
 		feder.Labels[v1beta1.ExternalIdLabel],
-		feder.Spec.GuestPartnerCredentials.TokenUrl,
-		feder.Spec.GuestPartnerCredentials.ClientId,
+		guestPartnerApiRoot(feder),
+		opgCredentials(feder, feder.Spec.GuestPartnerCredentials),
 	).DeleteAppWithResponse(
-		context.TODO(),
+		ctx,
 		feder.Status.FederationContextId,
 		a.Labels[v1beta1.ExternalIdLabel],
 	)
-	if err != nil {
-		log.Error(err, "error deleting application")
-		a.Status.State = v1beta1.ApplicationStateFailed
-		return err
-	}
-
-	statusCode := res.StatusCode()
-
-	switch {
-	case statusCode >= 200 && statusCode < 300:
+	out := opg.Classify(res, err, opg.WithOutcome(http.StatusNotFound, opg.OutcomeSuccess))
+	logOPGResponse(log, out)
+	switch out.Outcome {
+	case opg.OutcomeSuccess:
 		log.Info("Deleted")
 		a.Status.State = v1beta1.ApplicationStateRemoved
-	case statusCode == 400:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON400)
-		a.Status.State = v1beta1.ApplicationStateFailed
-	case statusCode == 401:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON401)
-		a.Status.State = v1beta1.ApplicationStateFailed
-	case statusCode == 404:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON404)
-		a.Status.State = v1beta1.ApplicationStateFailed
-	case statusCode == 409:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON409)
-		a.Status.State = v1beta1.ApplicationStateFailed
-	case statusCode == 422:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON422)
+	case opg.OutcomePermanent:
 		a.Status.State = v1beta1.ApplicationStateFailed
-	case statusCode == 500:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON500)
-		a.Status.State = v1beta1.ApplicationStateFailed
-	case statusCode == 503:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON503)
-		a.Status.State = v1beta1.ApplicationStateFailed
-	case statusCode == 520:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON520)
-		a.Status.State = v1beta1.ApplicationStateFailed
-	default:
-		log.Info(unexpectedStatusCodeMsg, "status", statusCode, "body", string(res.Body))
-		a.Status.State = v1beta1.ApplicationStatePending
 	}
-
-	upErr := r.Status().Update(ctx, a)
-	if upErr != nil {
+	recordAttempt(&a.Status.Attempts, &a.Status.LastError, out)
+	setPartnerConditions(&a.Status.Conditions, a.Generation, out)
+	if upErr := r.Status().Update(ctx, a); upErr != nil {
 		log.Error(upErr, errorUpdatingResourceStatusMsg)
 		return upErr
 	}
-	return nil
+	return out.Err()
+}
+
+// applicationZoneIds returns the ids of the zones in the app status.
+func applicationZoneIds(zones []v1beta1.ApplicationZoneStatus) []string {
+	ids := make([]string, 0, len(zones))
+	for _, z := range zones {
+		ids = append(ids, z.ZoneId)
+	}
+	return ids
+}
+
+// syncApplicationZones aligns the zones in the app status with the ones in its
+// spec, new zones have no state of their own until one is reported for them.
+// It reports whether the status changed.
+func syncApplicationZones(a *v1beta1.Application, now metav1.Time) bool {
+	changed := false
+	zones := make([]v1beta1.ApplicationZoneStatus, 0, len(a.Spec.Zones))
+	for _, z := range a.Status.Zones {
+		if slices.Contains(a.Spec.Zones, z.ZoneId) {
+			zones = append(zones, z)
+		} else {
+			changed = true
+		}
+	}
+	for _, zoneId := range sliceDifference(a.Spec.Zones, applicationZoneIds(zones)) {
+		zones = append(zones, v1beta1.ApplicationZoneStatus{
+			ZoneId:         zoneId,
+			LastUpdateTime: now,
+		})
+		changed = true
+	}
+	if changed {
+		a.Status.Zones = zones
+	}
+	return changed
+}
+
+// appStatusCallbackInfo returns the onboarding state of the app in each of its
+// zones, or its overall state when it has none.
+func appStatusCallbackInfo(a *v1beta1.Application) []struct {
+	OnboardStatusInfo opgmodels.AppStatusCallbackLinkJSONBodyStatusInfoOnboardStatusInfo `json:"onboardStatusInfo"`
+	ZoneId            opgmodels.ZoneIdentifier                                           `json:"zoneId"`
+} {
+	zones := a.Status.Zones
+	if len(zones) == 0 {
+		zones = []v1beta1.ApplicationZoneStatus{{State: a.Status.State}}
+	}
+	info := make([]struct {
+		OnboardStatusInfo opgmodels.AppStatusCallbackLinkJSONBodyStatusInfoOnboardStatusInfo `json:"onboardStatusInfo"`
+		ZoneId            opgmodels.ZoneIdentifier                                           `json:"zoneId"`
+	}, 0, len(zones))
+	for _, z := range zones {
+		state := z.State
+		if state == "" {
+			state = a.Status.State
+		}
+		info = append(info, struct {
+			OnboardStatusInfo opgmodels.AppStatusCallbackLinkJSONBodyStatusInfoOnboardStatusInfo `json:"onboardStatusInfo"`
+			ZoneId            opgmodels.ZoneIdentifier                                           `json:"zoneId"`
+		}{
+			OnboardStatusInfo: opgmodels.AppStatusCallbackLinkJSONBodyStatusInfoOnboardStatusInfo(state),
+			ZoneId:            z.ZoneId,
+		})
+	}
+	return info
+}
+
+// applicationZoneForbidChanges returns the zones to forbid and to allow on the
+// partner OP. Zones removed from the app are deboarded instead of allowed.
+func applicationZoneForbidChanges(a *v1beta1.Application) (forbid, allow []string) {
+	forbid = sliceDifference(a.Spec.ForbiddenZones, a.Status.ForbiddenZones)
+	allow = []string{}
+	for _, zoneId := range sliceDifference(a.Status.ForbiddenZones, a.Spec.ForbiddenZones) {
+		if len(a.Spec.Zones) == 0 || slices.Contains(a.Spec.Zones, zoneId) {
+			allow = append(allow, zoneId)
+		}
+	}
+	return forbid, allow
 }
@@ -26,6 +26,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
@@ -201,6 +202,247 @@ func TestApplicationReconciler(t *testing.T) {
 	}
 }
 
+func TestApplicationReconciler_Update(t *testing.T) {
+	feder := makeTestFederation(testFederationName, withFederationContextId(testFederationContextId))
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: testAppName, Namespace: testNamespace}}
+	// the spec of the onboarded app changed since it was sent to the partner OP
+	app := makeTestApplication(testFederationContextId, appWithFinalizer(),
+		appWithState(v1beta1.ApplicationStateOnboarded), appWithGeneration(2, 1))
+
+	tests := []struct {
+		name           string
+		statusCodes    []int
+		wantGeneration int64
+		wantStalled    bool
+	}{
+		{
+			name:           "An updated Guest Application is sent to the federation partner",
+			wantGeneration: 2,
+		},
+		{
+			name:           "An update rejected by the federation partner stalls the Application and keeps its state",
+			statusCodes:    []int{400},
+			wantGeneration: 1,
+			wantStalled:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.TODO()
+			cl, opgcmap, mockedOpgAPI, sch := prepareEnv([]client.Object{feder, app.DeepCopy()}, &ApiObjects{
+				Federations: []*v1beta1.Federation{feder},
+				Apps:        []*v1beta1.Application{app},
+			})
+			mockedOpgAPI.AppStatusCodes = tt.statusCodes
+			r := makeTestAppReconciler(cl, sch, opgcmap)
+
+			_, err := r.Reconcile(ctx, req)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"update " + testAppExternalId}, mockedOpgAPI.AppRequests)
+
+			var got v1beta1.Application
+			require.NoError(t, cl.Get(ctx, req.NamespacedName, &got))
+			assert.Equal(t, v1beta1.ApplicationStateOnboarded, got.Status.State)
+			assert.Equal(t, tt.wantGeneration, got.Status.ObservedGeneration)
+			stalled := meta.FindStatusCondition(got.Status.Conditions, v1beta1.ConditionStalled)
+			if !tt.wantStalled {
+				assert.Nil(t, stalled)
+				assert.Empty(t, got.Status.LastError)
+				return
+			}
+			require.NotNil(t, stalled)
+			assert.Equal(t, v1beta1.ReasonPartnerRejected, stalled.Reason)
+			assert.Equal(t, int64(2), stalled.ObservedGeneration)
+			assert.NotEmpty(t, got.Status.LastError)
+
+			// the rejected update isn't sent again for the same generation
+			_, err = r.Reconcile(ctx, req)
+			require.NoError(t, err)
+			assert.Len(t, mockedOpgAPI.AppRequests, 1)
+		})
+	}
+}
+
+func TestApplicationReconciler_ZonesUpdate(t *testing.T) {
+	feder := makeTestFederation(testFederationName, withFederationContextId(testFederationContextId))
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: testAppName, Namespace: testNamespace}}
+	update := "update " + testAppExternalId
+
+	tests := []struct {
+		name         string
+		specZones    []string
+		statusZones  []string
+		statusCodes  []int
+		wantRequests []string
+		wantZones    []string
+	}{
+		{
+			name:         "Zones added to and removed from the spec are onboarded and deboarded",
+			specZones:    []string{"zone-2"},
+			statusZones:  []string{"zone-1"},
+			wantRequests: []string{update, "onboard zone-2", "deboard zone-1"},
+			wantZones:    []string{"zone-2"},
+		},
+		{
+			name:         "Emptying the zones of the spec deboards the app from all of them",
+			statusZones:  []string{"zone-1", "zone-2"},
+			wantRequests: []string{update, "deboard zone-1", "deboard zone-2"},
+			wantZones:    []string{},
+		},
+		{
+			name:         "Zones onboarded before a failed deboard are not onboarded again",
+			specZones:    []string{"zone-2"},
+			statusZones:  []string{"zone-1"},
+			statusCodes:  []int{200, 200, 503},
+			wantRequests: []string{update, "onboard zone-2", "deboard zone-1", update, "deboard zone-1"},
+			wantZones:    []string{"zone-2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.TODO()
+			app := makeTestApplication(testFederationContextId, appWithFinalizer(),
+				appWithState(v1beta1.ApplicationStateOnboarded), appWithGeneration(2, 1),
+				appWithZones(tt.specZones, tt.statusZones))
+			cl, opgcmap, mockedOpgAPI, sch := prepareEnv([]client.Object{feder, app}, &ApiObjects{
+				Federations: []*v1beta1.Federation{feder},
+				Apps:        []*v1beta1.Application{app},
+			})
+			mockedOpgAPI.AppStatusCodes = tt.statusCodes
+			r := makeTestAppReconciler(cl, sch, opgcmap)
+
+			var got v1beta1.Application
+			for range 2 {
+				_, err := r.Reconcile(ctx, req)
+				require.NoError(t, err)
+				require.NoError(t, cl.Get(ctx, req.NamespacedName, &got))
+			}
+			assert.Equal(t, tt.wantRequests, mockedOpgAPI.AppRequests)
+			assert.Equal(t, tt.wantZones, applicationZoneIds(got.Status.Zones))
+			assert.Equal(t, int64(2), got.Status.ObservedGeneration)
+		})
+	}
+}
+
+func TestApplicationReconciler_Resync(t *testing.T) {
+	feder := makeTestFederation(testFederationName, withFederationContextId(testFederationContextId))
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: testAppName, Namespace: testNamespace}}
+	app := makeTestApplication(testFederationContextId, appWithFinalizer(),
+		appWithState(v1beta1.ApplicationStatePending))
+
+	tests := []struct {
+		name        string
+		partnerApps []*v1beta1.Application
+		wantState   v1beta1.ApplicationState
+		wantHeld    bool
+	}{
+		{
+			name:        "A pending Guest Application held by the federation partner is no longer polled",
+			partnerApps: []*v1beta1.Application{app},
+			wantState:   v1beta1.ApplicationStatePending,
+			wantHeld:    true,
+		},
+		{
+			name:      "A pending Guest Application unknown to the federation partner fails",
+			wantState: v1beta1.ApplicationStateFailed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.TODO()
+			cl, opgcmap, _, sch := prepareEnv([]client.Object{feder, app.DeepCopy()}, &ApiObjects{
+				Federations: []*v1beta1.Federation{feder},
+				Apps:        tt.partnerApps,
+			})
+			r := makeTestAppReconciler(cl, sch, opgcmap)
+			r.ResyncPeriod = time.Minute
+
+			gotResult, err := r.Reconcile(ctx, req)
+			require.NoError(t, err)
+			assert.Equal(t, ctrl.Result{}, gotResult)
+
+			var got v1beta1.Application
+			require.NoError(t, cl.Get(ctx, req.NamespacedName, &got))
+			assert.Equal(t, tt.wantState, got.Status.State)
+			assert.Equal(t, tt.wantHeld, got.Status.HeldByPartner)
+			require.NotNil(t, got.Status.LastSyncTime)
+		})
+	}
+}
+
+func TestApplicationReconciler_Retry(t *testing.T) {
+	feder := makeTestFederation(testFederationName, withFederationContextId(testFederationContextId))
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: testAppName, Namespace: testNamespace}}
+	update := "update " + testAppExternalId
+
+	tests := []struct {
+		name           string
+		opts           []appOpt
+		partnerHolds   bool
+		wantRequests   []string
+		wantState      v1beta1.ApplicationState
+		wantGeneration int64
+		wantZones      []string
+	}{
+		{
+			name: "A retried Guest Application the federation partner never accepted is onboarded again",
+			opts: []appOpt{appWithState(v1beta1.ApplicationStateFailed), appWithGeneration(1, 0),
+				appWithRejection()},
+			wantState:      v1beta1.ApplicationStatePending,
+			wantGeneration: 1,
+			wantZones:      []string{},
+		},
+		{
+			name: "A retried Guest Application with a rejected update sends the update again",
+			opts: []appOpt{appWithState(v1beta1.ApplicationStateOnboarded), appWithGeneration(2, 1),
+				appWithZones([]string{"zone-1"}, []string{"zone-1"}), appWithRejection()},
+			partnerHolds:   true,
+			wantRequests:   []string{update},
+			wantState:      v1beta1.ApplicationStateOnboarded,
+			wantGeneration: 2,
+			wantZones:      []string{"zone-1"},
+		},
+		{
+			name: "A retried Guest Application failed by the federation partner is polled again",
+			opts: []appOpt{appWithState(v1beta1.ApplicationStateFailed), appWithGeneration(1, 1),
+				appWithZones([]string{"zone-1"}, []string{"zone-1"})},
+			partnerHolds:   true,
+			wantState:      v1beta1.ApplicationStatePending,
+			wantGeneration: 1,
+			wantZones:      []string{"zone-1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.TODO()
+			app := makeTestApplication(testFederationContextId,
+				append(tt.opts, appWithFinalizer(), appWithAnnotation(v1beta1.RetryAnnotation, ""))...)
+			apiObjs := &ApiObjects{Federations: []*v1beta1.Federation{feder}}
+			if tt.partnerHolds {
+				apiObjs.Apps = []*v1beta1.Application{app}
+			}
+			cl, opgcmap, mockedOpgAPI, sch := prepareEnv([]client.Object{feder, app.DeepCopy()}, apiObjs)
+			r := makeTestAppReconciler(cl, sch, opgcmap)
+			r.ResyncPeriod = time.Minute
+
+			var got v1beta1.Application
+			for range 2 {
+				_, err := r.Reconcile(ctx, req)
+				require.NoError(t, err)
+				require.NoError(t, cl.Get(ctx, req.NamespacedName, &got))
+			}
+			assert.NotContains(t, got.Annotations, v1beta1.RetryAnnotation)
+			assert.Equal(t, tt.wantRequests, mockedOpgAPI.AppRequests)
+			assert.Contains(t, mockedOpgAPI.Apps, testAppExternalId)
+			assert.Equal(t, tt.wantState, got.Status.State)
+			assert.Equal(t, tt.wantGeneration, got.Status.ObservedGeneration)
+			assert.Equal(t, tt.wantZones, applicationZoneIds(got.Status.Zones))
+			assert.Nil(t, meta.FindStatusCondition(got.Status.Conditions, v1beta1.ConditionStalled))
+			assert.Empty(t, got.Status.LastError)
+		})
+	}
+}
+
 func appWithFinalizer() appOpt {
 	return func(f *v1beta1.Application) {
 		controllerutil.AddFinalizer(f, v1beta1.AppFinalizer)
@@ -215,12 +457,47 @@ func appWithDeletedAt(now time.Time) appOpt {
 	}
 }
 
+func appWithGeneration(generation, observedGeneration int64) appOpt {
+	return func(a *v1beta1.Application) {
+		a.Generation = generation
+		a.Status.ObservedGeneration = observedGeneration
+	}
+}
+
+func appWithZones(specZones, statusZones []string) appOpt {
+	return func(a *v1beta1.Application) {
+		a.Spec.Zones = specZones
+		for _, zoneId := range statusZones {
+			a.Status.Zones = append(a.Status.Zones, v1beta1.ApplicationZoneStatus{ZoneId: zoneId})
+		}
+	}
+}
+
 func appWithState(state v1beta1.ApplicationState) appOpt {
 	return func(f *v1beta1.Application) {
 		f.Status.State = state
 	}
 }
 
+func appWithAnnotation(key, value string) appOpt {
+	return func(a *v1beta1.Application) {
+		metav1.SetMetaDataAnnotation(&a.ObjectMeta, key, value)
+	}
+}
+
+// appWithRejection stalls the app as rejected by the partner OP at its generation
+func appWithRejection() appOpt {
+	return func(a *v1beta1.Application) {
+		a.Status.LastError = "rejected"
+		meta.SetStatusCondition(&a.Status.Conditions, metav1.Condition{
+			Type:               v1beta1.ConditionStalled,
+			Status:             metav1.ConditionTrue,
+			Reason:             v1beta1.ReasonPartnerRejected,
+			ObservedGeneration: a.Generation,
+		})
+	}
+}
+
 type appOpt func(*v1beta1.Application)
 
 func makeTestApplication(fedCtxId string, opts ...appOpt) *v1beta1.Application {
@@ -240,7 +517,6 @@ func makeTestApplication(fedCtxId string, opts ...appOpt) *v1beta1.Application {
 				ArtefactId: testArtefactName,
 			}},
 			MetaData: v1beta1.AppMetaData{
-				AccessToken:     "a1234567890123456789012345678901234567890123456789012345678901",
 				Name:            testAppMetaDataName,
 				MobilitySupport: false,
 				Version:         testAppMetaDataVersion,
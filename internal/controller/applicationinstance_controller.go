@@ -18,15 +18,23 @@ package controller
 
 import (
 	"context"
-	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
 
 	opgmodels "github.com/neonephos-katalis/opg-ewbi-operator/api/ewbi/models"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	"github.com/neonephos-katalis/opg-ewbi-operator/api/operator/v1beta1"
 	opgewbiv1beta1 "github.com/neonephos-katalis/opg-ewbi-operator/api/operator/v1beta1"
@@ -38,6 +46,9 @@ type ApplicationInstanceReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
 	opg.OPGClientsMapInterface
+	// ResyncPeriod, interval between the polls of the partner OP for the state of
+	// the guest ApplicationInstances until it is final, 0 disables polling
+	ResyncPeriod time.Duration
 }
 
 // +kubebuilder:rbac:groups=opg.ewbi.nby.one,resources=applicationinstances,verbs=*,namespace=foo
@@ -53,7 +64,16 @@ type ApplicationInstanceReconciler struct {
 //
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.19.1/pkg/reconcile
-func (r *ApplicationInstanceReconciler) Reconcile(
+func (r *ApplicationInstanceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	res, err := r.reconcile(ctx, req)
+	if upErr := updateConditions(ctx, r.Client, req.NamespacedName, &v1beta1.ApplicationInstance{}, setApplicationInstanceConditions); upErr != nil && err == nil {
+		return ctrl.Result{}, upErr
+	}
+	return res, err
+}
+
+// reconcile syncs the ApplicationInstance, its conditions are set afterwards from the resulting state
+func (r *ApplicationInstanceReconciler) reconcile(
 	ctx context.Context,
 	req ctrl.Request,
 ) (ctrl.Result, error) {
@@ -108,12 +128,7 @@ func (r *ApplicationInstanceReconciler) Reconcile(
 		if isGuest {
 			if err := r.handleExternalAppInstDeletion(ctx, &a, feder); err != nil {
 				log.Error(err, "error deleting appInst")
-				a.Status.State = v1beta1.ApplicationInstanceStateFailed
-				upErr := r.Status().Update(ctx, a.DeepCopy())
-				if upErr != nil {
-					log.Error(upErr, errorUpdatingResourceStatusMsg)
-				}
-				return ctrl.Result{}, err
+				return opgResult(err)
 			}
 		}
 		// if external appInst is correctly deleted, we can remove the finalizer
@@ -130,19 +145,40 @@ func (r *ApplicationInstanceReconciler) Reconcile(
 
 	// if federation is guest, send OPG API request
 	if isGuest {
+		if _, ok := a.Annotations[v1beta1.RetryAnnotation]; ok {
+			return ctrl.Result{}, handleRetryAnnotation(ctx, r.Client, &a, a.Status.State == v1beta1.ApplicationInstanceStateFailed, func() {
+				resetApplicationInstanceStatus(&a)
+			})
+		}
+		zoneAvailable, conditionChanged := setZoneDegradedCondition(&a, feder)
+		if a.Status.State == "" && !zoneAvailable && feder.Spec.SuppressInstallsInUnavailableZones {
+			// the instance is installed once the zone is available again, the federation changes are watched
+			log.Info("Holding appInst installation, zone not available", "zoneId", a.Spec.ZoneInfo.ZoneId)
+			if conditionChanged {
+				if upErr := r.Status().Update(ctx, &a); upErr != nil {
+					log.Error(upErr, errorUpdatingResourceStatusMsg)
+					return ctrl.Result{}, upErr
+				}
+			}
+			return ctrl.Result{}, nil
+		}
 		if a.Status.State == "" {
 			log.Info("AppInst is in Pending state, getting access point info")
 			if err := r.handleExternalAppInstCreation(ctx, &a, feder); err != nil {
-				log.Error(err, "error creating appInst info before deletion")
-				a.Status.State = v1beta1.ApplicationInstanceStateFailed
-				upErr := r.Status().Update(ctx, a.DeepCopy())
-				if upErr != nil {
-					log.Error(upErr, errorUpdatingResourceStatusMsg)
-				}
-				return ctrl.Result{}, err
+				log.Error(err, "error creating appInst")
+				return opgResult(err)
 			}
 		} else {
 			log.Info("+++++++++++++++++++ AppInst status is ", "state", a.Status.State)
+			if conditionChanged {
+				if upErr := r.Status().Update(ctx, &a); upErr != nil {
+					log.Error(upErr, errorUpdatingResourceStatusMsg)
+					return ctrl.Result{}, upErr
+				}
+			}
+			if r.ResyncPeriod > 0 && !appInstStateFinal(a.Status.State) {
+				return r.resyncExternalAppInst(ctx, &a, feder)
+			}
 		}
 	} else {
 		if a.Status.State == "" {
@@ -157,12 +193,8 @@ func (r *ApplicationInstanceReconciler) Reconcile(
 		} else {
 			log.Info("New CR state", "state", a.Status.State)
 			if err := r.handleExternalAppInstCallback(ctx, &a, feder); err != nil {
-				log.Error(err, "error handling appInst callback")
-				a.Status.State = v1beta1.ApplicationInstanceStateFailed
-				upErr := r.Status().Update(ctx, a.DeepCopy())
-				if upErr != nil {
-					log.Error(upErr, errorUpdatingResourceStatusMsg)
-				}
+				log.Error(err, "error queueing appInst callback")
+				return ctrl.Result{}, err
 			}
 		}
 
@@ -170,14 +202,219 @@ func (r *ApplicationInstanceReconciler) Reconcile(
 	return ctrl.Result{}, nil
 }
 
+// resyncExternalAppInst polls the partner OP for the AppInst, in case its callback was lost.
+// The state and access points reported by the partner are set in the status, an
+// ApplicationInstance it no longer holds is set as failed.
+func (r *ApplicationInstanceReconciler) resyncExternalAppInst(
+	ctx context.Context, a *v1beta1.ApplicationInstance, feder *v1beta1.Federation,
+) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	now := metav1.Now()
+	if wait := resyncDelay(r.ResyncPeriod, a.Status.LastSyncTime, now.Time); wait > 0 {
+		return ctrl.Result{RequeueAfter: wait}, nil
+	}
+
+	res, err := r.GetOPGClient(
+		feder.Labels[v1beta1.ExternalIdLabel],
+		guestPartnerApiRoot(feder),
+		opgCredentials(feder, feder.Spec.GuestPartnerCredentials),
+	).GetAppInstanceDetailsWithResponse(
+		ctx,
+		feder.Status.FederationContextId,
+		a.Spec.AppId,
+		a.Labels[v1beta1.ExternalIdLabel],
+		a.Spec.ZoneInfo.ZoneId,
+	)
+	out := opg.Classify(res, err)
+	logOPGResponse(log, out)
+	switch {
+	case out.Outcome == opg.OutcomeSuccess:
+		if details := res.JSON200; details != nil {
+			if details.AppInstanceState != nil {
+				a.Status.State = v1beta1.ApplicationInstanceState(*details.AppInstanceState)
+			}
+			if details.AccessPointInfo != nil {
+				a.Status.AccessPointInfo = accessPointInfoFromModel(*details.AccessPointInfo)
+			}
+		}
+		log.Info("Polled external application instance", "state", a.Status.State)
+	case out.StatusCode == http.StatusNotFound:
+		a.Status.State = v1beta1.ApplicationInstanceStateFailed
+		// the partner OP no longer holds the instance, a retry installs it again
+		a.Status.AppInstanceId = ""
+	}
+	a.Status.LastSyncTime = &now
+	setPartnerConditions(&a.Status.Conditions, a.Generation, out)
+	if upErr := r.Status().Update(ctx, a); upErr != nil {
+		log.Error(upErr, errorUpdatingResourceStatusMsg)
+		return ctrl.Result{}, upErr
+	}
+	return resyncResult(r.ResyncPeriod, appInstStateFinal(a.Status.State)), nil
+}
+
+// resetApplicationInstanceStatus clears the failed step of a retried ApplicationInstance.
+// An instance the partner OP never accepted is installed again, otherwise the instance
+// the partner failed is polled again.
+func resetApplicationInstanceStatus(a *v1beta1.ApplicationInstance) {
+	if a.Status.AppInstanceId == "" {
+		a.Status = v1beta1.ApplicationInstanceStatus{Conditions: a.Status.Conditions}
+	} else {
+		a.Status.State = v1beta1.ApplicationInstanceStatePending
+		a.Status.Attempts = 0
+		a.Status.LastError = ""
+		a.Status.ErrorMsg = ""
+		a.Status.LastSyncTime = nil
+	}
+	meta.RemoveStatusCondition(&a.Status.Conditions, v1beta1.ConditionStalled)
+}
+
+// appInstStateFinal returns whether the ApplicationInstance no longer changes without a callback
+func appInstStateFinal(state v1beta1.ApplicationInstanceState) bool {
+	switch state {
+	case v1beta1.ApplicationInstanceStateReady, v1beta1.ApplicationInstanceStateFailed,
+		v1beta1.ApplicationInstanceStateTerminating:
+		return true
+	}
+	return false
+}
+
+// accessPointInfoFromModel converts the access points reported by the partner OP
+func accessPointInfoFromModel(info opgmodels.AccessPointInfo) []v1beta1.AccessPointInfo {
+	accessPoints := make([]v1beta1.AccessPointInfo, 0, len(info))
+	for _, ap := range info {
+		endpoint := v1beta1.AccessPoints{Port: ap.AccessPoints.Port}
+		if ap.AccessPoints.Fqdn != nil {
+			endpoint.Fqdn = *ap.AccessPoints.Fqdn
+		}
+		if ap.AccessPoints.Ipv4Addresses != nil {
+			endpoint.Ipv4Addresses = append(endpoint.Ipv4Addresses, *ap.AccessPoints.Ipv4Addresses...)
+		}
+		if ap.AccessPoints.Ipv6Addresses != nil {
+			for _, addr := range *ap.AccessPoints.Ipv6Addresses {
+				endpoint.Ipv6Addresses = append(endpoint.Ipv6Addresses, fmt.Sprint(addr))
+			}
+		}
+		accessPoints = append(accessPoints, v1beta1.AccessPointInfo{
+			InterfaceId:  ap.InterfaceId,
+			AccessPoints: endpoint,
+		})
+	}
+	return accessPoints
+}
+
+// setApplicationInstanceConditions sets the conditions and observedGeneration of the ApplicationInstance from its state
+func setApplicationInstanceConditions(a *v1beta1.ApplicationInstance) bool {
+	phase := syncInProgress
+	switch a.Status.State {
+	case v1beta1.ApplicationInstanceStateReady:
+		phase = syncCompleted
+	case v1beta1.ApplicationInstanceStateFailed:
+		phase = syncFailed
+	}
+	changed := a.Status.ObservedGeneration != a.Generation
+	a.Status.ObservedGeneration = a.Generation
+	return setSyncConditions(&a.Status.Conditions, a.Generation, phase, string(a.Status.State)) || changed
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *ApplicationInstanceReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&opgewbiv1beta1.ApplicationInstance{}).
+		Watches(&v1beta1.Federation{}, handler.EnqueueRequestsFromMapFunc(r.guestAppInstsForFederation)).
 		Named("applicationinstance").
 		Complete(r)
 }
 
+// guestAppInstsForFederation enqueues the guest application instances of a guest
+// federation, so they follow the availability of their zones.
+func (r *ApplicationInstanceReconciler) guestAppInstsForFederation(ctx context.Context, obj client.Object) []reconcile.Request {
+	feder, ok := obj.(*v1beta1.Federation)
+	if !ok || !IsGuestResource(feder.Labels) || feder.Status.FederationContextId == "" {
+		return nil
+	}
+	var appInsts v1beta1.ApplicationInstanceList
+	if err := r.List(ctx, &appInsts, client.InNamespace(feder.Namespace), client.MatchingLabels{
+		v1beta1.FederationContextIdLabel: feder.Status.FederationContextId,
+		v1beta1.FederationRelationLabel:  string(v1beta1.FederationRelationGuest),
+	}); err != nil {
+		log.FromContext(ctx).Error(err, "error listing guest appInsts of federation", "federation", feder.Name)
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(appInsts.Items))
+	for _, a := range appInsts.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&a)})
+	}
+	return requests
+}
+
+// setZoneDegradedCondition sets the Degraded condition of the appInst from its
+// zone as notified by the partner OP, both in the zone status of the partner status
+// link and in the availability zone notifications. The zone is unavailable when
+// either of them says so, zones without notifications are available. It returns
+// whether the zone is available and whether the condition changed.
+func setZoneDegradedCondition(a *v1beta1.ApplicationInstance, feder *v1beta1.Federation) (available, changed bool) {
+	zoneId := a.Spec.ZoneInfo.ZoneId
+	available = true
+	notified := false
+	var messages []string
+	var transitionTime metav1.Time
+	notify := func(zoneAvailable bool, message string, at metav1.Time) {
+		notified = true
+		if available && !zoneAvailable {
+			// the notifications of the zone as available no longer apply
+			available, messages, transitionTime = false, nil, metav1.Time{}
+		}
+		if zoneAvailable != available {
+			return
+		}
+		messages = append(messages, message)
+		if at.After(transitionTime.Time) {
+			transitionTime = at
+		}
+	}
+	if i := slices.IndexFunc(feder.Status.ZonesStatus, func(z v1beta1.ZoneStatus) bool {
+		return z.ZoneId == zoneId
+	}); i >= 0 {
+		zone := feder.Status.ZonesStatus[i]
+		notify(zone.State == v1beta1.FederationStateAvailable,
+			fmt.Sprintf("zone %s notified as %s by the partner OP", zoneId, zone.State), zone.LastUpdateTime)
+	}
+	if i := slices.IndexFunc(feder.Status.ZonesAvailability, func(z v1beta1.ZoneAvailability) bool {
+		return z.ZoneId == zoneId
+	}); i >= 0 {
+		zone := feder.Status.ZonesAvailability[i]
+		if zone.State == v1beta1.ZoneAvailabilityStateNotAvailable {
+			notify(false, fmt.Sprintf("zone %s notified with no resources available by the partner OP", zoneId), zone.LastTransitionTime)
+		} else {
+			notify(true, fmt.Sprintf("zone %s notified with resources available", zoneId), zone.LastTransitionTime)
+		}
+	}
+
+	if !notified {
+		if meta.FindStatusCondition(a.Status.Conditions, v1beta1.ApplicationInstanceConditionDegraded) == nil {
+			return true, false
+		}
+		return true, meta.SetStatusCondition(&a.Status.Conditions, metav1.Condition{
+			Type:    v1beta1.ApplicationInstanceConditionDegraded,
+			Status:  metav1.ConditionFalse,
+			Reason:  v1beta1.ApplicationInstanceReasonZoneAvailable,
+			Message: "no availability notified for the zone",
+		})
+	}
+	condition := metav1.Condition{
+		Type:               v1beta1.ApplicationInstanceConditionDegraded,
+		Status:             metav1.ConditionFalse,
+		Reason:             v1beta1.ApplicationInstanceReasonZoneAvailable,
+		Message:            strings.Join(messages, ", "),
+		LastTransitionTime: transitionTime,
+	}
+	if !available {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = v1beta1.ApplicationInstanceReasonZoneNotAvailable
+	}
+	return available, meta.SetStatusCondition(&a.Status.Conditions, condition)
+}
+
 func (r *ApplicationInstanceReconciler) handleExternalAppInstCreation(
 	ctx context.Context, a *v1beta1.ApplicationInstance, feder *v1beta1.Federation,
 ) error {
@@ -206,62 +443,35 @@ func (r *ApplicationInstanceReconciler) handleExternalAppInstCreation(
 
 	res, err := r.GetOPGClient(
 		feder.Labels[v1beta1.ExternalIdLabel],
-		feder.Spec.GuestPartnerCredentials.TokenUrl,
-		feder.Spec.GuestPartnerCredentials.ClientId,
+		guestPartnerApiRoot(feder),
+		opgCredentials(feder, feder.Spec.GuestPartnerCredentials),
 	).InstallAppWithResponse(
-		context.TODO(),
+		ctx,
 		feder.Status.FederationContextId,
 		reqBody)
-
-	if err != nil {
-		log.Error(err, "error creating appInst")
-		a.Status.State = v1beta1.ApplicationInstanceStateFailed
-		return err
+	out := opg.Classify(res, err)
+	// this should be deleted when API returns a 400 for this case
+	if out.StatusCode == http.StatusInternalServerError && out.Detail() == "application not found" {
+		out.Outcome = opg.OutcomePermanent
 	}
-	statusCode := res.StatusCode()
-	switch {
-	case statusCode >= 200 && statusCode < 300:
-		log.Info("APP INSTANCES - Status code 2xx received from OPG API", "status", statusCode)
-
+	logOPGResponse(log, out)
+	switch out.Outcome {
+	case opg.OutcomeSuccess:
 		a.Status.State = v1beta1.ApplicationInstanceStatePending
-		a.Status.AppInstanceId = a.Name //"app-inst-2dae064c-28cc-456e-8b0a-dd67bab7d8f7"
+		a.Status.AppInstanceId = a.Name
+		now := metav1.Now()
+		a.Status.LastSyncTime = &now
 		log.Info("Created external application instances", "state", a.Status.State, "appInstanceId", a.Status.AppInstanceId)
-
-	case statusCode == 400:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON400)
-		log.Info("Couldn't be created", "Detail", res.ApplicationproblemJSON400.Detail)
-	case statusCode == 401:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON401)
-		a.Status.State = v1beta1.ApplicationInstanceStateFailed
-	case statusCode == 404:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON404)
-		a.Status.State = v1beta1.ApplicationInstanceStateFailed
-	case statusCode == 409:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON409)
-		a.Status.State = v1beta1.ApplicationInstanceStateFailed
-	case statusCode == 422:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON422)
+	case opg.OutcomePermanent:
 		a.Status.State = v1beta1.ApplicationInstanceStateFailed
-	case statusCode == 500:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON500)
-		a.Status.State = v1beta1.ApplicationInstanceStateFailed
-		// this should be deleted when API returns a 400 for this case
-		if *res.ApplicationproblemJSON500.Detail == "application not found" {
-			return errors.New(*res.ApplicationproblemJSON500.Detail)
-		}
-	case statusCode == 503:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON503)
-	case statusCode == 520:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON520)
-	default:
-		a.Status.State = v1beta1.ApplicationInstanceStatePending
 	}
-	upErr := r.Status().Update(ctx, a)
-	if upErr != nil {
+	recordAttempt(&a.Status.Attempts, &a.Status.LastError, out)
+	setPartnerConditions(&a.Status.Conditions, a.Generation, out)
+	if upErr := r.Status().Update(ctx, a); upErr != nil {
 		log.Error(upErr, errorUpdatingResourceStatusMsg)
 		return upErr
 	}
-	return nil
+	return out.Err()
 }
 
 func (r *ApplicationInstanceReconciler) handleExternalAppInstCallback(
@@ -277,7 +487,7 @@ func (r *ApplicationInstanceReconciler) handleExternalAppInstCallback(
 		return nil
 	}
 
-	log.Info("Sending AppInst callback to Guest",
+	log.Info("Queueing AppInst callback to Guest",
 		"appInstanceId", a.Status.AppInstanceId,
 		"state", a.Status.State,
 		"statusLink", feder.Spec.Partner.StatusLink)
@@ -324,45 +534,7 @@ func (r *ApplicationInstanceReconciler) handleExternalAppInstCallback(
 		}
 		callbackBody.AppInstanceInfo.AccesspointInfo = &accessPointInfo
 	}
-	// Get callback client (pointing to Guest's callback URL)
-	// Using a different cache key to separate callback client from regular client
-	res, err := r.GetOPGClient(
-		feder.Labels[v1beta1.ExternalIdLabel],
-		feder.Spec.Partner.StatusLink,
-		feder.Spec.Partner.CallbackCredentials.ClientId,
-	).AppInstCallbackLinkWithResponse(
-		context.TODO(),
-		feder.Spec.Partner.CallbackCredentials.ClientId,
-		callbackBody,
-	)
-	if err != nil {
-		log.Error(err, "Error while sending applicationinstance callback")
-		return err
-	}
-
-	statusCode := res.StatusCode()
-	switch {
-	case statusCode >= 200 && statusCode < 300:
-		log.Info("****************** Successfully sent ApplicationInstance callback to Guest", "status", statusCode)
-	case statusCode == 400:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON400)
-		a.Status.State = v1beta1.ApplicationInstanceStateFailed
-	case statusCode == 401:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON401)
-		a.Status.State = v1beta1.ApplicationInstanceStateFailed
-	case statusCode == 404:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON404)
-		a.Status.State = v1beta1.ApplicationInstanceStateFailed
-	default:
-		log.Info("############# ApplicationInstance callback returned unexpected status", "status", statusCode, "body", string(res.Body))
-		a.Status.State = v1beta1.ApplicationInstanceStatePending
-	}
-	upErr := r.Status().Update(ctx, a)
-	if upErr != nil {
-		log.Error(upErr, errorUpdatingResourceStatusMsg)
-		return upErr
-	}
-	return nil
+	return enqueueCallback(ctx, r.Client, feder, a, v1beta1.CallbackKindApplicationInstance, callbackBody)
 }
 
 func (r *ApplicationInstanceReconciler) handleExternalAppInstDeletion(
@@ -373,59 +545,30 @@ func (r *ApplicationInstanceReconciler) handleExternalAppInstDeletion(
 	// we should delete the appInst
 	res, err := r.GetOPGClient(
 		feder.Labels[v1beta1.ExternalIdLabel],
-		feder.Spec.GuestPartnerCredentials.TokenUrl,
-		feder.Spec.GuestPartnerCredentials.ClientId,
+		guestPartnerApiRoot(feder),
+		opgCredentials(feder, feder.Spec.GuestPartnerCredentials),
 	).RemoveAppWithResponse(
-		context.TODO(),
+		ctx,
 		feder.Status.FederationContextId,
 		appInst.Spec.AppId,
 		appInst.Labels[v1beta1.ExternalIdLabel],
 		appInst.Spec.ZoneInfo.ZoneId,
 	)
-	if err != nil {
-		log.Error(err, "error deleting federation")
-		return err
-	}
-
-	statusCode := res.StatusCode()
-
-	switch {
-	case statusCode >= 200 && statusCode < 300:
+	// an instance the partner OP doesn't know about is already removed
+	out := opg.Classify(res, err, opg.WithOutcome(http.StatusNotFound, opg.OutcomeSuccess))
+	logOPGResponse(log, out)
+	switch out.Outcome {
+	case opg.OutcomeSuccess:
 		log.Info("Deleted")
 		appInst.Status.State = v1beta1.ApplicationInstanceStateTerminating
-		// federResponse.OfferedAvailabilityZones
-	case statusCode == 400:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON400)
-		appInst.Status.State = v1beta1.ApplicationInstanceStateFailed
-	case statusCode == 401:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON401)
-		appInst.Status.State = v1beta1.ApplicationInstanceStateFailed
-	case statusCode == 404:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON404)
-		appInst.Status.State = v1beta1.ApplicationInstanceStateFailed
-	case statusCode == 409:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON409)
-		appInst.Status.State = v1beta1.ApplicationInstanceStateFailed
-	case statusCode == 422:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON422)
-		appInst.Status.State = v1beta1.ApplicationInstanceStateFailed
-	case statusCode == 500:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON500)
-		appInst.Status.State = v1beta1.ApplicationInstanceStateFailed
-	case statusCode == 503:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON503)
-		appInst.Status.State = v1beta1.ApplicationInstanceStateFailed
-	case statusCode == 520:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON520)
+	case opg.OutcomePermanent:
 		appInst.Status.State = v1beta1.ApplicationInstanceStateFailed
-	default:
-		log.Info(unexpectedStatusCodeMsg, "status", statusCode, "body", string(res.Body))
-		appInst.Status.State = v1beta1.ApplicationInstanceStatePending
 	}
-	upErr := r.Status().Update(ctx, appInst)
-	if upErr != nil {
+	recordAttempt(&appInst.Status.Attempts, &appInst.Status.LastError, out)
+	setPartnerConditions(&appInst.Status.Conditions, appInst.Generation, out)
+	if upErr := r.Status().Update(ctx, appInst); upErr != nil {
 		log.Error(upErr, errorUpdatingResourceStatusMsg)
 		return upErr
 	}
-	return nil
+	return out.Err()
 }
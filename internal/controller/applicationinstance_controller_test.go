@@ -27,6 +27,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
@@ -52,6 +53,7 @@ func TestApplicationInstanceReconciler(t *testing.T) {
 		resources          []client.Object
 		mockOpgFederations []*v1beta1.Federation
 		mockOpgAppInsts    []*v1beta1.ApplicationInstance
+		resyncPeriod       time.Duration
 	}
 	type args struct {
 		req ctrl.Request
@@ -147,6 +149,81 @@ func TestApplicationInstanceReconciler(t *testing.T) {
 				wantAPIAppInsts:  []string{testAppInstExternalId},
 			},
 		},
+		{
+			name: "A pending Guest ApplicationInstance gets its state polled from the federation partner",
+			fields: fields{
+				resources: []client.Object{
+					feder,
+					file,
+					makeTestAppInst(testFederationContextId,
+						appInstWithFinalizer(),
+						appInstWithState(v1beta1.ApplicationInstanceStatePending),
+					)},
+				mockOpgFederations: []*v1beta1.Federation{feder},
+				mockOpgAppInsts: []*v1beta1.ApplicationInstance{makeTestAppInst(testFederationContextId,
+					appInstWithState(v1beta1.ApplicationInstanceStateReady))},
+				resyncPeriod: time.Minute,
+			},
+			args: args{
+				req: ctrl.Request{NamespacedName: types.NamespacedName{Name: testAppInstName, Namespace: testNamespace}},
+			},
+			resp: response{
+				wantResult:       ctrl.Result{},
+				wantReconcileErr: false,
+				wantStatusState:  v1beta1.ApplicationInstanceStateReady,
+				wantFinalizer:    v1beta1.ApplicationInstanceFinalizer,
+				wantAPIAppInsts:  []string{testAppInstExternalId},
+			},
+		},
+		{
+			name: "A pending Guest ApplicationInstance is polled again while the federation partner reports it pending",
+			fields: fields{
+				resources: []client.Object{
+					feder,
+					file,
+					makeTestAppInst(testFederationContextId,
+						appInstWithFinalizer(),
+						appInstWithState(v1beta1.ApplicationInstanceStatePending),
+					)},
+				mockOpgFederations: []*v1beta1.Federation{feder},
+				mockOpgAppInsts: []*v1beta1.ApplicationInstance{makeTestAppInst(testFederationContextId,
+					appInstWithState(v1beta1.ApplicationInstanceStatePending))},
+				resyncPeriod: time.Minute,
+			},
+			args: args{
+				req: ctrl.Request{NamespacedName: types.NamespacedName{Name: testAppInstName, Namespace: testNamespace}},
+			},
+			resp: response{
+				wantResult:       ctrl.Result{RequeueAfter: time.Minute},
+				wantReconcileErr: false,
+				wantStatusState:  v1beta1.ApplicationInstanceStatePending,
+				wantFinalizer:    v1beta1.ApplicationInstanceFinalizer,
+				wantAPIAppInsts:  []string{testAppInstExternalId},
+			},
+		},
+		{
+			name: "A pending Guest ApplicationInstance no longer held by the federation partner is set as failed",
+			fields: fields{
+				resources: []client.Object{
+					feder,
+					file,
+					makeTestAppInst(testFederationContextId,
+						appInstWithFinalizer(),
+						appInstWithState(v1beta1.ApplicationInstanceStatePending),
+					)},
+				mockOpgFederations: []*v1beta1.Federation{feder},
+				resyncPeriod:       time.Minute,
+			},
+			args: args{
+				req: ctrl.Request{NamespacedName: types.NamespacedName{Name: testAppInstName, Namespace: testNamespace}},
+			},
+			resp: response{
+				wantResult:       ctrl.Result{},
+				wantReconcileErr: false,
+				wantStatusState:  v1beta1.ApplicationInstanceStateFailed,
+				wantFinalizer:    v1beta1.ApplicationInstanceFinalizer,
+			},
+		},
 		{
 			name: "Delete ApplicationInstance is synced at federation partner and its finalizer removed in a single reconcile",
 			fields: fields{
@@ -176,6 +253,7 @@ func TestApplicationInstanceReconciler(t *testing.T) {
 			cl, opgcmap, mockedOpgAPI, sch := prepareEnv(tt.fields.resources, apiObjs)
 
 			r := makeTestAppInstReconciler(cl, sch, opgcmap)
+			r.ResyncPeriod = tt.fields.resyncPeriod
 
 			gotResult, err := r.Reconcile(ctx, tt.args.req)
 
@@ -206,6 +284,138 @@ func TestApplicationInstanceReconciler(t *testing.T) {
 	}
 }
 
+func TestApplicationInstanceReconciler_ZoneAvailability(t *testing.T) {
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: testAppInstName, Namespace: testNamespace}}
+
+	tests := []struct {
+		name          string
+		federOpts     []federationOpt
+		wantInstalled bool
+		wantDegraded  metav1.ConditionStatus
+	}{
+		{
+			name:          "A Guest ApplicationInstance in a zone without notifications is installed",
+			federOpts:     []federationOpt{federationWithSuppressedInstalls()},
+			wantInstalled: true,
+		},
+		{
+			name: "A Guest ApplicationInstance in a zone available in both notifications is installed",
+			federOpts: []federationOpt{federationWithSuppressedInstalls(),
+				federationWithZoneStatus(testAZName, v1beta1.FederationStateAvailable),
+				federationWithZoneAvailability(testAZName, v1beta1.ZoneAvailabilityStateAvailable)},
+			wantInstalled: true,
+			wantDegraded:  metav1.ConditionFalse,
+		},
+		{
+			name: "A Guest ApplicationInstance in a zone without resources available is held",
+			federOpts: []federationOpt{federationWithSuppressedInstalls(),
+				federationWithZoneStatus(testAZName, v1beta1.FederationStateAvailable),
+				federationWithZoneAvailability(testAZName, v1beta1.ZoneAvailabilityStateNotAvailable)},
+			wantDegraded: metav1.ConditionTrue,
+		},
+		{
+			name: "A Guest ApplicationInstance in a zone notified as not available is held",
+			federOpts: []federationOpt{federationWithSuppressedInstalls(),
+				federationWithZoneStatus(testAZName, v1beta1.FederationStateNotAvailable)},
+			wantDegraded: metav1.ConditionTrue,
+		},
+		{
+			name: "A Guest ApplicationInstance in an unavailable zone is installed degraded when installs are not suppressed",
+			federOpts: []federationOpt{
+				federationWithZoneStatus(testAZName, v1beta1.FederationStateNotAvailable)},
+			wantInstalled: true,
+			wantDegraded:  metav1.ConditionTrue,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.TODO()
+			feder := makeTestFederation(testFederationName,
+				append(tt.federOpts, withFederationContextId(testFederationContextId))...)
+			cl, opgcmap, mockedOpgAPI, sch := prepareEnv(
+				[]client.Object{feder, makeTestAppInst(testFederationContextId, appInstWithFinalizer())},
+				&ApiObjects{Federations: []*v1beta1.Federation{feder}},
+			)
+			r := makeTestAppInstReconciler(cl, sch, opgcmap)
+
+			_, err := r.Reconcile(ctx, req)
+			require.NoError(t, err)
+
+			var got v1beta1.ApplicationInstance
+			require.NoError(t, cl.Get(ctx, req.NamespacedName, &got))
+			if tt.wantInstalled {
+				assert.Contains(t, mockedOpgAPI.AppInsts, testAppInstExternalId)
+				assert.NotEmpty(t, got.Status.State)
+			} else {
+				assert.NotContains(t, mockedOpgAPI.AppInsts, testAppInstExternalId)
+				assert.Empty(t, got.Status.State)
+			}
+			degraded := meta.FindStatusCondition(got.Status.Conditions, v1beta1.ApplicationInstanceConditionDegraded)
+			if tt.wantDegraded == "" {
+				assert.Nil(t, degraded)
+				return
+			}
+			require.NotNil(t, degraded)
+			assert.Equal(t, tt.wantDegraded, degraded.Status)
+		})
+	}
+}
+
+func TestApplicationInstanceReconciler_Retry(t *testing.T) {
+	feder := makeTestFederation(testFederationName, withFederationContextId(testFederationContextId))
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: testAppInstName, Namespace: testNamespace}}
+
+	tests := []struct {
+		name           string
+		instanceId     string
+		partnerState   v1beta1.ApplicationInstanceState
+		wantState      v1beta1.ApplicationInstanceState
+		wantInstanceId string
+	}{
+		{
+			name:           "A retried Guest ApplicationInstance the federation partner never accepted is installed again",
+			wantState:      v1beta1.ApplicationInstanceStatePending,
+			wantInstanceId: testAppInstName,
+		},
+		{
+			name:           "A retried Guest ApplicationInstance failed by the federation partner is polled again",
+			instanceId:     testAppInstName,
+			partnerState:   v1beta1.ApplicationInstanceStateReady,
+			wantState:      v1beta1.ApplicationInstanceStateReady,
+			wantInstanceId: testAppInstName,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.TODO()
+			inst := makeTestAppInst(testFederationContextId, appInstWithFinalizer(),
+				appInstWithState(v1beta1.ApplicationInstanceStateFailed), appInstWithInstanceId(tt.instanceId),
+				appInstWithAnnotation(v1beta1.RetryAnnotation, ""))
+			apiObjs := &ApiObjects{Federations: []*v1beta1.Federation{feder}}
+			if tt.partnerState != "" {
+				apiObjs.AppInsts = []*v1beta1.ApplicationInstance{
+					makeTestAppInst(testFederationContextId, appInstWithState(tt.partnerState)),
+				}
+			}
+			cl, opgcmap, mockedOpgAPI, sch := prepareEnv([]client.Object{feder, inst}, apiObjs)
+			r := makeTestAppInstReconciler(cl, sch, opgcmap)
+			r.ResyncPeriod = time.Minute
+
+			var got v1beta1.ApplicationInstance
+			for range 2 {
+				_, err := r.Reconcile(ctx, req)
+				require.NoError(t, err)
+				require.NoError(t, cl.Get(ctx, req.NamespacedName, &got))
+			}
+			assert.NotContains(t, got.Annotations, v1beta1.RetryAnnotation)
+			assert.Contains(t, mockedOpgAPI.AppInsts, testAppInstExternalId)
+			assert.Equal(t, tt.wantState, got.Status.State)
+			assert.Equal(t, tt.wantInstanceId, got.Status.AppInstanceId)
+			assert.Nil(t, meta.FindStatusCondition(got.Status.Conditions, v1beta1.ConditionStalled))
+		})
+	}
+}
+
 type appInstOpt func(*v1beta1.ApplicationInstance)
 
 func appInstWithDeletedAt(now time.Time) appInstOpt {
@@ -228,6 +438,18 @@ func appInstWithState(state v1beta1.ApplicationInstanceState) appInstOpt {
 	}
 }
 
+func appInstWithInstanceId(id string) appInstOpt {
+	return func(a *v1beta1.ApplicationInstance) {
+		a.Status.AppInstanceId = id
+	}
+}
+
+func appInstWithAnnotation(key, value string) appInstOpt {
+	return func(a *v1beta1.ApplicationInstance) {
+		metav1.SetMetaDataAnnotation(&a.ObjectMeta, key, value)
+	}
+}
+
 func makeTestAppInst(fedCtxId string, opts ...appInstOpt) *v1beta1.ApplicationInstance {
 	a := &v1beta1.ApplicationInstance{
 		ObjectMeta: metav1.ObjectMeta{
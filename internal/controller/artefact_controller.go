@@ -18,10 +18,13 @@ package controller
 
 import (
 	"context"
-	"errors"
+	"net/http"
+	"time"
 
 	opgmodels "github.com/neonephos-katalis/opg-ewbi-operator/api/ewbi/models"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -39,6 +42,9 @@ type ArtefactReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
 	opg.OPGClientsMapInterface
+	// ResyncPeriod, interval between the polls of the partner OP for the state of
+	// the guest Artefacts until it is final, 0 disables polling
+	ResyncPeriod time.Duration
 }
 
 // +kubebuilder:rbac:groups=opg.ewbi.nby.one,resources=artefacts,verbs=*,namespace=foo
@@ -55,6 +61,15 @@ type ArtefactReconciler struct {
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.19.4/pkg/reconcile
 func (r *ArtefactReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	res, err := r.reconcile(ctx, req)
+	if upErr := updateConditions(ctx, r.Client, req.NamespacedName, &v1beta1.Artefact{}, setArtefactConditions); upErr != nil && err == nil {
+		return ctrl.Result{}, upErr
+	}
+	return res, err
+}
+
+// reconcile syncs the Artefact, its conditions are set afterwards from the resulting state
+func (r *ArtefactReconciler) reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	log := log.FromContext(ctx).WithValues("name", req.Name, "namespace", req.Namespace)
 	log.Info("starting reconcile function for artefact")
 	defer log.Info("end reconcile for artefact")
@@ -105,12 +120,7 @@ func (r *ArtefactReconciler) Reconcile(ctx context.Context, req ctrl.Request) (c
 		if isGuest {
 			if err := r.handleExternalArtefactDeletion(ctx, &a, feder); err != nil {
 				log.Error(err, "error deleting Artefact")
-				a.Status.State = v1beta1.ArtefactStateError
-				upErr := r.Status().Update(ctx, a.DeepCopy())
-				if upErr != nil {
-					log.Error(upErr, errorUpdatingResourceStatusMsg)
-				}
-				return ctrl.Result{}, err
+				return opgResult(err)
 			}
 		}
 		// if external Artefact is correctly deleted, we can remove the finalizer
@@ -127,16 +137,19 @@ func (r *ArtefactReconciler) Reconcile(ctx context.Context, req ctrl.Request) (c
 
 	// if federation is guest, send OPG API request
 	if isGuest {
+		if _, ok := a.Annotations[v1beta1.RetryAnnotation]; ok {
+			return ctrl.Result{}, handleRetryAnnotation(ctx, r.Client, &a, a.Status.State == v1beta1.ArtefactStateError, func() {
+				a.Status = v1beta1.ArtefactStatus{Conditions: a.Status.Conditions}
+				meta.RemoveStatusCondition(&a.Status.Conditions, v1beta1.ConditionStalled)
+			})
+		}
 		if a.Status.State == "" {
 			if err := r.handleExternalArtefactCreation(ctx, &a, feder); err != nil {
-				log.Info("error creating Artefact")
-				a.Status.State = v1beta1.ArtefactStateError
-				upErr := r.Status().Update(ctx, a.DeepCopy())
-				if upErr != nil {
-					log.Error(upErr, errorUpdatingResourceStatusMsg)
-				}
-				return ctrl.Result{}, nil
+				log.Error(err, "error creating Artefact")
+				return opgResult(err)
 			}
+		} else if r.ResyncPeriod > 0 && !artefactStateFinal(a.Status.State) {
+			return r.resyncExternalArtefact(ctx, &a, feder)
 		} else {
 			log.Info("+++++++++++++++++++ Artefact status is ", "state", a.Status.State)
 		}
@@ -152,18 +165,70 @@ func (r *ArtefactReconciler) Reconcile(ctx context.Context, req ctrl.Request) (c
 		} else {
 			log.Info("New CR state", "state", a.Status.State)
 			if err := r.handleExternalArtefactCallback(ctx, &a, feder); err != nil {
-				log.Error(err, "error handling artefact callback")
-				a.Status.State = v1beta1.ArtefactStateError
-				upErr := r.Status().Update(ctx, a.DeepCopy())
-				if upErr != nil {
-					log.Error(upErr, errorUpdatingResourceStatusMsg)
-				}
+				log.Error(err, "error queueing artefact callback")
+				return ctrl.Result{}, err
 			}
 		}
 	}
 	return ctrl.Result{}, nil
 }
 
+// resyncExternalArtefact polls the partner OP for the Artefact, in case its callback was lost.
+// The partner doesn't report the state of artefacts, an Artefact it no longer holds is set in error.
+func (r *ArtefactReconciler) resyncExternalArtefact(
+	ctx context.Context, a *v1beta1.Artefact, feder *v1beta1.Federation,
+) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	now := metav1.Now()
+	if wait := resyncDelay(r.ResyncPeriod, a.Status.LastSyncTime, now.Time); wait > 0 {
+		return ctrl.Result{RequeueAfter: wait}, nil
+	}
+
+	res, err := r.GetOPGClient(
+		feder.Labels[v1beta1.ExternalIdLabel],
+		guestPartnerApiRoot(feder),
+		opgCredentials(feder, feder.Spec.GuestPartnerCredentials),
+	).GetArtefactWithResponse(
+		ctx,
+		feder.Status.FederationContextId,
+		a.Labels[v1beta1.ExternalIdLabel],
+	)
+	out := opg.Classify(res, err)
+	logOPGResponse(log, out)
+	switch {
+	case out.Outcome == opg.OutcomeSuccess:
+		log.Info("External artefact still held by the partner OP", "state", a.Status.State)
+	case out.StatusCode == http.StatusNotFound:
+		a.Status.State = v1beta1.ArtefactStateError
+	}
+	a.Status.LastSyncTime = &now
+	setPartnerConditions(&a.Status.Conditions, a.Generation, out)
+	if upErr := r.Status().Update(ctx, a); upErr != nil {
+		log.Error(upErr, errorUpdatingResourceStatusMsg)
+		return ctrl.Result{}, upErr
+	}
+	return resyncResult(r.ResyncPeriod, artefactStateFinal(a.Status.State)), nil
+}
+
+// artefactStateFinal returns whether the Artefact no longer changes without a callback
+func artefactStateFinal(state v1beta1.ArtefactState) bool {
+	return state == v1beta1.ArtefactStateReady || state == v1beta1.ArtefactStateError
+}
+
+// setArtefactConditions sets the conditions and observedGeneration of the Artefact from its state
+func setArtefactConditions(a *v1beta1.Artefact) bool {
+	phase := syncInProgress
+	switch a.Status.State {
+	case v1beta1.ArtefactStateReady:
+		phase = syncCompleted
+	case v1beta1.ArtefactStateError:
+		phase = syncFailed
+	}
+	changed := a.Status.ObservedGeneration != a.Generation
+	a.Status.ObservedGeneration = a.Generation
+	return setSyncConditions(&a.Status.Conditions, a.Generation, phase, string(a.Status.State)) || changed
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *ArtefactReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
@@ -226,61 +291,35 @@ func (r *ArtefactReconciler) handleExternalArtefactCreation(
 
 	res, err := r.GetOPGClient(
 		feder.Labels[v1beta1.ExternalIdLabel],
-		feder.Spec.GuestPartnerCredentials.TokenUrl,
-		feder.Spec.GuestPartnerCredentials.ClientId,
+		guestPartnerApiRoot(feder),
+		opgCredentials(feder, feder.Spec.GuestPartnerCredentials),
 	).UploadArtefactWithBodyWithResponse(
-		context.TODO(),
+		ctx,
 		feder.Status.FederationContextId,
 		contentType,
 		body)
-
-	if err != nil {
-		log.Error(err, "error creating Artefact")
-		return err
+	out := opg.Classify(res, err)
+	// this should be deleted when API returns a 400 for this case
+	if out.StatusCode == http.StatusInternalServerError && out.Detail() == "file not found" {
+		out.Outcome = opg.OutcomePermanent
 	}
-
-	statusCode := res.StatusCode()
-
-	switch {
-	case statusCode >= 200 && statusCode < 300:
-		log.Info("ARTEFACTS - Status code 2xx received from OPG API", "status", statusCode)
+	logOPGResponse(log, out)
+	switch out.Outcome {
+	case opg.OutcomeSuccess:
 		a.Status.State = v1beta1.ArtefactStateReconciling
+		now := metav1.Now()
+		a.Status.LastSyncTime = &now
 		log.Info("Created/Updated external artefact", "state", a.Status.State)
-	case statusCode == 400:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON400)
-		log.Info("Couldn't be created", "Detail", res.ApplicationproblemJSON400.Detail)
-		return errors.New(*res.ApplicationproblemJSON400.Detail)
-	case statusCode == 401:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON401)
-		a.Status.State = v1beta1.ArtefactStateError
-	case statusCode == 404:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON404)
-		a.Status.State = v1beta1.ArtefactStateError
-	case statusCode == 409:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON409)
+	case opg.OutcomePermanent:
 		a.Status.State = v1beta1.ArtefactStateError
-	case statusCode == 422:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON422)
-		a.Status.State = v1beta1.ArtefactStateError
-	case statusCode == 500:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON500)
-		// this should be deleted when API returns a 400 for this case
-		if *res.ApplicationproblemJSON500.Detail == "file not found" {
-			return errors.New(*res.ApplicationproblemJSON500.Detail)
-		}
-	case statusCode == 503:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON503)
-	case statusCode == 520:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON520)
-	default:
-		a.Status.State = v1beta1.ArtefactStateReconciling
 	}
-	upErr := r.Status().Update(ctx, a)
-	if upErr != nil {
+	recordAttempt(&a.Status.Attempts, &a.Status.LastError, out)
+	setPartnerConditions(&a.Status.Conditions, a.Generation, out)
+	if upErr := r.Status().Update(ctx, a); upErr != nil {
 		log.Error(upErr, errorUpdatingResourceStatusMsg)
 		return upErr
 	}
-	return nil
+	return out.Err()
 }
 
 func (r *ArtefactReconciler) handleExternalArtefactCallback(
@@ -292,7 +331,7 @@ func (r *ArtefactReconciler) handleExternalArtefactCallback(
 		log.Info("No callback StatusLink configured in Federation, skipping App callback")
 		return nil
 	}
-	log.Info("Sending App callback to Guest",
+	log.Info("Queueing App callback to Guest",
 		"appId", a.Labels[v1beta1.ExternalIdLabel],
 		"state", a.Status.State,
 		"statusLink", feder.Spec.Partner.StatusLink)
@@ -300,43 +339,7 @@ func (r *ArtefactReconciler) handleExternalArtefactCallback(
 		ArtefactId:   a.Labels[v1beta1.ExternalIdLabel],
 		UpdateStatus: opgmodels.ArtefactStatusCallbackLinkJSONBodyUpdateStatus(a.Status.State),
 	}
-	// Get callback client (pointing to Guest's callback URL via Federation.spec.partner.statusLink)
-	res, err := r.GetOPGClient(
-		feder.Labels[v1beta1.ExternalIdLabel],
-		feder.Spec.Partner.StatusLink,
-		feder.Spec.Partner.CallbackCredentials.ClientId,
-	).ArtefactStatusCallbackLinkWithResponse(
-		context.TODO(),
-		feder.Spec.Partner.CallbackCredentials.ClientId,
-		callbackBody)
-
-	if err != nil {
-		log.Error(err, "error sending App callback")
-		return err
-	}
-	statusCode := res.StatusCode()
-	switch {
-	case statusCode >= 200 && statusCode < 300:
-		log.Info("****************** Successfully sent Artefact callback to Guest", "status", statusCode)
-	case statusCode == 400:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON400)
-		a.Status.State = v1beta1.ArtefactStateError
-	case statusCode == 401:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON401)
-		a.Status.State = v1beta1.ArtefactStateError
-	case statusCode == 404:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON404)
-		a.Status.State = v1beta1.ArtefactStateError
-	default:
-		log.Info("############# Artefact callback returned unexpected status", "status", statusCode, "body", string(res.Body))
-		a.Status.State = v1beta1.ArtefactStateReconciling
-	}
-	upErr := r.Status().Update(ctx, a)
-	if upErr != nil {
-		log.Error(upErr, errorUpdatingResourceStatusMsg)
-		return upErr
-	}
-	return nil
+	return enqueueCallback(ctx, r.Client, feder, a, v1beta1.CallbackKindArtefact, callbackBody)
 }
 
 func (r *ArtefactReconciler) handleExternalArtefactDeletion(
@@ -344,61 +347,30 @@ func (r *ArtefactReconciler) handleExternalArtefactDeletion(
 ) error {
 	log := log.FromContext(ctx)
 	log.Info("Deleting external Artefact")
-	// we should delete the Artefact
+	// we should delete the Artefact, an Artefact the partner OP doesn't know about is already deleted
 	res, err := r.GetOPGClient(
 		feder.Labels[v1beta1.ExternalIdLabel],
-		feder.Spec.GuestPartnerCredentials.TokenUrl,
-		feder.Spec.GuestPartnerCredentials.ClientId,
+		guestPartnerApiRoot(feder),
+		opgCredentials(feder, feder.Spec.GuestPartnerCredentials),
 	).RemoveArtefactWithResponse(
-		context.TODO(),
+		ctx,
 		feder.Status.FederationContextId,
 		a.Labels[v1beta1.ExternalIdLabel],
 	)
-	if err != nil {
-		log.Error(err, "error deleting artefact")
-		a.Status.State = v1beta1.ArtefactStateError
-		return err
-	}
-
-	statusCode := res.StatusCode()
-
-	switch {
-	case statusCode >= 200 && statusCode < 300:
+	out := opg.Classify(res, err, opg.WithOutcome(http.StatusNotFound, opg.OutcomeSuccess))
+	logOPGResponse(log, out)
+	if out.Outcome == opg.OutcomeSuccess {
 		log.Info("Deleted")
-		// federResponse.OfferedAvailabilityZones
-		a.Status.State = v1beta1.ArtefactStateReady
-	case statusCode == 400:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON400)
-		a.Status.State = v1beta1.ArtefactStateError
-	case statusCode == 401:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON401)
-		a.Status.State = v1beta1.ArtefactStateError
-	case statusCode == 404:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON404)
-		a.Status.State = v1beta1.ArtefactStateError
-	case statusCode == 409:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON409)
-		a.Status.State = v1beta1.ArtefactStateError
-	case statusCode == 422:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON422)
-		a.Status.State = v1beta1.ArtefactStateError
-	case statusCode == 500:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON500)
-		a.Status.State = v1beta1.ArtefactStateError
-	case statusCode == 503:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON503)
-		a.Status.State = v1beta1.ArtefactStateError
-	case statusCode == 520:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON520)
+		return nil
+	}
+	if out.Outcome == opg.OutcomePermanent {
 		a.Status.State = v1beta1.ArtefactStateError
-	default:
-		log.Info(unexpectedStatusCodeMsg, "status", statusCode, "body", string(res.Body))
-		a.Status.State = v1beta1.ArtefactStateReconciling
 	}
-	upErr := r.Status().Update(ctx, a)
-	if upErr != nil {
+	recordAttempt(&a.Status.Attempts, &a.Status.LastError, out)
+	setPartnerConditions(&a.Status.Conditions, a.Generation, out)
+	if upErr := r.Status().Update(ctx, a); upErr != nil {
 		log.Error(upErr, errorUpdatingResourceStatusMsg)
 		return upErr
 	}
-	return nil
+	return out.Err()
 }
@@ -206,6 +206,54 @@ func TestArtefactReconciler(t *testing.T) {
 	}
 }
 
+func TestArtefactReconciler_Resync(t *testing.T) {
+	feder := makeTestFederation(testFederationName, withFederationContextId(testFederationContextId),
+		federationWithFederationRelation(v1beta1.FederationRelationGuest),
+	)
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: testArtefactName, Namespace: testNamespace}}
+	artefact := makeTestArtefact(testFederationContextId, artefactWithFinalizer(),
+		artefactWithState(v1beta1.ArtefactStateReconciling))
+
+	tests := []struct {
+		name             string
+		partnerArtefacts []*v1beta1.Artefact
+		wantResult       ctrl.Result
+		wantState        v1beta1.ArtefactState
+	}{
+		{
+			name:             "A pending Guest Artefact held by the federation partner is polled again",
+			partnerArtefacts: []*v1beta1.Artefact{artefact},
+			wantResult:       ctrl.Result{RequeueAfter: time.Minute},
+			wantState:        v1beta1.ArtefactStateReconciling,
+		},
+		{
+			name:      "A pending Guest Artefact unknown to the federation partner is set in error",
+			wantState: v1beta1.ArtefactStateError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.TODO()
+			cl, opgcmap, _, sch := prepareEnv([]client.Object{feder, makeTestFile(testFederationContextId), artefact.DeepCopy()},
+				&ApiObjects{
+					Federations: []*v1beta1.Federation{feder},
+					Artefacts:   tt.partnerArtefacts,
+				})
+			r := makeTestArtefactReconciler(cl, sch, opgcmap)
+			r.ResyncPeriod = time.Minute
+
+			gotResult, err := r.Reconcile(ctx, req)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantResult, gotResult)
+
+			var got v1beta1.Artefact
+			require.NoError(t, cl.Get(ctx, req.NamespacedName, &got))
+			assert.Equal(t, tt.wantState, got.Status.State)
+			require.NotNil(t, got.Status.LastSyncTime)
+		})
+	}
+}
+
 type artefactOpt func(*opgewbiv1beta1.Artefact)
 
 func artefactWithFinalizer() artefactOpt {
@@ -50,7 +50,16 @@ type AvailabilityZoneReconciler struct {
 //
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.19.4/pkg/reconcile
-func (r *AvailabilityZoneReconciler) Reconcile(
+func (r *AvailabilityZoneReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	res, err := r.reconcile(ctx, req)
+	if upErr := updateConditions(ctx, r.Client, req.NamespacedName, &v1beta1.AvailabilityZone{}, setAvailabilityZoneConditions); upErr != nil && err == nil {
+		return ctrl.Result{}, upErr
+	}
+	return res, err
+}
+
+// reconcile syncs the AvailabilityZone, its conditions are set afterwards from the resulting state
+func (r *AvailabilityZoneReconciler) reconcile(
 	ctx context.Context,
 	req ctrl.Request,
 ) (ctrl.Result, error) {
@@ -78,6 +87,20 @@ func (r *AvailabilityZoneReconciler) Reconcile(
 	return ctrl.Result{}, nil
 }
 
+// setAvailabilityZoneConditions sets the conditions and observedGeneration of the AvailabilityZone from its state
+func setAvailabilityZoneConditions(az *v1beta1.AvailabilityZone) bool {
+	phase := syncInProgress
+	switch az.Status.State {
+	case v1beta1.ZoneStateReady:
+		phase = syncCompleted
+	case v1beta1.ZoneStateError:
+		phase = syncFailed
+	}
+	changed := az.Status.ObservedGeneration != az.Generation
+	az.Status.ObservedGeneration = az.Generation
+	return setSyncConditions(&az.Status.Conditions, az.Generation, phase, string(az.Status.State)) || changed
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *AvailabilityZoneReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
@@ -25,6 +25,7 @@ import (
 	"github.com/neonephos-katalis/opg-ewbi-operator/internal/opg"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
@@ -105,6 +106,7 @@ func TestAvailabilityZoneReconciler(t *testing.T) {
 
 			require.NoError(t, err)
 			assert.Equal(t, tt.resp.wantStatusState, reqAvailabilityZone.Status.State)
+			assert.True(t, meta.IsStatusConditionTrue(reqAvailabilityZone.Status.Conditions, v1beta1.ConditionReady))
 
 		})
 	}
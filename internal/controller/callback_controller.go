@@ -0,0 +1,404 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"time"
+
+	opgmodels "github.com/neonephos-katalis/opg-ewbi-operator/api/ewbi/models"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/neonephos-katalis/opg-ewbi-operator/api/operator/v1beta1"
+	"github.com/neonephos-katalis/opg-ewbi-operator/internal/opg"
+)
+
+const (
+	DefaultCallbackInitialBackoff = 5 * time.Second
+	DefaultCallbackMaxBackoff     = 5 * time.Minute
+	DefaultCallbackMaxAge         = time.Hour
+	DefaultCallbackRetention      = 24 * time.Hour
+
+	// maxCallbackErrorLength bounds the partner response kept in the callback status
+	maxCallbackErrorLength = 256
+)
+
+var errInvalidCallbackPayload = errors.New("invalid callback payload")
+
+// CallbackReconciler delivers the Callback objects queued by the host
+// reconcilers to the guest OP
+type CallbackReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+	opg.OPGClientsMapInterface
+
+	// InitialBackoff, delay before retrying a failed delivery, doubled on every attempt up to MaxBackoff
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// MaxAge, callbacks not delivered MaxAge after being queued expire
+	MaxAge time.Duration
+	// Retention, delivered, failed and expired callbacks are deleted after Retention
+	Retention time.Duration
+}
+
+// +kubebuilder:rbac:groups=opg.ewbi.nby.one,resources=callbacks,verbs=*,namespace=foo
+// +kubebuilder:rbac:groups=opg.ewbi.nby.one,resources=callbacks/status,verbs=get;update;patch,namespace=foo
+
+// Reconcile is part of the main kubernetes reconciliation loop which aims to
+// move the current state of the cluster closer to the desired state.
+// The pending callbacks of the Federation are delivered in sequence order, a
+// callback is retried with exponential backoff until it is delivered, rejected
+// by the partner or expires, blocking the ones queued after it.
+//
+// For more details, check Reconcile and its Result here:
+// - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.19.4/pkg/reconcile
+func (r *CallbackReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx).WithValues("name", req.Name, "namespace", req.Namespace)
+
+	var cb v1beta1.Callback
+	if err := r.Get(ctx, req.NamespacedName, &cb); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "error getting callback object")
+		return ctrl.Result{}, err
+	}
+
+	if callbackCompleted(&cb) {
+		left := cb.Status.CompletionTime.Add(r.Retention).Sub(time.Now())
+		if left > 0 {
+			return ctrl.Result{RequeueAfter: left}, nil
+		}
+		log.Info("Deleting completed callback", "state", cb.Status.State)
+		if err := r.Delete(ctx, &cb); err != nil && !apierrors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+	return r.deliverFederationCallbacks(ctx, cb.Namespace, cb.Spec.FederationName)
+}
+
+// deliverFederationCallbacks sends the pending callbacks of the Federation in
+// sequence order, until one has to be retried
+func (r *CallbackReconciler) deliverFederationCallbacks(
+	ctx context.Context, namespace, federationName string,
+) (ctrl.Result, error) {
+	log := log.FromContext(ctx).WithValues("federation", federationName)
+
+	var list v1beta1.CallbackList
+	if err := r.List(ctx, &list, client.InNamespace(namespace),
+		client.MatchingLabels{v1beta1.CallbackFederationLabel: federationName}); err != nil {
+		log.Error(err, "error listing callback objects")
+		return ctrl.Result{}, err
+	}
+	pending := pendingCallbacks(list.Items)
+	if len(pending) == 0 {
+		return ctrl.Result{}, nil
+	}
+
+	var feder v1beta1.Federation
+	if err := r.Get(ctx, client.ObjectKey{Namespace: namespace, Name: federationName}, &feder); err != nil {
+		if !apierrors.IsNotFound(err) {
+			log.Error(err, "error getting federation object")
+			return ctrl.Result{}, err
+		}
+		feder = v1beta1.Federation{}
+	}
+
+	for i := range pending {
+		cb := &pending[i]
+		now := metav1.Now()
+		switch {
+		case now.Sub(cb.CreationTimestamp.Time) > r.MaxAge:
+			log.Info("Callback expired", "callback", cb.Name, "attempts", cb.Status.Attempts)
+			completeCallback(&cb.Status, v1beta1.CallbackStateExpired, now)
+		case feder.Name == "" || feder.Spec.Partner.StatusLink == "":
+			// nothing to deliver the callback to, it would never be delivered
+			completeCallback(&cb.Status, v1beta1.CallbackStateFailed, now)
+			cb.Status.LastError = "no callback StatusLink configured in Federation"
+		case cb.Status.NextAttemptTime != nil && now.Before(cb.Status.NextAttemptTime):
+			return ctrl.Result{RequeueAfter: cb.Status.NextAttemptTime.Sub(now.Time)}, nil
+		default:
+			code, body, err := r.sendCallback(ctx, &feder, cb)
+			recordCallbackAttempt(&cb.Status, code, body, err, now, r.backoff)
+			log.Info("Sent callback to Guest",
+				"callback", cb.Name, "kind", cb.Spec.Kind, "status", code,
+				"state", cb.Status.State, "attempts", cb.Status.Attempts)
+		}
+		var retryAfter time.Duration
+		if cb.Status.State == v1beta1.CallbackStatePending {
+			// taken before the update, the stored time is truncated to seconds
+			retryAfter = cb.Status.NextAttemptTime.Sub(now.Time)
+		}
+		setCallbackConditions(cb)
+		if err := r.Status().Update(ctx, cb); err != nil {
+			log.Error(err, errorUpdatingResourceStatusMsg)
+			return ctrl.Result{}, err
+		}
+		if cb.Status.State == v1beta1.CallbackStatePending {
+			return ctrl.Result{RequeueAfter: retryAfter}, nil
+		}
+	}
+	return ctrl.Result{}, nil
+}
+
+// setCallbackConditions sets the conditions and observedGeneration of the Callback from its state
+func setCallbackConditions(cb *v1beta1.Callback) bool {
+	phase := syncInProgress
+	switch cb.Status.State {
+	case v1beta1.CallbackStateDelivered:
+		phase = syncCompleted
+	case v1beta1.CallbackStateFailed, v1beta1.CallbackStateExpired:
+		phase = syncFailed
+	}
+	changed := cb.Status.ObservedGeneration != cb.Generation
+	cb.Status.ObservedGeneration = cb.Generation
+	return setSyncConditions(&cb.Status.Conditions, cb.Generation, phase, string(cb.Status.State)) || changed
+}
+
+func (r *CallbackReconciler) backoff(attempts int32) time.Duration {
+	return backoffDelay(attempts, r.InitialBackoff, r.MaxBackoff)
+}
+
+// sendCallback sends the callback to the partner statusLink of the Federation,
+// returning the status code and body of the response
+func (r *CallbackReconciler) sendCallback(
+	ctx context.Context, feder *v1beta1.Federation, cb *v1beta1.Callback,
+) (int, []byte, error) {
+	c := r.GetOPGClient(
+		feder.Labels[v1beta1.ExternalIdLabel],
+		feder.Spec.Partner.StatusLink,
+		opgCredentials(feder, feder.Spec.Partner.CallbackCredentials),
+	)
+	clientID := feder.Spec.Partner.CallbackCredentials.ClientId
+	raw := cb.Spec.Payload.Raw
+
+	switch cb.Spec.Kind {
+	case v1beta1.CallbackKindApplication:
+		return sendCallbackPayload(raw, func(b opgmodels.AppStatusCallbackLinkJSONRequestBody) (int, []byte, error) {
+			res, err := c.AppStatusCallbackLinkWithResponse(ctx, clientID, b)
+			if err != nil {
+				return 0, nil, err
+			}
+			return res.StatusCode(), res.Body, nil
+		})
+	case v1beta1.CallbackKindApplicationInstance:
+		return sendCallbackPayload(raw, func(b opgmodels.AppInstCallbackLinkJSONRequestBody) (int, []byte, error) {
+			res, err := c.AppInstCallbackLinkWithResponse(ctx, clientID, b)
+			if err != nil {
+				return 0, nil, err
+			}
+			return res.StatusCode(), res.Body, nil
+		})
+	case v1beta1.CallbackKindArtefact:
+		return sendCallbackPayload(raw, func(b opgmodels.ArtefactStatusCallbackLinkJSONRequestBody) (int, []byte, error) {
+			res, err := c.ArtefactStatusCallbackLinkWithResponse(ctx, clientID, b)
+			if err != nil {
+				return 0, nil, err
+			}
+			return res.StatusCode(), res.Body, nil
+		})
+	case v1beta1.CallbackKindFile:
+		return sendCallbackPayload(raw, func(b opgmodels.FileStatusCallbackLinkJSONRequestBody) (int, []byte, error) {
+			res, err := c.FileStatusCallbackLinkWithResponse(ctx, clientID, b)
+			if err != nil {
+				return 0, nil, err
+			}
+			return res.StatusCode(), res.Body, nil
+		})
+	case v1beta1.CallbackKindResourcePool:
+		return sendCallbackPayload(raw, func(b opgmodels.ResourceReservationCallbackLinkJSONRequestBody) (int, []byte, error) {
+			res, err := c.ResourceReservationCallbackLinkWithResponse(ctx, clientID, b)
+			if err != nil {
+				return 0, nil, err
+			}
+			return res.StatusCode(), res.Body, nil
+		})
+	}
+	return 0, nil, fmt.Errorf("%w: unknown kind '%s'", errInvalidCallbackPayload, cb.Spec.Kind)
+}
+
+func sendCallbackPayload[B any](raw []byte, send func(B) (int, []byte, error)) (int, []byte, error) {
+	var body B
+	if err := json.Unmarshal(raw, &body); err != nil {
+		return 0, nil, fmt.Errorf("%w: %w", errInvalidCallbackPayload, err)
+	}
+	return send(body)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *CallbackReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.InitialBackoff = cmp.Or(r.InitialBackoff, DefaultCallbackInitialBackoff)
+	r.MaxBackoff = cmp.Or(r.MaxBackoff, DefaultCallbackMaxBackoff)
+	r.MaxAge = cmp.Or(r.MaxAge, DefaultCallbackMaxAge)
+	r.Retention = cmp.Or(r.Retention, DefaultCallbackRetention)
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1beta1.Callback{}).
+		Named("callback").
+		Complete(r)
+}
+
+// enqueueCallback persists a callback about source to be delivered through the
+// Federation, unless the last callback queued for source has the same payload
+func enqueueCallback(
+	ctx context.Context, c client.Client, feder *v1beta1.Federation,
+	source client.Object, kind v1beta1.CallbackKind, body any,
+) error {
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	var list v1beta1.CallbackList
+	if err := c.List(ctx, &list, client.InNamespace(feder.Namespace),
+		client.MatchingLabels{v1beta1.CallbackSourceLabel: string(source.GetUID())}); err != nil {
+		return err
+	}
+	if last := lastCallback(list.Items); last != nil && sameCallbackPayload(last.Spec.Payload.Raw, raw) {
+		return nil
+	}
+
+	cb := &v1beta1.Callback{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: source.GetName() + "-",
+			Namespace:    feder.Namespace,
+			Labels: map[string]string{
+				v1beta1.CallbackFederationLabel:  feder.Name,
+				v1beta1.CallbackSourceLabel:      string(source.GetUID()),
+				v1beta1.FederationContextIdLabel: source.GetLabels()[v1beta1.FederationContextIdLabel],
+				v1beta1.FederationRelationLabel:  string(v1beta1.FederationRelationHost),
+			},
+		},
+		Spec: v1beta1.CallbackSpec{
+			FederationName: feder.Name,
+			Sequence:       time.Now().UnixNano(),
+			Kind:           kind,
+			SourceName:     source.GetName(),
+			SourceUID:      source.GetUID(),
+			Payload:        runtime.RawExtension{Raw: raw},
+		},
+	}
+	// the callbacks are garbage collected with the resource they notify about
+	if err := controllerutil.SetOwnerReference(source, cb, c.Scheme()); err != nil {
+		return err
+	}
+	if err := c.Create(ctx, cb); err != nil {
+		return err
+	}
+	log.FromContext(ctx).Info("Queued callback to Guest", "callback", cb.Name, "kind", kind)
+	return nil
+}
+
+// pendingCallbacks returns the callbacks not yet completed, in delivery order
+func pendingCallbacks(items []v1beta1.Callback) []v1beta1.Callback {
+	pending := slices.DeleteFunc(slices.Clone(items), func(cb v1beta1.Callback) bool {
+		return callbackCompleted(&cb)
+	})
+	slices.SortFunc(pending, compareCallbacks)
+	return pending
+}
+
+// lastCallback returns the callback queued last, nil if there is none
+func lastCallback(items []v1beta1.Callback) *v1beta1.Callback {
+	if len(items) == 0 {
+		return nil
+	}
+	last := slices.MaxFunc(items, compareCallbacks)
+	return &last
+}
+
+func compareCallbacks(a, b v1beta1.Callback) int {
+	return cmp.Or(cmp.Compare(a.Spec.Sequence, b.Spec.Sequence), cmp.Compare(a.Name, b.Name))
+}
+
+func callbackCompleted(cb *v1beta1.Callback) bool {
+	return cb.Status.State != "" && cb.Status.State != v1beta1.CallbackStatePending
+}
+
+// sameCallbackPayload compares the JSON payloads, the API server doesn't keep
+// the order of the fields
+func sameCallbackPayload(a, b []byte) bool {
+	var va, vb any
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+// recordCallbackAttempt records the outcome of a delivery attempt, the callback
+// is retried after backoff unless it was delivered or the partner rejected it
+func recordCallbackAttempt(
+	status *v1beta1.CallbackStatus, code int, body []byte, err error,
+	now metav1.Time, backoff func(attempts int32) time.Duration,
+) {
+	status.Attempts++
+	status.LastAttemptTime = &now
+	status.LastStatusCode = int32(code)
+	status.LastError = ""
+	status.NextAttemptTime = nil
+
+	switch {
+	case errors.Is(err, errInvalidCallbackPayload):
+		status.LastError = err.Error()
+		completeCallback(status, v1beta1.CallbackStateFailed, now)
+		return
+	case err != nil:
+		status.LastError = err.Error()
+	case code >= 200 && code < 300:
+		completeCallback(status, v1beta1.CallbackStateDelivered, now)
+		return
+	case opg.StatusOutcome(code) == opg.OutcomePermanent:
+		status.LastError = callbackResponseError(code, body)
+		completeCallback(status, v1beta1.CallbackStateFailed, now)
+		return
+	default:
+		// auth errors are retried too, the credentials may be fixed before the callback expires
+		status.LastError = callbackResponseError(code, body)
+	}
+	status.State = v1beta1.CallbackStatePending
+	next := metav1.NewTime(now.Add(backoff(status.Attempts)))
+	status.NextAttemptTime = &next
+}
+
+func completeCallback(status *v1beta1.CallbackStatus, state v1beta1.CallbackState, now metav1.Time) {
+	status.State = state
+	status.NextAttemptTime = nil
+	status.CompletionTime = &now
+}
+
+func callbackResponseError(code int, body []byte) string {
+	msg := fmt.Sprintf("callback returned status %d", code)
+	if len(body) == 0 {
+		return msg
+	}
+	if len(body) > maxCallbackErrorLength {
+		body = body[:maxCallbackErrorLength]
+	}
+	return msg + ": " + string(body)
+}
@@ -0,0 +1,158 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	opgmodels "github.com/neonephos-katalis/opg-ewbi-operator/api/ewbi/models"
+	"github.com/neonephos-katalis/opg-ewbi-operator/api/operator/v1beta1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestCallbackReconciler(t *testing.T) {
+	feder := makeTestFederation(testFederationName,
+		federationWithFederationRelation(v1beta1.FederationRelationHost),
+	)
+	first := makeTestCallback("file001-a", 1, opgmodels.FileStatusCallbackLinkJSONRequestBody{
+		FileId: "file001", UpdateStatus: "PENDING",
+	})
+	second := makeTestCallback("file001-b", 2, opgmodels.FileStatusCallbackLinkJSONRequestBody{
+		FileId: "file001", UpdateStatus: "READY",
+	})
+	cl, opgClients, mockedOpgAPI, sch := prepareEnv(
+		[]client.Object{feder, second, first}, &ApiObjects{},
+	)
+	mockedOpgAPI.CallbackStatusCodes = []int{503}
+	r := &CallbackReconciler{
+		Client:                 cl,
+		Scheme:                 sch,
+		OPGClientsMapInterface: opgClients,
+		InitialBackoff:         time.Millisecond,
+		MaxBackoff:             time.Second,
+		MaxAge:                 time.Hour,
+		Retention:              time.Hour,
+	}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: second.Name, Namespace: testNamespace}}
+
+	// the first callback is retried, blocking the second one
+	res, err := r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	assert.Positive(t, res.RequeueAfter)
+	got := getTestCallback(t, cl, first.Name)
+	assert.Equal(t, v1beta1.CallbackStatePending, got.Status.State)
+	assert.Equal(t, int32(1), got.Status.Attempts)
+	assert.Equal(t, int32(503), got.Status.LastStatusCode)
+	assert.NotNil(t, got.Status.NextAttemptTime)
+	assert.Empty(t, getTestCallback(t, cl, second.Name).Status.State)
+
+	time.Sleep(2 * time.Millisecond)
+	_, err = r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	for _, name := range []string{first.Name, second.Name} {
+		got := getTestCallback(t, cl, name)
+		assert.Equal(t, v1beta1.CallbackStateDelivered, got.Status.State, name)
+		assert.NotNil(t, got.Status.CompletionTime, name)
+	}
+	require.Len(t, mockedOpgAPI.FileCallbacks, 3)
+	assert.Equal(t, "PENDING", string(mockedOpgAPI.FileCallbacks[1].UpdateStatus))
+	assert.Equal(t, "READY", string(mockedOpgAPI.FileCallbacks[2].UpdateStatus))
+}
+
+func Test_recordCallbackAttempt(t *testing.T) {
+	now := metav1.NewTime(time.Date(2025, 1, 10, 9, 0, 0, 0, time.UTC))
+	backoff := func(attempts int32) time.Duration { return time.Duration(attempts) * time.Minute }
+	tests := []struct {
+		name      string
+		code      int
+		err       error
+		wantState v1beta1.CallbackState
+		wantNext  bool
+		wantError string
+	}{
+		{name: "delivered", code: 204, wantState: v1beta1.CallbackStateDelivered},
+		{name: "rejected by the partner", code: 400, wantState: v1beta1.CallbackStateFailed,
+			wantError: "callback returned status 400: problem"},
+		{name: "partner unavailable", code: 503, wantState: v1beta1.CallbackStatePending, wantNext: true,
+			wantError: "callback returned status 503: problem"},
+		{name: "unauthorized is retried", code: 401, wantState: v1beta1.CallbackStatePending, wantNext: true,
+			wantError: "callback returned status 401: problem"},
+		{name: "connection error", err: errors.New("connection refused"),
+			wantState: v1beta1.CallbackStatePending, wantNext: true, wantError: "connection refused"},
+		{name: "invalid payload", err: errInvalidCallbackPayload,
+			wantState: v1beta1.CallbackStateFailed, wantError: "invalid callback payload"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := v1beta1.CallbackStatus{State: v1beta1.CallbackStatePending, Attempts: 1}
+			recordCallbackAttempt(&status, tt.code, []byte("problem"), tt.err, now, backoff)
+			assert.Equal(t, tt.wantState, status.State)
+			assert.Equal(t, int32(2), status.Attempts)
+			assert.Equal(t, tt.wantError, status.LastError)
+			if tt.wantNext {
+				assert.Equal(t, now.Add(2*time.Minute), status.NextAttemptTime.Time)
+				assert.Nil(t, status.CompletionTime)
+			} else {
+				assert.Nil(t, status.NextAttemptTime)
+				assert.Equal(t, &now, status.CompletionTime)
+			}
+		})
+	}
+}
+
+func Test_pendingCallbacks(t *testing.T) {
+	delivered := makeTestCallback("c", 1, nil)
+	delivered.Status.State = v1beta1.CallbackStateDelivered
+	items := []v1beta1.Callback{
+		*makeTestCallback("b", 3, nil), *delivered, *makeTestCallback("a", 3, nil), *makeTestCallback("d", 2, nil),
+	}
+	var names []string
+	for _, cb := range pendingCallbacks(items) {
+		names = append(names, cb.Name)
+	}
+	assert.Equal(t, []string{"d", "a", "b"}, names)
+	assert.Equal(t, "b", lastCallback(items).Name)
+	assert.Nil(t, lastCallback(nil))
+}
+
+func Test_sameCallbackPayload(t *testing.T) {
+	assert.True(t, sameCallbackPayload([]byte(`{"fileId":"f","updateStatus":"READY"}`),
+		[]byte(`{"updateStatus":"READY","fileId":"f"}`)))
+	assert.False(t, sameCallbackPayload([]byte(`{"fileId":"f","updateStatus":"READY"}`),
+		[]byte(`{"fileId":"f","updateStatus":"PENDING"}`)))
+	assert.False(t, sameCallbackPayload([]byte(`{`), []byte(`{`)))
+}
+
+func makeTestCallback(name string, sequence int64, body any) *v1beta1.Callback {
+	raw, _ := json.Marshal(body)
+	return &v1beta1.Callback{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         testNamespace,
+			CreationTimestamp: metav1.Now(),
+			Labels: map[string]string{
+				v1beta1.CallbackFederationLabel: testFederationName,
+			},
+		},
+		Spec: v1beta1.CallbackSpec{
+			FederationName: testFederationName,
+			Sequence:       sequence,
+			Kind:           v1beta1.CallbackKindFile,
+			Payload:        runtime.RawExtension{Raw: raw},
+		},
+	}
+}
+
+func getTestCallback(t *testing.T, cl client.Client, name string) *v1beta1.Callback {
+	cb := &v1beta1.Callback{}
+	require.NoError(t, cl.Get(context.Background(), types.NamespacedName{Name: name, Namespace: testNamespace}, cb))
+	return cb
+}
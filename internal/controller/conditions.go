@@ -0,0 +1,154 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/neonephos-katalis/opg-ewbi-operator/api/operator/v1beta1"
+	"github.com/neonephos-katalis/opg-ewbi-operator/internal/opg"
+)
+
+// syncPhase summarizes the state of a resource for its conditions
+type syncPhase int
+
+const (
+	// syncInProgress, the resource is not in its final state yet
+	syncInProgress syncPhase = iota
+	// syncCompleted, the resource is usable
+	syncCompleted
+	// syncFailed, the resource reached a state it won't recover from on its own
+	syncFailed
+)
+
+// setPartnerConditions records the response of the partner OP to a request for
+// the resource. Rejected requests set the Stalled condition, the retried ones
+// set Reconciling with the error, both are removed once a request succeeds.
+func setPartnerConditions(conditions *[]metav1.Condition, generation int64, res *opg.Response) bool {
+	reason := v1beta1.ReasonPartnerRejected
+	switch res.Outcome {
+	case opg.OutcomeSuccess:
+		changed := meta.RemoveStatusCondition(conditions, v1beta1.ConditionStalled)
+		if c := meta.FindStatusCondition(*conditions, v1beta1.ConditionReconciling); c != nil &&
+			c.Reason == v1beta1.ReasonPartnerUnavailable {
+			changed = meta.RemoveStatusCondition(conditions, v1beta1.ConditionReconciling) || changed
+		}
+		return changed
+	case opg.OutcomeRetryable:
+		return meta.SetStatusCondition(conditions, metav1.Condition{
+			Type:               v1beta1.ConditionReconciling,
+			Status:             metav1.ConditionTrue,
+			ObservedGeneration: generation,
+			Reason:             v1beta1.ReasonPartnerUnavailable,
+			Message:            res.Error(),
+		})
+	case opg.OutcomeAuth:
+		reason = v1beta1.ReasonPartnerUnauthorized
+	}
+	return meta.SetStatusCondition(conditions, metav1.Condition{
+		Type:               v1beta1.ConditionStalled,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: generation,
+		Reason:             reason,
+		Message:            res.Error(),
+	})
+}
+
+// partnerRejected returns whether the partner OP rejected the last request sent
+// for generation, which isn't sent again until the spec changes or it is retried
+func partnerRejected(conditions []metav1.Condition, generation int64) bool {
+	c := meta.FindStatusCondition(conditions, v1beta1.ConditionStalled)
+	return c != nil && c.ObservedGeneration == generation &&
+		(c.Reason == v1beta1.ReasonPartnerRejected || c.Reason == v1beta1.ReasonPartnerUnauthorized)
+}
+
+// setSyncConditions sets the Ready and Reconciling conditions from the phase of
+// the resource, a Stalled resource is neither ready nor reconciling. A failed
+// resource is Stalled until it leaves the failure state.
+func setSyncConditions(conditions *[]metav1.Condition, generation int64, phase syncPhase, state string) bool {
+	message := "state " + state
+	if state == "" {
+		message = "state not set yet"
+	}
+	changed := false
+	set := func(condType string, status metav1.ConditionStatus, reason, message string) {
+		changed = meta.SetStatusCondition(conditions, metav1.Condition{
+			Type:               condType,
+			Status:             status,
+			ObservedGeneration: generation,
+			Reason:             reason,
+			Message:            message,
+		}) || changed
+	}
+
+	stalled := meta.FindStatusCondition(*conditions, v1beta1.ConditionStalled)
+	switch {
+	case phase == syncFailed && (stalled == nil || stalled.Reason == v1beta1.ReasonFailed):
+		set(v1beta1.ConditionStalled, metav1.ConditionTrue, v1beta1.ReasonFailed, message)
+	case phase != syncFailed && stalled != nil && stalled.Reason == v1beta1.ReasonFailed:
+		changed = meta.RemoveStatusCondition(conditions, v1beta1.ConditionStalled)
+	}
+	if stalled = meta.FindStatusCondition(*conditions, v1beta1.ConditionStalled); stalled != nil {
+		set(v1beta1.ConditionReady, metav1.ConditionFalse, stalled.Reason, stalled.Message)
+		set(v1beta1.ConditionReconciling, metav1.ConditionFalse, stalled.Reason, stalled.Message)
+		return changed
+	}
+
+	// a request retried by the controller keeps the resource reconciling
+	retrying := meta.FindStatusCondition(*conditions, v1beta1.ConditionReconciling)
+	if retrying != nil && (retrying.Status != metav1.ConditionTrue || retrying.Reason != v1beta1.ReasonPartnerUnavailable) {
+		retrying = nil
+	}
+	switch {
+	case phase == syncCompleted:
+		set(v1beta1.ConditionReady, metav1.ConditionTrue, v1beta1.ReasonSyncCompleted, message)
+		if retrying == nil {
+			set(v1beta1.ConditionReconciling, metav1.ConditionFalse, v1beta1.ReasonSyncCompleted, message)
+		}
+	case retrying != nil:
+		set(v1beta1.ConditionReady, metav1.ConditionFalse, retrying.Reason, retrying.Message)
+	default:
+		set(v1beta1.ConditionReady, metav1.ConditionFalse, v1beta1.ReasonSyncInProgress, message)
+		set(v1beta1.ConditionReconciling, metav1.ConditionTrue, v1beta1.ReasonSyncInProgress, message)
+	}
+	return changed
+}
+
+// updateConditions gets the latest version of the resource and updates its
+// status when setConditions changed it. Conflicts are ignored, the newer
+// version is reconciled again.
+func updateConditions[T client.Object](
+	ctx context.Context, c client.Client, key client.ObjectKey, obj T, setConditions func(T) bool,
+) error {
+	if err := c.Get(ctx, key, obj); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if !setConditions(obj) {
+		return nil
+	}
+	if err := c.Status().Update(ctx, obj); err != nil && !apierrors.IsConflict(err) && !apierrors.IsNotFound(err) {
+		log.FromContext(ctx).Error(err, errorUpdatingResourceStatusMsg)
+		return err
+	}
+	return nil
+}
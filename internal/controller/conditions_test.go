@@ -0,0 +1,104 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/neonephos-katalis/opg-ewbi-operator/api/operator/v1beta1"
+	"github.com/neonephos-katalis/opg-ewbi-operator/internal/opg"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSetPartnerConditions(t *testing.T) {
+	var conditions []metav1.Condition
+
+	assert.True(t, setPartnerConditions(&conditions, 1, &opg.Response{Outcome: opg.OutcomeRetryable, StatusCode: 503}))
+	reconciling := meta.FindStatusCondition(conditions, v1beta1.ConditionReconciling)
+	assert.Equal(t, metav1.ConditionTrue, reconciling.Status)
+	assert.Equal(t, v1beta1.ReasonPartnerUnavailable, reconciling.Reason)
+
+	assert.True(t, setPartnerConditions(&conditions, 1, &opg.Response{Outcome: opg.OutcomePermanent, StatusCode: 422}))
+	stalled := meta.FindStatusCondition(conditions, v1beta1.ConditionStalled)
+	assert.Equal(t, metav1.ConditionTrue, stalled.Status)
+	assert.Equal(t, v1beta1.ReasonPartnerRejected, stalled.Reason)
+	assert.Equal(t, "partner OP returned status 422", stalled.Message)
+
+	assert.True(t, setPartnerConditions(&conditions, 2, &opg.Response{Outcome: opg.OutcomeAuth, StatusCode: 401}))
+	assert.Equal(t, v1beta1.ReasonPartnerUnauthorized, meta.FindStatusCondition(conditions, v1beta1.ConditionStalled).Reason)
+
+	assert.True(t, setPartnerConditions(&conditions, 2, &opg.Response{Outcome: opg.OutcomeSuccess, StatusCode: 200}))
+	assert.Empty(t, conditions)
+}
+
+func TestSetSyncConditions(t *testing.T) {
+	type want struct {
+		ready, reconciling, stalled metav1.ConditionStatus
+		reason                      string
+	}
+	tests := []struct {
+		name     string
+		existing []metav1.Condition
+		phase    syncPhase
+		expected want
+	}{
+		{
+			name:     "In progress",
+			phase:    syncInProgress,
+			expected: want{metav1.ConditionFalse, metav1.ConditionTrue, "", v1beta1.ReasonSyncInProgress},
+		},
+		{
+			name:     "Completed",
+			phase:    syncCompleted,
+			expected: want{metav1.ConditionTrue, metav1.ConditionFalse, "", v1beta1.ReasonSyncCompleted},
+		},
+		{
+			name:     "Failed",
+			phase:    syncFailed,
+			expected: want{metav1.ConditionFalse, metav1.ConditionFalse, metav1.ConditionTrue, v1beta1.ReasonFailed},
+		},
+		{
+			name: "Recovered from a failure",
+			existing: []metav1.Condition{
+				{Type: v1beta1.ConditionStalled, Status: metav1.ConditionTrue, Reason: v1beta1.ReasonFailed},
+			},
+			phase:    syncCompleted,
+			expected: want{metav1.ConditionTrue, metav1.ConditionFalse, "", v1beta1.ReasonSyncCompleted},
+		},
+		{
+			name: "Rejected by the partner",
+			existing: []metav1.Condition{
+				{Type: v1beta1.ConditionStalled, Status: metav1.ConditionTrue, Reason: v1beta1.ReasonPartnerRejected},
+			},
+			phase:    syncInProgress,
+			expected: want{metav1.ConditionFalse, metav1.ConditionFalse, metav1.ConditionTrue, v1beta1.ReasonPartnerRejected},
+		},
+		{
+			name: "Retrying a request",
+			existing: []metav1.Condition{
+				{Type: v1beta1.ConditionReconciling, Status: metav1.ConditionTrue, Reason: v1beta1.ReasonPartnerUnavailable},
+			},
+			phase:    syncInProgress,
+			expected: want{metav1.ConditionFalse, metav1.ConditionTrue, "", v1beta1.ReasonPartnerUnavailable},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conditions := tt.existing
+			assert.True(t, setSyncConditions(&conditions, 3, tt.phase, "PENDING"))
+			assert.False(t, setSyncConditions(&conditions, 3, tt.phase, "PENDING"))
+
+			ready := meta.FindStatusCondition(conditions, v1beta1.ConditionReady)
+			assert.Equal(t, tt.expected.ready, ready.Status)
+			assert.Equal(t, tt.expected.reason, ready.Reason)
+			assert.Equal(t, int64(3), ready.ObservedGeneration)
+			assert.Equal(t, tt.expected.reconciling, meta.FindStatusCondition(conditions, v1beta1.ConditionReconciling).Status)
+			if stalled := meta.FindStatusCondition(conditions, v1beta1.ConditionStalled); stalled != nil {
+				assert.Equal(t, tt.expected.stalled, stalled.Status)
+			} else {
+				assert.Empty(t, tt.expected.stalled)
+			}
+		})
+	}
+}
@@ -18,9 +18,15 @@ package controller
 
 import (
 	"context"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
 
 	opgmodels "github.com/neonephos-katalis/opg-ewbi-operator/api/ewbi/models"
+	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -33,6 +39,7 @@ import (
 
 const (
 	errorCreatingFederationMsg = "error creating federation"
+	errorUpdatingFederationMsg = "error updating federation"
 )
 
 // FederationReconciler reconciles a Federation object
@@ -45,6 +52,7 @@ type FederationReconciler struct {
 // +kubebuilder:rbac:groups=opg.ewbi.nby.one,resources=federations,verbs=*,namespace=foo
 // +kubebuilder:rbac:groups=opg.ewbi.nby.one,resources=federations/status,verbs=get;update;patch,namespace=foo
 // +kubebuilder:rbac:groups=opg.ewbi.nby.one,resources=federations/finalizers,verbs=update,namespace=foo
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get,namespace=foo
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -55,7 +63,16 @@ type FederationReconciler struct {
 //
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.19.1/pkg/reconcile
-func (r *FederationReconciler) Reconcile(
+func (r *FederationReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	res, err := r.reconcile(ctx, req)
+	if upErr := updateConditions(ctx, r.Client, req.NamespacedName, &v1beta1.Federation{}, setFederationConditions); upErr != nil && err == nil {
+		return ctrl.Result{}, upErr
+	}
+	return res, err
+}
+
+// reconcile syncs the Federation, its conditions are set afterwards from the resulting state
+func (r *FederationReconciler) reconcile(
 	ctx context.Context,
 	req ctrl.Request,
 ) (ctrl.Result, error) {
@@ -92,12 +109,7 @@ func (r *FederationReconciler) Reconcile(
 		if isGuest {
 			if err := r.handleExternalFederationDeletion(ctx, &f); err != nil {
 				log.Error(err, "error deleting federation")
-				f.Status.State = v1beta1.FederationStateNotAvailable
-				upErr := r.Status().Update(ctx, f.DeepCopy())
-				if upErr != nil {
-					log.Error(upErr, errorUpdatingResourceStatusMsg)
-				}
-				return ctrl.Result{}, err
+				return opgResult(err)
 			}
 		}
 		// if external federation is correctly deleted, we can remove the finalizer
@@ -114,29 +126,33 @@ func (r *FederationReconciler) Reconcile(
 
 	// if federation is guest, send OPG API request
 	if isGuest {
-		updated, err := r.handleExternalFederationCreation(ctx, &f)
-		if err != nil {
-			log.Error(err, errorCreatingFederationMsg)
-			f.Status.State = v1beta1.FederationStateNotAvailable
-			upErr := r.Status().Update(ctx, f.DeepCopy())
-			if upErr != nil {
-				log.Error(upErr, errorUpdatingResourceStatusMsg)
+		// once the partner returned a federationContextId the federation exists,
+		// spec changes are sent as updates instead
+		if f.Status.FederationContextId == "" {
+			updated, err := r.handleExternalFederationCreation(ctx, &f)
+			if err != nil {
+				log.Error(err, errorCreatingFederationMsg)
+				return opgResult(err)
+			}
+			if updated {
+				// return, we will accept the AZ at the next reconcile
+				return ctrl.Result{}, nil
+			}
+		} else {
+			updated, err := r.handleExternalFederationUpdate(ctx, &f)
+			if err != nil {
+				log.Error(err, errorUpdatingFederationMsg)
+				return opgResult(err)
+			}
+			if updated {
+				// return, we will accept the AZ at the next reconcile
+				return ctrl.Result{}, nil
 			}
-			return ctrl.Result{}, err
-		}
-		if updated {
-			// return, we will accept the AZ at the next reconcile
-			return ctrl.Result{}, nil
 		}
 
-		if err := r.handleAcceptExternalAZ(ctx, &f); err != nil {
+		if err := r.handleExternalAZs(ctx, &f); err != nil {
 			log.Error(err, "error accepting az federation")
-			f.Status.State = v1beta1.FederationStateNotAvailable
-			upErr := r.Status().Update(ctx, f.DeepCopy())
-			if upErr != nil {
-				log.Error(upErr, errorUpdatingResourceStatusMsg)
-			}
-			return ctrl.Result{}, err
+			return opgResult(err)
 		}
 	} else {
 		if f.Status.State == "" {
@@ -155,6 +171,22 @@ func (r *FederationReconciler) Reconcile(
 	return ctrl.Result{}, nil
 }
 
+// setFederationConditions sets the conditions and observedGeneration of the Federation from its state
+func setFederationConditions(f *v1beta1.Federation) bool {
+	phase := syncInProgress
+	switch {
+	case f.Status.State == v1beta1.FederationStateFailed:
+		phase = syncFailed
+	case f.Status.SyncedOriginOP != nil && !equality.Semantic.DeepEqual(*f.Status.SyncedOriginOP, f.Spec.OriginOP):
+		// the network codes changes weren't acknowledged by the partner OP yet
+	case f.Status.State == v1beta1.FederationStateAvailable:
+		phase = syncCompleted
+	}
+	changed := f.Status.ObservedGeneration != f.Generation
+	f.Status.ObservedGeneration = f.Generation
+	return setSyncConditions(&f.Status.Conditions, f.Generation, phase, string(f.Status.State)) || changed
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *FederationReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
@@ -184,67 +216,198 @@ func (r *FederationReconciler) handleExternalFederationCreation(
 
 	res, err := r.GetOPGClient(
 		f.Labels[v1beta1.ExternalIdLabel],
-		f.Spec.GuestPartnerCredentials.TokenUrl,
-		f.Spec.GuestPartnerCredentials.ClientId,
+		guestPartnerApiRoot(f),
+		opgCredentials(f, f.Spec.GuestPartnerCredentials),
 	).CreateFederationWithResponse(
-		context.TODO(),
+		ctx,
 		fedReq,
 	)
-	if err != nil {
-		log.Error(err, errorCreatingFederationMsg)
-		return false, err
+	out := opg.Classify(res, err)
+	logOPGResponse(log, out)
+	if out.Outcome != opg.OutcomeSuccess {
+		if out.Outcome == opg.OutcomePermanent {
+			f.Status.State = v1beta1.FederationStateNotAvailable
+		}
+		setPartnerConditions(&f.Status.Conditions, f.Generation, out)
+		if upErr := r.Status().Update(ctx, f.DeepCopy()); upErr != nil {
+			log.Error(upErr, errorUpdatingResourceStatusMsg)
+			return false, upErr
+		}
+		return false, out.Err()
 	}
 
-	statusCode := res.StatusCode()
+	log.Info("Created", "response", res.JSON200)
+	federResponse := res.JSON200
+	zones := []v1beta1.ZoneDetails{}
+	if federResponse.OfferedAvailabilityZones != nil {
+		for _, z := range *federResponse.OfferedAvailabilityZones {
+			zones = append(zones, v1beta1.ZoneDetails{
+				GeographyDetails: z.GeographyDetails,
+				Geolocation:      z.Geolocation,
+				ZoneId:           z.ZoneId,
+			})
+		}
+	}
 
-	switch {
-	case statusCode >= 200 && statusCode < 300:
-		log.Info("Created", "response", res.JSON200)
-		federResponse := res.JSON200
-		zones := []v1beta1.ZoneDetails{}
-		if federResponse.OfferedAvailabilityZones != nil {
-			for _, z := range *federResponse.OfferedAvailabilityZones {
+	// a federation created without zones accepts the first offered one, emptying
+	// the zones of the spec afterwards releases all of them
+	if f.Spec.ZoneSelection == nil && len(f.Spec.AcceptedAvailabilityZones) == 0 && len(zones) > 0 {
+		f.Spec.AcceptedAvailabilityZones = []string{zones[0].ZoneId}
+		log.Info("Selected AZs", "zones", f.Spec.AcceptedAvailabilityZones)
+		if upErr := r.Update(ctx, f); upErr != nil {
+			log.Error(upErr, "Error Updating resource", "federation", f.Name)
+			return false, upErr
+		}
+	}
+
+	stalledChanged := setPartnerConditions(&f.Status.Conditions, f.Generation, out)
+	if compareSameAZs(f.Status.OfferedAvailabilityZones, zones) && f.Status.State == v1beta1.FederationStateAvailable &&
+		!stalledChanged {
+		return false, nil
+	}
+	f.Status.OfferedAvailabilityZones = zones
+	f.Status.State = v1beta1.FederationStateAvailable
+	f.Status.FederationContextId = *federResponse.FederationContextId
+	f.Status.SyncedOriginOP = f.Spec.OriginOP.DeepCopy()
+
+	upErr := r.Status().Update(ctx, f.DeepCopy())
+	if upErr != nil {
+		log.Error(upErr, "Error Updating resource", "federation", f.Name)
+		return false, upErr
+	}
+	return true, nil
+}
+
+// handleExternalFederationUpdate sends to the partner OP the network codes
+// changed in the spec since the last time they were acknowledged.
+func (r *FederationReconciler) handleExternalFederationUpdate(
+	ctx context.Context, f *v1beta1.Federation) (statusChanged bool, err error) {
+	log := log.FromContext(ctx)
+
+	if f.Status.SyncedOriginOP == nil {
+		// federations created before the codes were tracked, the current
+		// spec is taken as the one known by the partner
+		f.Status.SyncedOriginOP = f.Spec.OriginOP.DeepCopy()
+		if upErr := r.Status().Update(ctx, f.DeepCopy()); upErr != nil {
+			log.Error(upErr, errorUpdatingResourceStatusMsg)
+			return false, upErr
+		}
+		return true, nil
+	}
+
+	updates := federationUpdateRequests(*f.Status.SyncedOriginOP, f.Spec.OriginOP)
+	if len(updates) == 0 {
+		return false, nil
+	}
+	if stalled := meta.FindStatusCondition(f.Status.Conditions, v1beta1.ConditionStalled); stalled != nil &&
+		stalled.Reason == v1beta1.ReasonPartnerRejected && stalled.ObservedGeneration == f.Generation {
+		// the partner OP rejected the codes of this generation, they are sent again once the spec changes
+		return false, nil
+	}
+
+	out := &opg.Response{Outcome: opg.OutcomeSuccess}
+	for _, update := range updates {
+		log.Info("Updating external federation", "objectType", update.ObjectType, "operationType", update.OperationType)
+		res, err := r.GetOPGClient(
+			f.Labels[v1beta1.ExternalIdLabel],
+			guestPartnerApiRoot(f),
+			opgCredentials(f, f.Spec.GuestPartnerCredentials),
+		).UpdateFederationWithResponse(
+			ctx,
+			f.Status.FederationContextId,
+			update,
+		)
+		out = opg.Classify(res, err)
+		logOPGResponse(log, out)
+		if out.Outcome != opg.OutcomeSuccess {
+			// the update was not acknowledged, it will be sent again on the next reconcile
+			break
+		}
+		log.Info("Updated", "response", res.JSON200)
+		if update.ObjectType == opgmodels.UpdateFederationJSONBodyObjectTypeMOBILENETWORKCODES {
+			f.Status.SyncedOriginOP.MobileNetworkCodes = *f.Spec.OriginOP.MobileNetworkCodes.DeepCopy()
+		} else {
+			f.Status.SyncedOriginOP.FixedNetworkCodes = slices.Clone(f.Spec.OriginOP.FixedNetworkCodes)
+		}
+		if res.JSON200 != nil && res.JSON200.OfferedAvailabilityZones != nil {
+			zones := []v1beta1.ZoneDetails{}
+			for _, z := range *res.JSON200.OfferedAvailabilityZones {
 				zones = append(zones, v1beta1.ZoneDetails{
 					GeographyDetails: z.GeographyDetails,
 					Geolocation:      z.Geolocation,
 					ZoneId:           z.ZoneId,
 				})
 			}
+			f.Status.OfferedAvailabilityZones = zones
 		}
+	}
 
-		if compareSameAZs(f.Status.OfferedAvailabilityZones, zones) && f.Status.State == v1beta1.FederationStateAvailable {
-			return false, nil
+	setPartnerConditions(&f.Status.Conditions, f.Generation, out)
+	upErr := r.Status().Update(ctx, f.DeepCopy())
+	if upErr != nil {
+		log.Error(upErr, "Error Updating resource", "federation", f.Name)
+		return false, upErr
+	}
+	return true, out.Err()
+}
+
+// federationUpdateRequests builds the UpdateFederation requests needed to move
+// the partner OP from the synced network codes to the desired ones.
+func federationUpdateRequests(synced, desired v1beta1.Origin) []opgmodels.UpdateFederationJSONRequestBody {
+	var updates []opgmodels.UpdateFederationJSONRequestBody
+	now := time.Now()
+
+	oldMobile, newMobile := synced.MobileNetworkCodes, desired.MobileNetworkCodes
+	mccChanged := oldMobile.MCC != newMobile.MCC
+	addMNC := sliceDifference(newMobile.MNC, oldMobile.MNC)
+	removeMNC := sliceDifference(oldMobile.MNC, newMobile.MNC)
+	if mccChanged {
+		// a different MCC replaces the whole set of mobile codes
+		addMNC, removeMNC = slices.Clone(newMobile.MNC), slices.Clone(oldMobile.MNC)
+	}
+	if mccChanged || len(addMNC) > 0 || len(removeMNC) > 0 {
+		update := opgmodels.UpdateFederationJSONRequestBody{
+			ModificationDate: now,
+			ObjectType:       opgmodels.UpdateFederationJSONBodyObjectTypeMOBILENETWORKCODES,
+			OperationType:    updateCodesOperationType(mccChanged || len(addMNC) > 0, mccChanged || len(removeMNC) > 0),
+		}
+		if update.OperationType != opgmodels.REMOVECODES {
+			update.AddMobileNetworkIds = &opgmodels.MobileNetworkIds{Mcc: &newMobile.MCC, Mncs: &addMNC}
+		}
+		if update.OperationType != opgmodels.ADDCODES {
+			update.RemoveMobileNetworkIds = &opgmodels.MobileNetworkIds{Mcc: &oldMobile.MCC, Mncs: &removeMNC}
 		}
-		f.Status.OfferedAvailabilityZones = zones
-		f.Status.State = v1beta1.FederationStateAvailable
-		f.Status.FederationContextId = *federResponse.FederationContextId
+		updates = append(updates, update)
+	}
 
-		upErr := r.Status().Update(ctx, f.DeepCopy())
-		if upErr != nil {
-			log.Error(upErr, "Error Updating resource", "federation", f.Name)
-			return false, upErr
+	addFixed := sliceDifference(desired.FixedNetworkCodes, synced.FixedNetworkCodes)
+	removeFixed := sliceDifference(synced.FixedNetworkCodes, desired.FixedNetworkCodes)
+	if len(addFixed) > 0 || len(removeFixed) > 0 {
+		update := opgmodels.UpdateFederationJSONRequestBody{
+			ModificationDate: now,
+			ObjectType:       opgmodels.UpdateFederationJSONBodyObjectTypeFIXEDNETWORKCODES,
+			OperationType:    updateCodesOperationType(len(addFixed) > 0, len(removeFixed) > 0),
+		}
+		if len(addFixed) > 0 {
+			update.AddFixedNetworkIds = &addFixed
+		}
+		if len(removeFixed) > 0 {
+			update.RemoveFixedNetworkIds = &removeFixed
 		}
+		updates = append(updates, update)
+	}
+	return updates
+}
 
-	case statusCode == 400:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON400)
-	case statusCode == 401:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON401)
-	case statusCode == 404:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON404)
-	case statusCode == 409:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON409)
-	case statusCode == 422:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON422)
-	case statusCode == 500:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON500)
-	case statusCode == 503:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON503)
-	case statusCode == 520:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON520)
+func updateCodesOperationType(add, remove bool) opgmodels.UpdateFederationJSONBodyOperationType {
+	switch {
+	case add && remove:
+		return opgmodels.UPDATECODES
+	case remove:
+		return opgmodels.REMOVECODES
 	default:
-		log.Info(unexpectedStatusCodeMsg, "status", statusCode, "body", string(res.Body))
+		return opgmodels.ADDCODES
 	}
-	return true, nil
 }
 
 func compareSameAZs(s1, s2 []v1beta1.ZoneDetails) bool {
@@ -273,102 +436,156 @@ func (r *FederationReconciler) handleExternalFederationDeletion(
 	log.Info("Deleting external federation")
 	res, err := r.GetOPGClient(
 		f.Labels[v1beta1.ExternalIdLabel],
-		f.Spec.GuestPartnerCredentials.TokenUrl,
-		f.Spec.GuestPartnerCredentials.ClientId,
+		guestPartnerApiRoot(f),
+		opgCredentials(f, f.Spec.GuestPartnerCredentials),
 	).DeleteFederationDetailsWithResponse(
-		context.TODO(),
+		ctx,
 		f.Status.FederationContextId,
 	)
-	if err != nil {
-		log.Error(err, errorCreatingFederationMsg)
-		return err
-	}
-
-	statusCode := res.StatusCode()
-
-	switch {
-	case statusCode >= 200 && statusCode < 300:
+	// a federation the partner OP doesn't know about is already deleted
+	out := opg.Classify(res, err, opg.WithOutcome(http.StatusNotFound, opg.OutcomeSuccess))
+	logOPGResponse(log, out)
+	switch out.Outcome {
+	case opg.OutcomeSuccess:
 		log.Info("Deleted")
-		// federResponse.OfferedAvailabilityZones
-	case statusCode == 400:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON400)
-	case statusCode == 401:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON401)
-	case statusCode == 404:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON404)
-	case statusCode == 409:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON409)
-	case statusCode == 422:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON422)
-	case statusCode == 500:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON500)
-	case statusCode == 503:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON503)
-	case statusCode == 520:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON520)
-	default:
-		log.Info(unexpectedStatusCodeMsg, "status", statusCode, "body", string(res.Body))
+		return nil
+	case opg.OutcomePermanent:
+		f.Status.State = v1beta1.FederationStateNotAvailable
+	}
+	setPartnerConditions(&f.Status.Conditions, f.Generation, out)
+	if upErr := r.Status().Update(ctx, f.DeepCopy()); upErr != nil {
+		log.Error(upErr, errorUpdatingResourceStatusMsg)
+		return upErr
 	}
-	return nil
+	return out.Err()
 }
 
-func (r *FederationReconciler) handleAcceptExternalAZ(ctx context.Context, f *v1beta1.Federation) error {
+// handleExternalAZs subscribes to the zones in the spec AcceptedAvailabilityZones
+// not yet accepted at the partner OP and unsubscribes from the ones dropped from it.
+// With a ZoneSelection the spec zones are first updated from the offered ones,
+// otherwise an empty spec releases all the zones.
+func (r *FederationReconciler) handleExternalAZs(ctx context.Context, f *v1beta1.Federation) error {
 	log := log.FromContext(ctx)
 
-	if len(f.Status.OfferedAvailabilityZones) == 0 {
-		log.Info("No AZ was offered, no AZ available to be accepted")
-		return nil
+	desired := f.Spec.AcceptedAvailabilityZones
+	if f.Spec.ZoneSelection != nil {
+		desired = selectOfferedZones(f.Spec.ZoneSelection, f.Status.OfferedAvailabilityZones)
+	}
+	if !slices.Equal(desired, f.Spec.AcceptedAvailabilityZones) {
+		log.Info("Selected AZs", "zones", desired)
+		f.Spec.AcceptedAvailabilityZones = desired
+		if upErr := r.Update(ctx, f); upErr != nil {
+			log.Error(upErr, "Error Updating resource", "federation", f.Name)
+			return upErr
+		}
 	}
-	az := f.Status.OfferedAvailabilityZones[0].ZoneId
+
+	// out is the first request the partner OP didn't accept, the remaining zones are still sent
+	out := &opg.Response{Outcome: opg.OutcomeSuccess}
+	accepted := slices.Clone(f.Status.AcceptedAvailabilityZones)
+	for _, az := range sliceDifference(accepted, desired) {
+		res := r.handleReleaseExternalAZ(ctx, f, az)
+		if res.Outcome == opg.OutcomeSuccess {
+			accepted = slices.DeleteFunc(accepted, func(z string) bool { return z == az })
+		} else if out.Outcome == opg.OutcomeSuccess {
+			out = res
+		}
+	}
+
+	if toAccept := sliceDifference(desired, accepted); len(toAccept) > 0 {
+		res := r.handleAcceptExternalAZ(ctx, f, toAccept)
+		if res.Outcome == opg.OutcomeSuccess {
+			accepted = append(accepted, toAccept...)
+		} else if out.Outcome == opg.OutcomeSuccess {
+			out = res
+		}
+	}
+
+	stalledChanged := setPartnerConditions(&f.Status.Conditions, f.Generation, out)
+	if slices.Equal(accepted, f.Status.AcceptedAvailabilityZones) && !stalledChanged {
+		return out.Err()
+	}
+	f.Status.AcceptedAvailabilityZones = accepted
+	if upErr := r.Status().Update(ctx, f.DeepCopy()); upErr != nil {
+		log.Error(upErr, errorUpdatingResourceStatusMsg)
+		return upErr
+	}
+	return out.Err()
+}
+
+// selectOfferedZones returns, in the offered order, the ids of the offered zones
+// matching the selection policy.
+func selectOfferedZones(sel *v1beta1.ZoneSelection, offered []v1beta1.ZoneDetails) []string {
+	zones := []string{}
+	for _, z := range offered {
+		var selected bool
+		switch sel.Policy {
+		case v1beta1.ZoneSelectionPolicyAll:
+			selected = true
+		case v1beta1.ZoneSelectionPolicyList:
+			selected = slices.Contains(sel.ZoneIds, z.ZoneId)
+		case v1beta1.ZoneSelectionPolicyGeography:
+			selected = slices.ContainsFunc(sel.Geography, func(g string) bool {
+				return strings.Contains(strings.ToLower(z.GeographyDetails), strings.ToLower(g))
+			})
+		}
+		if selected {
+			zones = append(zones, z.ZoneId)
+		}
+	}
+	return zones
+}
+
+func (r *FederationReconciler) handleAcceptExternalAZ(
+	ctx context.Context, f *v1beta1.Federation, azs []string,
+) *opg.Response {
+	log := log.FromContext(ctx)
 
 	fedReq := opgmodels.ZoneRegistrationRequestData{
-		AcceptedAvailabilityZones: []opgmodels.ZoneIdentifier{az},
+		AcceptedAvailabilityZones: azs,
 	}
 
 	res, err := r.GetOPGClient(
 		f.Labels[v1beta1.ExternalIdLabel],
-		f.Spec.GuestPartnerCredentials.TokenUrl,
-		f.Spec.GuestPartnerCredentials.ClientId,
+		guestPartnerApiRoot(f),
+		opgCredentials(f, f.Spec.GuestPartnerCredentials),
 	).ZoneSubscribeWithResponse(
-		context.TODO(),
+		ctx,
 		f.Status.FederationContextId,
 		fedReq,
 	)
-	if err != nil {
-		log.Error(err, "error accepting AZ")
-		return err
+	out := opg.Classify(res, err)
+	logOPGResponse(log, out)
+	if out.Outcome == opg.OutcomeSuccess {
+		log.Info("Created", "response", res.JSON200)
 	}
+	return out
+}
 
-	statusCode := res.StatusCode()
-
-	switch {
-	case statusCode >= 200 && statusCode < 300:
-		log.Info("Created", "response", res.JSON200)
-		f.Spec.AcceptedAvailabilityZones = []string{az}
+func (r *FederationReconciler) handleReleaseExternalAZ(
+	ctx context.Context, f *v1beta1.Federation, az string,
+) *opg.Response {
+	log := log.FromContext(ctx).WithValues("zoneId", az)
+	log.Info("Releasing external AZ")
 
-		upErr := r.Update(ctx, f.DeepCopy())
-		if upErr != nil {
-			log.Error(upErr, "Error Updating resource", "federation", f.Name)
-			return upErr
-		}
-	case statusCode == 400:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON400)
-	case statusCode == 401:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON401)
-	case statusCode == 404:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON404)
-	case statusCode == 409:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON409)
-	case statusCode == 422:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON422)
-	case statusCode == 500:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON500)
-	case statusCode == 503:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON503)
-	case statusCode == 520:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON520)
-	default:
-		log.Info(unexpectedStatusCodeMsg, "status", statusCode, "body", string(res.Body))
+	res, err := r.GetOPGClient(
+		f.Labels[v1beta1.ExternalIdLabel],
+		guestPartnerApiRoot(f),
+		opgCredentials(f, f.Spec.GuestPartnerCredentials),
+	).ZoneUnsubscribeWithResponse(
+		ctx,
+		f.Status.FederationContextId,
+		az,
+	)
+	out := opg.Classify(res, err,
+		// the partner no longer has the zone accepted, nothing left to release
+		opg.WithOutcome(http.StatusNotFound, opg.OutcomeSuccess),
+		// application instances are still running in the zone
+		opg.WithOutcome(http.StatusConflict, opg.OutcomeRetryable),
+	)
+	logOPGResponse(log, out)
+	if out.Outcome == opg.OutcomeSuccess {
+		log.Info("Released")
 	}
-	return nil
+	return out
 }
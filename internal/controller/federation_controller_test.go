@@ -64,6 +64,7 @@ func TestFederationReconciler(t *testing.T) {
 	type fields struct {
 		resources          []client.Object
 		mockOpgFederations []*v1beta1.Federation
+		mockOpgAZs         []*v1beta1.AvailabilityZone
 	}
 	type args struct {
 		req ctrl.Request
@@ -77,6 +78,10 @@ func TestFederationReconciler(t *testing.T) {
 		wantOfferedAZs     []v1beta1.ZoneDetails
 		wantAcceptedAZs    []string
 		wantAPIFederations []string
+		wantSyncedOriginOP *v1beta1.Origin
+		wantAPIOriginOP    *v1beta1.Origin
+		wantStatusAZs      []string
+		wantAPIAZs         []string
 	}
 	tests := []struct {
 		name   string
@@ -146,7 +151,7 @@ func TestFederationReconciler(t *testing.T) {
 				wantStatusState:    v1beta1.FederationStateAvailable,
 				wantFinalizer:      v1beta1.FederationFinalizer,
 				wantOfferedAZs:     []v1beta1.ZoneDetails{{ZoneId: testAZName}},
-				wantAcceptedAZs:    nil, // none yet, these would be assigned next reconcile iter
+				wantAcceptedAZs:    []string{testAZName}, // selected on creation, accepted next reconcile iter
 				wantAPIFederations: []string{testFederationExternalId},
 			},
 		},
@@ -181,6 +186,148 @@ func TestFederationReconciler(t *testing.T) {
 				wantOfferedAZs:     []v1beta1.ZoneDetails{{ZoneId: testAZName}},
 				wantAcceptedAZs:    []string{testAZName},
 				wantAPIFederations: []string{testFederationExternalId},
+				wantStatusAZs:      []string{testAZName},
+			},
+		},
+		{
+			name: "A created Guest Federation pushes its network codes changes to the partner",
+			fields: fields{
+				resources: []client.Object{
+					makeTestFederation(testFederationName,
+						federationWithFinalizer(),
+						federationWithAvailableAZ(testAZName),
+						federationWithFederationState(v1beta1.FederationStateAvailable),
+						withFederationContextId(testFederationExternalId),
+						federationWithSyncedOriginOP(v1beta1.Origin{
+							CountryCode:       testFederationCountryCode,
+							FixedNetworkCodes: []string{"123"},
+							MobileNetworkCodes: v1beta1.MobileNetworkCodes{
+								MCC: testFederationMCC,
+								MNC: []string{testFederationMNC, "98"},
+							},
+						}),
+					),
+				},
+				mockOpgFederations: []*v1beta1.Federation{
+					makeTestFederation(testFederationName,
+						federationWithAvailableAZ(testAZName),
+						withFederationContextId(testFederationExternalId),
+						federationWithOriginOP(v1beta1.Origin{
+							CountryCode:       testFederationCountryCode,
+							FixedNetworkCodes: []string{"123"},
+							MobileNetworkCodes: v1beta1.MobileNetworkCodes{
+								MCC: testFederationMCC,
+								MNC: []string{testFederationMNC, "98"},
+							},
+						}),
+					),
+				},
+			},
+			args: args{
+				req: ctrl.Request{
+					NamespacedName: types.NamespacedName{Name: testFederationName, Namespace: testNamespace},
+				},
+			},
+			resp: response{
+				wantResult:         ctrl.Result{Requeue: false},
+				wantReconcileErr:   false,
+				wantStatusState:    v1beta1.FederationStateAvailable,
+				wantFinalizer:      v1beta1.FederationFinalizer,
+				wantOfferedAZs:     []v1beta1.ZoneDetails{{ZoneId: testAZName}},
+				wantAcceptedAZs:    nil, // these would be assigned next reconcile iter
+				wantAPIFederations: []string{testFederationExternalId},
+				wantSyncedOriginOP: &makeTestFederation(testFederationName).Spec.OriginOP,
+				wantAPIOriginOP:    &makeTestFederation(testFederationName).Spec.OriginOP,
+			},
+		},
+		{
+			name: "A Guest Federation releases the AZs dropped from its spec",
+			fields: fields{
+				resources: []client.Object{
+					makeTestFederation(testFederationName,
+						federationWithFinalizer(),
+						federationWithAvailableAZ(testAZName),
+						federationWithAvailableAZ("secondAZ"),
+						federationWithFederationState(v1beta1.FederationStateAvailable),
+						withFederationContextId(testFederationExternalId),
+						federationWithSyncedOriginOP(makeTestFederation(testFederationName).Spec.OriginOP),
+						federationWithAcceptedAZs(testAZName),
+						federationWithStatusAcceptedAZs(testAZName, "secondAZ"),
+					),
+				},
+				mockOpgFederations: []*v1beta1.Federation{
+					makeTestFederation(testFederationName,
+						federationWithAvailableAZ(testAZName),
+						withFederationContextId(testFederationExternalId),
+					),
+				},
+				mockOpgAZs: []*v1beta1.AvailabilityZone{
+					makeTestAPIAvailabilityZone(testAZName),
+					makeTestAPIAvailabilityZone("secondAZ"),
+				},
+			},
+			args: args{
+				req: ctrl.Request{
+					NamespacedName: types.NamespacedName{Name: testFederationName, Namespace: testNamespace},
+				},
+			},
+			resp: response{
+				wantResult:       ctrl.Result{Requeue: false},
+				wantReconcileErr: false,
+				wantStatusState:  v1beta1.FederationStateAvailable,
+				wantFinalizer:    v1beta1.FederationFinalizer,
+				wantOfferedAZs:   []v1beta1.ZoneDetails{{ZoneId: testAZName}, {ZoneId: "secondAZ"}},
+				wantAcceptedAZs:  []string{testAZName},
+				wantStatusAZs:    []string{testAZName},
+				wantAPIAZs:       []string{testAZName},
+			},
+		},
+		{
+			name: "A Guest Federation with a ZoneSelection accepts the selected AZs",
+			fields: fields{
+				resources: []client.Object{
+					makeTestFederation(testFederationName,
+						federationWithFinalizer(),
+						federationWithAvailableAZ(testAZName),
+						federationWithAvailableAZ("secondAZ"),
+						federationWithAvailableAZ("thirdAZ"),
+						federationWithFederationState(v1beta1.FederationStateAvailable),
+						withFederationContextId(testFederationExternalId),
+						federationWithSyncedOriginOP(makeTestFederation(testFederationName).Spec.OriginOP),
+						federationWithAcceptedAZs(testAZName),
+						federationWithStatusAcceptedAZs(testAZName),
+						federationWithZoneSelection(&v1beta1.ZoneSelection{
+							Policy:  v1beta1.ZoneSelectionPolicyList,
+							ZoneIds: []string{"secondAZ", "thirdAZ", "unknownAZ"},
+						}),
+					),
+				},
+				mockOpgFederations: []*v1beta1.Federation{
+					makeTestFederation(testFederationName,
+						federationWithAvailableAZ(testAZName),
+						withFederationContextId(testFederationExternalId),
+					),
+				},
+				mockOpgAZs: []*v1beta1.AvailabilityZone{
+					makeTestAPIAvailabilityZone(testAZName),
+				},
+			},
+			args: args{
+				req: ctrl.Request{
+					NamespacedName: types.NamespacedName{Name: testFederationName, Namespace: testNamespace},
+				},
+			},
+			resp: response{
+				wantResult:       ctrl.Result{Requeue: false},
+				wantReconcileErr: false,
+				wantStatusState:  v1beta1.FederationStateAvailable,
+				wantFinalizer:    v1beta1.FederationFinalizer,
+				wantOfferedAZs: []v1beta1.ZoneDetails{
+					{ZoneId: testAZName}, {ZoneId: "secondAZ"}, {ZoneId: "thirdAZ"},
+				},
+				wantAcceptedAZs: []string{"secondAZ", "thirdAZ"},
+				wantStatusAZs:   []string{"secondAZ", "thirdAZ"},
+				wantAPIAZs:      []string{"secondAZ", "thirdAZ"},
 			},
 		},
 		{
@@ -218,7 +365,9 @@ func TestFederationReconciler(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			ctx := context.TODO()
 			apiObjs := &ApiObjects{
-				Federations: tt.fields.mockOpgFederations}
+				Federations: tt.fields.mockOpgFederations,
+				AZs:         tt.fields.mockOpgAZs,
+			}
 			cl, opgcmap, mockedOpgAPI, sch := prepareEnv(tt.fields.resources, apiObjs)
 			r := makeTestFederationReconciler(cl, sch, opgcmap)
 
@@ -248,11 +397,112 @@ func TestFederationReconciler(t *testing.T) {
 			assert.Contains(t, reqFeder.Finalizers, tt.resp.wantFinalizer)
 			assert.Equal(t, tt.resp.wantOfferedAZs, reqFeder.Status.OfferedAvailabilityZones)
 			assert.Equal(t, tt.resp.wantAcceptedAZs, reqFeder.Spec.AcceptedAvailabilityZones)
+			if tt.resp.wantStatusAZs != nil {
+				assert.Equal(t, tt.resp.wantStatusAZs, reqFeder.Status.AcceptedAvailabilityZones)
+			}
+			if tt.resp.wantAPIAZs != nil {
+				assert.Len(t, mockedOpgAPI.AZs, len(tt.resp.wantAPIAZs))
+				for _, az := range tt.resp.wantAPIAZs {
+					assert.Contains(t, mockedOpgAPI.AZs, az)
+				}
+			}
+			if tt.resp.wantSyncedOriginOP != nil {
+				assert.Equal(t, tt.resp.wantSyncedOriginOP, reqFeder.Status.SyncedOriginOP)
+			}
+			if tt.resp.wantAPIOriginOP != nil {
+				apiFed := mockedOpgAPI.Federations[reqFeder.Status.FederationContextId]
+				assert.Equal(t, *tt.resp.wantAPIOriginOP, apiFed.Spec.OriginOP)
+			}
 
 		})
 	}
 }
 
+func TestFederationReconciler_ReleaseAllZones(t *testing.T) {
+	ctx := context.TODO()
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: testFederationName, Namespace: testNamespace}}
+	feder := makeTestFederation(testFederationName,
+		federationWithFinalizer(),
+		federationWithAvailableAZ(testAZName),
+		federationWithAvailableAZ("secondAZ"),
+		federationWithFederationState(v1beta1.FederationStateAvailable),
+		withFederationContextId(testFederationExternalId),
+		federationWithSyncedOriginOP(makeTestFederation(testFederationName).Spec.OriginOP),
+		federationWithStatusAcceptedAZs(testAZName, "secondAZ"),
+	)
+	cl, opgcmap, mockedOpgAPI, sch := prepareEnv([]client.Object{feder}, &ApiObjects{
+		Federations: []*v1beta1.Federation{makeTestFederation(testFederationName,
+			federationWithAvailableAZ(testAZName),
+			withFederationContextId(testFederationExternalId),
+		)},
+		AZs: []*v1beta1.AvailabilityZone{
+			makeTestAPIAvailabilityZone(testAZName),
+			makeTestAPIAvailabilityZone("secondAZ"),
+		},
+	})
+	r := makeTestFederationReconciler(cl, sch, opgcmap)
+
+	// the emptied spec releases every zone, and no zone is accepted again afterwards
+	var got v1beta1.Federation
+	for range 2 {
+		_, err := r.Reconcile(ctx, req)
+		require.NoError(t, err)
+		require.NoError(t, cl.Get(ctx, req.NamespacedName, &got))
+		assert.Empty(t, got.Spec.AcceptedAvailabilityZones)
+		assert.Empty(t, got.Status.AcceptedAvailabilityZones)
+		assert.Empty(t, mockedOpgAPI.AZs)
+	}
+}
+
+func TestSelectOfferedZones(t *testing.T) {
+	offered := []v1beta1.ZoneDetails{
+		{ZoneId: "zone-1", GeographyDetails: "Madrid, urban"},
+		{ZoneId: "zone-2", GeographyDetails: "Barcelona, urban"},
+		{ZoneId: "zone-3", GeographyDetails: "Teruel, rural"},
+	}
+	tests := []struct {
+		name     string
+		sel      *v1beta1.ZoneSelection
+		expected []string
+	}{
+		{
+			name:     "All offered zones",
+			sel:      &v1beta1.ZoneSelection{Policy: v1beta1.ZoneSelectionPolicyAll},
+			expected: []string{"zone-1", "zone-2", "zone-3"},
+		},
+		{
+			name: "Listed zones in offered order, ignoring the not offered ones",
+			sel: &v1beta1.ZoneSelection{
+				Policy:  v1beta1.ZoneSelectionPolicyList,
+				ZoneIds: []string{"zone-3", "zone-4", "zone-1"},
+			},
+			expected: []string{"zone-1", "zone-3"},
+		},
+		{
+			name: "Zones matching the geography",
+			sel: &v1beta1.ZoneSelection{
+				Policy:    v1beta1.ZoneSelectionPolicyGeography,
+				Geography: []string{"URBAN"},
+			},
+			expected: []string{"zone-1", "zone-2"},
+		},
+		{
+			name: "No zone matching the geography",
+			sel: &v1beta1.ZoneSelection{
+				Policy:    v1beta1.ZoneSelectionPolicyGeography,
+				Geography: []string{"Lisbon"},
+			},
+			expected: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, selectOfferedZones(tt.sel, offered))
+		})
+	}
+}
+
 type federationOpt func(*v1beta1.Federation)
 
 func federationDeletedAt(now time.Time) federationOpt {
@@ -285,6 +535,63 @@ func federationWithFederationState(state v1beta1.FederationState) federationOpt
 	}
 }
 
+func federationWithOriginOP(origin v1beta1.Origin) federationOpt {
+	return func(f *v1beta1.Federation) {
+		f.Spec.OriginOP = origin
+	}
+}
+
+func federationWithSyncedOriginOP(origin v1beta1.Origin) federationOpt {
+	return func(f *v1beta1.Federation) {
+		f.Status.SyncedOriginOP = &origin
+	}
+}
+
+func federationWithAcceptedAZs(azIds ...string) federationOpt {
+	return func(f *v1beta1.Federation) {
+		f.Spec.AcceptedAvailabilityZones = azIds
+	}
+}
+
+func federationWithStatusAcceptedAZs(azIds ...string) federationOpt {
+	return func(f *v1beta1.Federation) {
+		f.Status.AcceptedAvailabilityZones = azIds
+	}
+}
+
+func federationWithZoneSelection(sel *v1beta1.ZoneSelection) federationOpt {
+	return func(f *v1beta1.Federation) {
+		f.Spec.ZoneSelection = sel
+	}
+}
+
+func federationWithZoneStatus(azId string, state v1beta1.FederationState) federationOpt {
+	return func(f *v1beta1.Federation) {
+		f.Status.ZonesStatus = append(f.Status.ZonesStatus, v1beta1.ZoneStatus{ZoneId: azId, State: state})
+	}
+}
+
+func federationWithZoneAvailability(azId string, state v1beta1.ZoneAvailabilityState) federationOpt {
+	return func(f *v1beta1.Federation) {
+		f.Status.ZonesAvailability = append(f.Status.ZonesAvailability, v1beta1.ZoneAvailability{ZoneId: azId, State: state})
+	}
+}
+
+func federationWithSuppressedInstalls() federationOpt {
+	return func(f *v1beta1.Federation) {
+		f.Spec.SuppressInstallsInUnavailableZones = true
+	}
+}
+
+func makeTestAPIAvailabilityZone(azId string) *v1beta1.AvailabilityZone {
+	return &v1beta1.AvailabilityZone{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   azId,
+			Labels: map[string]string{v1beta1.ExternalIdLabel: azId},
+		},
+	}
+}
+
 func federationWithFinalizer() federationOpt {
 	return func(f *v1beta1.Federation) {
 		controllerutil.AddFinalizer(f, v1beta1.FederationFinalizer)
@@ -18,9 +18,12 @@ package controller
 
 import (
 	"context"
-	"errors"
+	"net/http"
+	"time"
 
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -39,11 +42,15 @@ type FileReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
 	opg.OPGClientsMapInterface
+	// ResyncPeriod, interval between the polls of the partner OP for the state of
+	// the guest Files until it is final, 0 disables polling
+	ResyncPeriod time.Duration
 }
 
 // +kubebuilder:rbac:groups=opg.ewbi.nby.one,resources=files,verbs=*,namespace=foo
 // +kubebuilder:rbac:groups=opg.ewbi.nby.one,resources=files/status,verbs=get;update;patch,namespace=foo
 // +kubebuilder:rbac:groups=opg.ewbi.nby.one,resources=files/finalizers,verbs=update,namespace=foo
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get,namespace=foo
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -55,6 +62,15 @@ type FileReconciler struct {
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.19.4/pkg/reconcile
 func (r *FileReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	res, err := r.reconcile(ctx, req)
+	if upErr := updateConditions(ctx, r.Client, req.NamespacedName, &v1beta1.File{}, setFileConditions); upErr != nil && err == nil {
+		return ctrl.Result{}, upErr
+	}
+	return res, err
+}
+
+// reconcile syncs the File, its conditions are set afterwards from the resulting state
+func (r *FileReconciler) reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	log := log.FromContext(ctx).WithValues("name", req.Name, "namespace", req.Namespace)
 	log.Info("starting reconcile function for file")
 	defer log.Info("end reconcile for file")
@@ -107,12 +123,7 @@ func (r *FileReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 		if isGuest {
 			if err := r.handleExternalFileDeletion(ctx, &f, feder); err != nil {
 				log.Error(err, "error deleting file")
-				f.Status.State = v1beta1.FileStateError
-				upErr := r.Status().Update(ctx, f.DeepCopy())
-				if upErr != nil {
-					log.Error(upErr, errorUpdatingResourceStatusMsg)
-				}
-				return ctrl.Result{}, err
+				return opgResult(err)
 			}
 		}
 		// if external file is correctly deleted, we can remove the finalizer
@@ -129,16 +140,19 @@ func (r *FileReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 
 	// if federation is guest, send OPG API request
 	if isGuest {
+		if _, ok := f.Annotations[v1beta1.RetryAnnotation]; ok {
+			return ctrl.Result{}, handleRetryAnnotation(ctx, r.Client, &f, f.Status.State == v1beta1.FileStateError, func() {
+				f.Status = v1beta1.FileStatus{Conditions: f.Status.Conditions}
+				meta.RemoveStatusCondition(&f.Status.Conditions, v1beta1.ConditionStalled)
+			})
+		}
 		if f.Status.State == "" {
 			if err := r.handleExternalFileCreation(ctx, &f, feder); err != nil {
-				log.Info("error creating file")
-				f.Status.State = v1beta1.FileStateError
-				upErr := r.Status().Update(ctx, f.DeepCopy())
-				if upErr != nil {
-					log.Error(upErr, errorUpdatingResourceStatusMsg)
-				}
-				return ctrl.Result{}, nil
+				log.Error(err, "error creating file")
+				return opgResult(err)
 			}
+		} else if r.ResyncPeriod > 0 && !fileStateFinal(f.Status.State) {
+			return r.resyncExternalFile(ctx, &f, feder)
 		} else {
 			log.Info("+++++++++++++++++++ File status is ", "state", f.Status.State)
 		}
@@ -153,18 +167,70 @@ func (r *FileReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 		} else {
 			log.Info("New CR state", "state", f.Status.State)
 			if err := r.handleExternalFileCallback(ctx, &f, feder); err != nil {
-				log.Error(err, "error handling file callback")
-				f.Status.State = v1beta1.FileStateError
-				upErr := r.Status().Update(ctx, f.DeepCopy())
-				if upErr != nil {
-					log.Error(upErr, errorUpdatingResourceStatusMsg)
-				}
+				log.Error(err, "error queueing file callback")
+				return ctrl.Result{}, err
 			}
 		}
 	}
 	return ctrl.Result{}, nil
 }
 
+// resyncExternalFile polls the partner OP for the File, in case its callback was lost.
+// The partner doesn't report the state of files, a File it no longer holds is set in error.
+func (r *FileReconciler) resyncExternalFile(
+	ctx context.Context, f *v1beta1.File, feder *v1beta1.Federation,
+) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	now := metav1.Now()
+	if wait := resyncDelay(r.ResyncPeriod, f.Status.LastSyncTime, now.Time); wait > 0 {
+		return ctrl.Result{RequeueAfter: wait}, nil
+	}
+
+	res, err := r.GetOPGClient(
+		feder.Labels[v1beta1.ExternalIdLabel],
+		guestPartnerApiRoot(feder),
+		opgCredentials(feder, feder.Spec.GuestPartnerCredentials),
+	).ViewFileWithResponse(
+		ctx,
+		feder.Status.FederationContextId,
+		f.Labels[v1beta1.ExternalIdLabel],
+	)
+	out := opg.Classify(res, err)
+	logOPGResponse(log, out)
+	switch {
+	case out.Outcome == opg.OutcomeSuccess:
+		log.Info("External file still held by the partner OP", "state", f.Status.State)
+	case out.StatusCode == http.StatusNotFound:
+		f.Status.State = v1beta1.FileStateError
+	}
+	f.Status.LastSyncTime = &now
+	setPartnerConditions(&f.Status.Conditions, f.Generation, out)
+	if upErr := r.Status().Update(ctx, f); upErr != nil {
+		log.Error(upErr, errorUpdatingResourceStatusMsg)
+		return ctrl.Result{}, upErr
+	}
+	return resyncResult(r.ResyncPeriod, fileStateFinal(f.Status.State)), nil
+}
+
+// fileStateFinal returns whether the File no longer changes without a callback
+func fileStateFinal(state v1beta1.FileState) bool {
+	return state == v1beta1.FileStateReady || state == v1beta1.FileStateError
+}
+
+// setFileConditions sets the conditions and observedGeneration of the File from its state
+func setFileConditions(f *v1beta1.File) bool {
+	phase := syncInProgress
+	switch f.Status.State {
+	case v1beta1.FileStateReady:
+		phase = syncCompleted
+	case v1beta1.FileStateError:
+		phase = syncFailed
+	}
+	changed := f.Status.ObservedGeneration != f.Generation
+	f.Status.ObservedGeneration = f.Generation
+	return setSyncConditions(&f.Status.Conditions, f.Generation, phase, string(f.Status.State)) || changed
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *FileReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	if err := indexer.GetFederationIndexers(context.Background(), mgr); err != nil {
@@ -180,14 +246,24 @@ func (r *FileReconciler) handleExternalFileCreation(
 	ctx context.Context, f *v1beta1.File, feder *v1beta1.Federation,
 ) error {
 	log := log.FromContext(ctx)
+	password, err := resolveSecretKeyRef(ctx, r.Client, f.Namespace, f.Spec.Repo.PasswordSecretRef)
+	if err != nil {
+		log.Error(err, "error getting repo password")
+		return err
+	}
+	token, err := resolveSecretKeyRef(ctx, r.Client, f.Namespace, f.Spec.Repo.TokenSecretRef)
+	if err != nil {
+		log.Error(err, "error getting repo token")
+		return err
+	}
 	fileReqBody := opgmodels.UploadFileMultipartBody{
 		AppProviderId: f.Spec.AppProviderId,
 		FileId:        f.Labels[v1beta1.ExternalIdLabel],
 		FileName:      f.Spec.FileName,
 		FileRepoLocation: &opgmodels.ObjectRepoLocation{
-			Password: &f.Spec.Repo.Password,
+			Password: &password,
 			RepoURL:  &f.Spec.Repo.URL,
-			Token:    &f.Spec.Repo.Token,
+			Token:    &token,
 			UserName: &f.Spec.Repo.UserName,
 		},
 		FileType:        opgmodels.VirtImageType(f.Spec.FileType),
@@ -209,63 +285,35 @@ func (r *FileReconciler) handleExternalFileCreation(
 	}
 	res, err := r.GetOPGClient(
 		feder.Labels[v1beta1.ExternalIdLabel],
-		feder.Spec.GuestPartnerCredentials.TokenUrl,
-		feder.Spec.GuestPartnerCredentials.ClientId,
+		guestPartnerApiRoot(feder),
+		opgCredentials(feder, feder.Spec.GuestPartnerCredentials),
 	).UploadFileWithBodyWithResponse(
-		context.TODO(),
+		ctx,
 		f.Labels[v1beta1.FederationContextIdLabel],
 		contentType,
 		body)
-	if err != nil {
-		log.Error(err, "error creating file")
-		f.Status.State = v1beta1.FileStateError
-		return err
+	out := opg.Classify(res, err)
+	// this should be deleted when API returns a 400 for this case
+	if out.StatusCode == http.StatusInternalServerError && out.Detail() == "file not found" {
+		out.Outcome = opg.OutcomePermanent
 	}
-	statusCode := res.StatusCode()
-	switch {
-	case statusCode >= 200 && statusCode < 300:
-		log.Info("FILE - Status code 2xx received from OPG API", "status", statusCode)
-
+	logOPGResponse(log, out)
+	switch out.Outcome {
+	case opg.OutcomeSuccess:
 		f.Status.State = v1beta1.FileStatePending
+		now := metav1.Now()
+		f.Status.LastSyncTime = &now
 		log.Info("Created external file", "state", f.Status.State)
-	case statusCode == 400:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON400)
-		f.Status.State = v1beta1.FileStateError
-		log.Info("Couldn't be created", "Detail", res.ApplicationproblemJSON400.Detail)
-		return errors.New(*res.ApplicationproblemJSON400.Detail)
-	case statusCode == 401:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON401)
+	case opg.OutcomePermanent:
 		f.Status.State = v1beta1.FileStateError
-	case statusCode == 404:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON404)
-		f.Status.State = v1beta1.FileStateError
-	case statusCode == 409:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON409)
-		f.Status.State = v1beta1.FileStateError
-	case statusCode == 422:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON422)
-		f.Status.State = v1beta1.FileStateError
-	case statusCode == 500:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON500)
-		f.Status.State = v1beta1.FileStateError
-		// this should be deleted when API returns a 400 for this case
-		if *res.ApplicationproblemJSON500.Detail == "file not found" {
-			return errors.New(*res.ApplicationproblemJSON500.Detail)
-		}
-	case statusCode == 503:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON503)
-		f.Status.State = v1beta1.FileStateError
-	case statusCode == 520:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON520)
-		f.Status.State = v1beta1.FileStateError
-	default:
-		f.Status.State = v1beta1.FileStatePending
 	}
-	upErr := r.Status().Update(ctx, f)
-	if upErr != nil {
+	recordAttempt(&f.Status.Attempts, &f.Status.LastError, out)
+	setPartnerConditions(&f.Status.Conditions, f.Generation, out)
+	if upErr := r.Status().Update(ctx, f); upErr != nil {
 		log.Error(upErr, errorUpdatingResourceStatusMsg)
+		return upErr
 	}
-	return nil
+	return out.Err()
 }
 
 func (r *FileReconciler) handleExternalFileCallback(
@@ -277,7 +325,7 @@ func (r *FileReconciler) handleExternalFileCallback(
 		log.Info("No callback StatusLink configured in Federation, skipping App callback")
 		return nil
 	}
-	log.Info("Sending App callback to Guest",
+	log.Info("Queueing App callback to Guest",
 		"appId", f.Labels[v1beta1.ExternalIdLabel],
 		"state", f.Status.State,
 		"statusLink", feder.Spec.Partner.StatusLink)
@@ -285,43 +333,7 @@ func (r *FileReconciler) handleExternalFileCallback(
 		FileId:       f.Labels[v1beta1.ExternalIdLabel],
 		UpdateStatus: opgmodels.FileStatusCallbackLinkJSONBodyUpdateStatus(f.Status.State),
 	}
-	// Get callback client (pointing to Guest's callback URL via Federation.spec.partner.statusLink)
-	res, err := r.GetOPGClient(
-		feder.Labels[v1beta1.ExternalIdLabel],
-		feder.Spec.Partner.StatusLink,
-		feder.Spec.Partner.CallbackCredentials.ClientId,
-	).FileStatusCallbackLinkWithResponse(
-		context.TODO(),
-		feder.Spec.Partner.CallbackCredentials.ClientId,
-		callbackBody)
-
-	if err != nil {
-		log.Error(err, "error sending App callback")
-		return err
-	}
-	statusCode := res.StatusCode()
-	switch {
-	case statusCode >= 200 && statusCode < 300:
-		log.Info("****************** Successfully sent File callback to Guest", "status", statusCode)
-	case statusCode == 400:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON400)
-		f.Status.State = v1beta1.FileStateError
-	case statusCode == 401:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON401)
-		f.Status.State = v1beta1.FileStateError
-	case statusCode == 404:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON404)
-		f.Status.State = v1beta1.FileStateError
-	default:
-		log.Info("############# File callback returned unexpected status", "status", statusCode, "body", string(res.Body))
-		f.Status.State = v1beta1.FileStatePending
-	}
-	upErr := r.Status().Update(ctx, f.DeepCopy())
-	if upErr != nil {
-		log.Error(upErr, errorUpdatingResourceStatusMsg)
-		return upErr
-	}
-	return nil
+	return enqueueCallback(ctx, r.Client, feder, f, v1beta1.CallbackKindFile, callbackBody)
 }
 
 func (r *FileReconciler) handleExternalFileDeletion(
@@ -329,59 +341,30 @@ func (r *FileReconciler) handleExternalFileDeletion(
 ) error {
 	log := log.FromContext(ctx)
 	log.Info("Deleting external file")
-	// we should delete the file
+	// we should delete the file, a file the partner OP doesn't know about is already deleted
 	res, err := r.GetOPGClient(
 		feder.Labels[v1beta1.ExternalIdLabel],
-		feder.Spec.GuestPartnerCredentials.TokenUrl,
-		feder.Spec.GuestPartnerCredentials.ClientId,
+		guestPartnerApiRoot(feder),
+		opgCredentials(feder, feder.Spec.GuestPartnerCredentials),
 	).RemoveFileWithResponse(
-		context.TODO(),
+		ctx,
 		feder.Status.FederationContextId,
 		f.Labels[v1beta1.ExternalIdLabel],
 	)
-	if err != nil {
-		log.Error(err, "error deleting federation")
-		return err
-	}
-
-	statusCode := res.StatusCode()
-
-	switch {
-	case statusCode >= 200 && statusCode < 300:
+	out := opg.Classify(res, err, opg.WithOutcome(http.StatusNotFound, opg.OutcomeSuccess))
+	logOPGResponse(log, out)
+	if out.Outcome == opg.OutcomeSuccess {
 		log.Info("Deleted")
-		// federResponse.OfferedAvailabilityZones
-	case statusCode == 400:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON400)
-		f.Status.State = v1beta1.FileStateError
-	case statusCode == 401:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON401)
-		f.Status.State = v1beta1.FileStateError
-	case statusCode == 404:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON404)
-		f.Status.State = v1beta1.FileStateError
-	case statusCode == 409:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON409)
-		f.Status.State = v1beta1.FileStateError
-	case statusCode == 422:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON422)
-		f.Status.State = v1beta1.FileStateError
-	case statusCode == 500:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON500)
-		f.Status.State = v1beta1.FileStateError
-	case statusCode == 503:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON503)
-		f.Status.State = v1beta1.FileStateError
-	case statusCode == 520:
-		handleProblemDetails(log, statusCode, res.ApplicationproblemJSON520)
+		return nil
+	}
+	if out.Outcome == opg.OutcomePermanent {
 		f.Status.State = v1beta1.FileStateError
-	default:
-		log.Info(unexpectedStatusCodeMsg, "status", statusCode, "body", string(res.Body))
-		f.Status.State = v1beta1.FileStatePending
 	}
-	upErr := r.Status().Update(ctx, f.DeepCopy())
-	if upErr != nil {
+	recordAttempt(&f.Status.Attempts, &f.Status.LastError, out)
+	setPartnerConditions(&f.Status.Conditions, f.Generation, out)
+	if upErr := r.Status().Update(ctx, f.DeepCopy()); upErr != nil {
 		log.Error(upErr, errorUpdatingResourceStatusMsg)
 		return upErr
 	}
-	return nil
+	return out.Err()
 }
@@ -159,6 +159,28 @@ func TestFileReconciler(t *testing.T) {
 				wantAPIFiles:     []string{testFileExternalId},
 			},
 		},
+		{
+			name: "A failed Guest File with the retry annotation is reset to be created again",
+			fields: fields{
+				resources: []client.Object{
+					feder,
+					makeTestFile(testFederationContextId,
+						fileWithFinalizer(),
+						fileWithState(v1beta1.FileStateError),
+						fileWithAnnotation(v1beta1.RetryAnnotation, ""),
+					)},
+				mockOpgFederations: []*v1beta1.Federation{feder},
+			},
+			args: args{
+				req: ctrl.Request{NamespacedName: types.NamespacedName{Name: testFileName, Namespace: testNamespace}},
+			},
+			resp: response{
+				wantResult:       ctrl.Result{},
+				wantReconcileErr: false,
+				wantStatusState:  "",
+				wantFinalizer:    v1beta1.FileFinalizer,
+			},
+		},
 		{
 			name: "Delete File is synced at federation partner and its finalizer removed in a single reconcile",
 			fields: fields{
@@ -211,11 +233,66 @@ func TestFileReconciler(t *testing.T) {
 			require.NoError(t, err)
 			assert.Equal(t, tt.resp.wantStatusState, reqFile.Status.State)
 			assert.Contains(t, reqFile.Finalizers, tt.resp.wantFinalizer)
+			assert.NotContains(t, reqFile.Annotations, v1beta1.RetryAnnotation)
 
 		})
 	}
 }
 
+func TestFileReconciler_Resync(t *testing.T) {
+	feder := makeTestFederation(testFederationName, withFederationContextId(testFederationContextId),
+		federationWithFederationRelation(v1beta1.FederationRelationGuest),
+	)
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: testFileName, Namespace: testNamespace}}
+	file := makeTestFile(testFederationContextId, fileWithFinalizer(), fileWithState(v1beta1.FileStatePending))
+
+	tests := []struct {
+		name         string
+		partnerFiles []*v1beta1.File
+		wantResult   ctrl.Result
+		wantState    v1beta1.FileState
+	}{
+		{
+			name:         "A pending Guest File held by the federation partner is polled again",
+			partnerFiles: []*v1beta1.File{file},
+			wantResult:   ctrl.Result{RequeueAfter: time.Minute},
+			wantState:    v1beta1.FileStatePending,
+		},
+		{
+			name:      "A pending Guest File unknown to the federation partner is set in error",
+			wantState: v1beta1.FileStateError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.TODO()
+			cl, opgcmap, _, sch := prepareEnv([]client.Object{feder, file.DeepCopy()}, &ApiObjects{
+				Federations: []*v1beta1.Federation{feder},
+				Files:       tt.partnerFiles,
+			})
+			r := makeTestFileReconciler(cl, sch, opgcmap)
+			r.ResyncPeriod = time.Minute
+
+			gotResult, err := r.Reconcile(ctx, req)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantResult, gotResult)
+
+			var got v1beta1.File
+			require.NoError(t, cl.Get(ctx, req.NamespacedName, &got))
+			assert.Equal(t, tt.wantState, got.Status.State)
+			require.NotNil(t, got.Status.LastSyncTime)
+
+			// the next poll waits for the period
+			if tt.wantResult.RequeueAfter > 0 {
+				gotResult, err = r.Reconcile(ctx, req)
+				require.NoError(t, err)
+				assert.Greater(t, gotResult.RequeueAfter, time.Duration(0))
+				assert.LessOrEqual(t, gotResult.RequeueAfter, time.Minute)
+			}
+		})
+	}
+}
+
 type fileOpt func(*v1beta1.File)
 
 func fileWithFinalizer() fileOpt {
@@ -238,6 +315,12 @@ func fileWithDeletedAt(now time.Time) fileOpt {
 	}
 }
 
+func fileWithAnnotation(key, value string) fileOpt {
+	return func(f *v1beta1.File) {
+		metav1.SetMetaDataAnnotation(&f.ObjectMeta, key, value)
+	}
+}
+
 func fileWithState(state v1beta1.FileState) fileOpt {
 	return func(f *v1beta1.File) {
 		f.Status.State = state
@@ -263,8 +346,6 @@ func makeTestFile(fedCtxId string, opts ...fileOpt) *v1beta1.File {
 			Repo: v1beta1.Repo{
 				Type:     "private",
 				URL:      "https://harbor.example.com/repo",
-				Password: "pass",
-				Token:    "token",
 				UserName: "foo",
 			},
 			Image: v1beta1.Image{
@@ -0,0 +1,505 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"net/http"
+
+	opgmodels "github.com/neonephos-katalis/opg-ewbi-operator/api/ewbi/models"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/neonephos-katalis/opg-ewbi-operator/api/operator/v1beta1"
+	"github.com/neonephos-katalis/opg-ewbi-operator/internal/opg"
+)
+
+// ResourcePoolReconciler reconciles a ResourcePool object
+type ResourcePoolReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+	opg.OPGClientsMapInterface
+}
+
+// +kubebuilder:rbac:groups=opg.ewbi.nby.one,resources=resourcepools,verbs=*,namespace=foo
+// +kubebuilder:rbac:groups=opg.ewbi.nby.one,resources=resourcepools/status,verbs=get;update;patch,namespace=foo
+// +kubebuilder:rbac:groups=opg.ewbi.nby.one,resources=resourcepools/finalizers,verbs=update,namespace=foo
+
+// Reconcile is part of the main kubernetes reconciliation loop which aims to
+// move the current state of the cluster closer to the desired state.
+// On the guest the pool is reserved on the partner OP, on the host the
+// requested flavours are reserved until the reservation expires and the
+// guest is notified of every change.
+//
+// For more details, check Reconcile and its Result here:
+// - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.19.4/pkg/reconcile
+func (r *ResourcePoolReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	res, err := r.reconcile(ctx, req)
+	if upErr := updateConditions(ctx, r.Client, req.NamespacedName, &v1beta1.ResourcePool{}, setResourcePoolConditions); upErr != nil && err == nil {
+		return ctrl.Result{}, upErr
+	}
+	return res, err
+}
+
+// reconcile syncs the ResourcePool, its conditions are set afterwards from the resulting state
+func (r *ResourcePoolReconciler) reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx).WithValues("name", req.Name, "namespace", req.Namespace)
+	log.Info("starting reconcile function for resource pool")
+	defer log.Info("end reconcile for resource pool")
+
+	// Getting main resource pool or requeue
+	var p v1beta1.ResourcePool
+	if err := r.Get(ctx, req.NamespacedName, &p); err != nil {
+		if apierrors.IsNotFound(err) {
+			log.Info("resource pool object not found")
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "error getting resource pool object")
+		return ctrl.Result{}, err
+	}
+
+	// Getting resource pool's federation or requeue by using federation-context-id label
+	isGuest := IsGuestResource(p.Labels)
+	extraLabels := map[string]string{}
+	if isGuest {
+		extraLabels[v1beta1.FederationRelationLabel] = string(v1beta1.FederationRelationGuest)
+	} else {
+		extraLabels[v1beta1.FederationRelationLabel] = string(v1beta1.FederationRelationHost)
+	}
+	feder, err := GetFederationByContextId(ctx, r.Client, p.Labels[v1beta1.FederationContextIdLabel], extraLabels)
+	if err != nil {
+		log.Error(err, "A ResourcePool should always have a parent federation")
+		p.Status.State = v1beta1.ResourcePoolStateFailed
+		if upErr := r.Status().Update(ctx, p.DeepCopy()); upErr != nil {
+			log.Error(upErr, errorUpdatingResourceStatusMsg)
+		}
+		return ctrl.Result{}, err
+	}
+
+	if p.GetDeletionTimestamp().IsZero() {
+		if controllerutil.AddFinalizer(&p, v1beta1.ResourcePoolFinalizer) {
+			log.Info("Added finalizer to resource pool")
+			if err := r.Update(ctx, p.DeepCopy()); err != nil {
+				log.Info("unable to Update resource pool with finalizer")
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{}, nil
+		}
+	} else {
+		if isGuest && p.Status.State != "" && p.Status.State != v1beta1.ResourcePoolStateFailed {
+			if err := r.handleExternalResourcePoolDeletion(ctx, &p, feder); err != nil {
+				log.Error(err, "error deleting resource pool")
+				return opgResult(err)
+			}
+		}
+		// if external resource pool is correctly deleted, we can remove the finalizer
+		if controllerutil.RemoveFinalizer(&p, v1beta1.ResourcePoolFinalizer) {
+			log.Info("Removed basic finalizer for resource pool")
+			if err := r.Update(ctx, p.DeepCopy()); err != nil {
+				log.Error(err, "update failed while removing finalizers")
+				return ctrl.Result{}, err
+			}
+		}
+		return ctrl.Result{}, nil
+	}
+
+	if isGuest {
+		switch {
+		case p.Status.State == "":
+			if err := r.handleExternalResourcePoolCreation(ctx, &p, feder); err != nil {
+				log.Error(err, "error creating resource pool")
+				return opgResult(err)
+			}
+		case p.Status.ObservedGeneration > 0 && p.Generation > p.Status.ObservedGeneration:
+			if err := r.handleExternalResourcePoolUpdate(ctx, &p, feder); err != nil {
+				log.Error(err, "error updating resource pool")
+				return opgResult(err)
+			}
+		default:
+			log.Info("Resource pool state", "state", p.Status.State)
+		}
+		return ctrl.Result{}, nil
+	}
+
+	now := metav1.Now()
+	switch {
+	case p.Status.State == v1beta1.ResourcePoolStateExpired,
+		p.Status.State == v1beta1.ResourcePoolStateFailed && p.Generation <= p.Status.ObservedGeneration:
+		// a pool failing for lack of capacity is reserved again when its spec changes
+		log.Info("Resource pool state", "state", p.Status.State)
+		return ctrl.Result{}, nil
+	case p.Status.State == "" || p.Generation > p.Status.ObservedGeneration:
+		available, err := r.zoneAvailableResources(ctx, &p)
+		if err != nil {
+			log.Error(err, "error getting the resources available in the zone")
+			return ctrl.Result{}, err
+		}
+		if reserveResourcePool(&p, now, available) {
+			log.Info("Reserved resource pool", "expirationTime", p.Status.ExpirationTime)
+		} else {
+			log.Info("Not enough resources left in the zone for the resource pool", "zone", p.Spec.ZoneId)
+		}
+	case !now.Before(p.Status.ExpirationTime):
+		p.Status.State = v1beta1.ResourcePoolStateExpired
+		p.Status.GrantedFlavours = nil
+		p.Status.Reserved = nil
+		log.Info("Resource pool reservation expired")
+	default:
+		return ctrl.Result{RequeueAfter: p.Status.ExpirationTime.Sub(now.Time)}, nil
+	}
+	if err := r.Status().Update(ctx, &p); err != nil {
+		log.Error(err, errorUpdatingResourceStatusMsg)
+		return ctrl.Result{}, err
+	}
+	if err := r.handleExternalResourcePoolCallback(ctx, &p, feder); err != nil {
+		log.Error(err, "error queueing resource pool callback")
+		return ctrl.Result{}, err
+	}
+	if p.Status.State == v1beta1.ResourcePoolStateReserved {
+		return ctrl.Result{RequeueAfter: p.Status.ExpirationTime.Sub(now.Time)}, nil
+	}
+	return ctrl.Result{}, nil
+}
+
+// setResourcePoolConditions sets the conditions and observedGeneration of the ResourcePool from its state
+func setResourcePoolConditions(p *v1beta1.ResourcePool) bool {
+	phase := syncInProgress
+	switch {
+	case p.Status.State == v1beta1.ResourcePoolStateFailed || p.Status.State == v1beta1.ResourcePoolStateExpired:
+		phase = syncFailed
+	case p.Status.ObservedGeneration < p.Generation:
+		// the spec changes weren't reserved yet
+	case p.Status.State == v1beta1.ResourcePoolStateReserved:
+		phase = syncCompleted
+	}
+	return setSyncConditions(&p.Status.Conditions, p.Generation, phase, string(p.Status.State))
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ResourcePoolReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1beta1.ResourcePool{}).
+		Named("resourcepool").
+		Complete(r)
+}
+
+// zoneAvailableResources returns the capacity of the zone of the pool not yet
+// reserved by the other pools, nil when the capacity of the zone is not limited
+func (r *ResourcePoolReconciler) zoneAvailableResources(ctx context.Context, p *v1beta1.ResourcePool) (corev1.ResourceList, error) {
+	zone := &v1beta1.AvailabilityZone{}
+	if err := r.Get(ctx, client.ObjectKey{Namespace: p.Namespace, Name: p.Spec.ZoneId}, zone); err != nil {
+		return nil, client.IgnoreNotFound(err)
+	}
+	if IsGuestResource(zone.Labels) || len(zone.Spec.Capacity) == 0 {
+		return nil, nil
+	}
+
+	pools := &v1beta1.ResourcePoolList{}
+	if err := r.List(ctx, pools, client.InNamespace(p.Namespace)); err != nil {
+		return nil, err
+	}
+	available := zone.Spec.Capacity.DeepCopy()
+	for _, other := range pools.Items {
+		if other.Name == p.Name || IsGuestResource(other.Labels) || other.Spec.ZoneId != p.Spec.ZoneId ||
+			other.Status.State != v1beta1.ResourcePoolStateReserved {
+			continue
+		}
+		for name, quantity := range other.Status.Reserved {
+			if left, ok := available[name]; ok {
+				left.Sub(quantity)
+				available[name] = left
+			}
+		}
+	}
+	return available, nil
+}
+
+// reserveResourcePool grants the flavours requested for the pool, the
+// reservation starts again every time the pool spec changes. Each flavour is
+// granted as many times as the available resources allow, down to its minimum
+// number, or else the pool fails and nothing is granted. Resources missing from
+// available, or a nil available, are not limited.
+func reserveResourcePool(p *v1beta1.ResourcePool, now metav1.Time, available corev1.ResourceList) bool {
+	d := p.Spec.ReserveDuration
+	expiration := metav1.NewTime(now.AddDate(int(d.Years), int(d.Months), int(d.Days)))
+	available = available.DeepCopy()
+
+	granted := make([]v1beta1.GrantedFlavour, 0, len(p.Spec.Flavours))
+	reserved := corev1.ResourceList{}
+	for _, f := range p.Spec.Flavours {
+		num := f.NumFlavour
+		for name, quantity := range f.Resources {
+			left, ok := available[name]
+			if !ok || quantity.IsZero() {
+				continue
+			}
+			num = min(num, int32(max(left.MilliValue(), 0)/quantity.MilliValue()))
+		}
+		minNum := f.MinNumFlavour
+		if minNum == 0 {
+			minNum = f.NumFlavour
+		}
+		if num < minNum {
+			p.Status.State = v1beta1.ResourcePoolStateFailed
+			p.Status.GrantedFlavours = nil
+			p.Status.Reserved = nil
+			p.Status.ObservedGeneration = p.Generation
+			return false
+		}
+		granted = append(granted, v1beta1.GrantedFlavour{
+			FlavourId:  f.FlavourId,
+			NumFlavour: num,
+		})
+		for name, quantity := range f.Resources {
+			total := quantity.DeepCopy()
+			total.Mul(int64(num))
+			if left, ok := available[name]; ok {
+				left.Sub(total)
+				available[name] = left
+			}
+			if r, ok := reserved[name]; ok {
+				total.Add(r)
+			}
+			reserved[name] = total
+		}
+	}
+
+	p.Status.State = v1beta1.ResourcePoolStateReserved
+	p.Status.ReservationTime = &now
+	p.Status.ExpirationTime = &expiration
+	p.Status.GrantedFlavours = granted
+	p.Status.Reserved = reserved
+	p.Status.ObservedGeneration = p.Generation
+	return true
+}
+
+func (r *ResourcePoolReconciler) handleExternalResourcePoolCreation(
+	ctx context.Context, p *v1beta1.ResourcePool, feder *v1beta1.Federation,
+) error {
+	log := log.FromContext(ctx)
+
+	reqBody := opgmodels.CreateResourcePoolsJSONRequestBody{
+		ResourceReservationCallbackLink: p.Spec.CallBackLink,
+	}
+	reqBody.ResRequest.PoolId = p.Labels[v1beta1.ExternalIdLabel]
+	reqBody.ResRequest.PoolName = p.Spec.PoolName
+	reqBody.ResRequest.ReserveDuration = reserveDurationModel(p.Spec.ReserveDuration)
+	for _, f := range p.Spec.Flavours {
+		flavour := struct {
+			FlavourId        opgmodels.FlavourId `json:"flavourId"`
+			MinNumOfFlavours *int32              `json:"minNumOfFlavours,omitempty"`
+			NumFlavour       int32               `json:"numFlavour"`
+		}{
+			FlavourId:  f.FlavourId,
+			NumFlavour: f.NumFlavour,
+		}
+		if f.MinNumFlavour > 0 {
+			flavour.MinNumOfFlavours = &f.MinNumFlavour
+		}
+		reqBody.ResRequest.Flavours = append(reqBody.ResRequest.Flavours, flavour)
+	}
+
+	res, err := r.GetOPGClient(
+		feder.Labels[v1beta1.ExternalIdLabel],
+		guestPartnerApiRoot(feder),
+		opgCredentials(feder, feder.Spec.GuestPartnerCredentials),
+	).CreateResourcePoolsWithResponse(
+		ctx,
+		feder.Status.FederationContextId,
+		p.Spec.ZoneId,
+		p.Spec.AppProviderId,
+		reqBody)
+	out := opg.Classify(res, err)
+	logOPGResponse(log, out)
+	switch out.Outcome {
+	case opg.OutcomeSuccess:
+		// the granted flavours are received in the resource reservation callback
+		p.Status.State = v1beta1.ResourcePoolStatePending
+		p.Status.ObservedGeneration = p.Generation
+		log.Info("Created external resource pool", "state", p.Status.State)
+	case opg.OutcomePermanent:
+		p.Status.State = v1beta1.ResourcePoolStateFailed
+	}
+	setPartnerConditions(&p.Status.Conditions, p.Generation, out)
+	if upErr := r.Status().Update(ctx, p); upErr != nil {
+		log.Error(upErr, errorUpdatingResourceStatusMsg)
+		return upErr
+	}
+	return out.Err()
+}
+
+// handleExternalResourcePoolUpdate sends the final number of each flavour and
+// the reservation duration to the partner OP when the pool spec changed since
+// it was last sent, flavours no longer in the spec are removed.
+func (r *ResourcePoolReconciler) handleExternalResourcePoolUpdate(
+	ctx context.Context, p *v1beta1.ResourcePool, feder *v1beta1.Federation,
+) error {
+	log := log.FromContext(ctx)
+
+	duration := reserveDurationModel(p.Spec.ReserveDuration)
+	reqBody := opgmodels.UpdateISVResPoolJSONRequestBody{}
+	flavourIds := make([]string, 0, len(p.Spec.Flavours))
+	for _, f := range p.Spec.Flavours {
+		flavourIds = append(flavourIds, f.FlavourId)
+		reqBody = append(reqBody, resPoolUpdateItem(f.FlavourId, f.NumFlavour, opgmodels.UpdateISVResPoolJSONBodyUpdateTypeADD, nil))
+	}
+	grantedIds := make([]string, 0, len(p.Status.GrantedFlavours))
+	for _, f := range p.Status.GrantedFlavours {
+		grantedIds = append(grantedIds, f.FlavourId)
+	}
+	for _, flavourId := range sliceDifference(grantedIds, flavourIds) {
+		reqBody = append(reqBody, resPoolUpdateItem(flavourId, 0, opgmodels.UpdateISVResPoolJSONBodyUpdateTypeREMOVE, nil))
+	}
+	reqBody = append(reqBody, resPoolUpdateItem("", 0, opgmodels.UpdateISVResPoolJSONBodyUpdateTypeDURATION, &duration))
+
+	res, err := r.GetOPGClient(
+		feder.Labels[v1beta1.ExternalIdLabel],
+		guestPartnerApiRoot(feder),
+		opgCredentials(feder, feder.Spec.GuestPartnerCredentials),
+	).UpdateISVResPoolWithResponse(
+		ctx,
+		feder.Status.FederationContextId,
+		p.Spec.ZoneId,
+		p.Spec.AppProviderId,
+		p.Labels[v1beta1.ExternalIdLabel],
+		reqBody)
+	out := opg.Classify(res, err)
+	logOPGResponse(log, out)
+	switch out.Outcome {
+	case opg.OutcomeSuccess:
+		log.Info("Updated external resource pool", "generation", p.Generation)
+		p.Status.ObservedGeneration = p.Generation
+	case opg.OutcomePermanent:
+		p.Status.State = v1beta1.ResourcePoolStateFailed
+		p.Status.ObservedGeneration = p.Generation
+	}
+	setPartnerConditions(&p.Status.Conditions, p.Generation, out)
+	if upErr := r.Status().Update(ctx, p); upErr != nil {
+		log.Error(upErr, errorUpdatingResourceStatusMsg)
+		return upErr
+	}
+	return out.Err()
+}
+
+func (r *ResourcePoolReconciler) handleExternalResourcePoolDeletion(
+	ctx context.Context, p *v1beta1.ResourcePool, feder *v1beta1.Federation,
+) error {
+	log := log.FromContext(ctx)
+	log.Info("Deleting external resource pool")
+
+	res, err := r.GetOPGClient(
+		feder.Labels[v1beta1.ExternalIdLabel],
+		guestPartnerApiRoot(feder),
+		opgCredentials(feder, feder.Spec.GuestPartnerCredentials),
+	).RemoveISVResPoolWithResponse(
+		ctx,
+		feder.Status.FederationContextId,
+		p.Spec.ZoneId,
+		p.Spec.AppProviderId,
+		p.Labels[v1beta1.ExternalIdLabel],
+	)
+	out := opg.Classify(res, err,
+		// the partner OP no longer holds the pool
+		opg.WithOutcome(http.StatusNotFound, opg.OutcomeSuccess),
+		// instances still use the pool, the deletion is retried
+		opg.WithOutcome(http.StatusConflict, opg.OutcomeRetryable),
+	)
+	logOPGResponse(log, out)
+	if out.Outcome == opg.OutcomeSuccess {
+		log.Info("Deleted")
+		return nil
+	}
+	if setPartnerConditions(&p.Status.Conditions, p.Generation, out) {
+		if upErr := r.Status().Update(ctx, p); upErr != nil {
+			log.Error(upErr, errorUpdatingResourceStatusMsg)
+			return upErr
+		}
+	}
+	return out.Err()
+}
+
+// handleExternalResourcePoolCallback queues the notification to the guest of the
+// flavours granted for the pool, none once the reservation expired
+func (r *ResourcePoolReconciler) handleExternalResourcePoolCallback(
+	ctx context.Context, p *v1beta1.ResourcePool, feder *v1beta1.Federation,
+) error {
+	log := log.FromContext(ctx)
+	// Check if callback is configured
+	if feder.Spec.Partner.StatusLink == "" {
+		log.Info("No callback StatusLink configured in Federation, skipping ResourcePool callback")
+		return nil
+	}
+	log.Info("Queueing ResourcePool callback to Guest",
+		"poolId", p.Labels[v1beta1.ExternalIdLabel],
+		"state", p.Status.State,
+		"statusLink", feder.Spec.Partner.StatusLink)
+
+	federationContextId := p.Labels[v1beta1.FederationContextIdLabel]
+	callbackBody := opgmodels.ResourceReservationCallbackLinkJSONRequestBody{
+		AppProviderId:       p.Spec.AppProviderId,
+		FederationContextId: &federationContextId,
+		PoolId:              p.Labels[v1beta1.ExternalIdLabel],
+		ZoneId:              p.Spec.ZoneId,
+	}
+	callbackBody.GrantedFlavours = make([]struct {
+		FlavourId  opgmodels.FlavourId `json:"flavourId"`
+		NumFlavour int32               `json:"numFlavour"`
+	}, 0, len(p.Status.GrantedFlavours))
+	for _, f := range p.Status.GrantedFlavours {
+		callbackBody.GrantedFlavours = append(callbackBody.GrantedFlavours, struct {
+			FlavourId  opgmodels.FlavourId `json:"flavourId"`
+			NumFlavour int32               `json:"numFlavour"`
+		}{FlavourId: f.FlavourId, NumFlavour: f.NumFlavour})
+	}
+	return enqueueCallback(ctx, r.Client, feder, p, v1beta1.CallbackKindResourcePool, callbackBody)
+}
+
+func reserveDurationModel(d v1beta1.ReserveDuration) opgmodels.ResourceReservationDuration {
+	return opgmodels.ResourceReservationDuration{
+		NumOfDays:   &d.Days,
+		NumOfMonths: &d.Months,
+		NumOfYears:  &d.Years,
+	}
+}
+
+func resPoolUpdateItem(
+	flavourId string, count int32, updateType opgmodels.UpdateISVResPoolJSONBodyUpdateType,
+	duration *opgmodels.ResourceReservationDuration,
+) struct {
+	Count           int32                                        `json:"count"`
+	FlavourId       opgmodels.FlavourId                          `json:"flavourId"`
+	ReserveDuration *opgmodels.ResourceReservationDuration       `json:"reserveDuration,omitempty"`
+	UpdateType      opgmodels.UpdateISVResPoolJSONBodyUpdateType `json:"updateType"`
+} {
+	return struct {
+		Count           int32                                        `json:"count"`
+		FlavourId       opgmodels.FlavourId                          `json:"flavourId"`
+		ReserveDuration *opgmodels.ResourceReservationDuration       `json:"reserveDuration,omitempty"`
+		UpdateType      opgmodels.UpdateISVResPoolJSONBodyUpdateType `json:"updateType"`
+	}{
+		Count:           count,
+		FlavourId:       flavourId,
+		ReserveDuration: duration,
+		UpdateType:      updateType,
+	}
+}
@@ -0,0 +1,248 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/neonephos-katalis/opg-ewbi-operator/api/operator/v1beta1"
+	"github.com/neonephos-katalis/opg-ewbi-operator/internal/opg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+
+	// ResourcePool
+	testPoolName       = "pool001"
+	testPoolExternalId = "pool-00000000-0000-0000-0000-000000000001"
+	testPoolZoneId     = "zone-1"
+	testPoolFlavourId  = "small"
+)
+
+func TestResourcePoolReconciler(t *testing.T) {
+	feder := makeTestFederation(testFederationName, withFederationContextId(testFederationContextId),
+		federationWithFederationRelation(v1beta1.FederationRelationHost))
+	// the zone has 8 cpus, 4 of them reserved by another pool
+	zone := makeTestAvailabilityZone(azWithName(testPoolZoneId), azWithCapacity("8"))
+	other := makeTestResourcePool(poolWithName("pool002"), poolWithFlavour(2, 0), poolWithReservation(2, time.Hour))
+
+	type response struct {
+		wantRequeue     bool
+		wantStatusState v1beta1.ResourcePoolState
+		wantGranted     []v1beta1.GrantedFlavour
+		wantReservedCPU string
+		wantCallback    bool
+	}
+	tests := []struct {
+		name      string
+		resources []client.Object
+		resp      response
+	}{
+		{
+			name:      "A host ResourcePool is granted the flavours left in its zone",
+			resources: []client.Object{feder, zone, other, makeTestResourcePool(poolWithFlavour(3, 1))},
+			resp: response{
+				wantRequeue:     true,
+				wantStatusState: v1beta1.ResourcePoolStateReserved,
+				wantGranted:     []v1beta1.GrantedFlavour{{FlavourId: testPoolFlavourId, NumFlavour: 2}},
+				wantReservedCPU: "4",
+				wantCallback:    true,
+			},
+		},
+		{
+			name:      "A host ResourcePool without a limited zone is granted all its flavours",
+			resources: []client.Object{feder, other, makeTestResourcePool(poolWithFlavour(3, 1))},
+			resp: response{
+				wantRequeue:     true,
+				wantStatusState: v1beta1.ResourcePoolStateReserved,
+				wantGranted:     []v1beta1.GrantedFlavour{{FlavourId: testPoolFlavourId, NumFlavour: 3}},
+				wantReservedCPU: "6",
+				wantCallback:    true,
+			},
+		},
+		{
+			name:      "A host ResourcePool fails when its zone can't grant its minimum number of flavours",
+			resources: []client.Object{feder, zone, other, makeTestResourcePool(poolWithFlavour(3, 0))},
+			resp: response{
+				wantStatusState: v1beta1.ResourcePoolStateFailed,
+				wantCallback:    true,
+			},
+		},
+		{
+			name: "A host ResourcePool is reserved again when its spec changes",
+			resources: []client.Object{feder, zone, other,
+				makeTestResourcePool(poolWithFlavour(1, 0), poolWithReservation(2, time.Hour), poolWithGeneration(2, 1))},
+			resp: response{
+				wantRequeue:     true,
+				wantStatusState: v1beta1.ResourcePoolStateReserved,
+				wantGranted:     []v1beta1.GrantedFlavour{{FlavourId: testPoolFlavourId, NumFlavour: 1}},
+				wantReservedCPU: "2",
+				wantCallback:    true,
+			},
+		},
+		{
+			name: "A host ResourcePool reservation expires",
+			resources: []client.Object{feder, zone, other,
+				makeTestResourcePool(poolWithFlavour(2, 0), poolWithReservation(2, -time.Minute))},
+			resp: response{
+				wantStatusState: v1beta1.ResourcePoolStateExpired,
+				wantCallback:    true,
+			},
+		},
+		{
+			name: "An expired host ResourcePool is not reserved again when its spec changes",
+			resources: []client.Object{feder, zone, other,
+				makeTestResourcePool(poolWithFlavour(1, 0), poolWithState(v1beta1.ResourcePoolStateExpired), poolWithGeneration(2, 1))},
+			resp: response{
+				wantStatusState: v1beta1.ResourcePoolStateExpired,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.TODO()
+			req := ctrl.Request{NamespacedName: types.NamespacedName{Name: testPoolName, Namespace: testNamespace}}
+			cl, opgcmap, _, sch := prepareEnv(tt.resources, &ApiObjects{})
+			r := makeTestResourcePoolReconciler(cl, sch, opgcmap)
+
+			gotResult, err := r.Reconcile(ctx, req)
+			require.NoError(t, err)
+			assert.Equal(t, tt.resp.wantRequeue, gotResult.RequeueAfter > 0)
+
+			var got v1beta1.ResourcePool
+			require.NoError(t, cl.Get(ctx, req.NamespacedName, &got))
+			assert.Equal(t, tt.resp.wantStatusState, got.Status.State)
+			assert.Equal(t, tt.resp.wantGranted, got.Status.GrantedFlavours)
+			if tt.resp.wantReservedCPU != "" {
+				assert.True(t, resource.MustParse(tt.resp.wantReservedCPU).Equal(got.Status.Reserved[corev1.ResourceCPU]))
+			}
+
+			var callbacks v1beta1.CallbackList
+			require.NoError(t, cl.List(ctx, &callbacks, client.InNamespace(testNamespace)))
+			assert.Equal(t, tt.resp.wantCallback, len(callbacks.Items) > 0)
+		})
+	}
+}
+
+func azWithName(name string) azOpt {
+	return func(a *v1beta1.AvailabilityZone) {
+		a.Name = name
+	}
+}
+
+func azWithCapacity(cpu string) azOpt {
+	return func(a *v1beta1.AvailabilityZone) {
+		a.Spec.Capacity = corev1.ResourceList{corev1.ResourceCPU: resource.MustParse(cpu)}
+	}
+}
+
+type poolOpt func(*v1beta1.ResourcePool)
+
+func poolWithName(name string) poolOpt {
+	return func(p *v1beta1.ResourcePool) {
+		p.Name = name
+		p.Labels[v1beta1.ExternalIdLabel] = name
+	}
+}
+
+// poolWithFlavour requests num flavours of 2 cpus, at least minNum of them
+func poolWithFlavour(num, minNum int32) poolOpt {
+	return func(p *v1beta1.ResourcePool) {
+		p.Spec.Flavours = []v1beta1.PoolFlavour{{
+			FlavourId:     testPoolFlavourId,
+			NumFlavour:    num,
+			MinNumFlavour: minNum,
+			Resources:     corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2")},
+		}}
+	}
+}
+
+// poolWithReservation grants num flavours of 2 cpus, expiring after expiresIn
+func poolWithReservation(num int32, expiresIn time.Duration) poolOpt {
+	return func(p *v1beta1.ResourcePool) {
+		now := metav1.Now()
+		expiration := metav1.NewTime(now.Add(expiresIn))
+		p.Status.State = v1beta1.ResourcePoolStateReserved
+		p.Status.ReservationTime = &now
+		p.Status.ExpirationTime = &expiration
+		p.Status.GrantedFlavours = []v1beta1.GrantedFlavour{{FlavourId: testPoolFlavourId, NumFlavour: num}}
+		p.Status.Reserved = corev1.ResourceList{corev1.ResourceCPU: *resource.NewQuantity(2*int64(num), resource.DecimalSI)}
+		p.Status.ObservedGeneration = p.Generation
+	}
+}
+
+func poolWithGeneration(generation, observedGeneration int64) poolOpt {
+	return func(p *v1beta1.ResourcePool) {
+		p.Generation = generation
+		p.Status.ObservedGeneration = observedGeneration
+	}
+}
+
+func poolWithState(state v1beta1.ResourcePoolState) poolOpt {
+	return func(p *v1beta1.ResourcePool) {
+		p.Status.State = state
+	}
+}
+
+func makeTestResourcePool(opts ...poolOpt) *v1beta1.ResourcePool {
+	p := &v1beta1.ResourcePool{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       testPoolName,
+			Namespace:  testNamespace,
+			Generation: 1,
+			Labels: map[string]string{
+				v1beta1.FederationContextIdLabel: testFederationContextId,
+				v1beta1.ExternalIdLabel:          testPoolExternalId,
+				v1beta1.FederationRelationLabel:  string(v1beta1.FederationRelationHost),
+			},
+		},
+		Spec: v1beta1.ResourcePoolSpec{
+			AppProviderId:   testAppProvider,
+			ZoneId:          testPoolZoneId,
+			ReserveDuration: v1beta1.ReserveDuration{Days: 1},
+		},
+	}
+	controllerutil.AddFinalizer(p, v1beta1.ResourcePoolFinalizer)
+	for _, o := range opts {
+		o(p)
+	}
+	return p
+}
+
+func makeTestResourcePoolReconciler(
+	client client.Client,
+	sch *runtime.Scheme,
+	opgClients opg.OPGClientsMapInterface,
+) *ResourcePoolReconciler {
+	r := &ResourcePoolReconciler{
+		Client:                 client,
+		Scheme:                 sch,
+		OPGClientsMapInterface: opgClients,
+	}
+	return r
+}
@@ -0,0 +1,45 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// DefaultResyncPeriod is the interval between the polls of the partner OP for the
+// state of the guest resources, a fallback for the callbacks that never arrive.
+const DefaultResyncPeriod = 5 * time.Minute
+
+// resyncDelay returns the time left until the state of a resource last polled
+// at lastSync should be polled again, 0 if it is due.
+func resyncDelay(period time.Duration, lastSync *metav1.Time, now time.Time) time.Duration {
+	if lastSync == nil {
+		return 0
+	}
+	return max(lastSync.Add(period).Sub(now), 0)
+}
+
+// resyncResult requeues the resource for its next poll until its state is final.
+func resyncResult(period time.Duration, final bool) ctrl.Result {
+	if final || period <= 0 {
+		return ctrl.Result{}
+	}
+	return ctrl.Result{RequeueAfter: period}
+}
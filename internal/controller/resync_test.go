@@ -0,0 +1,46 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func TestResyncDelay(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name     string
+		lastSync *metav1.Time
+		expected time.Duration
+	}{
+		{
+			name:     "Never polled is due",
+			lastSync: nil,
+			expected: 0,
+		},
+		{
+			name:     "Polled within the period waits for the rest of it",
+			lastSync: &metav1.Time{Time: now.Add(-time.Minute)},
+			expected: 4 * time.Minute,
+		},
+		{
+			name:     "Polled before the period is due",
+			lastSync: &metav1.Time{Time: now.Add(-10 * time.Minute)},
+			expected: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, resyncDelay(5*time.Minute, tt.lastSync, now))
+		})
+	}
+}
+
+func TestResyncResult(t *testing.T) {
+	assert.Equal(t, ctrl.Result{RequeueAfter: time.Minute}, resyncResult(time.Minute, false))
+	assert.Equal(t, ctrl.Result{}, resyncResult(time.Minute, true))
+	assert.Equal(t, ctrl.Result{}, resyncResult(0, false))
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/neonephos-katalis/opg-ewbi-operator/api/operator/v1beta1"
+	"github.com/neonephos-katalis/opg-ewbi-operator/internal/opg"
+)
+
+const (
+	// DefaultRetryInitialBackoff is the delay before retrying a request for a
+	// resource that failed with a transient error, doubled on every attempt
+	DefaultRetryInitialBackoff = opg.DefaultRetryAfter
+	// DefaultRetryMaxBackoff is the maximum delay between the retries of a request
+	DefaultRetryMaxBackoff = 10 * time.Minute
+)
+
+// recordAttempt records the outcome of a request to the partner OP in the
+// attempts and last error of the resource. The retry of a failed request is
+// delayed with an exponential backoff, or the Retry-After of the partner when longer.
+func recordAttempt(attempts *int32, lastError *string, out *opg.Response) {
+	if out.Outcome == opg.OutcomeSuccess {
+		*attempts, *lastError = 0, ""
+		return
+	}
+	*attempts++
+	*lastError = out.Error()
+	out.RetryAfter = max(out.RetryAfter, backoffDelay(*attempts, DefaultRetryInitialBackoff, DefaultRetryMaxBackoff))
+}
+
+// handleRetryAnnotation removes the RetryAnnotation from a guest resource. When
+// the resource failed, reset is called first to clear its state, so that its
+// request is sent again to the partner OP.
+func handleRetryAnnotation(ctx context.Context, c client.Client, obj client.Object, failed bool, reset func()) error {
+	log := log.FromContext(ctx)
+	if failed {
+		reset()
+		if err := c.Status().Update(ctx, obj); err != nil {
+			log.Error(err, errorUpdatingResourceStatusMsg)
+			return err
+		}
+		log.Info("Retrying failed resource at the partner OP")
+	} else {
+		log.Info("Ignoring retry annotation, the resource has not failed")
+	}
+	annotations := obj.GetAnnotations()
+	delete(annotations, v1beta1.RetryAnnotation)
+	obj.SetAnnotations(annotations)
+	if err := c.Update(ctx, obj); err != nil {
+		log.Error(err, "unable to remove retry annotation")
+		return err
+	}
+	return nil
+}
+
+// backoffDelay returns the delay before the next attempt, initial doubled for
+// every attempt after the first one up to max
+func backoffDelay(attempts int32, initial, max time.Duration) time.Duration {
+	d := initial
+	for i := int32(1); i < attempts && d < max; i++ {
+		d *= 2
+	}
+	return min(d, max)
+}
@@ -0,0 +1,48 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/neonephos-katalis/opg-ewbi-operator/internal/opg"
+)
+
+func TestRecordAttempt(t *testing.T) {
+	var attempts int32
+	var lastError string
+
+	out := &opg.Response{Outcome: opg.OutcomeRetryable, StatusCode: 503, RetryAfter: opg.DefaultRetryAfter}
+	recordAttempt(&attempts, &lastError, out)
+	assert.Equal(t, int32(1), attempts)
+	assert.Equal(t, out.Error(), lastError)
+	assert.Equal(t, DefaultRetryInitialBackoff, out.RetryAfter)
+
+	out = &opg.Response{Outcome: opg.OutcomeRetryable, StatusCode: 503, RetryAfter: opg.DefaultRetryAfter}
+	recordAttempt(&attempts, &lastError, out)
+	assert.Equal(t, int32(2), attempts)
+	assert.Equal(t, 2*DefaultRetryInitialBackoff, out.RetryAfter)
+
+	// a longer Retry-After of the partner is kept
+	out = &opg.Response{Outcome: opg.OutcomeRetryable, StatusCode: 429, RetryAfter: time.Hour}
+	recordAttempt(&attempts, &lastError, out)
+	assert.Equal(t, int32(3), attempts)
+	assert.Equal(t, time.Hour, out.RetryAfter)
+
+	recordAttempt(&attempts, &lastError, &opg.Response{Outcome: opg.OutcomeSuccess, StatusCode: 200})
+	assert.Zero(t, attempts)
+	assert.Empty(t, lastError)
+}
+
+func Test_backoffDelay(t *testing.T) {
+	for attempts, want := range map[int32]time.Duration{
+		1:  5 * time.Second,
+		2:  10 * time.Second,
+		4:  40 * time.Second,
+		10: 5 * time.Minute,
+		99: 5 * time.Minute,
+	} {
+		assert.Equal(t, want, backoffDelay(attempts, 5*time.Second, 5*time.Minute), attempts)
+	}
+}
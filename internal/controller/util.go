@@ -19,12 +19,16 @@ package controller
 import (
 	"context"
 	"errors"
+	"fmt"
 
 	"github.com/go-logr/logr"
-	opgmodels "github.com/neonephos-katalis/opg-ewbi-operator/api/ewbi/models"
 	"github.com/neonephos-katalis/opg-ewbi-operator/api/operator/v1beta1"
+	"github.com/neonephos-katalis/opg-ewbi-operator/internal/opg"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/labels"
+	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 )
@@ -60,8 +64,25 @@ func GetFederationByContextId(
 	return &federList.Items[0], nil
 }
 
-func handleProblemDetails(log logr.Logger, code int, p *opgmodels.ProblemDetails) {
-	log.Info("response with error", "error", code, "details", p)
+// logOPGResponse logs the outcome and problem details of a request the partner OP didn't accept
+func logOPGResponse(log logr.Logger, res *opg.Response) {
+	if res.Outcome != opg.OutcomeSuccess {
+		log.Info("response with error", "outcome", res.Outcome, "error", res.StatusCode, "details", res.Error())
+	}
+}
+
+// opgResult returns the result of a reconcile that failed with err. The requests
+// the partner OP may accept later are retried after a delay and the rejected ones
+// aren't retried, other errors are retried with the controller backoff.
+func opgResult(err error) (ctrl.Result, error) {
+	var res *opg.Response
+	if !errors.As(err, &res) {
+		return ctrl.Result{}, err
+	}
+	if res.Outcome == opg.OutcomePermanent {
+		return ctrl.Result{}, nil
+	}
+	return ctrl.Result{RequeueAfter: res.RetryAfter}, nil
 }
 
 // returns true if LabelValue is v1beta1.FederationRelationGuest
@@ -69,3 +90,80 @@ func handleProblemDetails(log logr.Logger, code int, p *opgmodels.ProblemDetails
 func IsGuestResource(labels map[string]string) bool {
 	return labels[v1beta1.FederationRelationLabel] == string(v1beta1.FederationRelationGuest)
 }
+
+// sliceDifference returns the elements of a that are not present in b
+func sliceDifference(a, b []string) []string {
+	set := make(map[string]bool, len(b))
+	for _, v := range b {
+		set[v] = true
+	}
+	diff := []string{}
+	for _, v := range a {
+		if !set[v] {
+			diff = append(diff, v)
+		}
+	}
+	return diff
+}
+
+// guestPartnerApiRoot returns the base URL of the partner OP federation API,
+// falling back to the TokenUrl used for it before GuestPartnerApiRoot existed
+func guestPartnerApiRoot(f *v1beta1.Federation) string {
+	if f.Spec.GuestPartnerApiRoot != "" {
+		return f.Spec.GuestPartnerApiRoot
+	}
+	return f.Spec.GuestPartnerCredentials.TokenUrl
+}
+
+// opgCredentials returns the credentials used by the OPG clients of the
+// Federation, the client secret and certificates are looked up in the Federation namespace
+func opgCredentials(f *v1beta1.Federation, creds v1beta1.FederationCredentials) opg.Credentials {
+	res := opg.Credentials{
+		ClientID: creds.ClientId,
+		TokenURL: creds.TokenUrl,
+	}
+	if creds.ClientSecretRef != nil {
+		res.ClientSecretRef = opg.SecretKeyRef{
+			Namespace: f.Namespace,
+			Name:      creds.ClientSecretRef.Name,
+			Key:       creds.ClientSecretRef.Key,
+		}
+	}
+	if f.Spec.TLS != nil {
+		if ref := f.Spec.TLS.ClientCertificateSecretRef; ref != nil {
+			res.TLS.ClientCertificate = opg.SecretKeyRef{Namespace: f.Namespace, Name: ref.Name}
+		}
+		if ref := f.Spec.TLS.CASecretRef; ref != nil {
+			res.TLS.CA = opg.SecretKeyRef{Namespace: f.Namespace, Name: ref.Name, Key: ref.Key}
+		}
+	}
+	if f.Spec.RequestTimeout != nil {
+		res.Timeout = f.Spec.RequestTimeout.Duration
+	}
+	return res
+}
+
+// resolveSecretKeyRef returns the value of the key referenced by ref in a Secret of namespace,
+// an empty string is returned when ref is nil
+func resolveSecretKeyRef(
+	ctx context.Context, c client.Reader, namespace string, ref *corev1.SecretKeySelector,
+) (string, error) {
+	if ref == nil {
+		return "", nil
+	}
+	secret := &corev1.Secret{}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: ref.Name}, secret); err != nil {
+		if apierrors.IsNotFound(err) && ref.Optional != nil && *ref.Optional {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to get secret %s/%s: %w", namespace, ref.Name, err)
+	}
+	value, ok := secret.Data[ref.Key]
+	if !ok {
+		if ref.Optional != nil && *ref.Optional {
+			return "", nil
+		}
+		return "", fmt.Errorf("key %s not found in secret %s/%s", ref.Key, namespace, ref.Name)
+	}
+	return string(value), nil
+}
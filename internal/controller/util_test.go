@@ -1,10 +1,19 @@
 package controller
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/neonephos-katalis/opg-ewbi-operator/api/operator/v1beta1"
+	"github.com/neonephos-katalis/opg-ewbi-operator/internal/opg"
 	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 )
 
 func TestIsGuestResource(t *testing.T) {
@@ -63,3 +72,100 @@ func TestIsGuestResource(t *testing.T) {
 		})
 	}
 }
+
+func TestResolveSecretKeyRef(t *testing.T) {
+	c := fake.NewClientBuilder().WithObjects(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "file-credentials", Namespace: testNamespace},
+		Data:       map[string][]byte{"password": []byte("pass")},
+	}).Build()
+	ref := func(name, key string, optional bool) *corev1.SecretKeySelector {
+		return &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: name},
+			Key:                  key,
+			Optional:             &optional,
+		}
+	}
+
+	tests := []struct {
+		name     string
+		ref      *corev1.SecretKeySelector
+		expected string
+		wantErr  bool
+	}{
+		{
+			name: "Nil reference",
+		},
+		{
+			name:     "Existing key",
+			ref:      ref("file-credentials", "password", false),
+			expected: "pass",
+		},
+		{
+			name:    "Missing key",
+			ref:     ref("file-credentials", "token", false),
+			wantErr: true,
+		},
+		{
+			name:    "Missing secret",
+			ref:     ref("missing", "password", false),
+			wantErr: true,
+		},
+		{
+			name: "Missing optional secret",
+			ref:  ref("missing", "password", true),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := resolveSecretKeyRef(context.Background(), c, testNamespace, tt.ref)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestOPGResult(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected ctrl.Result
+		wantErr  bool
+	}{
+		{
+			name:     "Retryable",
+			err:      &opg.Response{Outcome: opg.OutcomeRetryable, RetryAfter: time.Minute},
+			expected: ctrl.Result{RequeueAfter: time.Minute},
+		},
+		{
+			name:     "Auth",
+			err:      fmt.Errorf("creating: %w", &opg.Response{Outcome: opg.OutcomeAuth, RetryAfter: time.Second}),
+			expected: ctrl.Result{RequeueAfter: time.Second},
+		},
+		{
+			name: "Permanent",
+			err:  &opg.Response{Outcome: opg.OutcomePermanent},
+		},
+		{
+			name:    "Other error",
+			err:     errors.New("conflict"),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := opgResult(tt.err)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
@@ -1,23 +1,45 @@
 package opg
 
 import (
+	"cmp"
 	"context"
 	"crypto/tls"
 	"errors"
+	"fmt"
 	"net/http"
 	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	opgc "github.com/neonephos-katalis/opg-ewbi-operator/api/ewbi/client"
 )
 
+// DefaultRequestTimeout is the limit for each request to a partner OP, so that a
+// hanging partner doesn't block the reconcile workers
+const DefaultRequestTimeout = 30 * time.Second
+
 type OPGClientsMap struct {
-	opgClients         map[string]opgc.ClientWithResponsesInterface
+	opgClients         map[string]opgClientEntry
 	mutex              *sync.Mutex
 	insecureSkipVerify bool
+	secretReader       client.Reader
+	requestTimeout     time.Duration
+}
+
+// opgClientEntry keeps the credentials a client was built with, so it is
+// rebuilt when they change, e.g. after a Secret rotation.
+// Clients set through SetOPGClient have no credentials and are never rebuilt.
+type opgClientEntry struct {
+	client opgc.ClientWithResponsesInterface
+	url    string
+	creds  *Credentials
 }
 
 type OPGClientsMapInterface interface {
-	GetOPGClient(fedId, url, client string) opgc.ClientWithResponsesInterface
+	GetOPGClient(fedId, url string, creds Credentials) opgc.ClientWithResponsesInterface
 	SetOPGClient(key string, m opgc.ClientWithResponsesInterface)
 }
 
@@ -25,8 +47,9 @@ type OPGClientsMapOpt func(*OPGClientsMap)
 
 func NewOPGClientsMap(opts ...OPGClientsMapOpt) OPGClientsMapInterface {
 	m := &OPGClientsMap{
-		opgClients: map[string]opgc.ClientWithResponsesInterface{},
-		mutex:      &sync.Mutex{},
+		opgClients:     map[string]opgClientEntry{},
+		mutex:          &sync.Mutex{},
+		requestTimeout: DefaultRequestTimeout,
 	}
 	for _, o := range opts {
 		o(m)
@@ -40,6 +63,22 @@ func WithInsecureSkipVerify() OPGClientsMapOpt {
 	}
 }
 
+// WithRequestTimeout sets the limit for each request of the clients whose
+// Credentials have no Timeout, 0 disables it.
+func WithRequestTimeout(d time.Duration) OPGClientsMapOpt {
+	return func(m *OPGClientsMap) {
+		m.requestTimeout = d
+	}
+}
+
+// WithSecretReader sets the reader used to get the client secrets referenced
+// by the Credentials, required to enable the OAuth2 client credentials.
+func WithSecretReader(r client.Reader) OPGClientsMapOpt {
+	return func(m *OPGClientsMap) {
+		m.secretReader = r
+	}
+}
+
 func WithInsecureSkipVerifyClientOption(insecureSkipVerify bool) opgc.ClientOption {
 	return func(c *opgc.Client) error {
 
@@ -47,42 +86,83 @@ func WithInsecureSkipVerifyClientOption(insecureSkipVerify bool) opgc.ClientOpti
 			return errors.New("opgClient already exists and may not support the insecureSkipVerify interface")
 		}
 
-		tr := http.DefaultTransport.(*http.Transport).Clone()
-		tr.TLSClientConfig = &tls.Config{InsecureSkipVerify: insecureSkipVerify}
-		c.Client = &http.Client{Transport: tr}
+		c.Client = newHTTPClient(insecureSkipVerify)
 
 		return nil
 	}
 }
 
-func (m *OPGClientsMap) GetOPGClient(fedId, url, client string) opgc.ClientWithResponsesInterface {
+func newHTTPClient(insecureSkipVerify bool) *http.Client {
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tr.TLSClientConfig = &tls.Config{InsecureSkipVerify: insecureSkipVerify}
+	return &http.Client{Transport: tr}
+}
+
+func (m *OPGClientsMap) GetOPGClient(fedId, url string, creds Credentials) opgc.ClientWithResponsesInterface {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
-	_, ok := m.opgClients[fedId]
-	if !ok {
+	entry, ok := m.opgClients[fedId]
+	if !ok || (entry.creds != nil && (*entry.creds != creds || entry.url != url)) {
 
 		opts := []opgc.ClientOption{
 			opgc.WithRequestEditorFn(func(ctx context.Context, req *http.Request) error {
-				req.Header.Add("X-Client-ID", client)
+				req.Header.Add("X-Client-ID", creds.ClientID)
 				return nil
 			}),
 		}
-		if m.insecureSkipVerify {
-			opts = append(opts, WithInsecureSkipVerifyClientOption(m.insecureSkipVerify))
+		httpClient := m.newHTTPClientFor(creds)
+		httpClient.Timeout = cmp.Or(creds.Timeout, m.requestTimeout)
+		if creds.oauth2Enabled() {
+			ts := NewClientCredentialsTokenSource(
+				httpClient, creds.TokenURL, creds.ClientID, m.clientSecretFunc(creds.ClientSecretRef),
+			)
+			opts = append(opts,
+				opgc.WithHTTPClient(&unauthorizedRetryDoer{doer: httpClient, ts: ts}),
+				opgc.WithRequestEditorFn(bearerTokenRequestEditor(ts)),
+			)
+		} else {
+			opts = append(opts, opgc.WithHTTPClient(httpClient))
 		}
 
 		newC, _ := opgc.NewClientWithResponses(
 			url,
 			opts...,
 		)
-		m.opgClients[fedId] = newC
+		m.opgClients[fedId] = opgClientEntry{client: newC, url: url, creds: &creds}
 	}
-	return m.opgClients[fedId]
+	return m.opgClients[fedId].client
 }
 
 func (m *OPGClientsMap) SetOPGClient(key string, c opgc.ClientWithResponsesInterface) {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
-	m.opgClients[key] = c
+	m.opgClients[key] = opgClientEntry{client: c}
+}
+
+// clientSecretFunc reads the client secret from the referenced Secret on every call,
+// so rotated secrets are used on the next token request.
+func (m *OPGClientsMap) clientSecretFunc(ref SecretKeyRef) ClientSecretFunc {
+	return func(ctx context.Context) (string, error) {
+		secret, err := m.getSecret(ctx, ref)
+		if err != nil {
+			return "", err
+		}
+		value, ok := secret.Data[ref.Key]
+		if !ok || len(value) == 0 {
+			return "", fmt.Errorf("key '%s' not found in secret %s/%s", ref.Key, ref.Namespace, ref.Name)
+		}
+		return string(value), nil
+	}
+}
+
+func (m *OPGClientsMap) getSecret(ctx context.Context, ref SecretKeyRef) (*corev1.Secret, error) {
+	if m.secretReader == nil {
+		return nil, errors.New("no secret reader configured to get the federation secrets")
+	}
+	secret := &corev1.Secret{}
+	if err := m.secretReader.Get(ctx, types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}, secret); err != nil {
+		return nil, fmt.Errorf("failed to get secret %s/%s: %w", ref.Namespace, ref.Name, err)
+	}
+	return secret, nil
 }
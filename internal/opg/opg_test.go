@@ -0,0 +1,64 @@
+package opg
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOPGClientsMapRequestTimeout(t *testing.T) {
+	release := make(chan struct{})
+	apiSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(apiSrv.Close)
+	t.Cleanup(func() { close(release) })
+
+	tests := []struct {
+		name    string
+		opts    []OPGClientsMapOpt
+		timeout time.Duration
+	}{
+		{
+			name: "Default of the clients map",
+			opts: []OPGClientsMapOpt{WithRequestTimeout(50 * time.Millisecond)},
+		},
+		{
+			name:    "Timeout of the partner credentials",
+			opts:    []OPGClientsMapOpt{WithRequestTimeout(time.Hour)},
+			timeout: 50 * time.Millisecond,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewOPGClientsMap(tt.opts...)
+			start := time.Now()
+			_, err := m.GetOPGClient("fed-1", apiSrv.URL, Credentials{ClientID: testClientID, Timeout: tt.timeout}).
+				DeleteFederationDetailsWithResponse(context.Background(), "fed-ctx")
+			require.Error(t, err)
+			assert.Less(t, time.Since(start), 5*time.Second)
+		})
+	}
+}
+
+func TestOPGClientsMapContextCancel(t *testing.T) {
+	apiSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	t.Cleanup(apiSrv.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := NewOPGClientsMap().GetOPGClient("fed-1", apiSrv.URL, Credentials{ClientID: testClientID}).
+		DeleteFederationDetailsWithResponse(ctx, "fed-ctx")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -0,0 +1,187 @@
+package opg
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+
+	opgmodels "github.com/neonephos-katalis/opg-ewbi-operator/api/ewbi/models"
+)
+
+// DefaultRetryAfter is the delay before retrying a request when the partner OP
+// doesn't send a Retry-After header.
+const DefaultRetryAfter = 30 * time.Second
+
+// Outcome classifies the response of the partner OP to a request.
+type Outcome string
+
+const (
+	OutcomeSuccess Outcome = "Success"
+	// OutcomePermanent, the request was rejected and would be rejected again
+	OutcomePermanent Outcome = "Permanent"
+	// OutcomeRetryable, the partner OP or the connection failed, the request may succeed later
+	OutcomeRetryable Outcome = "Retryable"
+	// OutcomeAuth, the credentials were rejected, the request may succeed once they are fixed
+	OutcomeAuth Outcome = "Auth"
+)
+
+// Response is the classified response of the partner OP to a request. It is
+// an error for every outcome but OutcomeSuccess, see Err.
+type Response struct {
+	Outcome    Outcome
+	StatusCode int
+	// Problem, details of the error sent by the partner OP, nil if there are none
+	Problem *opgmodels.ProblemDetails
+	// RetryAfter, delay before retrying the request for the retryable and auth outcomes
+	RetryAfter time.Duration
+	// Cause, error sending the request or reading its response
+	Cause error
+}
+
+// statusCoder is implemented by the responses of the generated client.
+type statusCoder interface {
+	StatusCode() int
+}
+
+// ClassifyOpt overrides the outcome of a status code, for the requests where
+// it has a specific meaning, e.g. a 404 deleting a resource.
+type ClassifyOpt func(*Response)
+
+// WithOutcome sets the outcome of the responses with statusCode.
+func WithOutcome(statusCode int, outcome Outcome) ClassifyOpt {
+	return func(r *Response) {
+		if r.Cause == nil && r.StatusCode == statusCode {
+			r.Outcome = outcome
+		}
+	}
+}
+
+// Classify classifies the result of a *WithResponse method of the generated
+// client, taking the problem details from the response of the status code.
+func Classify[R statusCoder](res R, err error, opts ...ClassifyOpt) *Response {
+	var r *Response
+	if err != nil || isNil(res) {
+		r = &Response{Outcome: OutcomeRetryable, RetryAfter: DefaultRetryAfter, Cause: err}
+		if r.Cause == nil {
+			r.Cause = errors.New("no response")
+		}
+	} else {
+		r = &Response{
+			Outcome:    StatusOutcome(res.StatusCode()),
+			StatusCode: res.StatusCode(),
+			Problem:    problemDetails(res),
+			RetryAfter: retryAfter(res),
+		}
+	}
+	for _, o := range opts {
+		o(r)
+	}
+	return r
+}
+
+// Err returns the response as an error, nil if the request succeeded.
+func (r *Response) Err() error {
+	if r.Outcome == OutcomeSuccess {
+		return nil
+	}
+	return r
+}
+
+func (r *Response) Error() string {
+	if r.Cause != nil {
+		return r.Cause.Error()
+	}
+	msg := fmt.Sprintf("partner OP returned status %d", r.StatusCode)
+	if detail := r.Detail(); detail != "" {
+		msg += ": " + detail
+	}
+	return msg
+}
+
+func (r *Response) Unwrap() error {
+	return r.Cause
+}
+
+// Detail returns the detail, or else the title, of the problem details.
+func (r *Response) Detail() string {
+	switch {
+	case r.Problem == nil:
+		return ""
+	case r.Problem.Detail != nil:
+		return *r.Problem.Detail
+	case r.Problem.Title != nil:
+		return *r.Problem.Title
+	}
+	return ""
+}
+
+// StatusOutcome returns the outcome of a response with statusCode.
+func StatusOutcome(statusCode int) Outcome {
+	switch {
+	case statusCode >= 200 && statusCode < 300:
+		return OutcomeSuccess
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		return OutcomeAuth
+	case statusCode == http.StatusRequestTimeout || statusCode == http.StatusTooManyRequests:
+		return OutcomeRetryable
+	case statusCode >= 400 && statusCode < 500:
+		return OutcomePermanent
+	}
+	// server errors and unexpected status codes
+	return OutcomeRetryable
+}
+
+// problemDetails returns the ApplicationproblemJSON<statusCode> field of the
+// response, or else its body when it holds problem details.
+func problemDetails(res any) *opgmodels.ProblemDetails {
+	v := reflect.Indirect(reflect.ValueOf(res))
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+	code := res.(statusCoder).StatusCode()
+	if f := v.FieldByName(fmt.Sprintf("ApplicationproblemJSON%d", code)); f.IsValid() && !f.IsNil() {
+		if p, ok := f.Interface().(*opgmodels.ProblemDetails); ok {
+			return p
+		}
+	}
+	if code < 300 {
+		return nil
+	}
+	if f := v.FieldByName("Body"); f.IsValid() && f.Kind() == reflect.Slice && f.Len() > 0 {
+		var p opgmodels.ProblemDetails
+		if json.Unmarshal(f.Bytes(), &p) == nil && (p.Detail != nil || p.Title != nil || p.Cause != nil) {
+			return &p
+		}
+	}
+	return nil
+}
+
+// retryAfter returns the delay of the Retry-After header of the response,
+// DefaultRetryAfter if it has none or it isn't a number of seconds.
+func retryAfter(res any) time.Duration {
+	v := reflect.Indirect(reflect.ValueOf(res))
+	if v.Kind() != reflect.Struct {
+		return DefaultRetryAfter
+	}
+	f := v.FieldByName("HTTPResponse")
+	if !f.IsValid() || f.IsNil() {
+		return DefaultRetryAfter
+	}
+	httpRes, ok := f.Interface().(*http.Response)
+	if !ok {
+		return DefaultRetryAfter
+	}
+	if s, err := strconv.Atoi(httpRes.Header.Get("Retry-After")); err == nil && s > 0 {
+		return time.Duration(s) * time.Second
+	}
+	return DefaultRetryAfter
+}
+
+func isNil(v any) bool {
+	rv := reflect.ValueOf(v)
+	return !rv.IsValid() || (rv.Kind() == reflect.Pointer && rv.IsNil())
+}
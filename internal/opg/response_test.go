@@ -0,0 +1,103 @@
+package opg
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	opgc "github.com/neonephos-katalis/opg-ewbi-operator/api/ewbi/client"
+	opgmodels "github.com/neonephos-katalis/opg-ewbi-operator/api/ewbi/models"
+)
+
+func TestClassify(t *testing.T) {
+	detail := "file not found"
+	tests := []struct {
+		name        string
+		res         *opgc.RemoveFileResponse
+		err         error
+		opts        []ClassifyOpt
+		wantOutcome Outcome
+		wantCode    int
+		wantError   string
+		wantRetry   time.Duration
+	}{
+		{
+			name:        "success",
+			res:         &opgc.RemoveFileResponse{HTTPResponse: &http.Response{StatusCode: 200}},
+			wantOutcome: OutcomeSuccess,
+			wantCode:    200,
+		},
+		{
+			name: "bad request is permanent",
+			res: &opgc.RemoveFileResponse{
+				HTTPResponse:              &http.Response{StatusCode: 400},
+				ApplicationproblemJSON400: &opgmodels.ProblemDetails{Detail: &detail},
+			},
+			wantOutcome: OutcomePermanent,
+			wantCode:    400,
+			wantError:   "partner OP returned status 400: file not found",
+		},
+		{
+			name:        "unauthorized",
+			res:         &opgc.RemoveFileResponse{HTTPResponse: &http.Response{StatusCode: 401}},
+			wantOutcome: OutcomeAuth,
+			wantCode:    401,
+			wantError:   "partner OP returned status 401",
+			wantRetry:   DefaultRetryAfter,
+		},
+		{
+			name: "unavailable with retry after",
+			res: &opgc.RemoveFileResponse{
+				HTTPResponse: &http.Response{StatusCode: 503, Header: http.Header{"Retry-After": []string{"120"}}},
+				Body:         []byte(`{"title":"maintenance"}`),
+			},
+			wantOutcome: OutcomeRetryable,
+			wantCode:    503,
+			wantError:   "partner OP returned status 503: maintenance",
+			wantRetry:   2 * time.Minute,
+		},
+		{
+			name:        "unexpected status code",
+			res:         &opgc.RemoveFileResponse{HTTPResponse: &http.Response{StatusCode: 302}, Body: []byte("moved")},
+			wantOutcome: OutcomeRetryable,
+			wantCode:    302,
+			wantError:   "partner OP returned status 302",
+			wantRetry:   DefaultRetryAfter,
+		},
+		{
+			name:        "connection error",
+			err:         errors.New("connection refused"),
+			wantOutcome: OutcomeRetryable,
+			wantError:   "connection refused",
+			wantRetry:   DefaultRetryAfter,
+		},
+		{
+			name:        "overridden outcome",
+			res:         &opgc.RemoveFileResponse{HTTPResponse: &http.Response{StatusCode: 404}},
+			opts:        []ClassifyOpt{WithOutcome(http.StatusNotFound, OutcomeSuccess)},
+			wantOutcome: OutcomeSuccess,
+			wantCode:    404,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Classify(tt.res, tt.err, tt.opts...)
+			assert.Equal(t, tt.wantOutcome, got.Outcome)
+			assert.Equal(t, tt.wantCode, got.StatusCode)
+			if tt.wantOutcome == OutcomeSuccess {
+				assert.NoError(t, got.Err())
+				return
+			}
+			assert.EqualError(t, got.Err(), tt.wantError)
+			if tt.err != nil {
+				assert.ErrorIs(t, got.Err(), tt.err)
+			}
+			if tt.wantRetry > 0 {
+				assert.Equal(t, tt.wantRetry, got.RetryAfter)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package opg
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// TLSRef locates the Secrets with the certificates used against a federation partner.
+type TLSRef struct {
+	// ClientCertificate, kubernetes.io/tls Secret with the client certificate and key
+	ClientCertificate SecretKeyRef
+	// CA, Secret key with the PEM CA bundle the partner certificate is verified with
+	CA SecretKeyRef
+}
+
+func (t TLSRef) enabled() bool {
+	return t.ClientCertificate.Name != "" || t.CA.Name != ""
+}
+
+// newHTTPClientFor returns the http client used with the given credentials.
+// The certificates are read from their Secrets when a connection is dialed,
+// so rotated certificates are used by the next connections.
+func (m *OPGClientsMap) newHTTPClientFor(creds Credentials) *http.Client {
+	if !creds.TLS.enabled() {
+		return newHTTPClient(m.insecureSkipVerify)
+	}
+
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tr.DialTLSContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conf, err := m.tlsConfig(ctx, creds.TLS, addr)
+		if err != nil {
+			return nil, err
+		}
+		dialer := &tls.Dialer{Config: conf}
+		return dialer.DialContext(ctx, network, addr)
+	}
+	return &http.Client{Transport: tr}
+}
+
+func (m *OPGClientsMap) tlsConfig(ctx context.Context, ref TLSRef, addr string) (*tls.Config, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	conf := &tls.Config{
+		ServerName:         host,
+		InsecureSkipVerify: m.insecureSkipVerify,
+		MinVersion:         tls.VersionTLS12,
+	}
+
+	if ref.CA.Name != "" {
+		secret, err := m.getSecret(ctx, ref.CA)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(secret.Data[ref.CA.Key]) {
+			return nil, fmt.Errorf("no CA certificates found in key '%s' of secret %s/%s",
+				ref.CA.Key, ref.CA.Namespace, ref.CA.Name)
+		}
+		conf.RootCAs = pool
+	}
+
+	if ref.ClientCertificate.Name != "" {
+		secret, err := m.getSecret(ctx, ref.ClientCertificate)
+		if err != nil {
+			return nil, err
+		}
+		cert, err := tls.X509KeyPair(secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey])
+		if err != nil {
+			return nil, fmt.Errorf("invalid client certificate in secret %s/%s: %w",
+				ref.ClientCertificate.Namespace, ref.ClientCertificate.Name, err)
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+	return conf, nil
+}
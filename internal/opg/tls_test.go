@@ -0,0 +1,127 @@
+package opg
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// newTestClientCertificate returns a self signed client certificate and its PEM encoded cert and key
+func newTestClientCertificate(t *testing.T, cn string) (*x509.Certificate, []byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	return cert,
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+}
+
+func TestOPGClientsMapMutualTLS(t *testing.T) {
+	clientCert, clientCertPEM, clientKeyPEM := newTestClientCertificate(t, "guest-operator")
+
+	var peerCN string
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		peerCN = r.TLS.PeerCertificates[0].Subject.CommonName
+		w.WriteHeader(http.StatusOK)
+	}))
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+	srv.TLS = &tls.Config{ClientCAs: clientCAs, ClientAuth: tls.RequireAndVerifyClientCert}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	serverCAPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	secrets := fake.NewClientBuilder().WithObjects(
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "client-cert", Namespace: "ns"},
+			Type:       corev1.SecretTypeTLS,
+			Data:       map[string][]byte{corev1.TLSCertKey: clientCertPEM, corev1.TLSPrivateKeyKey: clientKeyPEM},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "partner-ca", Namespace: "ns"},
+			Data:       map[string][]byte{"ca.crt": serverCAPEM},
+		},
+	).Build()
+
+	tests := []struct {
+		name    string
+		tlsRef  TLSRef
+		wantErr bool
+	}{
+		{
+			name: "Client certificate and partner CA",
+			tlsRef: TLSRef{
+				ClientCertificate: SecretKeyRef{Namespace: "ns", Name: "client-cert"},
+				CA:                SecretKeyRef{Namespace: "ns", Name: "partner-ca", Key: "ca.crt"},
+			},
+		},
+		{
+			name: "Partner CA without client certificate",
+			tlsRef: TLSRef{
+				CA: SecretKeyRef{Namespace: "ns", Name: "partner-ca", Key: "ca.crt"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Client certificate without partner CA",
+			tlsRef: TLSRef{
+				ClientCertificate: SecretKeyRef{Namespace: "ns", Name: "client-cert"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Missing client certificate secret",
+			tlsRef: TLSRef{
+				ClientCertificate: SecretKeyRef{Namespace: "ns", Name: "missing"},
+				CA:                SecretKeyRef{Namespace: "ns", Name: "partner-ca", Key: "ca.crt"},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			peerCN = ""
+			m := NewOPGClientsMap(WithSecretReader(secrets))
+			_, err := m.GetOPGClient("fed-1", srv.URL, Credentials{ClientID: testClientID, TLS: tt.tlsRef}).
+				DeleteFederationDetailsWithResponse(context.Background(), "fed-ctx")
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "guest-operator", peerCN)
+		})
+	}
+}
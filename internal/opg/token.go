@@ -0,0 +1,201 @@
+package opg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenExpiryDelta is subtracted from the token lifetime so a cached token
+// is refreshed before the partner starts rejecting it.
+const tokenExpiryDelta = 10 * time.Second
+
+// Credentials identifies this operator against a federation partner.
+// When ClientSecretRef is empty only the X-Client-ID header is sent.
+type Credentials struct {
+	ClientID        string
+	TokenURL        string
+	ClientSecretRef SecretKeyRef
+	TLS             TLSRef
+	// Timeout, limit for each request, the default of the OPGClientsMap when 0
+	Timeout time.Duration
+}
+
+// SecretKeyRef locates a Kubernetes Secret and one of its keys.
+type SecretKeyRef struct {
+	Namespace string
+	Name      string
+	Key       string
+}
+
+func (c Credentials) oauth2Enabled() bool {
+	return c.ClientSecretRef.Name != "" && c.TokenURL != ""
+}
+
+// ClientSecretFunc returns the client secret used to request the tokens.
+type ClientSecretFunc func(ctx context.Context) (string, error)
+
+// TokenSource provides the bearer tokens attached to the OPG client requests.
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+	// Invalidate drops the cached token so the next call fetches a new one
+	Invalidate()
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// clientCredentialsTokenSource fetches tokens with the OAuth2 client credentials
+// grant and caches them until they expire.
+type clientCredentialsTokenSource struct {
+	httpClient   *http.Client
+	tokenURL     string
+	clientID     string
+	clientSecret ClientSecretFunc
+	now          func() time.Time
+
+	mutex  sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+// NewClientCredentialsTokenSource returns a TokenSource requesting tokens to
+// tokenURL with the OAuth2 client credentials grant.
+func NewClientCredentialsTokenSource(
+	httpClient *http.Client, tokenURL, clientID string, clientSecret ClientSecretFunc,
+) TokenSource {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &clientCredentialsTokenSource{
+		httpClient:   httpClient,
+		tokenURL:     tokenURL,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		now:          time.Now,
+	}
+}
+
+func (s *clientCredentialsTokenSource) Token(ctx context.Context) (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.token != "" && (s.expiry.IsZero() || s.now().Before(s.expiry)) {
+		return s.token, nil
+	}
+
+	tok, err := s.fetchToken(ctx)
+	if err != nil {
+		return "", err
+	}
+	s.token = tok.AccessToken
+	s.expiry = time.Time{}
+	if tok.ExpiresIn > 0 {
+		s.expiry = s.now().Add(time.Duration(tok.ExpiresIn)*time.Second - tokenExpiryDelta)
+	}
+	return s.token, nil
+}
+
+func (s *clientCredentialsTokenSource) Invalidate() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.token = ""
+	s.expiry = time.Time{}
+}
+
+func (s *clientCredentialsTokenSource) fetchToken(ctx context.Context) (*tokenResponse, error) {
+	secret, err := s.clientSecret(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get client secret: %w", err)
+	}
+
+	form := url.Values{"grant_type": {"client_credentials"}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(s.clientID), url.QueryEscape(secret))
+
+	res, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to request token to %s: %w", s.tokenURL, err)
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token response: %w", err)
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token endpoint %s returned status %d: %s", s.tokenURL, res.StatusCode, string(body))
+	}
+
+	tok := &tokenResponse{}
+	if err := json.Unmarshal(body, tok); err != nil {
+		return nil, fmt.Errorf("failed to decode token response: %w", err)
+	}
+	if tok.AccessToken == "" {
+		return nil, fmt.Errorf("token endpoint %s returned no access_token", s.tokenURL)
+	}
+	if tok.TokenType != "" && !strings.EqualFold(tok.TokenType, "bearer") {
+		return nil, fmt.Errorf("unsupported token type '%s'", tok.TokenType)
+	}
+	return tok, nil
+}
+
+// bearerTokenRequestEditor sets the Authorization header with a token from ts.
+func bearerTokenRequestEditor(ts TokenSource) func(ctx context.Context, req *http.Request) error {
+	return func(ctx context.Context, req *http.Request) error {
+		tok, err := ts.Token(ctx)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+tok)
+		return nil
+	}
+}
+
+// unauthorizedRetryDoer retries once with a fresh token the requests the
+// partner rejects with a 401, e.g. because it revoked the cached token.
+type unauthorizedRetryDoer struct {
+	doer *http.Client
+	ts   TokenSource
+}
+
+func (d *unauthorizedRetryDoer) Do(req *http.Request) (*http.Response, error) {
+	res, err := d.doer.Do(req)
+	if err != nil || res.StatusCode != http.StatusUnauthorized {
+		return res, err
+	}
+	if req.Body != nil && req.GetBody == nil {
+		return res, nil
+	}
+
+	d.ts.Invalidate()
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return res, nil
+		}
+		retry.Body = body
+	}
+	if err := bearerTokenRequestEditor(d.ts)(req.Context(), retry); err != nil {
+		return res, nil
+	}
+
+	_, _ = io.Copy(io.Discard, res.Body)
+	res.Body.Close()
+	return d.doer.Do(retry)
+}
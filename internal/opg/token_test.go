@@ -0,0 +1,168 @@
+package opg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	opgmodels "github.com/neonephos-katalis/opg-ewbi-operator/api/ewbi/models"
+)
+
+const (
+	testClientID     = "client-id"
+	testClientSecret = "client-secret"
+)
+
+// newTestTokenServer returns a token endpoint issuing "token-<n>" tokens
+// and the counter of the tokens issued
+func newTestTokenServer(t *testing.T, expiresIn int) (*httptest.Server, *int) {
+	issued := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, secret, ok := r.BasicAuth()
+		if !ok || id != testClientID || secret != testClientSecret {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "client_credentials" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		issued++
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(tokenResponse{
+			AccessToken: fmt.Sprintf("token-%d", issued),
+			TokenType:   "Bearer",
+			ExpiresIn:   int64(expiresIn),
+		})
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &issued
+}
+
+func staticSecret(secret string) ClientSecretFunc {
+	return func(context.Context) (string, error) { return secret, nil }
+}
+
+func TestClientCredentialsTokenSource(t *testing.T) {
+	srv, issued := newTestTokenServer(t, 3600)
+	now := time.Now()
+	ts := NewClientCredentialsTokenSource(srv.Client(), srv.URL, testClientID, staticSecret(testClientSecret))
+	ts.(*clientCredentialsTokenSource).now = func() time.Time { return now }
+
+	tok, err := ts.Token(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "token-1", tok)
+
+	tok, err = ts.Token(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "token-1", tok, "token should be cached until it expires")
+
+	now = now.Add(time.Hour)
+	tok, err = ts.Token(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "token-2", tok, "expired token should be refreshed")
+
+	ts.Invalidate()
+	tok, err = ts.Token(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "token-3", tok, "invalidated token should be refreshed")
+	assert.Equal(t, 3, *issued)
+}
+
+func TestClientCredentialsTokenSourceWrongSecret(t *testing.T) {
+	srv, _ := newTestTokenServer(t, 3600)
+	ts := NewClientCredentialsTokenSource(srv.Client(), srv.URL, testClientID, staticSecret("wrong"))
+
+	_, err := ts.Token(context.Background())
+	require.Error(t, err)
+}
+
+func TestOPGClientsMapOAuth2(t *testing.T) {
+	tokenSrv, issued := newTestTokenServer(t, 3600)
+
+	// the partner revokes the first token, so the client has to get a new one
+	var authHeaders, clientIDs, bodies []string
+	apiSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		authHeaders = append(authHeaders, r.Header.Get("Authorization"))
+		clientIDs = append(clientIDs, r.Header.Get("X-Client-ID"))
+		bodies = append(bodies, string(body))
+		if r.Header.Get("Authorization") != "Bearer token-2" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	t.Cleanup(apiSrv.Close)
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "partner-creds", Namespace: "ns"},
+		Data:       map[string][]byte{"clientSecret": []byte(testClientSecret)},
+	}
+	m := NewOPGClientsMap(WithSecretReader(fake.NewClientBuilder().WithObjects(secret).Build()))
+
+	creds := Credentials{
+		ClientID: testClientID,
+		TokenURL: tokenSrv.URL,
+		ClientSecretRef: SecretKeyRef{
+			Namespace: "ns",
+			Name:      "partner-creds",
+			Key:       "clientSecret",
+		},
+	}
+	res, err := m.GetOPGClient("fed-1", apiSrv.URL, creds).UpdateFederationWithResponse(
+		context.Background(),
+		"fed-ctx",
+		opgmodels.UpdateFederationJSONRequestBody{AddFixedNetworkIds: &[]string{"123"}},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode())
+	assert.Equal(t, []string{"Bearer token-1", "Bearer token-2"}, authHeaders)
+	assert.Equal(t, []string{testClientID, testClientID}, clientIDs)
+	require.Len(t, bodies, 2)
+	assert.Equal(t, bodies[0], bodies[1], "retried request should send the same body")
+	assert.Equal(t, 2, *issued)
+}
+
+func TestOPGClientsMapWithoutOAuth2(t *testing.T) {
+	var authHeader, clientID string
+	apiSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authHeader = r.Header.Get("Authorization")
+		clientID = r.Header.Get("X-Client-ID")
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(apiSrv.Close)
+
+	m := NewOPGClientsMap()
+	_, err := m.GetOPGClient("fed-1", apiSrv.URL, Credentials{ClientID: testClientID}).
+		DeleteFederationDetailsWithResponse(context.Background(), "fed-ctx")
+	require.NoError(t, err)
+	assert.Empty(t, authHeader)
+	assert.Equal(t, testClientID, clientID)
+}
+
+func TestOPGClientsMapMissingSecret(t *testing.T) {
+	tokenSrv, _ := newTestTokenServer(t, 3600)
+	m := NewOPGClientsMap(WithSecretReader(fake.NewClientBuilder().Build()))
+
+	creds := Credentials{
+		ClientID:        testClientID,
+		TokenURL:        tokenSrv.URL,
+		ClientSecretRef: SecretKeyRef{Namespace: "ns", Name: "missing", Key: "clientSecret"},
+	}
+	_, err := m.GetOPGClient("fed-1", "http://localhost", creds).
+		DeleteFederationDetailsWithResponse(context.Background(), "fed-ctx")
+	require.Error(t, err)
+}
@@ -0,0 +1,234 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// minRefreshInterval limits how often an unknown kid triggers a JWKS reload.
+const minRefreshInterval = 30 * time.Second
+
+// KeySet holds the public keys the tokens are verified with,
+// loaded from a JWKS document either in a file or served at an URL.
+type KeySet struct {
+	load            func(ctx context.Context) ([]byte, error)
+	refreshInterval time.Duration
+	now             func() time.Time
+
+	mutex    sync.Mutex
+	keys     map[string]crypto.PublicKey
+	loadedAt time.Time
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type jsonWebKeySet struct {
+	Keys []jsonWebKey `json:"keys"`
+}
+
+// NewFileKeySet loads the JWKS document in path.
+func NewFileKeySet(path string) (*KeySet, error) {
+	k := &KeySet{
+		load: func(context.Context) ([]byte, error) {
+			return os.ReadFile(path)
+		},
+		now: time.Now,
+	}
+	if err := k.reload(context.Background()); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// NewURLKeySet loads the JWKS document served at url, it is reloaded every
+// refreshInterval and whenever a token is signed with an unknown key.
+func NewURLKeySet(ctx context.Context, url string, httpClient *http.Client, refreshInterval time.Duration) (*KeySet, error) {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	k := &KeySet{
+		load: func(ctx context.Context) ([]byte, error) {
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+			if err != nil {
+				return nil, err
+			}
+			res, err := httpClient.Do(req)
+			if err != nil {
+				return nil, err
+			}
+			defer res.Body.Close()
+			if res.StatusCode != http.StatusOK {
+				return nil, fmt.Errorf("jwks endpoint %s returned status %d", url, res.StatusCode)
+			}
+			return io.ReadAll(res.Body)
+		},
+		refreshInterval: refreshInterval,
+		now:             time.Now,
+	}
+	if err := k.reload(ctx); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Key returns the public key identified by kid. An empty kid is only
+// accepted when the set holds a single key.
+func (k *KeySet) Key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	expired := k.refreshInterval > 0 && k.now().Sub(k.loadedAt) > k.refreshInterval
+	key, ok := k.lookup(kid)
+	if expired || (!ok && k.refreshInterval > 0 && k.now().Sub(k.loadedAt) > minRefreshInterval) {
+		if err := k.reloadLocked(ctx); err != nil && !ok {
+			return nil, err
+		}
+		key, ok = k.lookup(kid)
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key '%s'", kid)
+	}
+	return key, nil
+}
+
+func (k *KeySet) lookup(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(k.keys) == 1 {
+		for _, key := range k.keys {
+			return key, true
+		}
+	}
+	key, ok := k.keys[kid]
+	return key, ok
+}
+
+func (k *KeySet) reload(ctx context.Context) error {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	return k.reloadLocked(ctx)
+}
+
+func (k *KeySet) reloadLocked(ctx context.Context) error {
+	data, err := k.load(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load jwks: %w", err)
+	}
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return err
+	}
+	k.keys = keys
+	k.loadedAt = k.now()
+	return nil
+}
+
+// parseJWKS parses the signature keys of a JWKS document,
+// keys of unsupported types are skipped.
+func parseJWKS(data []byte) (map[string]crypto.PublicKey, error) {
+	set := jsonWebKeySet{}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("failed to decode jwks: %w", err)
+	}
+	keys := map[string]crypto.PublicKey{}
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("invalid key '%s': %w", jwk.Kid, err)
+		}
+		if key != nil {
+			keys[jwk.Kid] = key
+		}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("jwks has no signature keys")
+	}
+	return keys, nil
+}
+
+func (j jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch j.Kty {
+	case "RSA":
+		n, err := decodeBigInt(j.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(j.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch j.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve '%s'", j.Crv)
+		}
+		x, err := decodeBigInt(j.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(j.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("point is not on curve '%s'", j.Crv)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if j.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve '%s'", j.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(j.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 key size %d", len(x))
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, nil
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	if s == "" {
+		return nil, fmt.Errorf("missing key parameter")
+	}
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// leeway tolerates small clock skews with the token issuer.
+const leeway = 30 * time.Second
+
+var (
+	// ErrInvalidToken reports a missing, malformed, expired or untrusted token.
+	ErrInvalidToken = errors.New("invalid token")
+	// ErrForbidden reports a valid token that grants no access to the request.
+	ErrForbidden = errors.New("forbidden")
+	// ErrUnavailable reports a token that could not be checked, e.g. the validation service is down.
+	ErrUnavailable = errors.New("token validation unavailable")
+)
+
+// Claims are the token claims the API relies on.
+type Claims struct {
+	jwt.RegisteredClaims
+	// ClientID of the OAuth2 client the token was issued to
+	ClientID string `json:"client_id,omitempty"`
+}
+
+// Validator verifies the signature and the registered claims of the tokens.
+type Validator struct {
+	keys   *KeySet
+	parser *jwt.Parser
+}
+
+// NewValidator returns a Validator checking the tokens are signed by keys,
+// issued by issuer and intended for audience. Empty issuer or audience are not checked.
+func NewValidator(keys *KeySet, issuer, audience string) *Validator {
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{
+			"RS256", "RS384", "RS512",
+			"PS256", "PS384", "PS512",
+			"ES256", "ES384", "ES512",
+			"EdDSA",
+		}),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(leeway),
+	}
+	if issuer != "" {
+		opts = append(opts, jwt.WithIssuer(issuer))
+	}
+	if audience != "" {
+		opts = append(opts, jwt.WithAudience(audience))
+	}
+	return &Validator{
+		keys:   keys,
+		parser: jwt.NewParser(opts...),
+	}
+}
+
+// Validate parses the token and returns its claims when it is valid.
+func (v *Validator) Validate(ctx context.Context, token string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := v.parser.ParseWithClaims(token, claims, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		return v.keys.Key(ctx, kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidToken, err.Error())
+	}
+	return claims, nil
+}
@@ -0,0 +1,202 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testIssuer   = "https://idp.partner.com"
+	testAudience = "opg-ewbi"
+)
+
+func encodeBigInt(i *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(i.Bytes())
+}
+
+func rsaJWK(kid string, key *rsa.PrivateKey) jsonWebKey {
+	return jsonWebKey{
+		Kty: "RSA",
+		Kid: kid,
+		Use: "sig",
+		N:   encodeBigInt(key.N),
+		E:   encodeBigInt(big.NewInt(int64(key.E))),
+	}
+}
+
+func ecJWK(kid string, key *ecdsa.PrivateKey) jsonWebKey {
+	return jsonWebKey{
+		Kty: "EC",
+		Kid: kid,
+		Crv: "P-256",
+		X:   encodeBigInt(key.X),
+		Y:   encodeBigInt(key.Y),
+	}
+}
+
+func writeJWKS(t *testing.T, keys ...jsonWebKey) string {
+	data, err := json.Marshal(jsonWebKeySet{Keys: keys})
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	return path
+}
+
+func signToken(t *testing.T, method jwt.SigningMethod, kid string, key any, claims Claims) string {
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	require.NoError(t, err)
+	return signed
+}
+
+func validClaims() Claims {
+	return Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    testIssuer,
+			Audience:  jwt.ClaimStrings{testAudience},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+		ClientID: "client-1",
+	}
+}
+
+func TestValidator(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	keys, err := NewFileKeySet(writeJWKS(t, rsaJWK("rsa", rsaKey), ecJWK("ec", ecKey)))
+	require.NoError(t, err)
+	v := NewValidator(keys, testIssuer, testAudience)
+
+	withClaims := func(f func(c *Claims)) Claims {
+		c := validClaims()
+		f(&c)
+		return c
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{
+			name:  "Valid RSA token",
+			token: signToken(t, jwt.SigningMethodRS256, "rsa", rsaKey, validClaims()),
+		},
+		{
+			name:  "Valid EC token",
+			token: signToken(t, jwt.SigningMethodES256, "ec", ecKey, validClaims()),
+		},
+		{
+			name: "Expired token",
+			token: signToken(t, jwt.SigningMethodRS256, "rsa", rsaKey, withClaims(func(c *Claims) {
+				c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Hour))
+			})),
+			wantErr: true,
+		},
+		{
+			name: "Token without expiration",
+			token: signToken(t, jwt.SigningMethodRS256, "rsa", rsaKey, withClaims(func(c *Claims) {
+				c.ExpiresAt = nil
+			})),
+			wantErr: true,
+		},
+		{
+			name: "Token from another issuer",
+			token: signToken(t, jwt.SigningMethodRS256, "rsa", rsaKey, withClaims(func(c *Claims) {
+				c.Issuer = "https://idp.other.com"
+			})),
+			wantErr: true,
+		},
+		{
+			name: "Token for another audience",
+			token: signToken(t, jwt.SigningMethodRS256, "rsa", rsaKey, withClaims(func(c *Claims) {
+				c.Audience = jwt.ClaimStrings{"other"}
+			})),
+			wantErr: true,
+		},
+		{
+			name:    "Token signed with an untrusted key",
+			token:   signToken(t, jwt.SigningMethodRS256, "rsa", otherKey, validClaims()),
+			wantErr: true,
+		},
+		{
+			name:    "Token signed with an unknown kid",
+			token:   signToken(t, jwt.SigningMethodRS256, "unknown", rsaKey, validClaims()),
+			wantErr: true,
+		},
+		{
+			name:    "Unsigned token",
+			token:   signToken(t, jwt.SigningMethodNone, "rsa", jwt.UnsafeAllowNoneSignatureType, validClaims()),
+			wantErr: true,
+		},
+		{
+			name:    "Malformed token",
+			token:   "not-a-token",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := v.Validate(context.Background(), tt.token)
+			if tt.wantErr {
+				require.ErrorIs(t, err, ErrInvalidToken)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, "client-1", claims.ClientID)
+		})
+	}
+}
+
+func TestURLKeySetReloadsOnUnknownKid(t *testing.T) {
+	oldKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	newKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	served := []jsonWebKey{rsaJWK("old", oldKey)}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(jsonWebKeySet{Keys: served})
+	}))
+	t.Cleanup(srv.Close)
+
+	keys, err := NewURLKeySet(context.Background(), srv.URL, srv.Client(), time.Hour)
+	require.NoError(t, err)
+	now := time.Now()
+	keys.now = func() time.Time { return now }
+	v := NewValidator(keys, "", "")
+
+	// the issuer rotates its keys
+	served = []jsonWebKey{rsaJWK("new", newKey)}
+	token := signToken(t, jwt.SigningMethodRS256, "new", newKey, validClaims())
+
+	_, err = v.Validate(context.Background(), token)
+	require.ErrorIs(t, err, ErrInvalidToken, "reloads should be rate limited")
+
+	now = now.Add(time.Minute)
+	_, err = v.Validate(context.Background(), token)
+	require.NoError(t, err)
+}
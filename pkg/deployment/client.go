@@ -3,9 +3,10 @@ package deployment
 import (
 	"context"
 	"errors"
+	"fmt"
+	"slices"
 
-	k8scl "sigs.k8s.io/controller-runtime/pkg/client"
-
+	"github.com/neonephos-katalis/opg-ewbi-operator/api/ewbi/models"
 	"github.com/neonephos-katalis/opg-ewbi-operator/pkg/metastore"
 	opgv1beta1 "github.com/neonephos-katalis/opg-ewbi-operator/api/operator/v1beta1"
 )
@@ -15,11 +16,13 @@ var _ Client = &client{}
 type Client interface {
 	Install(ctx context.Context, app *InstallDeployment) (*opgv1beta1.ApplicationInstance, string, error)
 	Uninstall(ctx context.Context, federationContextID, id string) error
+	Get(ctx context.Context, federationContextID, id string) (*metastore.ApplicationInstance, error)
+	Status(ctx context.Context, federationContextID, id string) (*metastore.ApplicationInstanceDetails, error)
 }
 
-func NewClient(k8sClient k8scl.Client, namespace string) *client {
+func NewClient(appMetaClient metastore.Client) *client {
 	return &client{
-		appMetaClient: metastore.NewK8sClient(k8sClient, namespace),
+		appMetaClient: appMetaClient,
 	}
 }
 
@@ -30,6 +33,18 @@ type client struct {
 func (c *client) Install(ctx context.Context, dep *InstallDeployment) (*opgv1beta1.ApplicationInstance, string, error) {
 	var obj *opgv1beta1.ApplicationInstance
 	var err error
+	// an unknown application is reported when adding the instance
+	app, err := c.appMetaClient.GetApplication(ctx, dep.FederationContextID, dep.AppId)
+	if err != nil && !metastore.IsNotFoundError(err) {
+		return nil, "", err
+	}
+	if app != nil && slices.Contains(app.ForbiddenZones, dep.ZoneInfo.ZoneId) {
+		return nil, "", fmt.Errorf("%w: instantiation of application '%s' forbidden in zone '%s'",
+			metastore.ErrConflict, dep.AppId, dep.ZoneInfo.ZoneId)
+	}
+	if err := c.checkResourcePool(ctx, dep); err != nil {
+		return nil, "", err
+	}
 	if obj, err = c.appMetaClient.AddApplicationInstance(ctx, &metastore.ApplicationInstance{
 		InstallAppJSONBody:  dep.InstallAppJSONBody,
 		FederationContextId: dep.FederationContextID,
@@ -40,6 +55,50 @@ func (c *client) Install(ctx context.Context, dep *InstallDeployment) (*opgv1bet
 	return obj, dep.AppInstanceId, nil
 }
 
+// checkResourcePool checks the resource pool of the instance has the requested
+// flavour left. Instances preferring reserved resources are installed without
+// the pool when it has none left, the ones avoiding them don't use it, which is
+// the default of the API when the resource consumption is not set.
+func (c *client) checkResourcePool(ctx context.Context, dep *InstallDeployment) error {
+	zoneInfo := &dep.ZoneInfo
+	consumption := models.RESERVEDRESAVOID
+	if zoneInfo.ResourceConsumption != nil {
+		consumption = *zoneInfo.ResourceConsumption
+	}
+	switch consumption {
+	case models.RESERVEDRESAVOID, models.RESERVEDRESFORBID:
+		zoneInfo.ResPool = nil
+		return nil
+	}
+	if zoneInfo.ResPool == nil || *zoneInfo.ResPool == "" {
+		if consumption == models.RESERVEDRESSHALL {
+			return fmt.Errorf("%w: resPool is required to use reserved resources", metastore.ErrBadRequest)
+		}
+		return nil
+	}
+
+	usage, err := c.appMetaClient.GetResourcePoolUsage(ctx, dep.FederationContextID, *zoneInfo.ResPool)
+	if err != nil {
+		if metastore.IsNotFoundError(err) {
+			return fmt.Errorf("%w: %w", metastore.ErrBadRequest, err)
+		}
+		return err
+	}
+	if usage.ZoneId != zoneInfo.ZoneId || usage.AppProviderId != dep.AppProviderId {
+		return fmt.Errorf("%w: resource pool '%s' not reserved in zone '%s' for app provider '%s'",
+			metastore.ErrBadRequest, *zoneInfo.ResPool, zoneInfo.ZoneId, dep.AppProviderId)
+	}
+	if usage.Remaining(zoneInfo.FlavourId) > 0 {
+		return nil
+	}
+	if consumption == models.RESERVEDRESPREFER {
+		zoneInfo.ResPool = nil
+		return nil
+	}
+	return fmt.Errorf("%w: no flavour '%s' left in resource pool '%s'",
+		metastore.ErrConflict, zoneInfo.FlavourId, *zoneInfo.ResPool)
+}
+
 func (c *client) Uninstall(ctx context.Context, federationContextID, id string) error {
 	if err := c.appMetaClient.RemoveApplicationInstance(ctx, federationContextID, id); err != nil && !errors.Is(err, metastore.ErrNotFound) {
 		return err
@@ -47,3 +106,13 @@ func (c *client) Uninstall(ctx context.Context, federationContextID, id string)
 
 	return nil
 }
+
+// Get returns the installation request of the application instance
+func (c *client) Get(ctx context.Context, federationContextID, id string) (*metastore.ApplicationInstance, error) {
+	return c.appMetaClient.GetApplicationInstance(ctx, federationContextID, id)
+}
+
+// Status returns the state and access points of the application instance
+func (c *client) Status(ctx context.Context, federationContextID, id string) (*metastore.ApplicationInstanceDetails, error) {
+	return c.appMetaClient.GetApplicationInstanceDetails(ctx, federationContextID, id)
+}
@@ -0,0 +1,146 @@
+package deployment
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	k8scli "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/neonephos-katalis/opg-ewbi-operator/api/ewbi/models"
+	opgv1beta1 "github.com/neonephos-katalis/opg-ewbi-operator/api/operator/v1beta1"
+	"github.com/neonephos-katalis/opg-ewbi-operator/pkg/metastore"
+)
+
+const (
+	testNamespace           = "federation"
+	testFederationContextID = "fed-ctx-1"
+)
+
+func newTestClient(t *testing.T) (*client, k8scli.Client) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, opgv1beta1.AddToScheme(scheme))
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&opgv1beta1.Federation{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "federation-" + testFederationContextID,
+			Namespace: testNamespace,
+			Labels: map[string]string{
+				opgv1beta1.FederationContextIdLabel: testFederationContextID,
+				opgv1beta1.ExternalIdLabel:          testFederationContextID,
+				opgv1beta1.FederationRelationLabel:  string(opgv1beta1.FederationRelationHost),
+			},
+		},
+		Spec: opgv1beta1.FederationSpec{
+			AcceptedAvailabilityZones: []string{"zone-1", "zone-2"},
+		},
+	}).WithStatusSubresource(&opgv1beta1.ResourcePool{}).Build()
+	return NewClient(metastore.NewK8sClient(k8sClient, testNamespace)), k8sClient
+}
+
+func onboardTestApplication(t *testing.T, c *client) {
+	users := 1
+	_, err := c.appMetaClient.OnboardApplication(context.Background(), &metastore.OnboardApplication{
+		OnboardApplicationJSONBody: &models.OnboardApplicationJSONBody{
+			AppId:              "app-1",
+			AppProviderId:      "provider-1",
+			AppDeploymentZones: &[]models.ZoneIdentifier{"zone-1", "zone-2"},
+			AppQoSProfile:      models.AppQoSProfile{NoOfUsersPerAppInst: &users},
+		},
+		FederationContextId: testFederationContextID,
+	})
+	require.NoError(t, err)
+}
+
+func newTestInstallDeployment(id, zoneID string) *InstallDeployment {
+	dep := &InstallDeployment{
+		InstallAppJSONBody: &models.InstallAppJSONBody{
+			AppInstanceId: id,
+			AppId:         "app-1",
+			AppProviderId: "provider-1",
+		},
+		FederationContextID: testFederationContextID,
+	}
+	dep.ZoneInfo.ZoneId = zoneID
+	return dep
+}
+
+func TestInstallForbiddenZone(t *testing.T) {
+	c, _ := newTestClient(t)
+	ctx := context.Background()
+	onboardTestApplication(t, c)
+	forbid := true
+	_, err := c.appMetaClient.SetApplicationZoneForbids(ctx, testFederationContextID, "app-1", []metastore.ApplicationZoneForbid{
+		{ZoneId: "zone-1", Forbid: &forbid},
+	})
+	require.NoError(t, err)
+
+	install := func(id, zoneID string) error {
+		_, _, err := c.Install(ctx, newTestInstallDeployment(id, zoneID))
+		return err
+	}
+	require.ErrorIs(t, install("inst-1", "zone-1"), metastore.ErrConflict)
+	require.NoError(t, install("inst-2", "zone-2"))
+}
+
+func TestInstallResourcePool(t *testing.T) {
+	c, k8sClient := newTestClient(t)
+	ctx := context.Background()
+	onboardTestApplication(t, c)
+
+	days := int32(1)
+	pool := &metastore.ResourcePool{
+		CreateResourcePoolsJSONBody: &models.CreateResourcePoolsJSONBody{},
+		FederationContextId:         testFederationContextID,
+		ZoneId:                      "zone-1",
+		AppProviderId:               "provider-1",
+	}
+	pool.ResRequest.PoolId = "pool-1"
+	pool.ResRequest.ReserveDuration.NumOfDays = &days
+	pool.ResRequest.Flavours = append(pool.ResRequest.Flavours, struct {
+		FlavourId        models.FlavourId `json:"flavourId"`
+		MinNumOfFlavours *int32           `json:"minNumOfFlavours,omitempty"`
+		NumFlavour       int32            `json:"numFlavour"`
+	}{FlavourId: "small", NumFlavour: 1})
+	obj, err := c.appMetaClient.CreateResourcePool(ctx, pool)
+	require.NoError(t, err)
+	obj.Status.State = opgv1beta1.ResourcePoolStateReserved
+	obj.Status.GrantedFlavours = []opgv1beta1.GrantedFlavour{{FlavourId: "small", NumFlavour: 1}}
+	require.NoError(t, k8sClient.Status().Update(ctx, obj))
+
+	install := func(id, zoneID, resPool string, consumption models.InstallAppJSONBodyZoneInfoResourceConsumption) (*InstallDeployment, error) {
+		dep := newTestInstallDeployment(id, zoneID)
+		dep.ZoneInfo.FlavourId = "small"
+		dep.ZoneInfo.ResPool = &resPool
+		dep.ZoneInfo.ResourceConsumption = &consumption
+		_, _, err := c.Install(ctx, dep)
+		return dep, err
+	}
+	_, err = install("inst-1", "zone-2", "pool-1", models.RESERVEDRESSHALL)
+	require.ErrorIs(t, err, metastore.ErrBadRequest)
+	_, err = install("inst-1", "zone-1", "pool-2", models.RESERVEDRESSHALL)
+	require.ErrorIs(t, err, metastore.ErrBadRequest)
+
+	dep, err := install("inst-1", "zone-1", "pool-1", models.RESERVEDRESSHALL)
+	require.NoError(t, err)
+	require.Equal(t, "pool-1", *dep.ZoneInfo.ResPool)
+
+	_, err = install("inst-2", "zone-1", "pool-1", models.RESERVEDRESSHALL)
+	require.ErrorIs(t, err, metastore.ErrConflict)
+	dep, err = install("inst-3", "zone-1", "pool-1", models.RESERVEDRESPREFER)
+	require.NoError(t, err)
+	require.Nil(t, dep.ZoneInfo.ResPool)
+
+	// without a resource consumption the reserved resources are avoided
+	dep = newTestInstallDeployment("inst-4", "zone-1")
+	dep.ZoneInfo.FlavourId = "small"
+	resPool := "pool-1"
+	dep.ZoneInfo.ResPool = &resPool
+	_, _, err = c.Install(ctx, dep)
+	require.NoError(t, err)
+	require.Nil(t, dep.ZoneInfo.ResPool)
+}
@@ -1,15 +1,139 @@
 package handler
 
 import (
-	"net/http"
+	"errors"
+	"fmt"
+	"strings"
 
 	"github.com/labstack/echo/v4"
 
+	"github.com/neonephos-katalis/opg-ewbi-operator/pkg/auth"
+	"github.com/neonephos-katalis/opg-ewbi-operator/pkg/metastore"
 	"github.com/neonephos-katalis/opg-ewbi-operator/pkg/uuid"
 )
 
-func (h *handler) ValidateAuthHeaders(c echo.Context) (statusCode int, err error) {
-	return http.StatusAccepted, nil
+const (
+	headerKeyAuthorization = "Authorization"
+	bearerPrefix           = "Bearer "
+
+	// clientCredentialsContextKey holds the metastore.ClientCredentials of the authenticated request
+	clientCredentialsContextKey = "clientCredentials"
+)
+
+// Authenticator authenticates the requests and returns the client credentials of the caller.
+// Errors wrapping auth.ErrForbidden are answered with 403, any other error with 401.
+type Authenticator interface {
+	Authenticate(c echo.Context) (metastore.ClientCredentials, error)
+}
+
+type jwtAuthenticator struct {
+	validator *auth.Validator
+}
+
+// NewJWTAuthenticator returns an Authenticator validating the bearer tokens and
+// taking the client credentials from their client_id claim.
+func NewJWTAuthenticator(validator *auth.Validator) Authenticator {
+	return &jwtAuthenticator{validator: validator}
+}
+
+func (a *jwtAuthenticator) Authenticate(c echo.Context) (metastore.ClientCredentials, error) {
+	header := c.Request().Header.Get(headerKeyAuthorization)
+	if len(header) < len(bearerPrefix) || !strings.EqualFold(header[:len(bearerPrefix)], bearerPrefix) {
+		return metastore.ClientCredentials{}, fmt.Errorf("%w: missing bearer token", auth.ErrInvalidToken)
+	}
+
+	claims, err := a.validator.Validate(c.Request().Context(), strings.TrimSpace(header[len(bearerPrefix):]))
+	if err != nil {
+		return metastore.ClientCredentials{}, err
+	}
+	if claims.ClientID == "" {
+		return metastore.ClientCredentials{}, fmt.Errorf("%w: token has no client_id claim", auth.ErrForbidden)
+	}
+	if clientID := c.Request().Header.Get(headerKeyClientID); clientID != "" && clientID != claims.ClientID {
+		return metastore.ClientCredentials{}, fmt.Errorf("%w: %s header does not match the token client_id", auth.ErrForbidden, headerKeyClientID)
+	}
+	return metastore.ClientCredentials{ClientID: claims.ClientID}, nil
+}
+
+// Client certificate fields the client id can be taken from
+const (
+	CertClientIDFieldCN  = "cn"
+	CertClientIDFieldDNS = "dns"
+	CertClientIDFieldURI = "uri"
+)
+
+type certAuthenticator struct {
+	field string
+}
+
+// NewCertAuthenticator returns an Authenticator taking the client credentials
+// from the given field of the client certificate verified by the TLS server.
+func NewCertAuthenticator(field string) (Authenticator, error) {
+	switch field {
+	case CertClientIDFieldCN, CertClientIDFieldDNS, CertClientIDFieldURI:
+		return &certAuthenticator{field: field}, nil
+	default:
+		return nil, fmt.Errorf("unsupported client certificate field '%s'", field)
+	}
+}
+
+func (a *certAuthenticator) Authenticate(c echo.Context) (metastore.ClientCredentials, error) {
+	state := c.Request().TLS
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return metastore.ClientCredentials{}, errors.New("missing verified client certificate")
+	}
+	cert := state.VerifiedChains[0][0]
+
+	var clientID string
+	switch a.field {
+	case CertClientIDFieldCN:
+		clientID = cert.Subject.CommonName
+	case CertClientIDFieldDNS:
+		if len(cert.DNSNames) > 0 {
+			clientID = cert.DNSNames[0]
+		}
+	case CertClientIDFieldURI:
+		if len(cert.URIs) > 0 {
+			clientID = cert.URIs[0].String()
+		}
+	}
+	if clientID == "" {
+		return metastore.ClientCredentials{}, fmt.Errorf("%w: client certificate has no %s", auth.ErrForbidden, a.field)
+	}
+	if headerID := c.Request().Header.Get(headerKeyClientID); headerID != "" && headerID != clientID {
+		return metastore.ClientCredentials{}, fmt.Errorf("%w: %s header does not match the client certificate", auth.ErrForbidden, headerKeyClientID)
+	}
+	return metastore.ClientCredentials{ClientID: clientID}, nil
+}
+
+type headerAuthenticator struct{}
+
+// NewInsecureHeaderAuthenticator returns an Authenticator trusting the X-Client-ID header,
+// meant for development only as any caller can impersonate any client.
+func NewInsecureHeaderAuthenticator() Authenticator {
+	return &headerAuthenticator{}
+}
+
+func (a *headerAuthenticator) Authenticate(c echo.Context) (metastore.ClientCredentials, error) {
+	clientID := c.Request().Header.Get(headerKeyClientID)
+	if clientID == "" {
+		return metastore.ClientCredentials{}, fmt.Errorf("missing %s header", headerKeyClientID)
+	}
+	return metastore.ClientCredentials{ClientID: clientID}, nil
+}
+
+// ValidateAuthHeaders authenticates the request, its errors wrap auth.ErrForbidden
+// when the caller is not allowed and metastore.ErrUnauthorized otherwise.
+func (h *handler) ValidateAuthHeaders(c echo.Context) error {
+	creds, err := h.authenticator.Authenticate(c)
+	if err != nil {
+		if errors.Is(err, auth.ErrForbidden) {
+			return err
+		}
+		return fmt.Errorf("%w: %w", metastore.ErrUnauthorized, err)
+	}
+	c.Set(clientCredentialsContextKey, creds)
+	return nil
 }
 
 func (h *handler) generateFederationContextID(c echo.Context) string {
@@ -0,0 +1,227 @@
+package handler
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+
+	"github.com/neonephos-katalis/opg-ewbi-operator/api/ewbi/models"
+	"github.com/neonephos-katalis/opg-ewbi-operator/pkg/auth"
+)
+
+func newTestJWTAuthenticator(t *testing.T) (Authenticator, *rsa.PrivateKey) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	jwks := fmt.Sprintf(`{"keys":[{"kty":"RSA","kid":"k1","n":"%s","e":"%s"}]}`,
+		base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, []byte(jwks), 0o600))
+
+	keys, err := auth.NewFileKeySet(path)
+	require.NoError(t, err)
+	return NewJWTAuthenticator(auth.NewValidator(keys, "issuer", "audience")), key
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	jwtAuthenticator, key := newTestJWTAuthenticator(t)
+	token := func(clientID string) string {
+		tok := jwt.NewWithClaims(jwt.SigningMethodRS256, auth.Claims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Issuer:    "issuer",
+				Audience:  jwt.ClaimStrings{"audience"},
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			},
+			ClientID: clientID,
+		})
+		tok.Header["kid"] = "k1"
+		signed, err := tok.SignedString(key)
+		require.NoError(t, err)
+		return signed
+	}
+
+	tests := []struct {
+		name          string
+		authenticator Authenticator
+		headers       map[string]string
+		wantStatus    int
+		wantClientID  string
+	}{
+		{
+			name:          "Valid bearer token",
+			authenticator: jwtAuthenticator,
+			headers:       map[string]string{"Authorization": "Bearer " + token("client-1")},
+			wantStatus:    http.StatusOK,
+			wantClientID:  "client-1",
+		},
+		{
+			name:          "Missing bearer token",
+			authenticator: jwtAuthenticator,
+			headers:       map[string]string{headerKeyClientID: "client-1"},
+			wantStatus:    http.StatusUnauthorized,
+		},
+		{
+			name:          "Invalid bearer token",
+			authenticator: jwtAuthenticator,
+			headers:       map[string]string{"Authorization": "Bearer invalid"},
+			wantStatus:    http.StatusUnauthorized,
+		},
+		{
+			name:          "Token without client_id",
+			authenticator: jwtAuthenticator,
+			headers:       map[string]string{"Authorization": "Bearer " + token("")},
+			wantStatus:    http.StatusForbidden,
+		},
+		{
+			name:          "Client id header not matching the token",
+			authenticator: jwtAuthenticator,
+			headers: map[string]string{
+				"Authorization":   "Bearer " + token("client-1"),
+				headerKeyClientID: "client-2",
+			},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:          "Insecure client id header",
+			authenticator: NewInsecureHeaderAuthenticator(),
+			headers:       map[string]string{headerKeyClientID: "client-1"},
+			wantStatus:    http.StatusOK,
+			wantClientID:  "client-1",
+		},
+		{
+			name:          "Insecure missing client id header",
+			authenticator: NewInsecureHeaderAuthenticator(),
+			wantStatus:    http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &handler{
+				authenticator:                   tt.authenticator,
+				getRequestClientCredentialsFunc: getRequestClientCredentials,
+			}
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			c := echo.New().NewContext(req, rec)
+
+			var gotClientID string
+			err := AuthMiddleware(h)(func(c echo.Context) error {
+				creds, err := h.getRequestClientCredentialsFunc(c)
+				require.NoError(t, err)
+				gotClientID = creds.ClientID
+				return c.NoContent(http.StatusOK)
+			})(c)
+			require.NoError(t, err)
+
+			require.Equal(t, tt.wantStatus, rec.Code)
+			require.Equal(t, tt.wantClientID, gotClientID)
+			if tt.wantStatus != http.StatusOK {
+				problem := models.ProblemDetails{}
+				require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+				require.NotNil(t, problem.Detail)
+				require.Nil(t, problem.Title)
+			}
+		})
+	}
+}
+
+func TestCertAuthenticator(t *testing.T) {
+	spiffeID, _ := url.Parse("spiffe://partner.com/client-1")
+	cert := &x509.Certificate{
+		Subject:  pkix.Name{CommonName: "client-cn"},
+		DNSNames: []string{"client.partner.com"},
+		URIs:     []*url.URL{spiffeID},
+	}
+
+	tests := []struct {
+		name         string
+		field        string
+		state        *tls.ConnectionState
+		headers      map[string]string
+		wantErr      error
+		wantClientID string
+	}{
+		{
+			name:         "Client id from the subject CN",
+			field:        CertClientIDFieldCN,
+			state:        &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}},
+			wantClientID: "client-cn",
+		},
+		{
+			name:         "Client id from the DNS SAN",
+			field:        CertClientIDFieldDNS,
+			state:        &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}},
+			wantClientID: "client.partner.com",
+		},
+		{
+			name:         "Client id from the URI SAN",
+			field:        CertClientIDFieldURI,
+			state:        &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}},
+			wantClientID: "spiffe://partner.com/client-1",
+		},
+		{
+			name:  "Certificate without the field",
+			field: CertClientIDFieldDNS,
+			state: &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{
+				{Subject: pkix.Name{CommonName: "client-cn"}},
+			}}},
+			wantErr: auth.ErrForbidden,
+		},
+		{
+			name:    "Client id header not matching the certificate",
+			field:   CertClientIDFieldCN,
+			state:   &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}},
+			headers: map[string]string{headerKeyClientID: "other"},
+			wantErr: auth.ErrForbidden,
+		},
+		{
+			name:  "Plain HTTP request",
+			field: CertClientIDFieldCN,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := NewCertAuthenticator(tt.field)
+			require.NoError(t, err)
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.TLS = tt.state
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			creds, err := a.Authenticate(echo.New().NewContext(req, httptest.NewRecorder()))
+			switch {
+			case tt.wantErr != nil:
+				require.ErrorIs(t, err, tt.wantErr)
+			case tt.wantClientID == "":
+				require.Error(t, err)
+			default:
+				require.NoError(t, err)
+				require.Equal(t, tt.wantClientID, creds.ClientID)
+			}
+		})
+	}
+}
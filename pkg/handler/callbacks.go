@@ -75,7 +75,17 @@ func (h *handler) AppInstCallbackLink(c echo.Context, federationCallbackId model
 // Notification about resource availability.
 // (POST /{federationCallbackId}/availZoneNotifLink)
 func (h *handler) AvailZoneNotifLink(c echo.Context, federationCallbackId models.FederationCallbackId) error {
-	return c.JSON(http.StatusNotImplemented, nil)
+	ctx := h.getRequestContextFunc(c)
+
+	request, err := bindRequest[models.AvailZoneNotifLinkJSONRequestBody](c)
+	if err != nil {
+		return sendErrorResponse(c, http.StatusBadRequest, err.Error())
+	}
+
+	if err := h.metaStoreClient.UpdateFederationZoneAvailability(ctx, federationCallbackId, request); err != nil {
+		return sendErrorResponseFromError(c, err)
+	}
+	return c.JSON(http.StatusNoContent, nil)
 }
 
 // OP uses this callback api to notify partner OP about change in federation status, federation metadata or offered zone details. Allowed combinations of objectType and operationType are
@@ -113,7 +123,9 @@ func (h *handler) PartnerStatusLink(c echo.Context, federationCallbackId models.
 			return sendErrorResponseFromError(c, err)
 		}
 	default:
-		return sendErrorResponse(c, http.StatusNotImplemented, "ObjectType not implemented")
+		if err := h.metaStoreClient.UpdateFederationPartnerStatus(ctx, federationCallbackId, request); err != nil {
+			return sendErrorResponseFromError(c, err)
+		}
 	}
 
 	return c.JSON(http.StatusNoContent, nil)
@@ -122,5 +134,15 @@ func (h *handler) PartnerStatusLink(c echo.Context, federationCallbackId models.
 // Notification payload.
 // (POST /{federationCallbackId}/resourceReservationCallbackLink)
 func (h *handler) ResourceReservationCallbackLink(c echo.Context, federationCallbackId models.FederationCallbackId) error {
-	return c.JSON(http.StatusNotImplemented, nil)
+	ctx := h.getRequestContextFunc(c)
+
+	request, err := bindRequest[models.ResourceReservationCallbackLinkJSONRequestBody](c)
+	if err != nil {
+		return sendErrorResponse(c, http.StatusBadRequest, err.Error())
+	}
+
+	if err := h.metaStoreClient.UpdateResourcePoolStatus(ctx, federationCallbackId, request); err != nil {
+		return sendErrorResponseFromError(c, err)
+	}
+	return c.JSON(http.StatusNoContent, nil)
 }
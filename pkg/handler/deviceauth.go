@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/neonephos-katalis/opg-ewbi-operator/pkg/auth"
+)
+
+// DeviceAuthenticator validates the tokens of the roaming devices the partner OPs
+// ask the home OP about. Errors wrapping auth.ErrInvalidToken are answered with 401,
+// auth.ErrUnavailable with 503 and any other error with 500.
+type DeviceAuthenticator interface {
+	AuthenticateDevice(ctx context.Context, federationContextID, deviceID, token string) error
+}
+
+type jwtDeviceAuthenticator struct {
+	validator *auth.Validator
+}
+
+// NewJWTDeviceAuthenticator returns a DeviceAuthenticator validating signed device
+// tokens, which are only valid for the device in their sub claim.
+func NewJWTDeviceAuthenticator(validator *auth.Validator) DeviceAuthenticator {
+	return &jwtDeviceAuthenticator{validator: validator}
+}
+
+func (a *jwtDeviceAuthenticator) AuthenticateDevice(ctx context.Context, federationContextID, deviceID, token string) error {
+	claims, err := a.validator.Validate(ctx, token)
+	if err != nil {
+		return err
+	}
+	if claims.Subject != deviceID {
+		return fmt.Errorf("%w: token not issued to device '%s'", auth.ErrInvalidToken, deviceID)
+	}
+	return nil
+}
+
+// deviceValidationRequest is the body sent to the device validation service.
+type deviceValidationRequest struct {
+	FederationContextID string `json:"federationContextId"`
+	DeviceID            string `json:"deviceId"`
+	AuthToken           string `json:"authToken"`
+}
+
+type httpDeviceAuthenticator struct {
+	url        string
+	httpClient *http.Client
+}
+
+// NewHTTPDeviceAuthenticator returns a DeviceAuthenticator posting the device tokens
+// to the validation service at url. The service answers 2xx for valid tokens and
+// 401, 403 or 404 for invalid ones, any other answer is reported as unavailable.
+func NewHTTPDeviceAuthenticator(url string, httpClient *http.Client) DeviceAuthenticator {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &httpDeviceAuthenticator{url: url, httpClient: httpClient}
+}
+
+func (a *httpDeviceAuthenticator) AuthenticateDevice(ctx context.Context, federationContextID, deviceID, token string) error {
+	body, err := json.Marshal(deviceValidationRequest{
+		FederationContextID: federationContextID,
+		DeviceID:            deviceID,
+		AuthToken:           token,
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := a.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %s", auth.ErrUnavailable, err.Error())
+	}
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode >= 200 && res.StatusCode < 300:
+		return nil
+	case res.StatusCode == http.StatusUnauthorized, res.StatusCode == http.StatusForbidden, res.StatusCode == http.StatusNotFound:
+		return fmt.Errorf("%w: rejected by the device validation service", auth.ErrInvalidToken)
+	default:
+		return fmt.Errorf("%w: device validation service returned status %d", auth.ErrUnavailable, res.StatusCode)
+	}
+}
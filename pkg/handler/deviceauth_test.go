@@ -0,0 +1,159 @@
+package handler
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+
+	"github.com/neonephos-katalis/opg-ewbi-operator/pkg/auth"
+	"github.com/neonephos-katalis/opg-ewbi-operator/pkg/metastore"
+)
+
+type fakeFederationMetaStoreClient struct {
+	metastore.Client
+}
+
+func (f *fakeFederationMetaStoreClient) GetFederation(ctx context.Context, federationContextID string) (*metastore.Federation, error) {
+	if federationContextID != "fed-1" {
+		return nil, fmt.Errorf("federation %w", metastore.ErrNotFound)
+	}
+	return &metastore.Federation{FederationContextId: federationContextID}, nil
+}
+
+func TestJWTDeviceAuthenticator(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	jwks := fmt.Sprintf(`{"keys":[{"kty":"RSA","kid":"k1","n":"%s","e":"%s"}]}`,
+		base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, []byte(jwks), 0o600))
+	keys, err := auth.NewFileKeySet(path)
+	require.NoError(t, err)
+	a := NewJWTDeviceAuthenticator(auth.NewValidator(keys, "home-op", ""))
+
+	token := func(subject string, expiresAt time.Time) string {
+		tok := jwt.NewWithClaims(jwt.SigningMethodRS256, auth.Claims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Issuer:    "home-op",
+				Subject:   subject,
+				ExpiresAt: jwt.NewNumericDate(expiresAt),
+			},
+		})
+		tok.Header["kid"] = "k1"
+		signed, err := tok.SignedString(key)
+		require.NoError(t, err)
+		return signed
+	}
+
+	ctx := context.Background()
+	require.NoError(t, a.AuthenticateDevice(ctx, "fed-1", "device-1", token("device-1", time.Now().Add(time.Hour))))
+	require.ErrorIs(t, a.AuthenticateDevice(ctx, "fed-1", "device-2", token("device-1", time.Now().Add(time.Hour))), auth.ErrInvalidToken)
+	require.ErrorIs(t, a.AuthenticateDevice(ctx, "fed-1", "device-1", token("device-1", time.Now().Add(-time.Hour))), auth.ErrInvalidToken)
+	require.ErrorIs(t, a.AuthenticateDevice(ctx, "fed-1", "device-1", "not-a-token"), auth.ErrInvalidToken)
+}
+
+func TestHTTPDeviceAuthenticator(t *testing.T) {
+	// stub of the device validation service accepting the token "valid"
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := deviceValidationRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		switch req.AuthToken {
+		case "valid":
+			w.WriteHeader(http.StatusOK)
+		case "unavailable":
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer stub.Close()
+	a := NewHTTPDeviceAuthenticator(stub.URL, stub.Client())
+	ctx := context.Background()
+
+	require.NoError(t, a.AuthenticateDevice(ctx, "fed-1", "device-1", "valid"))
+	require.ErrorIs(t, a.AuthenticateDevice(ctx, "fed-1", "device-1", "invalid"), auth.ErrInvalidToken)
+	require.ErrorIs(t, a.AuthenticateDevice(ctx, "fed-1", "device-1", "unavailable"), auth.ErrUnavailable)
+
+	down := NewHTTPDeviceAuthenticator("http://127.0.0.1:0", nil)
+	require.ErrorIs(t, down.AuthenticateDevice(ctx, "fed-1", "device-1", "valid"), auth.ErrUnavailable)
+}
+
+type fakeDeviceAuthenticator struct {
+	err error
+}
+
+func (f *fakeDeviceAuthenticator) AuthenticateDevice(ctx context.Context, federationContextID, deviceID, token string) error {
+	return f.err
+}
+
+func TestAuthenticateDevice(t *testing.T) {
+	tests := []struct {
+		name                string
+		federationContextID string
+		deviceAuthenticator DeviceAuthenticator
+		wantStatus          int
+	}{
+		{
+			name:                "Valid token",
+			federationContextID: "fed-1",
+			deviceAuthenticator: &fakeDeviceAuthenticator{},
+			wantStatus:          http.StatusOK,
+		},
+		{
+			name:                "Invalid token",
+			federationContextID: "fed-1",
+			deviceAuthenticator: &fakeDeviceAuthenticator{err: fmt.Errorf("%w: expired", auth.ErrInvalidToken)},
+			wantStatus:          http.StatusUnauthorized,
+		},
+		{
+			name:                "Validation unavailable",
+			federationContextID: "fed-1",
+			deviceAuthenticator: &fakeDeviceAuthenticator{err: fmt.Errorf("%w: timeout", auth.ErrUnavailable)},
+			wantStatus:          http.StatusServiceUnavailable,
+		},
+		{
+			name:                "Unknown federation",
+			federationContextID: "fed-2",
+			deviceAuthenticator: &fakeDeviceAuthenticator{},
+			wantStatus:          http.StatusNotFound,
+		},
+		{
+			name:                "Not configured",
+			federationContextID: "fed-1",
+			wantStatus:          http.StatusNotImplemented,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &handler{
+				deviceAuthenticator:   tt.deviceAuthenticator,
+				getRequestContextFunc: getRequestContext,
+				metaStoreClient:       &fakeFederationMetaStoreClient{},
+			}
+			rec := httptest.NewRecorder()
+			c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/", nil), rec)
+			require.NoError(t, h.AuthenticateDevice(c, tt.federationContextID, "device-1", "token"))
+			require.Equal(t, tt.wantStatus, rec.Code)
+		})
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/labstack/echo/v4"
 
 	"github.com/neonephos-katalis/opg-ewbi-operator/api/ewbi/models"
+	"github.com/neonephos-katalis/opg-ewbi-operator/pkg/auth"
 	"github.com/neonephos-katalis/opg-ewbi-operator/pkg/metastore"
 )
 
@@ -33,12 +34,16 @@ func statusCodeFromError(err error) int {
 	switch {
 	case errors.Is(err, metastore.ErrAlreadyExists):
 		return http.StatusConflict
+	case errors.Is(err, metastore.ErrConflict):
+		return http.StatusConflict
 	case errors.Is(err, metastore.ErrBadRequest):
 		return http.StatusBadRequest
 	case errors.Is(err, metastore.ErrNotFound):
 		return http.StatusNotFound
 	case errors.Is(err, metastore.ErrUnauthorized):
 		return http.StatusUnauthorized
+	case errors.Is(err, auth.ErrForbidden):
+		return http.StatusForbidden
 	default:
 		return http.StatusInternalServerError
 	}
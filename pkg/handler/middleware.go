@@ -1,6 +1,8 @@
 package handler
 
 import (
+	"net/http"
+
 	"github.com/labstack/echo/v4"
 )
 
@@ -8,9 +10,11 @@ import (
 func AuthMiddleware(h *handler) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
-			// TODO: Integration with IdP
-			if errCode, err := h.ValidateAuthHeaders(c); err != nil {
-				return sendErrorResponse(c, errCode, err.Error())
+			if err := h.ValidateAuthHeaders(c); err != nil {
+				if statusCodeFromError(err) == http.StatusUnauthorized {
+					c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Bearer error="invalid_token"`)
+				}
+				return sendErrorResponseFromError(c, err)
 			}
 			return next(c)
 		}
@@ -2,14 +2,15 @@ package handler
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 
 	"github.com/labstack/echo/v4"
-	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/neonephos-katalis/opg-ewbi-operator/api/ewbi/models"
 	"github.com/neonephos-katalis/opg-ewbi-operator/api/ewbi/server"
+	"github.com/neonephos-katalis/opg-ewbi-operator/pkg/auth"
 	"github.com/neonephos-katalis/opg-ewbi-operator/pkg/deployment"
 	"github.com/neonephos-katalis/opg-ewbi-operator/pkg/metastore"
 )
@@ -20,19 +21,26 @@ const (
 	headerKeyClientID = "X-Client-ID"
 )
 
-func NewServer(apiRoot string, k8sClient client.Client, namespace string) *handler {
+// NewServer returns the E/WBI handler, AuthenticateDevice answers 501 when deviceAuthenticator is nil.
+func NewServer(
+	apiRoot string, metaStoreClient metastore.Client, authenticator Authenticator, deviceAuthenticator DeviceAuthenticator,
+) *handler {
 	return &handler{
 		apiRoot:                         apiRoot,
-		depClient:                       deployment.NewClient(k8sClient, namespace),
+		authenticator:                   authenticator,
+		depClient:                       deployment.NewClient(metaStoreClient),
+		deviceAuthenticator:             deviceAuthenticator,
 		getRequestClientCredentialsFunc: getRequestClientCredentials,
 		getRequestContextFunc:           getRequestContext,
-		metaStoreClient:                 metastore.NewK8sClient(k8sClient, namespace),
+		metaStoreClient:                 metaStoreClient,
 	}
 }
 
 type handler struct {
 	apiRoot                         string
+	authenticator                   Authenticator
 	depClient                       deployment.Client
+	deviceAuthenticator             DeviceAuthenticator
 	getRequestClientCredentialsFunc func(echo.Context) (metastore.ClientCredentials, error) // test purposes
 	getRequestContextFunc           func(echo.Context) context.Context                      // test purposes
 	metaStoreClient                 metastore.Client
@@ -90,7 +98,12 @@ func (h *handler) InstallApp(c echo.Context, federationContextId models.Federati
 // Terminate an application instance on a partner OP zone.
 // (DELETE /{federationContextId}/application/lcm/app/{appId}/instance/{appInstanceId}/zone/{zoneId})
 func (h *handler) RemoveApp(c echo.Context, federationContextId models.FederationContextId, appId models.AppIdentifier, appInstanceId models.InstanceIdentifier, zoneId models.ZoneIdentifier) error {
-	if err := h.depClient.Uninstall(h.getRequestContextFunc(c), federationContextId, appInstanceId); err != nil {
+	ctx := h.getRequestContextFunc(c)
+
+	if err := h.checkAppInstance(ctx, federationContextId, appId, appInstanceId, zoneId); err != nil {
+		return sendErrorResponseFromError(c, err)
+	}
+	if err := h.depClient.Uninstall(ctx, federationContextId, appInstanceId); err != nil {
 		return sendErrorResponseFromError(c, err)
 	}
 	return c.JSON(http.StatusOK, nil)
@@ -99,7 +112,12 @@ func (h *handler) RemoveApp(c echo.Context, federationContextId models.Federatio
 // Retrieves an application instance details from partner OP.
 // (GET /{federationContextId}/application/lcm/app/{appId}/instance/{appInstanceId}/zone/{zoneId})
 func (h *handler) GetAppInstanceDetails(c echo.Context, federationContextId models.FederationContextId, appId models.AppIdentifier, appInstanceId models.InstanceIdentifier, zoneId models.ZoneIdentifier) error {
-	appInst, err := h.metaStoreClient.GetApplicationInstanceDetails(h.getRequestContextFunc(c), federationContextId, appInstanceId)
+	ctx := h.getRequestContextFunc(c)
+
+	if err := h.checkAppInstance(ctx, federationContextId, appId, appInstanceId, zoneId); err != nil {
+		return sendErrorResponseFromError(c, err)
+	}
+	appInst, err := h.depClient.Status(ctx, federationContextId, appInstanceId)
 	if err != nil {
 		return sendErrorResponseFromError(c, err)
 	}
@@ -107,6 +125,32 @@ func (h *handler) GetAppInstanceDetails(c echo.Context, federationContextId mode
 	return c.JSON(http.StatusOK, appInst.GetAppInstanceDetails200JSONResponse)
 }
 
+// checkAppInstance verifies the application instance exists and belongs to the application and zone in the request path
+func (h *handler) checkAppInstance(ctx context.Context, federationContextId models.FederationContextId, appId models.AppIdentifier, appInstanceId models.InstanceIdentifier, zoneId models.ZoneIdentifier) error {
+	appInst, err := h.depClient.Get(ctx, federationContextId, appInstanceId)
+	if err != nil {
+		return err
+	}
+	if appInst.AppId != appId {
+		return fmt.Errorf("application instance '%s' of application '%s' %w", appInstanceId, appId, metastore.ErrNotFound)
+	}
+	if appInst.ZoneInfo.ZoneId != zoneId {
+		return fmt.Errorf("application instance '%s' in zone '%s' %w", appInstanceId, zoneId, metastore.ErrNotFound)
+	}
+	return nil
+}
+
+// Retrieves all application instances of partner OP
+// (GET /{federationContextId}/application/lcm/app/{appId}/appProvider/{appProviderId})
+func (h *handler) GetAllAppInstances(c echo.Context, federationContextId models.FederationContextId, appId models.AppIdentifier, appProviderId models.AppProviderId) error {
+	appInsts, err := h.metaStoreClient.ListApplicationInstances(h.getRequestContextFunc(c), federationContextId, appId, appProviderId)
+	if err != nil {
+		return sendErrorResponseFromError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, appInstancesByZone(appInsts))
+}
+
 // Submits an application details to a partner OP. Based on the details provided,  partner OP shall do bookkeeping, resource validation and other pre-deployment operations.
 // (POST /{federationContextId}/application/onboarding)
 func (h *handler) OnboardApplication(c echo.Context, federationContextId models.FederationContextId) error {
@@ -129,6 +173,23 @@ func (h *handler) OnboardApplication(c echo.Context, federationContextId models.
 
 	return c.JSON(http.StatusAccepted, nil)
 }
+
+// Updates partner OP about changes in application compute resource requirements,
+// QOS Profile, associated descriptor, or change in associated components
+// (PATCH /{federationContextId}/application/onboarding/app/{appId})
+func (h *handler) UpdateApplication(c echo.Context, federationContextId models.FederationContextId, appId models.AppIdentifier) error {
+	request, err := bindRequest[models.UpdateApplicationJSONBody](c)
+	if err != nil {
+		return sendErrorResponse(c, http.StatusBadRequest, err.Error())
+	}
+
+	if _, err := h.metaStoreClient.UpdateApplication(h.getRequestContextFunc(c), federationContextId, appId, request); err != nil {
+		return sendErrorResponseFromError(c, err)
+	}
+
+	return c.JSON(http.StatusAccepted, nil)
+}
+
 // Deboards the application from any zones, if any, and deletes the App.
 // (GET /{federationContextId}/application/onboarding/app/{appId})
 func (h *handler) DeleteApp(c echo.Context, federationContextId models.FederationContextId, appId string) error {
@@ -152,7 +213,43 @@ func (h *handler) ViewApplication(c echo.Context, federationContextId models.Fed
 // Deboards an application from partner OP zones
 // (DELETE /{federationContextId}/application/onboarding/app/{appId}/zone/{zoneId})
 func (h *handler) DeboardApplication(c echo.Context, federationContextId models.FederationContextId, appId models.AppIdentifier, zoneId models.ZoneIdentifier) error {
-	if err := h.metaStoreClient.RemoveApplication(h.getRequestContextFunc(c), federationContextId, appId); err != nil {
+	if err := h.metaStoreClient.RemoveApplicationZone(h.getRequestContextFunc(c), federationContextId, appId, zoneId); err != nil {
+		return sendErrorResponseFromError(c, err)
+	}
+
+	return c.JSON(http.StatusAccepted, nil)
+}
+
+// Forbid/allow application instantiation on a partner zone
+// (POST /{federationContextId}/application/onboarding/app/{appId}/zoneForbid)
+func (h *handler) LockUnlockApplicationZone(c echo.Context, federationContextId models.FederationContextId, appId models.AppIdentifier) error {
+	request, err := bindRequest[[]metastore.ApplicationZoneForbid](c)
+	if err != nil {
+		return sendErrorResponse(c, http.StatusBadRequest, err.Error())
+	}
+	if len(*request) == 0 {
+		return sendErrorResponse(c, http.StatusBadRequest, "no zones to forbid or allow")
+	}
+
+	if _, err := h.metaStoreClient.SetApplicationZoneForbids(h.getRequestContextFunc(c), federationContextId, appId, *request); err != nil {
+		return sendErrorResponseFromError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, nil)
+}
+
+// Onboards an existing application to a new zone within partner OP.
+// (POST /{federationContextId}/application/onboarding/app/{appId}/additionalZones)
+func (h *handler) OnboardExistingAppNewZones(c echo.Context, federationContextId models.FederationContextId, appId models.AppIdentifier) error {
+	request, err := bindRequest[models.OnboardExistingAppNewZonesJSONBody](c)
+	if err != nil {
+		return sendErrorResponse(c, http.StatusBadRequest, err.Error())
+	}
+	if len(*request) == 0 {
+		return sendErrorResponse(c, http.StatusBadRequest, "no zones to onboard")
+	}
+
+	if _, err := h.metaStoreClient.AddApplicationZones(h.getRequestContextFunc(c), federationContextId, appId, *request); err != nil {
 		return sendErrorResponseFromError(c, err)
 	}
 
@@ -276,6 +373,33 @@ func (h *handler) GetFederationDetails(c echo.Context, federationContextId model
 	return c.JSON(http.StatusOK, response)
 }
 
+// API used by the Originating OP towards the partner OP, to update the parameters associated to the existing federation
+// (PATCH /{federationContextId}/partner)
+func (h *handler) UpdateFederation(c echo.Context, federationContextId models.FederationContextId) error {
+	ctx := h.getRequestContextFunc(c)
+
+	request, err := bindRequest[models.UpdateFederationJSONBody](c)
+	if err != nil {
+		return sendErrorResponse(c, http.StatusBadRequest, err.Error())
+	}
+
+	fed, err := h.metaStoreClient.UpdateFederation(ctx, federationContextId, request)
+	if err != nil {
+		return sendErrorResponseFromError(c, err)
+	}
+
+	response := server.UpdateFederation200JSONResponse{
+		AllowedFixedNetworkIds: fed.OrigOPFixedNetworkCodes,
+		AllowedMobileNetworkIds: &models.MobileNetworkIds{
+			Mcc:  fed.OrigOPMobileNetworkCodes.Mcc,
+			Mncs: fed.OrigOPMobileNetworkCodes.Mncs,
+		},
+		OfferedAvailabilityZones: fed.OfferedAvailabilityZones,
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
 // Originating OP informs partner OP that it is willing to access the specified zones  and partner OP shall reserve compute and network resources for these zones.
 // (POST /{federationContextId}/zones)
 func (h *handler) ZoneSubscribe(c echo.Context, federationContextId models.FederationContextId) error {
@@ -325,6 +449,16 @@ func (h *handler) ZoneSubscribe(c echo.Context, federationContextId models.Feder
 	return c.JSON(http.StatusOK, &resp)
 }
 
+// Asservate usage of a partner OP zone.
+// Originating OP informs partner OP that it will no longer access the specified zone.
+// (DELETE /{federationContextId}/zones/{zoneId})
+func (h *handler) ZoneUnsubscribe(c echo.Context, federationContextId models.FederationContextId, zoneId models.ZoneIdentifier) error {
+	if err := h.metaStoreClient.RemoveAvailabilityZone(h.getRequestContextFunc(c), federationContextId, zoneId); err != nil {
+		return sendErrorResponseFromError(c, err)
+	}
+	return c.JSON(http.StatusOK, nil)
+}
+
 // Retrieves details about the computation and network resources that partner OP has reserved for this zone.
 // (GET /{federationContextId}/zones/{zoneId})
 func (h *handler) GetZoneData(c echo.Context, federationContextId models.FederationContextId, zoneId models.ZoneIdentifier) error {
@@ -345,13 +479,142 @@ func getRequestContext(c echo.Context) context.Context {
 }
 
 func getRequestClientCredentials(c echo.Context) (metastore.ClientCredentials, error) {
-	headerErrorResponse := func(header string) (metastore.ClientCredentials, error) {
-		return metastore.ClientCredentials{}, fmt.Errorf("missing %s header", header)
+	creds, ok := c.Get(clientCredentialsContextKey).(metastore.ClientCredentials)
+	if !ok {
+		return metastore.ClientCredentials{}, errors.New("unauthenticated request")
 	}
+	return creds, nil
+}
 
-	clientID := c.Request().Header.Get(headerKeyClientID)
-	if clientID == "" {
-		return headerErrorResponse(headerKeyClientID)
+// appInstancesByZone groups the application instances by zone, in the order the zones are first found
+func appInstancesByZone(appInsts []*metastore.ApplicationInstanceInfo) server.GetAllAppInstances200JSONResponse {
+	// element types of the generated response
+	type appInstanceInfo = struct {
+		AppInstIdentifier models.InstanceIdentifier `json:"appInstIdentifier"`
+		AppInstanceState  models.InstanceState      `json:"appInstanceState"`
+	}
+	type zoneAppInstances = struct {
+		AppInstanceInfo []appInstanceInfo     `json:"appInstanceInfo"`
+		ZoneId          models.ZoneIdentifier `json:"zoneId"`
+	}
+
+	res := server.GetAllAppInstances200JSONResponse{}
+	zoneIndex := map[models.ZoneIdentifier]int{}
+	for _, appInst := range appInsts {
+		i, ok := zoneIndex[appInst.ZoneId]
+		if !ok {
+			i = len(res)
+			zoneIndex[appInst.ZoneId] = i
+			res = append(res, zoneAppInstances{ZoneId: appInst.ZoneId, AppInstanceInfo: []appInstanceInfo{}})
+		}
+		res[i].AppInstanceInfo = append(res[i].AppInstanceInfo, appInstanceInfo{
+			AppInstIdentifier: appInst.AppInstanceId,
+			AppInstanceState:  appInst.State,
+		})
 	}
-	return metastore.ClientCredentials{ClientID: clientID}, nil
+	return res
+}
+
+// Reserves resources (compute, network and storage) on a partner OP zone.
+// ISVs registered with home OP reserves resources on a partner OP zone.
+// (POST /{federationContextId}/isv/resource/zone/{zoneId}/appProvider/{appProviderId})
+func (h *handler) CreateResourcePools(c echo.Context, federationContextId models.FederationContextId, zoneId models.ZoneIdentifier, appProviderId models.AppProviderId) error {
+	request, err := bindRequest[models.CreateResourcePoolsJSONBody](c)
+	if err != nil {
+		return sendErrorResponse(c, http.StatusBadRequest, err.Error())
+	}
+
+	if _, err := h.metaStoreClient.CreateResourcePool(h.getRequestContextFunc(c), &metastore.ResourcePool{
+		CreateResourcePoolsJSONBody: request,
+		FederationContextId:         federationContextId,
+		ZoneId:                      zoneId,
+		AppProviderId:               appProviderId,
+	}); err != nil {
+		return sendErrorResponseFromError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, nil)
+}
+
+// Retrieves the resource pool reserved by an ISV
+// (GET /{federationContextId}/isv/resource/zone/{zoneId}/appProvider/{appProviderId})
+func (h *handler) ViewISVResPool(c echo.Context, federationContextId models.FederationContextId, zoneId models.ZoneIdentifier, appProviderId models.AppProviderId) error {
+	pools, err := h.metaStoreClient.ListResourcePools(h.getRequestContextFunc(c), federationContextId, zoneId, appProviderId)
+	if err != nil {
+		return sendErrorResponseFromError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, server.ViewISVResPool200JSONResponse(pools))
+}
+
+// Updates resources reserved for a pool by an ISV
+// (PATCH /{federationContextId}/isv/resource/zone/{zoneId}/appProvider/{appProviderId}/pool/{poolId})
+func (h *handler) UpdateISVResPool(c echo.Context, federationContextId models.FederationContextId, zoneId models.ZoneIdentifier, appProviderId models.AppProviderId, poolId models.PoolId) error {
+	request, err := bindRequest[models.UpdateISVResPoolJSONBody](c)
+	if err != nil {
+		return sendErrorResponse(c, http.StatusBadRequest, err.Error())
+	}
+	if len(*request) == 0 {
+		return sendErrorResponse(c, http.StatusBadRequest, "no resources to update")
+	}
+
+	if err := h.metaStoreClient.UpdateResourcePool(h.getRequestContextFunc(c), federationContextId, zoneId, appProviderId, poolId, *request); err != nil {
+		return sendErrorResponseFromError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, nil)
+}
+
+// Deletes the resource pool reserved by an ISV
+// (DELETE /{federationContextId}/isv/resource/zone/{zoneId}/appProvider/{appProviderId}/pool/{poolId})
+func (h *handler) RemoveISVResPool(c echo.Context, federationContextId models.FederationContextId, zoneId models.ZoneIdentifier, appProviderId models.AppProviderId, poolId models.PoolId) error {
+	if err := h.metaStoreClient.RemoveResourcePool(h.getRequestContextFunc(c), federationContextId, zoneId, appProviderId, poolId); err != nil {
+		return sendErrorResponseFromError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, nil)
+}
+
+// Edge discovery procedures towards partner OP over E/WBI.
+// Originating OP requests partner OP to provide a list of candidate zones
+// where an application instance can be created. Partner OP applies a set
+// of filtering criteria to select candidate zones.
+// (POST /{federationContextId}/edgenodesharing/edgeDiscovery)
+func (h *handler) GetCandidateZones(c echo.Context, federationContextId models.FederationContextId) error {
+	request, err := bindRequest[models.GetCandidateZonesJSONBody](c)
+	if err != nil {
+		return sendErrorResponse(c, http.StatusBadRequest, err.Error())
+	}
+
+	zones, err := h.metaStoreClient.GetCandidateZones(h.getRequestContextFunc(c), federationContextId, request)
+	if err != nil {
+		return sendErrorResponseFromError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, server.GetCandidateZones200JSONResponse(zones))
+}
+
+// Validates the authenticity of a roaming user from home OP
+// (GET /{federationContextId}/roaminguserauth/device/{deviceId}/token/{authToken})
+func (h *handler) AuthenticateDevice(c echo.Context, federationContextId models.FederationContextId, deviceId models.DeviceId, authToken models.AuthorizationToken) error {
+	if h.deviceAuthenticator == nil {
+		return sendErrorResponse(c, http.StatusNotImplemented, "roaming device authentication is not configured")
+	}
+	ctx := h.getRequestContextFunc(c)
+
+	if _, err := h.metaStoreClient.GetFederation(ctx, federationContextId); err != nil {
+		return sendErrorResponseFromError(c, err)
+	}
+	if err := h.deviceAuthenticator.AuthenticateDevice(ctx, federationContextId, deviceId, authToken); err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInvalidToken):
+			return sendErrorResponse(c, http.StatusUnauthorized, err.Error())
+		case errors.Is(err, auth.ErrUnavailable):
+			return sendErrorResponse(c, http.StatusServiceUnavailable, err.Error())
+		default:
+			return sendErrorResponse(c, http.StatusInternalServerError, err.Error())
+		}
+	}
+
+	return c.NoContent(http.StatusOK)
 }
@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+
+	"github.com/neonephos-katalis/opg-ewbi-operator/api/ewbi/models"
+	"github.com/neonephos-katalis/opg-ewbi-operator/api/ewbi/server"
+	"github.com/neonephos-katalis/opg-ewbi-operator/pkg/deployment"
+	"github.com/neonephos-katalis/opg-ewbi-operator/pkg/metastore"
+)
+
+func TestAppInstancesByZone(t *testing.T) {
+	res := appInstancesByZone([]*metastore.ApplicationInstanceInfo{
+		{AppInstanceId: "inst-1", ZoneId: "zone-1", State: models.InstanceStateREADY},
+		{AppInstanceId: "inst-2", ZoneId: "zone-2", State: models.InstanceStatePENDING},
+		{AppInstanceId: "inst-3", ZoneId: "zone-1", State: models.InstanceStateFAILED},
+	})
+
+	data, err := json.Marshal(res)
+	require.NoError(t, err)
+	require.JSONEq(t, `[
+		{"zoneId":"zone-1","appInstanceInfo":[
+			{"appInstIdentifier":"inst-1","appInstanceState":"READY"},
+			{"appInstIdentifier":"inst-3","appInstanceState":"FAILED"}
+		]},
+		{"zoneId":"zone-2","appInstanceInfo":[
+			{"appInstIdentifier":"inst-2","appInstanceState":"PENDING"}
+		]}
+	]`, string(data))
+
+	data, err = json.Marshal(appInstancesByZone(nil))
+	require.NoError(t, err)
+	require.JSONEq(t, `[]`, string(data))
+}
+
+type fakeDeploymentClient struct {
+	deployment.Client
+	appInsts    map[string]*metastore.ApplicationInstance
+	uninstalled []string
+}
+
+func (f *fakeDeploymentClient) Get(ctx context.Context, federationContextID, id string) (*metastore.ApplicationInstance, error) {
+	appInst, ok := f.appInsts[id]
+	if !ok {
+		return nil, fmt.Errorf("applicationInstance %w", metastore.ErrNotFound)
+	}
+	return appInst, nil
+}
+
+func (f *fakeDeploymentClient) Status(ctx context.Context, federationContextID, id string) (*metastore.ApplicationInstanceDetails, error) {
+	state := models.InstanceStateREADY
+	return &metastore.ApplicationInstanceDetails{
+		GetAppInstanceDetails200JSONResponse: &server.GetAppInstanceDetails200JSONResponse{AppInstanceState: &state},
+	}, nil
+}
+
+func (f *fakeDeploymentClient) Uninstall(ctx context.Context, federationContextID, id string) error {
+	f.uninstalled = append(f.uninstalled, id)
+	return nil
+}
+
+func TestAppInstancePathParameters(t *testing.T) {
+	appInst := &metastore.ApplicationInstance{
+		InstallAppJSONBody: &models.InstallAppJSONBody{AppId: "app-1", AppInstanceId: "inst-1"},
+	}
+	appInst.ZoneInfo.ZoneId = "zone-1"
+
+	tests := []struct {
+		name          string
+		appID         string
+		appInstanceID string
+		zoneID        string
+		wantStatus    int
+	}{
+		{
+			name:          "Matching instance",
+			appID:         "app-1",
+			appInstanceID: "inst-1",
+			zoneID:        "zone-1",
+			wantStatus:    http.StatusOK,
+		},
+		{
+			name:          "Instance of another application",
+			appID:         "app-2",
+			appInstanceID: "inst-1",
+			zoneID:        "zone-1",
+			wantStatus:    http.StatusNotFound,
+		},
+		{
+			name:          "Instance in another zone",
+			appID:         "app-1",
+			appInstanceID: "inst-1",
+			zoneID:        "zone-2",
+			wantStatus:    http.StatusNotFound,
+		},
+		{
+			name:          "Unknown instance",
+			appID:         "app-1",
+			appInstanceID: "inst-2",
+			zoneID:        "zone-1",
+			wantStatus:    http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			depClient := &fakeDeploymentClient{appInsts: map[string]*metastore.ApplicationInstance{"inst-1": appInst}}
+			h := &handler{depClient: depClient, getRequestContextFunc: getRequestContext}
+
+			rec := httptest.NewRecorder()
+			c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/", nil), rec)
+			require.NoError(t, h.GetAppInstanceDetails(c, "fed-1", tt.appID, tt.appInstanceID, tt.zoneID))
+			require.Equal(t, tt.wantStatus, rec.Code)
+
+			rec = httptest.NewRecorder()
+			c = echo.New().NewContext(httptest.NewRequest(http.MethodDelete, "/", nil), rec)
+			require.NoError(t, h.RemoveApp(c, "fed-1", tt.appID, tt.appInstanceID, tt.zoneID))
+			require.Equal(t, tt.wantStatus, rec.Code)
+			if tt.wantStatus == http.StatusOK {
+				require.Equal(t, []string{tt.appInstanceID}, depClient.uninstalled)
+			} else {
+				require.Empty(t, depClient.uninstalled)
+			}
+		})
+	}
+}
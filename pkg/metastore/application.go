@@ -3,7 +3,9 @@ package metastore
 import (
 	"encoding/json"
 	"fmt"
+	"slices"
 
+	"github.com/pkg/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/icza/gog"
@@ -16,6 +18,15 @@ import (
 type Application struct {
 	*camara.ViewApplication200JSONResponse
 	FederationContextId models.FederationContextId
+	// ForbiddenZones, zones where the application can't be instantiated
+	ForbiddenZones []models.ZoneIdentifier
+}
+
+// ApplicationZoneForbid is an item of a LockUnlockApplicationZone request, the
+// generated model is an untyped map.
+type ApplicationZoneForbid struct {
+	ZoneId models.ZoneIdentifier `json:"zoneId"`
+	Forbid *bool                 `json:"forbid"`
 }
 
 type OnboardApplication struct {
@@ -29,9 +40,10 @@ func (a *OnboardApplication) MarshalJSON() ([]byte, error) {
 }
 
 func (a *OnboardApplication) k8sCustomResource(namespace string, opts ...Opt) (*opgv1beta1.Application, error) {
+	name := k8sCustomResourceNameFromApplicationID(a.FederationContextId, a.AppId)
 	obj := &opgv1beta1.Application{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      k8sCustomResourceNameFromApplicationID(a.FederationContextId, a.AppId),
+			Name:      name,
 			Namespace: namespace,
 			Labels: map[string]string{
 				opgLabel(federationContextIDLabel): a.FederationContextId,
@@ -42,9 +54,10 @@ func (a *OnboardApplication) k8sCustomResource(namespace string, opts ...Opt) (*
 		Spec: opgv1beta1.ApplicationSpec{
 			AppProviderId:  a.AppProviderId,
 			ComponentSpecs: a.componentSpecs(),
-			MetaData:       a.metaData(),
+			MetaData:       a.metaData(name),
 			QoSProfile:     a.qosProfile(),
 			StatusLink:     a.AppStatusCallbackLink,
+			Zones:          mergeUnique(nil, defaultIfNil(a.AppDeploymentZones)),
 		},
 	}
 	for _, opt := range opts {
@@ -68,18 +81,26 @@ func (a *OnboardApplication) componentSpecs() []opgv1beta1.ComponentSpecRef {
 	out := make([]opgv1beta1.ComponentSpecRef, len(a.AppComponentSpecs))
 	for i, componentSpec := range a.AppComponentSpecs {
 		out[i] = opgv1beta1.ComponentSpecRef{
-			ArtefactId: componentSpec.ArtefactId,
+			ArtefactId:    componentSpec.ArtefactId,
+			ComponentName: defaultIfNil(componentSpec.ComponentName),
 		}
 	}
 	return out
 }
 
-func (a *OnboardApplication) metaData() opgv1beta1.AppMetaData {
+// credentials returns the access token of the application, kept out of the Application spec.
+func (a *OnboardApplication) credentials() credentialsSecret {
+	creds := credentialsSecret{}
+	creds.set(accessTokenSecretKey, &a.AppMetaData.AccessToken)
+	return creds
+}
+
+func (a *OnboardApplication) metaData(name string) opgv1beta1.AppMetaData {
 	return opgv1beta1.AppMetaData{
-		AccessToken:     a.AppMetaData.AccessToken,
-		Name:            a.AppMetaData.AppName,
-		MobilitySupport: defaultIfNil(a.AppMetaData.MobilitySupport),
-		Version:         a.AppMetaData.Version,
+		AccessTokenSecretRef: a.credentials().ref(name, accessTokenSecretKey),
+		Name:                 a.AppMetaData.AppName,
+		MobilitySupport:      defaultIfNil(a.AppMetaData.MobilitySupport),
+		Version:              a.AppMetaData.Version,
 	}
 }
 
@@ -92,6 +113,65 @@ func (a *OnboardApplication) qosProfile() opgv1beta1.QoSProfile {
 	}
 }
 
+// updatedArtefacts returns the artefacts referenced by the component specs of the update.
+func updatedArtefacts(update *models.UpdateApplicationJSONBody) []string {
+	if update.AppComponentSpecs == nil {
+		return nil
+	}
+	out := []string{}
+	for _, componentSpec := range *update.AppComponentSpecs {
+		if componentSpec.ArtefactId != nil {
+			out = append(out, *componentSpec.ArtefactId)
+		}
+	}
+	return out
+}
+
+// applyApplicationUpdate applies the changes of an UpdateApplication request to the application spec.
+// Component specs replace the existing ones, a component without artefact keeps the artefact
+// of the existing component with the same name.
+func applyApplicationUpdate(spec *opgv1beta1.ApplicationSpec, update *models.UpdateApplicationJSONBody) error {
+	if update.AppComponentSpecs != nil {
+		componentSpecs := make([]opgv1beta1.ComponentSpecRef, 0, len(*update.AppComponentSpecs))
+		for _, componentSpec := range *update.AppComponentSpecs {
+			artefactID := defaultIfNil(componentSpec.ArtefactId)
+			if artefactID == "" {
+				i := slices.IndexFunc(spec.ComponentSpecs, func(cs opgv1beta1.ComponentSpecRef) bool {
+					return cs.ComponentName == componentSpec.ComponentName
+				})
+				if i < 0 {
+					return errors.Wrapf(ErrBadRequest, "component '%s' has no artefact", componentSpec.ComponentName)
+				}
+				artefactID = spec.ComponentSpecs[i].ArtefactId
+			}
+			componentSpecs = append(componentSpecs, opgv1beta1.ComponentSpecRef{
+				ArtefactId:    artefactID,
+				ComponentName: componentSpec.ComponentName,
+			})
+		}
+		spec.ComponentSpecs = componentSpecs
+	}
+
+	if qos := update.AppUpdQoSProfile; qos != nil {
+		if qos.AppProvisioning != nil {
+			spec.QoSProfile.Provisioning = *qos.AppProvisioning
+		}
+		if qos.LatencyConstraints != nil {
+			spec.QoSProfile.LatencyConstraints = string(*qos.LatencyConstraints)
+		}
+		if qos.MultiUserClients != nil {
+			spec.QoSProfile.MultiUserClients = string(*qos.MultiUserClients)
+		}
+		if qos.NoOfUsersPerAppInst != nil {
+			spec.QoSProfile.UsersPerAppInst = int64(*qos.NoOfUsersPerAppInst)
+		}
+		if qos.MobilitySupport != nil {
+			spec.MetaData.MobilitySupport = *qos.MobilitySupport
+		}
+	}
+	return nil
+}
+
 func k8sCustomResourceNameFromApplicationID(federationContextID, appID string) string {
 	return fmt.Sprintf("%s-%s", applicationKind, uuidV5Fn(federationContextID+"/"+appID))
 }
@@ -116,13 +196,17 @@ func applicationFromK8sCustomResource(app opgv1beta1.Application) (*Application,
 		componentSpec[i] = appComponentSpec{
 			ArtefactId: cs.ArtefactId,
 		}
+		if cs.ComponentName != "" {
+			componentSpec[i].ComponentName = gog.Ptr(cs.ComponentName)
+		}
 	}
 	return &Application{
 		ViewApplication200JSONResponse: &camara.ViewApplication200JSONResponse{
-			AppProviderId:     app.Spec.AppProviderId,
-			AppComponentSpecs: componentSpec,
+			AppProviderId:      app.Spec.AppProviderId,
+			AppComponentSpecs:  componentSpec,
+			AppDeploymentZones: mergeUnique(nil, app.Spec.Zones),
 			AppMetaData: models.AppMetaData{
-				AccessToken:     app.Spec.MetaData.AccessToken,
+				AccessToken:     defaultIfNil(redactedIfSet(app.Spec.MetaData.AccessTokenSecretRef)),
 				AppName:         app.Spec.MetaData.Name,
 				Version:         app.Spec.MetaData.Version,
 				MobilitySupport: &app.Spec.MetaData.MobilitySupport,
@@ -135,9 +219,92 @@ func applicationFromK8sCustomResource(app opgv1beta1.Application) (*Application,
 			},
 		},
 		FederationContextId: app.Labels[opgLabel(federationContextIDLabel)],
+		ForbiddenZones:      mergeUnique(nil, app.Spec.ForbiddenZones),
 	}, nil
 }
 
+// applyApplicationZoneForbids forbids or allows the instantiation of the application in
+// the zones of a LockUnlockApplicationZone request, all of them must be onboarded.
+func applyApplicationZoneForbids(spec *opgv1beta1.ApplicationSpec, forbids []ApplicationZoneForbid) error {
+	forbidden, allowed := []string{}, []string{}
+	for _, f := range forbids {
+		if f.ZoneId == "" || f.Forbid == nil {
+			return errors.Wrap(ErrBadRequest, "zoneId and forbid are required")
+		}
+		if !slices.Contains(spec.Zones, f.ZoneId) {
+			return errors.Wrapf(ErrNotFound, "application not onboarded in zone '%s'", f.ZoneId)
+		}
+		if *f.Forbid {
+			forbidden = append(forbidden, f.ZoneId)
+		} else {
+			allowed = append(allowed, f.ZoneId)
+		}
+	}
+	spec.ForbiddenZones = removeValues(mergeUnique(spec.ForbiddenZones, forbidden), allowed)
+	return nil
+}
+
+// applicationStatePrecedence orders the zone states, the application state is the
+// state of its zones with the highest precedence
+var applicationStatePrecedence = []opgv1beta1.ApplicationState{
+	opgv1beta1.ApplicationStateFailed,
+	opgv1beta1.ApplicationStateDeboarding,
+	opgv1beta1.ApplicationStatePending,
+	opgv1beta1.ApplicationStateOnboarded,
+}
+
+// applyApplicationStatusUpdate applies the zone states of an application status callback,
+// zones reported as REMOVED are dropped from the status. It returns false when the
+// callback carries no valid state.
+func applyApplicationStatusUpdate(status *opgv1beta1.ApplicationStatus, updates *models.AppStatusCallbackLinkJSONRequestBody, now metav1.Time) bool {
+	updated := false
+	removed := false
+	for _, info := range updates.StatusInfo {
+		state := opgv1beta1.ApplicationState(info.OnboardStatusInfo)
+		if !isValidApplicationStatus(string(state)) {
+			continue
+		}
+		updated = true
+		if info.ZoneId == "" {
+			// no zone, the state applies to the application
+			status.State = state
+			continue
+		}
+		i := slices.IndexFunc(status.Zones, func(z opgv1beta1.ApplicationZoneStatus) bool {
+			return z.ZoneId == info.ZoneId
+		})
+		switch {
+		case state == opgv1beta1.ApplicationStateRemoved:
+			removed = true
+			if i >= 0 {
+				status.Zones = slices.Delete(status.Zones, i, i+1)
+			}
+		case i >= 0:
+			status.Zones[i].State = state
+			status.Zones[i].LastUpdateTime = now
+		default:
+			status.Zones = append(status.Zones, opgv1beta1.ApplicationZoneStatus{
+				ZoneId:         info.ZoneId,
+				State:          state,
+				LastUpdateTime: now,
+			})
+		}
+	}
+	if len(status.Zones) == 0 {
+		if removed {
+			status.State = opgv1beta1.ApplicationStateRemoved
+		}
+		return updated
+	}
+	for _, state := range applicationStatePrecedence {
+		if slices.ContainsFunc(status.Zones, func(z opgv1beta1.ApplicationZoneStatus) bool { return z.State == state }) {
+			status.State = state
+			break
+		}
+	}
+	return updated
+}
+
 func isValidApplicationStatus(status string) bool {
 	switch opgv1beta1.ApplicationState(status) {
 	case opgv1beta1.ApplicationStatePending, opgv1beta1.ApplicationStateOnboarded, opgv1beta1.ApplicationStateDeboarding, opgv1beta1.ApplicationStateFailed, opgv1beta1.ApplicationStateRemoved:
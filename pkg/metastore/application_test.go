@@ -0,0 +1,364 @@
+package metastore
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/neonephos-katalis/opg-ewbi-operator/api/ewbi/models"
+	opgv1beta1 "github.com/neonephos-katalis/opg-ewbi-operator/api/operator/v1beta1"
+)
+
+func Test_applyApplicationUpdate(t *testing.T) {
+	ptr := func(s string) *string { return &s }
+	spec := func() opgv1beta1.ApplicationSpec {
+		return opgv1beta1.ApplicationSpec{
+			ComponentSpecs: []opgv1beta1.ComponentSpecRef{
+				{ArtefactId: "artefact-1", ComponentName: "frontend"},
+				{ArtefactId: "artefact-2", ComponentName: "backend"},
+			},
+			MetaData: opgv1beta1.AppMetaData{Name: "app", Version: "1.0"},
+			QoSProfile: opgv1beta1.QoSProfile{
+				LatencyConstraints: "LOW",
+				MultiUserClients:   "APP_TYPE_SINGLE_USER",
+				UsersPerAppInst:    1,
+			},
+		}
+	}
+	latency := models.UpdateApplicationJSONBodyAppUpdQoSProfileLatencyConstraints("ULTRALOW")
+	users := 10
+	mobility := true
+
+	tests := []struct {
+		name    string
+		update  models.UpdateApplicationJSONBody
+		want    func(s *opgv1beta1.ApplicationSpec)
+		wantErr error
+	}{
+		{
+			name: "Update QoS profile",
+			update: models.UpdateApplicationJSONBody{
+				AppUpdQoSProfile: &models.UpdateApplicationJSONBody_AppUpdQoSProfile{
+					LatencyConstraints:  &latency,
+					NoOfUsersPerAppInst: &users,
+					MobilitySupport:     &mobility,
+				},
+			},
+			want: func(s *opgv1beta1.ApplicationSpec) {
+				s.QoSProfile.LatencyConstraints = "ULTRALOW"
+				s.QoSProfile.UsersPerAppInst = 10
+				s.MetaData.MobilitySupport = true
+			},
+		},
+		{
+			name: "Replace component specs",
+			update: models.UpdateApplicationJSONBody{
+				AppComponentSpecs: &[]models.UpdateApplicationJSONBody_AppComponentSpecs_Item{
+					{ComponentName: "frontend", ArtefactId: ptr("artefact-3")},
+					{ComponentName: "backend"},
+				},
+			},
+			want: func(s *opgv1beta1.ApplicationSpec) {
+				s.ComponentSpecs = []opgv1beta1.ComponentSpecRef{
+					{ArtefactId: "artefact-3", ComponentName: "frontend"},
+					{ArtefactId: "artefact-2", ComponentName: "backend"},
+				}
+			},
+		},
+		{
+			name: "New component without artefact",
+			update: models.UpdateApplicationJSONBody{
+				AppComponentSpecs: &[]models.UpdateApplicationJSONBody_AppComponentSpecs_Item{
+					{ComponentName: "cache"},
+				},
+			},
+			wantErr: ErrBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := spec()
+			err := applyApplicationUpdate(&got, &tt.update)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			want := spec()
+			tt.want(&want)
+			require.Equal(t, want, got)
+		})
+	}
+}
+
+func Test_k8sClient_UpdateApplication(t *testing.T) {
+	ptr := func(s string) *string { return &s }
+	artefact := func(id string) *opgv1beta1.Artefact {
+		return &opgv1beta1.Artefact{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      k8sCustomResourceNameFromArtefactID(testFederationContextID, id),
+				Namespace: testNamespace,
+				Labels: map[string]string{
+					opgLabel(federationContextIDLabel): testFederationContextID,
+					opgLabel(idLabel):                  id,
+					opgLabel(federationRelation):       host,
+				},
+			},
+		}
+	}
+	onboard := &OnboardApplication{
+		OnboardApplicationJSONBody: &models.OnboardApplicationJSONBody{
+			AppId: "app-1",
+			AppQoSProfile: models.AppQoSProfile{
+				NoOfUsersPerAppInst: func() *int { i := 1; return &i }(),
+			},
+		},
+		FederationContextId: testFederationContextID,
+	}
+	app, err := onboard.k8sCustomResource(testNamespace)
+	require.NoError(t, err)
+	c := newTestK8sClient(t, newTestHostFederation(testFederationContextID), artefact("artefact-1"), app)
+
+	_, err = c.UpdateApplication(context.Background(), testFederationContextID, "app-1", &models.UpdateApplicationJSONBody{
+		AppComponentSpecs: &[]models.UpdateApplicationJSONBody_AppComponentSpecs_Item{
+			{ComponentName: "frontend", ArtefactId: ptr("artefact-2")},
+		},
+	})
+	require.ErrorIs(t, err, ErrBadRequest)
+
+	updated, err := c.UpdateApplication(context.Background(), testFederationContextID, "app-1", &models.UpdateApplicationJSONBody{
+		AppComponentSpecs: &[]models.UpdateApplicationJSONBody_AppComponentSpecs_Item{
+			{ComponentName: "frontend", ArtefactId: ptr("artefact-1")},
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, updated.AppComponentSpecs, 1)
+	require.Equal(t, "artefact-1", updated.AppComponentSpecs[0].ArtefactId)
+
+	_, err = c.UpdateApplication(context.Background(), testFederationContextID, "app-2", &models.UpdateApplicationJSONBody{})
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func Test_applyApplicationStatusUpdate(t *testing.T) {
+	now := metav1.Now()
+	type statusInfo = struct {
+		OnboardStatusInfo models.AppStatusCallbackLinkJSONBodyStatusInfoOnboardStatusInfo `json:"onboardStatusInfo"`
+		ZoneId            models.ZoneIdentifier                                           `json:"zoneId"`
+	}
+	status := func() opgv1beta1.ApplicationStatus {
+		return opgv1beta1.ApplicationStatus{
+			State: opgv1beta1.ApplicationStatePending,
+			Zones: []opgv1beta1.ApplicationZoneStatus{
+				{ZoneId: "zone-1", State: opgv1beta1.ApplicationStatePending},
+				{ZoneId: "zone-2", State: opgv1beta1.ApplicationStatePending},
+			},
+		}
+	}
+
+	tests := []struct {
+		name        string
+		statusInfo  []statusInfo
+		wantUpdated bool
+		want        opgv1beta1.ApplicationStatus
+	}{
+		{
+			name: "All zones onboarded",
+			statusInfo: []statusInfo{
+				{OnboardStatusInfo: "ONBOARDED", ZoneId: "zone-1"},
+				{OnboardStatusInfo: "ONBOARDED", ZoneId: "zone-2"},
+			},
+			wantUpdated: true,
+			want: opgv1beta1.ApplicationStatus{
+				State: opgv1beta1.ApplicationStateOnboarded,
+				Zones: []opgv1beta1.ApplicationZoneStatus{
+					{ZoneId: "zone-1", State: opgv1beta1.ApplicationStateOnboarded, LastUpdateTime: now},
+					{ZoneId: "zone-2", State: opgv1beta1.ApplicationStateOnboarded, LastUpdateTime: now},
+				},
+			},
+		},
+		{
+			name: "Failed zone",
+			statusInfo: []statusInfo{
+				{OnboardStatusInfo: "ONBOARDED", ZoneId: "zone-1"},
+				{OnboardStatusInfo: "FAILED", ZoneId: "zone-3"},
+			},
+			wantUpdated: true,
+			want: opgv1beta1.ApplicationStatus{
+				State: opgv1beta1.ApplicationStateFailed,
+				Zones: []opgv1beta1.ApplicationZoneStatus{
+					{ZoneId: "zone-1", State: opgv1beta1.ApplicationStateOnboarded, LastUpdateTime: now},
+					{ZoneId: "zone-2", State: opgv1beta1.ApplicationStatePending},
+					{ZoneId: "zone-3", State: opgv1beta1.ApplicationStateFailed, LastUpdateTime: now},
+				},
+			},
+		},
+		{
+			name: "Removed zone",
+			statusInfo: []statusInfo{
+				{OnboardStatusInfo: "REMOVED", ZoneId: "zone-1"},
+			},
+			wantUpdated: true,
+			want: opgv1beta1.ApplicationStatus{
+				State: opgv1beta1.ApplicationStatePending,
+				Zones: []opgv1beta1.ApplicationZoneStatus{
+					{ZoneId: "zone-2", State: opgv1beta1.ApplicationStatePending},
+				},
+			},
+		},
+		{
+			name: "All zones removed",
+			statusInfo: []statusInfo{
+				{OnboardStatusInfo: "REMOVED", ZoneId: "zone-1"},
+				{OnboardStatusInfo: "REMOVED", ZoneId: "zone-2"},
+			},
+			wantUpdated: true,
+			want: opgv1beta1.ApplicationStatus{
+				State: opgv1beta1.ApplicationStateRemoved,
+				Zones: []opgv1beta1.ApplicationZoneStatus{},
+			},
+		},
+		{
+			name: "Invalid state",
+			statusInfo: []statusInfo{
+				{OnboardStatusInfo: "UNKNOWN", ZoneId: "zone-1"},
+			},
+			want: status(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := status()
+			updated := applyApplicationStatusUpdate(&got, &models.AppStatusCallbackLinkJSONRequestBody{StatusInfo: tt.statusInfo}, now)
+			require.Equal(t, tt.wantUpdated, updated)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_k8sClient_ApplicationZones(t *testing.T) {
+	fed := newTestHostFederation(testFederationContextID)
+	fed.Spec.AcceptedAvailabilityZones = []string{"zone-1", "zone-2"}
+	c := newTestK8sClient(t, fed,
+		newTestApplicationInstance(t, "inst-1", "app-1", "provider-1", "zone-1", opgv1beta1.ApplicationInstanceStateReady),
+	)
+	ctx := context.Background()
+
+	_, err := c.OnboardApplication(ctx, &OnboardApplication{
+		OnboardApplicationJSONBody: &models.OnboardApplicationJSONBody{
+			AppId:         "app-1",
+			AppProviderId: "provider-1",
+			AppQoSProfile: models.AppQoSProfile{
+				NoOfUsersPerAppInst: func() *int { i := 1; return &i }(),
+			},
+		},
+		FederationContextId: testFederationContextID,
+	})
+	require.NoError(t, err)
+	app, err := c.GetApplication(ctx, testFederationContextID, "app-1")
+	require.NoError(t, err)
+	require.Equal(t, []string{"zone-1", "zone-2"}, app.AppDeploymentZones)
+
+	app, err = c.AddApplicationZones(ctx, testFederationContextID, "app-1", []string{"zone-2", "zone-3"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"zone-1", "zone-2", "zone-3"}, app.AppDeploymentZones)
+
+	_, err = c.AddApplicationZones(ctx, testFederationContextID, "app-2", []string{"zone-1"})
+	require.ErrorIs(t, err, ErrNotFound)
+
+	require.ErrorIs(t, c.RemoveApplicationZone(ctx, testFederationContextID, "app-1", "zone-4"), ErrNotFound)
+	require.ErrorIs(t, c.RemoveApplicationZone(ctx, testFederationContextID, "app-1", "zone-1"), ErrConflict)
+
+	require.NoError(t, c.RemoveApplicationZone(ctx, testFederationContextID, "app-1", "zone-2"))
+	app, err = c.GetApplication(ctx, testFederationContextID, "app-1")
+	require.NoError(t, err)
+	require.Equal(t, []string{"zone-1", "zone-3"}, app.AppDeploymentZones)
+
+	require.NoError(t, c.kubernetes.Delete(ctx, newTestApplicationInstance(t, "inst-1", "app-1", "provider-1", "zone-1", "")))
+	require.NoError(t, c.RemoveApplicationZone(ctx, testFederationContextID, "app-1", "zone-1"))
+	require.NoError(t, c.RemoveApplicationZone(ctx, testFederationContextID, "app-1", "zone-3"))
+	_, err = c.GetApplication(ctx, testFederationContextID, "app-1")
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func Test_k8sClient_RemoveAvailabilityZone(t *testing.T) {
+	fed := newTestHostFederation(testFederationContextID)
+	fed.Spec.AcceptedAvailabilityZones = []string{"zone-1", "zone-2"}
+	appInst := newTestApplicationInstance(t, "inst-1", "app-1", "provider-1", "zone-1", opgv1beta1.ApplicationInstanceStateReady)
+	c := newTestK8sClient(t, fed, appInst)
+	ctx := context.Background()
+
+	require.ErrorIs(t, c.RemoveAvailabilityZone(ctx, "unknown", "zone-1"), ErrNotFound)
+	require.ErrorIs(t, c.RemoveAvailabilityZone(ctx, testFederationContextID, "zone-3"), ErrNotFound)
+	require.ErrorIs(t, c.RemoveAvailabilityZone(ctx, testFederationContextID, "zone-1"), ErrConflict)
+
+	require.NoError(t, c.RemoveAvailabilityZone(ctx, testFederationContextID, "zone-2"))
+	got, err := c.GetFederation(ctx, testFederationContextID)
+	require.NoError(t, err)
+	require.Equal(t, []string{"zone-1"}, *got.AcceptedAvailabilityZones)
+	require.ErrorIs(t, c.RemoveAvailabilityZone(ctx, testFederationContextID, "zone-2"), ErrNotFound)
+
+	require.NoError(t, c.kubernetes.Delete(ctx, appInst))
+	require.NoError(t, c.RemoveAvailabilityZone(ctx, testFederationContextID, "zone-1"))
+	got, err = c.GetFederation(ctx, testFederationContextID)
+	require.NoError(t, err)
+	require.Empty(t, got.AcceptedAvailabilityZones)
+}
+
+func Test_applyApplicationZoneForbids(t *testing.T) {
+	forbid, allow := true, false
+	spec := func() opgv1beta1.ApplicationSpec {
+		return opgv1beta1.ApplicationSpec{
+			Zones:          []string{"zone-1", "zone-2", "zone-3"},
+			ForbiddenZones: []string{"zone-1"},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		forbids []ApplicationZoneForbid
+		want    []string
+		wantErr error
+	}{
+		{
+			name: "Forbid and allow zones",
+			forbids: []ApplicationZoneForbid{
+				{ZoneId: "zone-1", Forbid: &allow},
+				{ZoneId: "zone-2", Forbid: &forbid},
+				{ZoneId: "zone-3", Forbid: &forbid},
+			},
+			want: []string{"zone-2", "zone-3"},
+		},
+		{
+			name:    "Forbid a forbidden zone",
+			forbids: []ApplicationZoneForbid{{ZoneId: "zone-1", Forbid: &forbid}},
+			want:    []string{"zone-1"},
+		},
+		{
+			name:    "Zone not onboarded",
+			forbids: []ApplicationZoneForbid{{ZoneId: "zone-4", Forbid: &forbid}},
+			wantErr: ErrNotFound,
+		},
+		{
+			name:    "Missing forbid",
+			forbids: []ApplicationZoneForbid{{ZoneId: "zone-2"}},
+			wantErr: ErrBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := spec()
+			err := applyApplicationZoneForbids(&got, tt.forbids)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got.ForbiddenZones)
+		})
+	}
+}
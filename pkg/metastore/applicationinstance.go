@@ -14,6 +14,13 @@ type ApplicationInstanceDetails struct {
 	*camara.GetAppInstanceDetails200JSONResponse
 }
 
+// ApplicationInstanceInfo is the zone and state of an application instance.
+type ApplicationInstanceInfo struct {
+	AppInstanceId models.InstanceIdentifier
+	ZoneId        models.ZoneIdentifier
+	State         models.InstanceState
+}
+
 type ApplicationInstance struct {
 	*models.InstallAppJSONBody
 	FederationContextId models.FederationContextId `json:"-"`
@@ -28,6 +35,8 @@ func (d *ApplicationInstance) k8sCustomResource(namespace string, opts ...Opt) (
 				opgLabel(federationContextIDLabel): d.FederationContextId,
 				opgLabel(idLabel):                  d.AppInstanceId,
 				opgLabel(federationRelation):       host,
+				opgLabel(appIDLabel):               d.AppId,
+				opgLabel(appProviderIDLabel):       d.AppProviderId,
 			},
 		},
 		Spec: opgv1beta1.ApplicationInstanceSpec{
@@ -43,6 +52,9 @@ func (d *ApplicationInstance) k8sCustomResource(namespace string, opts ...Opt) (
 			CallbBackLink: d.AppInstCallbackLink,
 		},
 	}
+	if obj.Spec.ZoneInfo.ResPool != "" {
+		obj.Labels[opgLabel(resPoolIDLabel)] = obj.Spec.ZoneInfo.ResPool
+	}
 	for _, opt := range opts {
 		if err := opt(&obj.ObjectMeta); err != nil {
 			return nil, err
@@ -64,6 +76,72 @@ func k8sCustomResourceNameFromApplicationInstance(federationContextID, appID str
 	return fmt.Sprintf("%s-%s", applicationInstancePrefix, uuidV5Fn(federationContextID+"/"+appID))
 }
 
-func applicationInstanceFromK8sCustomResource(appInstanceID string, appInstance opgv1beta1.ApplicationInstance) (*ApplicationInstanceDetails, error) {
-	return &ApplicationInstanceDetails{}, nil
+// applicationInstanceInfoFromK8sCustomResource returns the zone and state of the
+// instance, instances not processed yet are reported as PENDING.
+func applicationInstanceInfoFromK8sCustomResource(appInstance opgv1beta1.ApplicationInstance) *ApplicationInstanceInfo {
+	state := models.InstanceState(appInstance.Status.State)
+	if state == "" {
+		state = models.InstanceStatePENDING
+	}
+	return &ApplicationInstanceInfo{
+		AppInstanceId: appInstance.Labels[opgLabel(idLabel)],
+		ZoneId:        appInstance.Spec.ZoneInfo.ZoneId,
+		State:         state,
+	}
+}
+
+func applicationInstanceFromK8sCustomResource(appInstance opgv1beta1.ApplicationInstance) (*ApplicationInstance, error) {
+	body := &models.InstallAppJSONBody{
+		AppId:               appInstance.Spec.AppId,
+		AppInstCallbackLink: appInstance.Spec.CallbBackLink,
+		AppInstanceId:       appInstance.Labels[opgLabel(idLabel)],
+		AppProviderId:       appInstance.Spec.AppProviderId,
+		AppVersion:          appInstance.Spec.AppVersion,
+	}
+	body.ZoneInfo.ZoneId = appInstance.Spec.ZoneInfo.ZoneId
+	body.ZoneInfo.FlavourId = appInstance.Spec.ZoneInfo.FlavourId
+	if appInstance.Spec.ZoneInfo.ResPool != "" {
+		body.ZoneInfo.ResPool = &appInstance.Spec.ZoneInfo.ResPool
+	}
+	if appInstance.Spec.ZoneInfo.ResourceConsumption != "" {
+		body.ZoneInfo.ResourceConsumption = (*models.InstallAppJSONBodyZoneInfoResourceConsumption)(&appInstance.Spec.ZoneInfo.ResourceConsumption)
+	}
+	return &ApplicationInstance{
+		InstallAppJSONBody:  body,
+		FederationContextId: appInstance.Labels[opgLabel(federationContextIDLabel)],
+	}, nil
+}
+
+func applicationInstanceDetailsFromK8sCustomResource(appInstance opgv1beta1.ApplicationInstance) (*ApplicationInstanceDetails, error) {
+	details := &camara.GetAppInstanceDetails200JSONResponse{
+		AppInstanceState: &applicationInstanceInfoFromK8sCustomResource(appInstance).State,
+	}
+	if len(appInstance.Status.AccessPointInfo) > 0 {
+		accessPointInfo := make(models.AccessPointInfo, len(appInstance.Status.AccessPointInfo))
+		for i, api := range appInstance.Status.AccessPointInfo {
+			accessPointInfo[i].InterfaceId = api.InterfaceId
+			accessPointInfo[i].AccessPoints = serviceEndpointToModel(api.AccessPoints)
+		}
+		details.AccessPointInfo = &accessPointInfo
+	}
+	return &ApplicationInstanceDetails{GetAppInstanceDetails200JSONResponse: details}, nil
+}
+
+// serviceEndpointToModel returns the access points of the instance in the API format.
+func serviceEndpointToModel(ap opgv1beta1.AccessPoints) models.ServiceEndpoint {
+	endpoint := models.ServiceEndpoint{Port: ap.Port}
+	if ap.Fqdn != "" {
+		endpoint.Fqdn = &ap.Fqdn
+	}
+	if len(ap.Ipv4Addresses) > 0 {
+		endpoint.Ipv4Addresses = &ap.Ipv4Addresses
+	}
+	if len(ap.Ipv6Addresses) > 0 {
+		ipv6 := make([]models.Ipv6Addr, len(ap.Ipv6Addresses))
+		for i, a := range ap.Ipv6Addresses {
+			ipv6[i] = a
+		}
+		endpoint.Ipv6Addresses = &ipv6
+	}
+	return endpoint
 }
@@ -0,0 +1,288 @@
+package metastore
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	k8scli "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+
+	opgv1beta1 "github.com/neonephos-katalis/opg-ewbi-operator/api/operator/v1beta1"
+)
+
+// readYourWritesTimeout is the longest time the objects written by the client are
+// read from the API server while the cache hasn't observed the write
+const readYourWritesTimeout = 30 * time.Second
+
+// cachedKinds, the resources the metastore reads from the cache
+var cachedKinds = []k8scli.Object{
+	&opgv1beta1.Application{},
+	&opgv1beta1.ApplicationInstance{},
+	&opgv1beta1.Artefact{},
+	&opgv1beta1.AvailabilityZone{},
+	&opgv1beta1.Federation{},
+	&opgv1beta1.File{},
+	&opgv1beta1.ResourcePool{},
+}
+
+// indexedLabels, the labels the metastore searches by, indexed in the cache
+var indexedLabels = []labelKey{federationContextIDLabel, idLabel, federationRelation}
+
+type K8sClientOpt func(*k8sClient)
+
+// WithCache makes the client read its resources from the informers cache c, see NewCache.
+// The objects written by the client are read from the API server until c observes them.
+func WithCache(c k8scli.Reader) K8sClientOpt {
+	return func(k *k8sClient) {
+		k.kubernetes = &cachedClient{Client: k.kubernetes, cache: c, writes: map[writeKey]pendingWrite{}}
+	}
+}
+
+// NewCache returns an informers cache of the resources of the metastore bounded to
+// namespace, with an index on each of the labels the metastore searches by.
+// It must be started before the client reads from it.
+func NewCache(ctx context.Context, config *rest.Config, scheme *runtime.Scheme, namespace string) (cache.Cache, error) {
+	c, err := cache.New(config, cache.Options{
+		Scheme:            scheme,
+		DefaultNamespaces: map[string]cache.Config{namespace: {}},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create cache")
+	}
+	if err := IndexLabels(ctx, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// IndexLabels registers on indexer the indexes of the labels the metastore searches by
+func IndexLabels(ctx context.Context, indexer k8scli.FieldIndexer) error {
+	for _, obj := range cachedKinds {
+		for _, l := range indexedLabels {
+			label := opgLabel(l)
+			if err := indexer.IndexField(ctx, obj, labelIndexField(label), func(o k8scli.Object) []string {
+				if v, ok := o.GetLabels()[label]; ok {
+					return []string{v}
+				}
+				return nil
+			}); err != nil {
+				return errors.Wrapf(err, "unable to index label %s of %T", label, obj)
+			}
+		}
+	}
+	return nil
+}
+
+func labelIndexField(label string) string {
+	return "metadata.labels." + label
+}
+
+// cachedClient reads the cached kinds from the cache, using the label indexes for
+// the searches. The objects it writes are read from the API server until the cache
+// observes their new resource version, so that the callers read their own writes.
+type cachedClient struct {
+	k8scli.Client
+	cache k8scli.Reader
+
+	mutex  sync.Mutex
+	writes map[writeKey]pendingWrite
+}
+
+type writeKey struct {
+	gvk schema.GroupVersionKind
+	key types.NamespacedName
+}
+
+// pendingWrite, a write the cache has not observed yet
+type pendingWrite struct {
+	resourceVersion string
+	deleted         bool
+	expires         time.Time
+}
+
+func (c *cachedClient) Get(ctx context.Context, key k8scli.ObjectKey, obj k8scli.Object, opts ...k8scli.GetOption) error {
+	gvk, ok := c.cachedGVK(obj)
+	if !ok {
+		return c.Client.Get(ctx, key, obj, opts...)
+	}
+	err := c.cache.Get(ctx, key, obj, opts...)
+	if c.observed(writeKey{gvk, key}, obj, err) {
+		return err
+	}
+	return c.Client.Get(ctx, key, obj, opts...)
+}
+
+func (c *cachedClient) List(ctx context.Context, list k8scli.ObjectList, opts ...k8scli.ListOption) error {
+	gvk, ok := c.cachedGVK(list)
+	if !ok {
+		return c.Client.List(ctx, list, opts...)
+	}
+	gvk.Kind = strings.TrimSuffix(gvk.Kind, "List")
+	if c.pending(ctx, gvk) {
+		return c.Client.List(ctx, list, opts...)
+	}
+	return c.cache.List(ctx, list, indexedListOptions(opts))
+}
+
+func (c *cachedClient) Create(ctx context.Context, obj k8scli.Object, opts ...k8scli.CreateOption) error {
+	if err := c.Client.Create(ctx, obj, opts...); err != nil {
+		return err
+	}
+	c.record(obj, false)
+	return nil
+}
+
+func (c *cachedClient) Update(ctx context.Context, obj k8scli.Object, opts ...k8scli.UpdateOption) error {
+	if err := c.Client.Update(ctx, obj, opts...); err != nil {
+		return err
+	}
+	c.record(obj, false)
+	return nil
+}
+
+func (c *cachedClient) Patch(ctx context.Context, obj k8scli.Object, patch k8scli.Patch, opts ...k8scli.PatchOption) error {
+	if err := c.Client.Patch(ctx, obj, patch, opts...); err != nil {
+		return err
+	}
+	c.record(obj, false)
+	return nil
+}
+
+func (c *cachedClient) Delete(ctx context.Context, obj k8scli.Object, opts ...k8scli.DeleteOption) error {
+	if err := c.Client.Delete(ctx, obj, opts...); err != nil {
+		return err
+	}
+	// objects with finalizers are only marked for deletion
+	c.record(obj, true)
+	return nil
+}
+
+func (c *cachedClient) Status() k8scli.SubResourceWriter {
+	return &cachedStatusWriter{SubResourceWriter: c.Client.Status(), client: c}
+}
+
+// cachedStatusWriter records the status writes of the cachedClient
+type cachedStatusWriter struct {
+	k8scli.SubResourceWriter
+	client *cachedClient
+}
+
+func (w *cachedStatusWriter) Update(ctx context.Context, obj k8scli.Object, opts ...k8scli.SubResourceUpdateOption) error {
+	if err := w.SubResourceWriter.Update(ctx, obj, opts...); err != nil {
+		return err
+	}
+	w.client.record(obj, false)
+	return nil
+}
+
+func (w *cachedStatusWriter) Patch(
+	ctx context.Context, obj k8scli.Object, patch k8scli.Patch, opts ...k8scli.SubResourcePatchOption,
+) error {
+	if err := w.SubResourceWriter.Patch(ctx, obj, patch, opts...); err != nil {
+		return err
+	}
+	w.client.record(obj, false)
+	return nil
+}
+
+// cachedGVK returns the kind of obj when it is read from the cache
+func (c *cachedClient) cachedGVK(obj runtime.Object) (schema.GroupVersionKind, bool) {
+	gvk, err := apiutil.GVKForObject(obj, c.Scheme())
+	if err != nil || gvk.Group != opgv1beta1.GroupVersion.Group {
+		return gvk, false
+	}
+	return gvk, true
+}
+
+// record keeps the write of obj until the cache observes it
+func (c *cachedClient) record(obj k8scli.Object, deleted bool) {
+	gvk, ok := c.cachedGVK(obj)
+	if !ok {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.writes[writeKey{gvk, k8scli.ObjectKeyFromObject(obj)}] = pendingWrite{
+		resourceVersion: obj.GetResourceVersion(),
+		deleted:         deleted,
+		expires:         time.Now().Add(readYourWritesTimeout),
+	}
+}
+
+// observed returns whether the cache read of obj, with error err, reflects the
+// last write of the client, forgetting the write once it does
+func (c *cachedClient) observed(k writeKey, obj k8scli.Object, err error) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	w, ok := c.writes[k]
+	switch {
+	case !ok:
+		return true
+	case time.Now().After(w.expires),
+		w.deleted && (k8serrors.IsNotFound(err) || err == nil && !obj.GetDeletionTimestamp().IsZero()),
+		!w.deleted && err == nil && obj.GetResourceVersion() == w.resourceVersion:
+		delete(c.writes, k)
+		return true
+	}
+	return false
+}
+
+// pending returns whether a write of the kind gvk is not observed by the cache yet
+func (c *cachedClient) pending(ctx context.Context, gvk schema.GroupVersionKind) bool {
+	c.mutex.Lock()
+	var keys []writeKey
+	for k := range c.writes {
+		if k.gvk == gvk {
+			keys = append(keys, k)
+		}
+	}
+	c.mutex.Unlock()
+
+	for _, k := range keys {
+		ro, err := c.Scheme().New(gvk)
+		if err != nil {
+			return true
+		}
+		obj := ro.(k8scli.Object)
+		if !c.observed(k, obj, c.cache.Get(ctx, k.key, obj)) {
+			return true
+		}
+	}
+	return false
+}
+
+// indexedListOptions replaces the equality requirements on the indexed labels of
+// the label selector with the field selector of their index
+func indexedListOptions(opts []k8scli.ListOption) *k8scli.ListOptions {
+	listOpts := (&k8scli.ListOptions{}).ApplyOptions(opts)
+	if listOpts.LabelSelector == nil || listOpts.FieldSelector != nil {
+		return listOpts
+	}
+	requirements, _ := listOpts.LabelSelector.Requirements()
+	indexed := fields.Set{}
+	for _, r := range requirements {
+		if r.Operator() != selection.Equals && r.Operator() != selection.DoubleEquals {
+			continue
+		}
+		for _, l := range indexedLabels {
+			if r.Key() == opgLabel(l) {
+				indexed[labelIndexField(r.Key())] = r.Values().List()[0]
+			}
+		}
+	}
+	if len(indexed) > 0 {
+		listOpts.FieldSelector = fields.SelectorFromSet(indexed)
+	}
+	return listOpts
+}
@@ -0,0 +1,111 @@
+package metastore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	k8scli "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	opgv1beta1 "github.com/neonephos-katalis/opg-ewbi-operator/api/operator/v1beta1"
+)
+
+// builderIndexer registers the indexes on a fake client builder
+type builderIndexer struct {
+	builder *fake.ClientBuilder
+}
+
+func (i builderIndexer) IndexField(_ context.Context, obj k8scli.Object, field string, fn k8scli.IndexerFunc) error {
+	i.builder.WithIndex(obj, field, fn)
+	return nil
+}
+
+// newTestCache returns a fake client with the indexes of the metastore cache,
+// holding objs as the cache observed them
+func newTestCache(t *testing.T, scheme *runtime.Scheme, objs ...k8scli.Object) k8scli.Reader {
+	builder := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...)
+	require.NoError(t, IndexLabels(context.Background(), builderIndexer{builder}))
+	return builder.Build()
+}
+
+func newTestCachedClient(t *testing.T) (*k8sClient, *cachedClient, *runtime.Scheme) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, opgv1beta1.AddToScheme(scheme))
+	live := fake.NewClientBuilder().WithScheme(scheme).Build()
+	c := NewK8sClient(live, testNamespace, WithCache(newTestCache(t, scheme)))
+	return c, c.kubernetes.(*cachedClient), scheme
+}
+
+func Test_cachedClient_ReadYourWrites(t *testing.T) {
+	ctx := context.Background()
+	c, cached, scheme := newTestCachedClient(t)
+
+	// the cache didn't observe the federation yet, it is read from the API server
+	feder := newTestHostFederation(testFederationContextID)
+	require.NoError(t, c.createK8sObject(ctx, feder))
+	_, err := c.GetFederation(ctx, testFederationContextID)
+	require.NoError(t, err)
+
+	// the cache observed the federation, the write is forgotten
+	cached.cache = newTestCache(t, scheme, feder.DeepCopy())
+	_, err = c.GetFederation(ctx, testFederationContextID)
+	require.NoError(t, err)
+	assert.Empty(t, cached.writes)
+
+	// the cache didn't observe the deletion yet, it is read from the API server
+	require.NoError(t, c.RemoveFederation(ctx, testFederationContextID))
+	_, err = c.GetFederation(ctx, testFederationContextID)
+	require.True(t, IsNotFoundError(err), err)
+
+	// the write expired, the stale cache is read
+	for k, w := range cached.writes {
+		w.expires = time.Now().Add(-time.Second)
+		cached.writes[k] = w
+	}
+	_, err = c.GetFederation(ctx, testFederationContextID)
+	require.NoError(t, err)
+	assert.Empty(t, cached.writes)
+}
+
+func Test_cachedClient_ListUsesIndexes(t *testing.T) {
+	ctx := context.Background()
+	c, cached, scheme := newTestCachedClient(t)
+	cached.cache = newTestCache(t, scheme,
+		newTestHostFederation(testFederationContextID),
+		newTestHostFederation("fed-ctx-2"),
+	)
+
+	list := &opgv1beta1.FederationList{}
+	_, err := c.searchKubernetesObjects(ctx, list, labels.Set{
+		opgLabel(federationContextIDLabel): "fed-ctx-2",
+		opgLabel(federationRelation):       host,
+	})
+	require.NoError(t, err)
+	require.Len(t, list.Items, 1)
+	assert.Equal(t, "federation-fed-ctx-2", list.Items[0].Name)
+}
+
+func Test_indexedListOptions(t *testing.T) {
+	opts := indexedListOptions([]k8scli.ListOption{
+		k8scli.InNamespace(testNamespace),
+		k8scli.MatchingLabels{
+			opgLabel(idLabel):    "id-1",
+			opgLabel(appIDLabel): "app-1",
+		},
+	})
+	assert.Equal(t, testNamespace, opts.Namespace)
+	assert.Equal(t,
+		fields.SelectorFromSet(fields.Set{labelIndexField(opgLabel(idLabel)): "id-1"}).String(),
+		opts.FieldSelector.String(),
+	)
+	// the label selector still filters on the labels not indexed
+	assert.Contains(t, opts.LabelSelector.String(), opgLabel(appIDLabel))
+}
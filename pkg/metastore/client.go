@@ -15,7 +15,10 @@ var uuidV5Fn = uuid.V5
 type Client interface {
 	GetFederation(ctx context.Context, federationContextID string) (*Federation, error)
 	CreateFederation(ctx context.Context, fed *Federation) (*Federation, error)
+	UpdateFederation(ctx context.Context, federationContextID string, update *models.UpdateFederationJSONBody) (*Federation, error)
 	UpdateFederationStatus(ctx context.Context, federationCallbackID string, status models.Status) error
+	UpdateFederationPartnerStatus(ctx context.Context, federationCallbackID string, update *models.PartnerStatusLinkJSONRequestBody) error
+	UpdateFederationZoneAvailability(ctx context.Context, federationCallbackID string, update *models.AvailZoneNotifLinkJSONRequestBody) error
 	RemoveFederation(ctx context.Context, federationContextID string) error
 
 	GetFile(ctx context.Context, federationContextID, id string) (*File, error)
@@ -31,11 +34,23 @@ type Client interface {
 
 	GetApplication(ctx context.Context, federationContextID, id string) (*Application, error)
 	OnboardApplication(ctx context.Context, app *OnboardApplication) (*opgv1beta1.Application, error)
+	UpdateApplication(ctx context.Context, federationContextID, id string, update *models.UpdateApplicationJSONBody) (*Application, error)
 	UpdateApplicationStatus(ctx context.Context, federationCallbackID string, updates *models.AppStatusCallbackLinkJSONRequestBody) error
+	AddApplicationZones(ctx context.Context, federationContextID, id string, zones []string) (*Application, error)
+	RemoveApplicationZone(ctx context.Context, federationContextID, id, zoneID string) error
+	SetApplicationZoneForbids(ctx context.Context, federationContextID, id string, forbids []ApplicationZoneForbid) (*Application, error)
 	RemoveApplication(ctx context.Context, federationContextID, id string) error
 
+	CreateResourcePool(ctx context.Context, pool *ResourcePool) (*opgv1beta1.ResourcePool, error)
+	ListResourcePools(ctx context.Context, federationContextID, zoneID, appProviderID string) ([]ResourcePoolDetails, error)
+	UpdateResourcePool(ctx context.Context, federationContextID, zoneID, appProviderID, id string, update models.UpdateISVResPoolJSONBody) error
+	RemoveResourcePool(ctx context.Context, federationContextID, zoneID, appProviderID, id string) error
+	GetResourcePoolUsage(ctx context.Context, federationContextID, id string) (*ResourcePoolUsage, error)
+	UpdateResourcePoolStatus(ctx context.Context, federationCallbackID string, updates *models.ResourceReservationCallbackLinkJSONRequestBody) error
+
 	AddApplicationInstance(ctx context.Context, dep *ApplicationInstance) (*opgv1beta1.ApplicationInstance, error)
 	GetApplicationInstance(ctx context.Context, federationContextID, id string) (*ApplicationInstance, error)
+	ListApplicationInstances(ctx context.Context, federationContextID, appID, appProviderID string) ([]*ApplicationInstanceInfo, error)
 	UpdateApplicationInstanceStatus(ctx context.Context, federationCallbackID string, updates *models.AppInstCallbackLinkJSONRequestBody) error
 	RemoveApplicationInstance(ctx context.Context, federationContextID, id string) error
 
@@ -46,6 +61,7 @@ type Client interface {
 	GetAvailabilityZone(ctx context.Context, federationContextID, id string) (*PartnerAvailabilityZone, error)
 	ListAvailabilityZones(ctx context.Context) ([]*PartnerAvailabilityZone, error)
 	RemoveAvailabilityZone(ctx context.Context, federationContextID, id string) error
+	GetCandidateZones(ctx context.Context, federationContextID string, req *models.GetCandidateZonesJSONBody) (models.DiscoveredEdgeNodes, error)
 
 	GetClientCredentials(ctx context.Context, ClientID string) (ClientCredentials, error)
 }
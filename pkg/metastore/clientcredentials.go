@@ -15,7 +15,7 @@ type ClientCredentials struct {
 }
 
 func (c *k8sClient) GetClientCredentials(ctx context.Context, ClientID string) (ClientCredentials, error) {
-	obj, err := c.searchKubernetesObject(&opgv1beta1.FederationList{}, labels.Set{
+	obj, err := c.searchKubernetesObject(ctx, &opgv1beta1.FederationList{}, labels.Set{
 		opgLabel(clientIDLabel): ClientID,
 	})
 	if err != nil {
@@ -0,0 +1,584 @@
+package metastore
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	_ "modernc.org/sqlite"
+	k8scli "sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/neonephos-katalis/opg-ewbi-operator/api/ewbi/models"
+	opgv1beta1 "github.com/neonephos-katalis/opg-ewbi-operator/api/operator/v1beta1"
+)
+
+// testPostgresDSNEnv, the database the postgres backend is tested against, the
+// backend is skipped when it is not set
+const testPostgresDSNEnv = "METASTORE_TEST_POSTGRES_DSN"
+
+const testClientID = "client-1"
+
+// testBackends builds a client of each metastore backend seeded with objs
+var testBackends = map[string]func(t *testing.T, objs ...k8scli.Object) *k8sClient{
+	"kubernetes": newTestK8sClient,
+	"memory": func(t *testing.T, objs ...k8scli.Object) *k8sClient {
+		c, err := NewMemoryClient(testNamespace, objs...)
+		require.NoError(t, err)
+		return c
+	},
+	"sqlite":   newTestSQLiteClient,
+	"postgres": newTestPostgresClient,
+}
+
+func newTestSQLiteClient(t *testing.T, objs ...k8scli.Object) *k8sClient {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "metastore.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	c, err := NewSQLiteClient(context.Background(), db, testNamespace, objs...)
+	require.NoError(t, err)
+	return c
+}
+
+func newTestPostgresClient(t *testing.T, objs ...k8scli.Object) *k8sClient {
+	dsn := os.Getenv(testPostgresDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s not set", testPostgresDSNEnv)
+	}
+	db, err := sql.Open("postgres", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	// the objects left by the previous runs are removed before seeding objs
+	clear := func() {
+		_, err := db.Exec(`DELETE FROM metastore_objects WHERE namespace = $1`, testNamespace)
+		require.NoError(t, err)
+	}
+	_, err = db.Exec(postgresDialect.schema)
+	require.NoError(t, err)
+	clear()
+	t.Cleanup(clear)
+	c, err := NewPostgresClient(context.Background(), db, testNamespace, objs...)
+	require.NoError(t, err)
+	return c
+}
+
+// newTestFederationAuth returns the host federation of testClientID, as created
+// before the partner requests the federation
+func newTestFederationAuth() *opgv1beta1.Federation {
+	return &opgv1beta1.Federation{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "federation-auth",
+			Namespace: testNamespace,
+			Labels: map[string]string{
+				opgLabel(clientIDLabel):      testClientID,
+				opgLabel(federationRelation): host,
+			},
+		},
+		Spec: opgv1beta1.FederationSpec{
+			GuestPartnerCredentials: opgv1beta1.FederationCredentials{ClientId: testClientID},
+			OfferedAvailabilityZones: []opgv1beta1.ZoneDetails{
+				{ZoneId: "zone-1", Geolocation: "45.4642,9.1900"},
+			},
+		},
+	}
+}
+
+// newTestGuestObjectMeta returns the metadata of the guest resource id of the
+// federation with federationCallbackID
+func newTestGuestObjectMeta(kind, id, federationCallbackID string) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Name:      kind + "-" + id,
+		Namespace: testNamespace,
+		Labels: map[string]string{
+			opgLabel(federationCallbackIDLabel): federationCallbackID,
+			opgLabel(idLabel):                   id,
+			opgLabel(federationRelation):        guest,
+		},
+	}
+}
+
+func newTestGuestApplicationInstance(id, federationCallbackID string) *opgv1beta1.ApplicationInstance {
+	return &opgv1beta1.ApplicationInstance{
+		ObjectMeta: newTestGuestObjectMeta("appinst", id, federationCallbackID),
+		Spec:       opgv1beta1.ApplicationInstanceSpec{AppId: "app-1"},
+	}
+}
+
+func TestClient_Contract(t *testing.T) {
+	for name, newClient := range testBackends {
+		t.Run(name, func(t *testing.T) {
+			t.Run("Federation", func(t *testing.T) { testContractFederation(t, newClient) })
+			t.Run("Files", func(t *testing.T) { testContractFiles(t, newClient) })
+			t.Run("Artefacts", func(t *testing.T) { testContractArtefacts(t, newClient) })
+			t.Run("Availability zones", func(t *testing.T) { testContractAvailabilityZones(t, newClient) })
+			t.Run("Applications", func(t *testing.T) { testContractApplications(t, newClient) })
+			t.Run("Application zones", func(t *testing.T) { testContractApplicationZones(t, newClient) })
+			t.Run("Resource pools", func(t *testing.T) { testContractResourcePools(t, newClient) })
+			t.Run("Status callbacks", func(t *testing.T) { testContractStatusCallbacks(t, newClient) })
+		})
+	}
+}
+
+func testContractFederation(t *testing.T, newClient func(*testing.T, ...k8scli.Object) *k8sClient) {
+	ctx := context.Background()
+	c := newClient(t, newTestFederationAuth())
+
+	creds, err := c.GetClientCredentials(ctx, testClientID)
+	require.NoError(t, err)
+	assert.Equal(t, testClientID, creds.ClientID)
+	_, err = c.GetClientCredentials(ctx, "unknown")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	mcc, mncs := "001", []string{"01"}
+	input := &Federation{
+		FederationRequestData: &models.FederationRequestData{
+			InitialDate:              time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+			OrigOPFixedNetworkCodes:  &[]string{"fixed-1"},
+			OrigOPMobileNetworkCodes: &models.MobileNetworkIds{Mcc: &mcc, Mncs: &mncs},
+			PartnerCallbackCredentials: &models.CallbackCredentials{
+				ClientId: "callback-client", TokenUrl: "http://partner/token",
+			},
+			PartnerStatusLink: "http://partner/status",
+		},
+		ClientCredentials:   ClientCredentials{ClientID: testClientID},
+		FederationContextId: testFederationContextID,
+	}
+	_, err = c.CreateFederation(ctx, input)
+	require.NoError(t, err)
+	_, err = c.CreateFederation(ctx, input)
+	require.ErrorIs(t, err, ErrAlreadyExists)
+
+	fed, err := c.GetFederation(ctx, testFederationContextID)
+	require.NoError(t, err)
+	assert.Equal(t, testFederationContextID, fed.FederationContextId)
+	assert.Equal(t, []string{"fixed-1"}, *fed.OrigOPFixedNetworkCodes)
+	require.Len(t, *fed.OfferedAvailabilityZones, 1)
+	_, err = c.GetFederation(ctx, "unknown")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	fed, err = c.UpdateFederation(ctx, testFederationContextID, &models.UpdateFederationJSONBody{
+		ObjectType:         models.UpdateFederationJSONBodyObjectTypeFIXEDNETWORKCODES,
+		OperationType:      models.ADDCODES,
+		AddFixedNetworkIds: &[]string{"fixed-2"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"fixed-1", "fixed-2"}, *fed.OrigOPFixedNetworkCodes)
+
+	require.NoError(t, c.AddAvailabilityZones(ctx, testFederationContextID, []string{"zone-1"}))
+	fed, err = c.GetFederation(ctx, testFederationContextID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"zone-1"}, *fed.AcceptedAvailabilityZones)
+
+	require.NoError(t, c.RemoveFederation(ctx, testFederationContextID))
+	_, err = c.GetFederation(ctx, testFederationContextID)
+	require.ErrorIs(t, err, ErrNotFound)
+	require.ErrorIs(t, c.RemoveFederation(ctx, testFederationContextID), ErrNotFound)
+}
+
+func testContractFiles(t *testing.T, newClient func(*testing.T, ...k8scli.Object) *k8sClient) {
+	ctx := context.Background()
+	c := newClient(t, newTestHostFederation(testFederationContextID))
+
+	token, repoURL := "token", "http://repo/file"
+	upload := func(federationContextID string) (*opgv1beta1.File, error) {
+		return c.UploadFile(ctx, &UploadFile{
+			UploadFileMultipartBody: &models.UploadFileMultipartBody{
+				FileId:           "file-1",
+				FileName:         "file.qcow2",
+				FileRepoLocation: &models.ObjectRepoLocation{RepoURL: &repoURL, Token: &token},
+			},
+			FederationContextId: federationContextID,
+		})
+	}
+	_, err := upload("unknown")
+	require.ErrorIs(t, err, ErrNotFound)
+	obj, err := upload(testFederationContextID)
+	require.NoError(t, err)
+	_, err = upload(testFederationContextID)
+	require.ErrorIs(t, err, ErrAlreadyExists)
+
+	file, err := c.GetFile(ctx, testFederationContextID, "file-1")
+	require.NoError(t, err)
+	assert.Equal(t, "file.qcow2", file.FileName)
+	secret := &corev1.Secret{}
+	require.NoError(t, c.kubernetes.Get(ctx, types.NamespacedName{Namespace: testNamespace, Name: credentialsSecretName(obj.Name)}, secret))
+	assert.Equal(t, obj.Name, secret.OwnerReferences[0].Name)
+
+	require.NoError(t, c.RemoveFile(ctx, testFederationContextID, "file-1"))
+	_, err = c.GetFile(ctx, testFederationContextID, "file-1")
+	require.ErrorIs(t, err, ErrNotFound)
+	require.True(t, apierrors.IsNotFound(c.RemoveFile(ctx, testFederationContextID, "file-1")))
+}
+
+func testContractArtefacts(t *testing.T, newClient func(*testing.T, ...k8scli.Object) *k8sClient) {
+	ctx := context.Background()
+	c := newClient(t, newTestHostFederation(testFederationContextID))
+
+	upload := func(id string) error {
+		_, err := c.UploadArtefact(ctx, &UploadArtefact{
+			UploadArtefactMultipartBody: &models.UploadArtefactMultipartBody{
+				ArtefactId:    id,
+				ArtefactName:  "artefact",
+				AppProviderId: "provider-1",
+				ComponentSpec: []models.ComponentSpec{{
+					ComponentName:     "component",
+					CommandLineParams: &models.CommandLineParams{},
+					Images:            []models.FileId{"file-1"},
+				}},
+			},
+			FederationContextId: testFederationContextID,
+		})
+		return err
+	}
+	require.ErrorIs(t, upload("artefact-1"), ErrBadRequest)
+	_, err := c.UploadFile(ctx, &UploadFile{
+		UploadFileMultipartBody: &models.UploadFileMultipartBody{
+			FileId:           "file-1",
+			FileRepoLocation: &models.ObjectRepoLocation{},
+		},
+		FederationContextId: testFederationContextID,
+	})
+	require.NoError(t, err)
+	require.NoError(t, upload("artefact-1"))
+	require.ErrorIs(t, upload("artefact-1"), ErrAlreadyExists)
+
+	artefact, err := c.GetArtefact(ctx, testFederationContextID, "artefact-1")
+	require.NoError(t, err)
+	assert.Equal(t, "artefact", artefact.ArtefactName)
+	_, err = c.GetArtefact(ctx, testFederationContextID, "artefact-2")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	onboard := func(artefactID string) error {
+		users := 1
+		_, err := c.OnboardApplication(ctx, &OnboardApplication{
+			OnboardApplicationJSONBody: &models.OnboardApplicationJSONBody{
+				AppId:             "app-1",
+				AppProviderId:     "provider-1",
+				AppComponentSpecs: models.AppComponentSpecs{{ArtefactId: artefactID}},
+				AppQoSProfile:     models.AppQoSProfile{NoOfUsersPerAppInst: &users},
+			},
+			FederationContextId: testFederationContextID,
+		})
+		return err
+	}
+	require.ErrorIs(t, onboard("artefact-2"), ErrBadRequest)
+	require.NoError(t, onboard("artefact-1"))
+
+	updateArtefact := func(artefactID string) (*Application, error) {
+		return c.UpdateApplication(ctx, testFederationContextID, "app-1", &models.UpdateApplicationJSONBody{
+			AppComponentSpecs: &[]models.UpdateApplicationJSONBody_AppComponentSpecs_Item{
+				{ComponentName: "component", ArtefactId: &artefactID},
+			},
+		})
+	}
+	_, err = updateArtefact("artefact-2")
+	require.ErrorIs(t, err, ErrBadRequest)
+	app, err := updateArtefact("artefact-1")
+	require.NoError(t, err)
+	require.Len(t, app.AppComponentSpecs, 1)
+	assert.Equal(t, "artefact-1", app.AppComponentSpecs[0].ArtefactId)
+
+	require.NoError(t, c.RemoveArtefact(ctx, testFederationContextID, "artefact-1"))
+	_, err = c.GetArtefact(ctx, testFederationContextID, "artefact-1")
+	require.ErrorIs(t, err, ErrNotFound)
+	require.True(t, apierrors.IsNotFound(c.RemoveArtefact(ctx, testFederationContextID, "artefact-1")))
+}
+
+func testContractAvailabilityZones(t *testing.T, newClient func(*testing.T, ...k8scli.Object) *k8sClient) {
+	ctx := context.Background()
+	fed := newTestHostFederation(testFederationContextID)
+	fed.Spec.AcceptedAvailabilityZones = []string{"zone-1", "zone-2"}
+	c := newClient(t, fed,
+		newTestAvailabilityZone("zone-1", "", opgv1beta1.ZoneStateReady),
+		newTestAvailabilityZone("zone-2", "", opgv1beta1.ZoneStateReady),
+		newTestApplicationInstance(t, "inst-1", "app-1", "provider-1", "zone-1", opgv1beta1.ApplicationInstanceStateReady),
+	)
+
+	zones, err := c.ListAvailabilityZones(ctx)
+	require.NoError(t, err)
+	zoneIDs := []string{}
+	for _, z := range zones {
+		zoneIDs = append(zoneIDs, z.ZoneDetails.ZoneId)
+	}
+	assert.ElementsMatch(t, []string{"zone-1", "zone-2"}, zoneIDs)
+	zone, err := c.GetAvailabilityZone(ctx, testFederationContextID, "zone-1")
+	require.NoError(t, err)
+	assert.Equal(t, "zone-1", zone.ZoneDetails.ZoneId)
+	_, err = c.GetAvailabilityZone(ctx, testFederationContextID, "zone-3")
+	require.True(t, apierrors.IsNotFound(err))
+
+	require.NoError(t, c.AddAvailabilityZones(ctx, testFederationContextID, []string{"zone-3"}))
+	require.ErrorIs(t, c.AddAvailabilityZones(ctx, "unknown", []string{"zone-3"}), ErrNotFound)
+
+	require.ErrorIs(t, c.RemoveAvailabilityZone(ctx, testFederationContextID, "zone-4"), ErrNotFound)
+	require.ErrorIs(t, c.RemoveAvailabilityZone(ctx, testFederationContextID, "zone-1"), ErrConflict)
+	require.NoError(t, c.RemoveAvailabilityZone(ctx, testFederationContextID, "zone-2"))
+	got, err := c.GetFederation(ctx, testFederationContextID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"zone-1", "zone-3"}, *got.AcceptedAvailabilityZones)
+}
+
+func testContractApplications(t *testing.T, newClient func(*testing.T, ...k8scli.Object) *k8sClient) {
+	ctx := context.Background()
+	fed := newTestHostFederation(testFederationContextID)
+	fed.Spec.AcceptedAvailabilityZones = []string{"zone-1"}
+	c := newClient(t, fed)
+
+	users := 1
+	_, err := c.OnboardApplication(ctx, &OnboardApplication{
+		OnboardApplicationJSONBody: &models.OnboardApplicationJSONBody{
+			AppId:              "app-1",
+			AppProviderId:      "provider-1",
+			AppDeploymentZones: &[]models.ZoneIdentifier{"zone-1"},
+			AppQoSProfile:      models.AppQoSProfile{NoOfUsersPerAppInst: &users},
+		},
+		FederationContextId: testFederationContextID,
+	})
+	require.NoError(t, err)
+
+	install := func(id, appID string) error {
+		dep := &ApplicationInstance{
+			InstallAppJSONBody: &models.InstallAppJSONBody{
+				AppInstanceId: id,
+				AppId:         appID,
+				AppProviderId: "provider-1",
+			},
+			FederationContextId: testFederationContextID,
+		}
+		dep.ZoneInfo.ZoneId = "zone-1"
+		_, err := c.AddApplicationInstance(ctx, dep)
+		return err
+	}
+	require.ErrorIs(t, install("inst-1", "app-2"), ErrBadRequest)
+	require.NoError(t, install("inst-1", "app-1"))
+	require.NoError(t, install("inst-2", "app-1"))
+	require.ErrorIs(t, install("inst-1", "app-1"), ErrAlreadyExists)
+
+	inst, err := c.GetApplicationInstance(ctx, testFederationContextID, "inst-1")
+	require.NoError(t, err)
+	assert.Equal(t, "app-1", inst.AppId)
+	_, err = c.GetApplicationInstance(ctx, testFederationContextID, "inst-3")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	instances, err := c.ListApplicationInstances(ctx, testFederationContextID, "app-1", "provider-1")
+	require.NoError(t, err)
+	require.Len(t, instances, 2)
+
+	require.NoError(t, c.RemoveApplicationInstance(ctx, testFederationContextID, "inst-1"))
+	instances, err = c.ListApplicationInstances(ctx, testFederationContextID, "app-1", "provider-1")
+	require.NoError(t, err)
+	require.Len(t, instances, 1)
+	assert.Equal(t, "inst-2", instances[0].AppInstanceId)
+
+	details, err := c.GetApplicationInstanceDetails(ctx, testFederationContextID, "inst-2")
+	require.NoError(t, err)
+	assert.NotNil(t, details)
+	_, err = c.GetApplicationInstanceDetails(ctx, testFederationContextID, "inst-1")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, c.RemoveApplication(ctx, testFederationContextID, "app-1"))
+	_, err = c.GetApplication(ctx, testFederationContextID, "app-1")
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func testContractApplicationZones(t *testing.T, newClient func(*testing.T, ...k8scli.Object) *k8sClient) {
+	ctx := context.Background()
+	fed := newTestHostFederation(testFederationContextID)
+	fed.Spec.AcceptedAvailabilityZones = []string{"zone-1", "zone-2", "zone-3"}
+	c := newClient(t, fed,
+		newTestAvailabilityZone("zone-1", "", ""),
+		newTestAvailabilityZone("zone-2", "", ""),
+		newTestAvailabilityZone("zone-3", "", ""),
+		newTestApplicationInstance(t, "inst-1", "app-1", "provider-1", "zone-1", opgv1beta1.ApplicationInstanceStateReady),
+	)
+
+	// the zone states are only written through the status subresource
+	for _, zoneID := range []string{"zone-1", "zone-2", "zone-3"} {
+		zone := &opgv1beta1.AvailabilityZone{}
+		require.NoError(t, c.kubernetes.Get(ctx, types.NamespacedName{Namespace: testNamespace, Name: zoneID}, zone))
+		zone.Status = opgv1beta1.AvailabilityZoneStatus{State: opgv1beta1.ZoneStateReady, FlavoursSupported: []string{"small"}}
+		require.NoError(t, c.kubernetes.Status().Update(ctx, zone))
+	}
+
+	users := 1
+	_, err := c.OnboardApplication(ctx, &OnboardApplication{
+		OnboardApplicationJSONBody: &models.OnboardApplicationJSONBody{
+			AppId:              "app-1",
+			AppProviderId:      "provider-1",
+			AppDeploymentZones: &[]models.ZoneIdentifier{"zone-1"},
+			AppQoSProfile:      models.AppQoSProfile{NoOfUsersPerAppInst: &users},
+		},
+		FederationContextId: testFederationContextID,
+	})
+	require.NoError(t, err)
+
+	app, err := c.AddApplicationZones(ctx, testFederationContextID, "app-1", []string{"zone-2", "zone-3"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"zone-1", "zone-2", "zone-3"}, app.AppDeploymentZones)
+	_, err = c.AddApplicationZones(ctx, testFederationContextID, "app-2", []string{"zone-2"})
+	require.ErrorIs(t, err, ErrNotFound)
+
+	require.ErrorIs(t, c.RemoveApplicationZone(ctx, testFederationContextID, "app-1", "zone-4"), ErrNotFound)
+	require.ErrorIs(t, c.RemoveApplicationZone(ctx, testFederationContextID, "app-1", "zone-1"), ErrConflict)
+	require.NoError(t, c.RemoveApplicationZone(ctx, testFederationContextID, "app-1", "zone-3"))
+
+	forbid := true
+	app, err = c.SetApplicationZoneForbids(ctx, testFederationContextID, "app-1", []ApplicationZoneForbid{{ZoneId: "zone-2", Forbid: &forbid}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"zone-2"}, app.ForbiddenZones)
+	_, err = c.SetApplicationZoneForbids(ctx, testFederationContextID, "app-1", []ApplicationZoneForbid{{ZoneId: "zone-3", Forbid: &forbid}})
+	require.ErrorIs(t, err, ErrNotFound)
+
+	nodes, err := c.GetCandidateZones(ctx, testFederationContextID, &models.GetCandidateZonesJSONBody{AppId: "app-1", AppProviderId: "provider-1"})
+	require.NoError(t, err)
+	require.Len(t, nodes, 1)
+	assert.Equal(t, "zone-1", nodes[0].ZoneId)
+	_, err = c.GetCandidateZones(ctx, testFederationContextID, &models.GetCandidateZonesJSONBody{AppId: "app-1", AppProviderId: "provider-2"})
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func testContractResourcePools(t *testing.T, newClient func(*testing.T, ...k8scli.Object) *k8sClient) {
+	ctx := context.Background()
+	fed := newTestHostFederation(testFederationContextID)
+	fed.Spec.AcceptedAvailabilityZones = []string{"zone-1"}
+	appInst := newTestApplicationInstance(t, "inst-1", "app-1", "provider-1", "zone-1", opgv1beta1.ApplicationInstanceStateReady)
+	appInst.Spec.ZoneInfo.FlavourId = "small"
+	appInst.Labels[opgLabel(resPoolIDLabel)] = "pool-1"
+	c := newClient(t, fed, appInst)
+
+	_, err := c.CreateResourcePool(ctx, newTestResourcePool("pool-1", "zone-2", "provider-1", testPoolFlavour{FlavourId: "small", NumFlavour: 2}))
+	require.ErrorIs(t, err, ErrBadRequest)
+	_, err = c.CreateResourcePool(ctx, newTestResourcePool("pool-1", "zone-1", "provider-1", testPoolFlavour{FlavourId: "small", NumFlavour: 2}))
+	require.NoError(t, err)
+	_, err = c.CreateResourcePool(ctx, newTestResourcePool("pool-1", "zone-1", "provider-1", testPoolFlavour{FlavourId: "small", NumFlavour: 2}))
+	require.ErrorIs(t, err, ErrAlreadyExists)
+
+	pools, err := c.ListResourcePools(ctx, testFederationContextID, "zone-1", "provider-1")
+	require.NoError(t, err)
+	require.Len(t, pools, 1)
+	assert.Equal(t, "pool-1", pools[0].ReservedPoolId)
+	pools, err = c.ListResourcePools(ctx, testFederationContextID, "zone-1", "provider-2")
+	require.NoError(t, err)
+	assert.Empty(t, pools)
+
+	// the granted flavours are kept by the spec updates
+	obj, err := c.getResourcePool(ctx, testFederationContextID, "pool-1")
+	require.NoError(t, err)
+	obj.Status.State = opgv1beta1.ResourcePoolStateReserved
+	obj.Status.GrantedFlavours = []opgv1beta1.GrantedFlavour{{FlavourId: "small", NumFlavour: 2}}
+	require.NoError(t, c.kubernetes.Status().Update(ctx, obj))
+	require.NoError(t, c.UpdateResourcePool(ctx, testFederationContextID, "zone-1", "provider-1", "pool-1", models.UpdateISVResPoolJSONBody{
+		{FlavourId: "small", Count: 3, UpdateType: models.UpdateISVResPoolJSONBodyUpdateTypeADD},
+	}))
+	usage, err := c.GetResourcePoolUsage(ctx, testFederationContextID, "pool-1")
+	require.NoError(t, err)
+	assert.Equal(t, opgv1beta1.ResourcePoolStateReserved, usage.State)
+	assert.Equal(t, int32(1), usage.Remaining("small"))
+	obj, err = c.getResourcePool(ctx, testFederationContextID, "pool-1")
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), obj.Spec.Flavours[0].NumFlavour)
+
+	require.ErrorIs(t, c.RemoveResourcePool(ctx, testFederationContextID, "zone-1", "provider-1", "pool-1"), ErrConflict)
+	require.NoError(t, c.kubernetes.Delete(ctx, appInst))
+	require.NoError(t, c.RemoveResourcePool(ctx, testFederationContextID, "zone-1", "provider-1", "pool-1"))
+	_, err = c.GetResourcePoolUsage(ctx, testFederationContextID, "pool-1")
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func testContractStatusCallbacks(t *testing.T, newClient func(*testing.T, ...k8scli.Object) *k8sClient) {
+	ctx := context.Background()
+	c := newClient(t,
+		newTestGuestApplicationInstance("inst-1", "fed-callback-1"),
+		&opgv1beta1.File{ObjectMeta: newTestGuestObjectMeta("file", "file-1", "fed-callback-1")},
+		&opgv1beta1.Artefact{ObjectMeta: newTestGuestObjectMeta("artefact", "artefact-1", "fed-callback-1")},
+		&opgv1beta1.Application{ObjectMeta: newTestGuestObjectMeta("app", "app-1", "fed-callback-1")},
+		&opgv1beta1.ResourcePool{ObjectMeta: newTestGuestObjectMeta("pool", "pool-1", "fed-callback-1")},
+		&opgv1beta1.Federation{ObjectMeta: newTestGuestObjectMeta("federation", "fed-callback-1", "fed-callback-1")},
+	)
+
+	// the status callbacks of the federation each keep the state of the others
+	require.NoError(t, c.UpdateFederationStatus(ctx, "fed-callback-1", models.Status(opgv1beta1.FederationStateAvailable)))
+	require.ErrorIs(t, c.UpdateFederationStatus(ctx, "fed-callback-2", models.Status(opgv1beta1.FederationStateAvailable)), ErrNotFound)
+	require.NoError(t, c.UpdateFederationPartnerStatus(ctx, "fed-callback-1", &models.PartnerStatusLinkJSONRequestBody{
+		ObjectType:    models.PartnerStatusLinkJSONBodyObjectTypeZONES,
+		OperationType: models.PartnerStatusLinkJSONBodyOperationTypeADD,
+		AddZones:      &[]models.ZoneDetails{{ZoneId: "zone-1"}},
+	}))
+	require.NoError(t, c.UpdateFederationZoneAvailability(ctx, "fed-callback-1", &models.AvailZoneNotifLinkJSONRequestBody{ZoneId: "zone-1"}))
+	guestFed := &opgv1beta1.Federation{}
+	require.NoError(t, c.kubernetes.Get(ctx, types.NamespacedName{Namespace: testNamespace, Name: "federation-fed-callback-1"}, guestFed))
+	assert.Equal(t, opgv1beta1.FederationStateAvailable, guestFed.Status.State)
+	require.Len(t, guestFed.Status.OfferedAvailabilityZones, 1)
+	require.Len(t, guestFed.Status.ZonesAvailability, 1)
+	assert.Equal(t, opgv1beta1.ZoneAvailabilityStateNotAvailable, guestFed.Status.ZonesAvailability[0].State)
+
+	require.NoError(t, c.UpdateFileStatus(ctx, "fed-callback-1", &models.FileStatusCallbackLinkJSONRequestBody{
+		FileId: "file-1", UpdateStatus: models.FileStatusCallbackLinkJSONBodyUpdateStatus(opgv1beta1.FileStateReady),
+	}))
+	file := &opgv1beta1.File{}
+	require.NoError(t, c.kubernetes.Get(ctx, types.NamespacedName{Namespace: testNamespace, Name: "file-file-1"}, file))
+	assert.Equal(t, opgv1beta1.FileStateReady, file.Status.State)
+
+	require.NoError(t, c.UpdateArtefactStatus(ctx, "fed-callback-1", &models.ArtefactStatusCallbackLinkJSONRequestBody{
+		ArtefactId: "artefact-1", UpdateStatus: models.ArtefactStatusCallbackLinkJSONBodyUpdateStatus(opgv1beta1.ArtefactStateError),
+	}))
+	artefact := &opgv1beta1.Artefact{}
+	require.NoError(t, c.kubernetes.Get(ctx, types.NamespacedName{Namespace: testNamespace, Name: "artefact-artefact-1"}, artefact))
+	assert.Equal(t, opgv1beta1.ArtefactStateError, artefact.Status.State)
+
+	appUpdate := &models.AppStatusCallbackLinkJSONRequestBody{AppId: "app-1"}
+	appUpdate.StatusInfo = append(appUpdate.StatusInfo, struct {
+		OnboardStatusInfo models.AppStatusCallbackLinkJSONBodyStatusInfoOnboardStatusInfo `json:"onboardStatusInfo"`
+		ZoneId            models.ZoneIdentifier                                           `json:"zoneId"`
+	}{OnboardStatusInfo: "ONBOARDED", ZoneId: "zone-1"})
+	require.NoError(t, c.UpdateApplicationStatus(ctx, "fed-callback-1", appUpdate))
+	app := &opgv1beta1.Application{}
+	require.NoError(t, c.kubernetes.Get(ctx, types.NamespacedName{Namespace: testNamespace, Name: "app-app-1"}, app))
+	assert.Equal(t, opgv1beta1.ApplicationStateOnboarded, app.Status.State)
+	require.Len(t, app.Status.Zones, 1)
+
+	poolUpdate := &models.ResourceReservationCallbackLinkJSONRequestBody{PoolId: "pool-1"}
+	poolUpdate.GrantedFlavours = append(poolUpdate.GrantedFlavours, struct {
+		FlavourId  models.FlavourId `json:"flavourId"`
+		NumFlavour int32            `json:"numFlavour"`
+	}{FlavourId: "small", NumFlavour: 2})
+	require.NoError(t, c.UpdateResourcePoolStatus(ctx, "fed-callback-1", poolUpdate))
+	pool := &opgv1beta1.ResourcePool{}
+	require.NoError(t, c.kubernetes.Get(ctx, types.NamespacedName{Namespace: testNamespace, Name: "pool-pool-1"}, pool))
+	assert.Equal(t, opgv1beta1.ResourcePoolStateReserved, pool.Status.State)
+	assert.Equal(t, []opgv1beta1.GrantedFlavour{{FlavourId: "small", NumFlavour: 2}}, pool.Status.GrantedFlavours)
+
+	update := &models.AppInstCallbackLinkJSONRequestBody{AppInstanceId: "inst-1"}
+	state := models.InstanceStateREADY
+	update.AppInstanceInfo.AppInstanceState = &state
+	require.NoError(t, c.UpdateApplicationInstanceStatus(ctx, "fed-callback-1", update))
+	require.ErrorIs(t, c.UpdateApplicationInstanceStatus(ctx, "fed-callback-2", update), ErrNotFound)
+
+	// the status is only written through the status subresource
+	obj := &opgv1beta1.ApplicationInstance{}
+	key := types.NamespacedName{Namespace: testNamespace, Name: "appinst-inst-1"}
+	require.NoError(t, c.kubernetes.Get(ctx, key, obj))
+	assert.Equal(t, opgv1beta1.ApplicationInstanceStateReady, obj.Status.State)
+	obj.Spec.AppId = "app-2"
+	obj.Status.State = opgv1beta1.ApplicationInstanceStateFailed
+	require.NoError(t, c.kubernetes.Update(ctx, obj))
+	require.NoError(t, c.kubernetes.Get(ctx, key, obj))
+	assert.Equal(t, "app-2", obj.Spec.AppId)
+	assert.Equal(t, opgv1beta1.ApplicationInstanceStateReady, obj.Status.State)
+
+	// writes of an outdated version conflict
+	stale := obj.DeepCopy()
+	require.NoError(t, c.kubernetes.Status().Update(ctx, obj))
+	stale.Status.State = opgv1beta1.ApplicationInstanceStateFailed
+	require.True(t, apierrors.IsConflict(c.kubernetes.Status().Update(ctx, stale)))
+}
@@ -0,0 +1,129 @@
+package metastore
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/neonephos-katalis/opg-ewbi-operator/api/ewbi/models"
+	opgv1beta1 "github.com/neonephos-katalis/opg-ewbi-operator/api/operator/v1beta1"
+)
+
+// earthRadiusKm is the mean radius of the earth used to compute the distance
+// between the client and the zones.
+const earthRadiusKm = 6371.0
+
+// candidateZone is a zone where the application can be instantiated, with its
+// distance to the client when the client location is known.
+type candidateZone struct {
+	zoneID   models.ZoneIdentifier
+	endpoint models.ServiceEndpoint
+	distance float64
+}
+
+// parseGeoLocation parses a "lat,long" geolocation as decimal fractions.
+func parseGeoLocation(geoLocation string) (lat, long float64, err error) {
+	latStr, longStr, ok := strings.Cut(geoLocation, ",")
+	if !ok {
+		return 0, 0, errors.Errorf("invalid geolocation '%s', expected 'lat,long'", geoLocation)
+	}
+	if lat, err = strconv.ParseFloat(strings.TrimSpace(latStr), 64); err != nil || lat < -90 || lat > 90 {
+		return 0, 0, errors.Errorf("invalid latitude in geolocation '%s'", geoLocation)
+	}
+	if long, err = strconv.ParseFloat(strings.TrimSpace(longStr), 64); err != nil || long < -180 || long > 180 {
+		return 0, 0, errors.Errorf("invalid longitude in geolocation '%s'", geoLocation)
+	}
+	return lat, long, nil
+}
+
+// geoDistance returns the great-circle distance in km between two points.
+func geoDistance(lat1, long1, lat2, long2 float64) float64 {
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+	dLat := toRad(lat2 - lat1)
+	dLong := toRad(long2 - long1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRad(lat1))*math.Cos(toRad(lat2))*math.Sin(dLong/2)*math.Sin(dLong/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(a))
+}
+
+// clientGeoLocation returns the geolocation of the client in the edge discovery
+// filters, if any.
+func clientGeoLocation(req *models.GetCandidateZonesJSONBody) (lat, long float64, ok bool, err error) {
+	if req.EdgeDiscoveryFilters == nil || req.EdgeDiscoveryFilters.Location == nil ||
+		req.EdgeDiscoveryFilters.Location.GeoLocation == nil {
+		return 0, 0, false, nil
+	}
+	lat, long, err = parseGeoLocation(*req.EdgeDiscoveryFilters.Location.GeoLocation)
+	if err != nil {
+		return 0, 0, false, errors.Wrap(ErrBadRequest, err.Error())
+	}
+	return lat, long, true, nil
+}
+
+// candidateZones returns the zones of the application which are ready and
+// support the flavours used by its instances, nearest to the client first.
+// Zones with an unknown geolocation are ranked last when the client location
+// is given, otherwise the order of the zones is kept.
+func candidateZones(
+	zones []string,
+	azs []opgv1beta1.AvailabilityZone,
+	appInsts []opgv1beta1.ApplicationInstance,
+	lat, long float64, located bool,
+) []candidateZone {
+	azByZone := make(map[string]opgv1beta1.AvailabilityZone, len(azs))
+	for _, az := range azs {
+		zoneID := string(az.Spec.ZoneId)
+		if zoneID == "" {
+			zoneID = az.Name
+		}
+		azByZone[zoneID] = az
+	}
+	flavours := []string{}
+	endpoints := map[string]models.ServiceEndpoint{}
+	for _, appInst := range appInsts {
+		if appInst.Spec.ZoneInfo.FlavourId != "" {
+			flavours = mergeUnique(flavours, []string{appInst.Spec.ZoneInfo.FlavourId})
+		}
+		_, ok := endpoints[appInst.Spec.ZoneInfo.ZoneId]
+		if !ok && appInst.Status.State == opgv1beta1.ApplicationInstanceStateReady && len(appInst.Status.AccessPointInfo) > 0 {
+			endpoints[appInst.Spec.ZoneInfo.ZoneId] = serviceEndpointToModel(appInst.Status.AccessPointInfo[0].AccessPoints)
+		}
+	}
+
+	candidates := []candidateZone{}
+	for _, zoneID := range zones {
+		az, ok := azByZone[zoneID]
+		if !ok || az.Status.State != opgv1beta1.ZoneStateReady {
+			continue
+		}
+		if len(removeValues(flavours, az.Status.FlavoursSupported)) > 0 {
+			continue
+		}
+		candidate := candidateZone{zoneID: zoneID, endpoint: endpoints[zoneID], distance: math.Inf(1)}
+		if located {
+			if zoneLat, zoneLong, err := parseGeoLocation(string(az.Spec.Geolocation)); err == nil {
+				candidate.distance = geoDistance(lat, long, zoneLat, zoneLong)
+			}
+		}
+		candidates = append(candidates, candidate)
+	}
+	slices.SortStableFunc(candidates, func(a, b candidateZone) int {
+		return cmp.Compare(a.distance, b.distance)
+	})
+	return candidates
+}
+
+func discoveredEdgeNodesFromCandidates(candidates []candidateZone) models.DiscoveredEdgeNodes {
+	nodes := make(models.DiscoveredEdgeNodes, 0, len(candidates))
+	for _, c := range candidates {
+		nodes = append(nodes, struct {
+			LatencyServiceEndPoints models.ServiceEndpoint `json:"latencyServiceEndPoints"`
+			ZoneId                  models.ZoneIdentifier  `json:"zoneId"`
+		}{LatencyServiceEndPoints: c.endpoint, ZoneId: c.zoneID})
+	}
+	return nodes
+}
@@ -0,0 +1,123 @@
+package metastore
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/neonephos-katalis/opg-ewbi-operator/api/ewbi/models"
+	opgv1beta1 "github.com/neonephos-katalis/opg-ewbi-operator/api/operator/v1beta1"
+)
+
+func Test_parseGeoLocation(t *testing.T) {
+	tests := []struct {
+		name     string
+		geo      string
+		wantLat  float64
+		wantLong float64
+		wantErr  bool
+	}{
+		{name: "Valid", geo: "40.4168,-3.7038", wantLat: 40.4168, wantLong: -3.7038},
+		{name: "Spaces", geo: " 41.3874, 2.1686 ", wantLat: 41.3874, wantLong: 2.1686},
+		{name: "Missing longitude", geo: "40.4168", wantErr: true},
+		{name: "Not a number", geo: "north,-3.7038", wantErr: true},
+		{name: "Latitude out of range", geo: "91,0", wantErr: true},
+		{name: "Longitude out of range", geo: "0,-181", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lat, long, err := parseGeoLocation(tt.geo)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.wantLat, lat)
+			require.Equal(t, tt.wantLong, long)
+		})
+	}
+}
+
+func newTestAvailabilityZone(zoneID, geoLocation string, state opgv1beta1.ZoneState, flavours ...string) *opgv1beta1.AvailabilityZone {
+	return &opgv1beta1.AvailabilityZone{
+		ObjectMeta: metav1.ObjectMeta{Name: zoneID, Namespace: testNamespace},
+		Spec: opgv1beta1.AvailabilityZoneSpec{
+			ZoneId:      opgv1beta1.ZoneIdentifier(zoneID),
+			Geolocation: opgv1beta1.GeoLocation(geoLocation),
+		},
+		Status: opgv1beta1.AvailabilityZoneStatus{State: state, FlavoursSupported: flavours},
+	}
+}
+
+func Test_k8sClient_GetCandidateZones(t *testing.T) {
+	fed := newTestHostFederation(testFederationContextID)
+	fed.Spec.AcceptedAvailabilityZones = []string{"madrid", "barcelona", "lisbon", "paris", "berlin", "rome"}
+	appInst := newTestApplicationInstance(t, "inst-1", "app-1", "provider-1", "barcelona", opgv1beta1.ApplicationInstanceStateReady)
+	appInst.Spec.ZoneInfo.FlavourId = "small"
+	appInst.Status.AccessPointInfo = []opgv1beta1.AccessPointInfo{
+		{InterfaceId: "http", AccessPoints: opgv1beta1.AccessPoints{Port: 8080, Fqdn: "app.barcelona.example.com"}},
+	}
+	c := newTestK8sClient(t, fed, appInst,
+		newTestAvailabilityZone("madrid", "40.4168,-3.7038", opgv1beta1.ZoneStateReady, "small", "large"),
+		newTestAvailabilityZone("barcelona", "41.3874,2.1686", opgv1beta1.ZoneStateReady, "small"),
+		newTestAvailabilityZone("lisbon", "38.7223,-9.1393", opgv1beta1.ZoneStateReady, "large"),
+		newTestAvailabilityZone("paris", "48.8566,2.3522", opgv1beta1.ZoneStatePending, "small"),
+		newTestAvailabilityZone("berlin", "", opgv1beta1.ZoneStateReady, "small"),
+		newTestAvailabilityZone("rome", "41.9028,12.4964", opgv1beta1.ZoneStateReady, "small"),
+	)
+	ctx := context.Background()
+	users := 1
+	_, err := c.OnboardApplication(ctx, &OnboardApplication{
+		OnboardApplicationJSONBody: &models.OnboardApplicationJSONBody{
+			AppId:              "app-1",
+			AppProviderId:      "provider-1",
+			AppDeploymentZones: &[]models.ZoneIdentifier{"berlin", "barcelona", "lisbon", "paris", "madrid", "rome", "tokyo"},
+			AppQoSProfile:      models.AppQoSProfile{NoOfUsersPerAppInst: &users},
+		},
+		FederationContextId: testFederationContextID,
+	})
+	require.NoError(t, err)
+	forbid := true
+	_, err = c.SetApplicationZoneForbids(ctx, testFederationContextID, "app-1", []ApplicationZoneForbid{{ZoneId: "rome", Forbid: &forbid}})
+	require.NoError(t, err)
+
+	request := func(appProviderID string, geoLocation *string) *models.GetCandidateZonesJSONBody {
+		req := &models.GetCandidateZonesJSONBody{AppId: "app-1", AppProviderId: appProviderID}
+		if geoLocation != nil {
+			req.EdgeDiscoveryFilters = &struct {
+				Location *models.ClientLocation `json:"location,omitempty"`
+			}{Location: &models.ClientLocation{GeoLocation: geoLocation}}
+		}
+		return req
+	}
+	zoneIDs := func(nodes models.DiscoveredEdgeNodes) []string {
+		ids := []string{}
+		for _, n := range nodes {
+			ids = append(ids, n.ZoneId)
+		}
+		return ids
+	}
+
+	// zaragoza lies between madrid and barcelona, slightly nearer to barcelona
+	zaragoza := "41.6488,-0.8891"
+	nodes, err := c.GetCandidateZones(ctx, testFederationContextID, request("provider-1", &zaragoza))
+	require.NoError(t, err)
+	require.Equal(t, []string{"barcelona", "madrid", "berlin"}, zoneIDs(nodes))
+	require.Equal(t, 8080, nodes[0].LatencyServiceEndPoints.Port)
+	require.Equal(t, "app.barcelona.example.com", *nodes[0].LatencyServiceEndPoints.Fqdn)
+
+	nodes, err = c.GetCandidateZones(ctx, testFederationContextID, request("provider-1", nil))
+	require.NoError(t, err)
+	require.Equal(t, []string{"berlin", "barcelona", "madrid"}, zoneIDs(nodes))
+
+	invalid := "zaragoza"
+	_, err = c.GetCandidateZones(ctx, testFederationContextID, request("provider-1", &invalid))
+	require.ErrorIs(t, err, ErrBadRequest)
+	_, err = c.GetCandidateZones(ctx, testFederationContextID, request("provider-2", nil))
+	require.ErrorIs(t, err, ErrNotFound)
+	_, err = c.GetCandidateZones(ctx, "unknown", request("provider-1", nil))
+	require.ErrorIs(t, err, ErrNotFound)
+}
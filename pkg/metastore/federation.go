@@ -1,6 +1,11 @@
 package metastore
 
 import (
+	"fmt"
+	"slices"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/neonephos-katalis/opg-ewbi-operator/api/ewbi/models"
@@ -80,3 +85,282 @@ func isValidFederationStatus(status string) bool {
 	}
 	return false
 }
+
+// applyFederationUpdate applies the network codes changes requested by the
+// originating OP to the federation origin.
+// UPDATE_CODES removes the requested codes first and then adds the new ones.
+func applyFederationUpdate(origin *opgv1beta1.Origin, update *models.UpdateFederationJSONBody) error {
+	switch update.ObjectType {
+	case models.UpdateFederationJSONBodyObjectTypeMOBILENETWORKCODES:
+		add, remove, err := updateCodesOperations(update.OperationType,
+			update.AddMobileNetworkIds != nil, update.RemoveMobileNetworkIds != nil)
+		if err != nil {
+			return err
+		}
+		if remove {
+			if err := removeMobileNetworkCodes(&origin.MobileNetworkCodes, update.RemoveMobileNetworkIds); err != nil {
+				return err
+			}
+		}
+		if add {
+			if err := addMobileNetworkCodes(&origin.MobileNetworkCodes, update.AddMobileNetworkIds); err != nil {
+				return err
+			}
+		}
+	case models.UpdateFederationJSONBodyObjectTypeFIXEDNETWORKCODES:
+		add, remove, err := updateCodesOperations(update.OperationType,
+			update.AddFixedNetworkIds != nil, update.RemoveFixedNetworkIds != nil)
+		if err != nil {
+			return err
+		}
+		if remove {
+			origin.FixedNetworkCodes = removeValues(origin.FixedNetworkCodes, *update.RemoveFixedNetworkIds)
+		}
+		if add {
+			origin.FixedNetworkCodes = mergeUnique(origin.FixedNetworkCodes, *update.AddFixedNetworkIds)
+		}
+	default:
+		return errors.Wrapf(ErrBadRequest, "unsupported objectType '%s'", update.ObjectType)
+	}
+	return nil
+}
+
+// updateCodesOperations returns whether codes have to be added and/or removed
+// for the given operationType, failing if the required codes are missing.
+func updateCodesOperations(
+	op models.UpdateFederationJSONBodyOperationType, hasAdd, hasRemove bool,
+) (add bool, remove bool, err error) {
+	switch op {
+	case models.ADDCODES:
+		add = hasAdd
+	case models.REMOVECODES:
+		remove = hasRemove
+	case models.UPDATECODES:
+		add, remove = hasAdd, hasRemove
+	default:
+		return false, false, errors.Wrapf(ErrBadRequest, "unsupported operationType '%s'", op)
+	}
+	if !add && !remove {
+		return false, false, errors.Wrapf(ErrBadRequest, "missing network codes for operationType '%s'", op)
+	}
+	return add, remove, nil
+}
+
+// addMobileNetworkCodes adds the MNCs to the origin ones, a MCC can only be set
+// if the origin has none or it is the same one.
+func addMobileNetworkCodes(codes *opgv1beta1.MobileNetworkCodes, ids *models.MobileNetworkIds) error {
+	if mcc := defaultIfNil(ids.Mcc); mcc != "" {
+		if codes.MCC != "" && codes.MCC != mcc {
+			return errors.Wrapf(ErrBadRequest, "mcc '%s' does not match federation mcc '%s'", mcc, codes.MCC)
+		}
+		codes.MCC = mcc
+	}
+	if ids.Mncs != nil {
+		codes.MNC = mergeUnique(codes.MNC, *ids.Mncs)
+	}
+	return nil
+}
+
+// removeMobileNetworkCodes removes the MNCs from the origin ones, the MCC is
+// cleared when it is given and no MNC is left.
+func removeMobileNetworkCodes(codes *opgv1beta1.MobileNetworkCodes, ids *models.MobileNetworkIds) error {
+	mcc := defaultIfNil(ids.Mcc)
+	if mcc != "" && codes.MCC != mcc {
+		return errors.Wrapf(ErrBadRequest, "mcc '%s' does not match federation mcc '%s'", mcc, codes.MCC)
+	}
+	if ids.Mncs != nil {
+		codes.MNC = removeValues(codes.MNC, *ids.Mncs)
+	}
+	if mcc != "" && len(codes.MNC) == 0 {
+		codes.MCC = ""
+	}
+	return nil
+}
+
+// applyPartnerStatusUpdate applies the changes notified by the partner OP
+// through the partner status link to the guest federation status.
+func applyPartnerStatusUpdate(status *opgv1beta1.FederationStatus, update *models.PartnerStatusLinkJSONRequestBody) error {
+	unsupportedErr := errors.Wrapf(ErrBadRequest, "unsupported operationType '%s' for objectType '%s'", update.OperationType, update.ObjectType)
+	missingErr := func(param string) error {
+		return errors.Wrapf(ErrBadRequest, "missing %s for objectType '%s'", param, update.ObjectType)
+	}
+	modificationDate := metav1.NewTime(update.ModificationDate)
+
+	switch update.ObjectType {
+	case models.PartnerStatusLinkJSONBodyObjectTypeZONES:
+		switch update.OperationType {
+		case models.PartnerStatusLinkJSONBodyOperationTypeADD:
+			if update.AddZones == nil {
+				return missingErr("addZones")
+			}
+			for _, z := range *update.AddZones {
+				zone := opgv1beta1.ZoneDetails{
+					GeographyDetails: z.GeographyDetails,
+					Geolocation:      z.Geolocation,
+					ZoneId:           z.ZoneId,
+				}
+				i := slices.IndexFunc(status.OfferedAvailabilityZones, func(o opgv1beta1.ZoneDetails) bool { return o.ZoneId == z.ZoneId })
+				if i >= 0 {
+					status.OfferedAvailabilityZones[i] = zone
+				} else {
+					status.OfferedAvailabilityZones = append(status.OfferedAvailabilityZones, zone)
+				}
+			}
+		case models.PartnerStatusLinkJSONBodyOperationTypeREMOVE:
+			if update.RemoveZones == nil {
+				return missingErr("removeZones")
+			}
+			status.OfferedAvailabilityZones = slices.DeleteFunc(status.OfferedAvailabilityZones, func(o opgv1beta1.ZoneDetails) bool {
+				return slices.Contains(*update.RemoveZones, o.ZoneId)
+			})
+			status.ZonesStatus = slices.DeleteFunc(status.ZonesStatus, func(z opgv1beta1.ZoneStatus) bool {
+				return slices.Contains(*update.RemoveZones, z.ZoneId)
+			})
+			status.ZonesAvailability = slices.DeleteFunc(status.ZonesAvailability, func(z opgv1beta1.ZoneAvailability) bool {
+				return slices.Contains(*update.RemoveZones, z.ZoneId)
+			})
+		case models.PartnerStatusLinkJSONBodyOperationTypeSTATUS:
+			if update.ZoneStatus == nil {
+				return missingErr("zoneStatus")
+			}
+			for _, z := range *update.ZoneStatus {
+				if !isValidFederationStatus(string(z.Status)) {
+					return errors.Wrapf(ErrBadRequest, "invalid status '%s' for zone '%s'", z.Status, z.ZoneId)
+				}
+				if !slices.ContainsFunc(status.OfferedAvailabilityZones, func(o opgv1beta1.ZoneDetails) bool { return o.ZoneId == z.ZoneId }) {
+					return errors.Wrapf(ErrNotFound, "zone '%s' not offered", z.ZoneId)
+				}
+				zoneStatus := opgv1beta1.ZoneStatus{
+					ZoneId:         z.ZoneId,
+					State:          opgv1beta1.FederationState(z.Status),
+					LastUpdateTime: modificationDate,
+				}
+				i := slices.IndexFunc(status.ZonesStatus, func(s opgv1beta1.ZoneStatus) bool { return s.ZoneId == z.ZoneId })
+				if i >= 0 {
+					status.ZonesStatus[i] = zoneStatus
+				} else {
+					status.ZonesStatus = append(status.ZonesStatus, zoneStatus)
+				}
+			}
+		default:
+			return unsupportedErr
+		}
+	case models.PartnerStatusLinkJSONBodyObjectTypeEDGEDISCOVERYSERVICE:
+		if update.OperationType != models.PartnerStatusLinkJSONBodyOperationTypeUPDATE {
+			return unsupportedErr
+		}
+		if update.EdgeDiscoverySvcEndPoint == nil {
+			return missingErr("edgeDiscoverySvcEndPoint")
+		}
+		status.EdgeDiscoveryServiceEndpoint = serviceEndpointFromModel(update.EdgeDiscoverySvcEndPoint)
+	case models.PartnerStatusLinkJSONBodyObjectTypeLCMSERVICE:
+		if update.OperationType != models.PartnerStatusLinkJSONBodyOperationTypeUPDATE {
+			return unsupportedErr
+		}
+		if update.LcmSvcEndPoint == nil {
+			return missingErr("lcmSvcEndPoint")
+		}
+		status.LcmServiceEndpoint = serviceEndpointFromModel(update.LcmSvcEndPoint)
+	case models.PartnerStatusLinkJSONBodyObjectTypeMOBILENETWORKCODES:
+		if status.PartnerNetworkCodes == nil {
+			status.PartnerNetworkCodes = &opgv1beta1.NetworkCodes{}
+		}
+		codes := &status.PartnerNetworkCodes.MobileNetworkCodes
+		switch update.OperationType {
+		case models.PartnerStatusLinkJSONBodyOperationTypeADD:
+			if update.AddMobileNetworkIds == nil {
+				return missingErr("addMobileNetworkIds")
+			}
+			return addMobileNetworkCodes(codes, update.AddMobileNetworkIds)
+		case models.PartnerStatusLinkJSONBodyOperationTypeREMOVE:
+			if update.RemoveMobileNetworkIds == nil {
+				return missingErr("removeMobileNetworkIds")
+			}
+			return removeMobileNetworkCodes(codes, update.RemoveMobileNetworkIds)
+		default:
+			return unsupportedErr
+		}
+	case models.PartnerStatusLinkJSONBodyObjectTypeFIXEDNETWORKCODES:
+		if status.PartnerNetworkCodes == nil {
+			status.PartnerNetworkCodes = &opgv1beta1.NetworkCodes{}
+		}
+		codes := status.PartnerNetworkCodes
+		switch update.OperationType {
+		case models.PartnerStatusLinkJSONBodyOperationTypeADD:
+			if update.AddFixedNetworkIds == nil {
+				return missingErr("addFixedNetworkIds")
+			}
+			codes.FixedNetworkCodes = mergeUnique(codes.FixedNetworkCodes, *update.AddFixedNetworkIds)
+		case models.PartnerStatusLinkJSONBodyOperationTypeREMOVE:
+			if update.RemoveFixedNetworkIds == nil {
+				return missingErr("removeFixedNetworkIds")
+			}
+			codes.FixedNetworkCodes = removeValues(codes.FixedNetworkCodes, *update.RemoveFixedNetworkIds)
+		default:
+			return unsupportedErr
+		}
+	default:
+		return errors.Wrapf(ErrBadRequest, "unsupported objectType '%s'", update.ObjectType)
+	}
+	return nil
+}
+
+func serviceEndpointFromModel(endpoint *models.ServiceEndpoint) *opgv1beta1.ServiceEndpoint {
+	return &opgv1beta1.ServiceEndpoint{
+		Fqdn:          defaultIfNil(endpoint.Fqdn),
+		Ipv4Addresses: defaultIfNil(endpoint.Ipv4Addresses),
+		Ipv6Addresses: ipv6AddressesFromModel(defaultIfNil(endpoint.Ipv6Addresses)),
+		Port:          endpoint.Port,
+	}
+}
+
+// ipv6AddressesFromModel stringifies the ipv6 addresses, which the generated
+// models leave untyped.
+func ipv6AddressesFromModel(addrs []models.Ipv6Addr) []string {
+	if addrs == nil {
+		return nil
+	}
+	res := make([]string, 0, len(addrs))
+	for _, a := range addrs {
+		res = append(res, fmt.Sprint(a))
+	}
+	return res
+}
+
+// applyZoneAvailabilityUpdate applies the resources availability notified by the partner OP
+// for a zone to the guest federation status. The zone is NOT_AVAILABLE when no compute
+// resources are available in it.
+func applyZoneAvailabilityUpdate(status *opgv1beta1.FederationStatus, update *models.AvailZoneNotifLinkJSONRequestBody, now metav1.Time) error {
+	if update.FederationContextId != nil && *update.FederationContextId != status.FederationContextId {
+		return errors.Wrapf(ErrBadRequest, "federationContextId '%s' does not match the federation", *update.FederationContextId)
+	}
+	if !slices.ContainsFunc(status.OfferedAvailabilityZones, func(o opgv1beta1.ZoneDetails) bool { return o.ZoneId == update.ZoneId }) {
+		return errors.Wrapf(ErrNotFound, "zone '%s' not offered", update.ZoneId)
+	}
+
+	state := opgv1beta1.ZoneAvailabilityStateNotAvailable
+	for _, info := range update.ZoneResUpdInfo {
+		if info.AvailableCompResources != nil && slices.ContainsFunc(*info.AvailableCompResources, hasComputeResources) {
+			state = opgv1beta1.ZoneAvailabilityStateAvailable
+			break
+		}
+	}
+
+	i := slices.IndexFunc(status.ZonesAvailability, func(z opgv1beta1.ZoneAvailability) bool { return z.ZoneId == update.ZoneId })
+	if i < 0 {
+		status.ZonesAvailability = append(status.ZonesAvailability, opgv1beta1.ZoneAvailability{ZoneId: update.ZoneId})
+		i = len(status.ZonesAvailability) - 1
+	}
+	zone := &status.ZonesAvailability[i]
+	if zone.State != state {
+		zone.State = state
+		zone.LastTransitionTime = now
+	}
+	zone.LastUpdateTime = now
+	return nil
+}
+
+func hasComputeResources(r models.ComputeResourceInfo) bool {
+	cpu, err := resource.ParseQuantity(r.NumCPU)
+	return r.Memory > 0 && err == nil && !cpu.IsZero()
+}
@@ -0,0 +1,374 @@
+package metastore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/neonephos-katalis/opg-ewbi-operator/api/ewbi/models"
+	opgv1beta1 "github.com/neonephos-katalis/opg-ewbi-operator/api/operator/v1beta1"
+)
+
+func Test_applyFederationUpdate(t *testing.T) {
+	ptr := func(s string) *string { return &s }
+
+	tests := []struct {
+		name    string
+		update  models.UpdateFederationJSONBody
+		want    opgv1beta1.Origin
+		wantErr error
+	}{
+		{
+			name: "Add mobile network codes",
+			update: models.UpdateFederationJSONBody{
+				ObjectType:          models.UpdateFederationJSONBodyObjectTypeMOBILENETWORKCODES,
+				OperationType:       models.ADDCODES,
+				AddMobileNetworkIds: &models.MobileNetworkIds{Mcc: ptr("999"), Mncs: &[]string{"02", "01"}},
+			},
+			want: opgv1beta1.Origin{
+				FixedNetworkCodes:  []string{"123"},
+				MobileNetworkCodes: opgv1beta1.MobileNetworkCodes{MCC: "999", MNC: []string{"01", "02"}},
+			},
+		},
+		{
+			name: "Add mobile network codes with another mcc",
+			update: models.UpdateFederationJSONBody{
+				ObjectType:          models.UpdateFederationJSONBodyObjectTypeMOBILENETWORKCODES,
+				OperationType:       models.ADDCODES,
+				AddMobileNetworkIds: &models.MobileNetworkIds{Mcc: ptr("111"), Mncs: &[]string{"02"}},
+			},
+			wantErr: ErrBadRequest,
+		},
+		{
+			name: "Remove mobile network codes",
+			update: models.UpdateFederationJSONBody{
+				ObjectType:             models.UpdateFederationJSONBodyObjectTypeMOBILENETWORKCODES,
+				OperationType:          models.REMOVECODES,
+				RemoveMobileNetworkIds: &models.MobileNetworkIds{Mncs: &[]string{"01"}},
+			},
+			want: opgv1beta1.Origin{
+				FixedNetworkCodes:  []string{"123"},
+				MobileNetworkCodes: opgv1beta1.MobileNetworkCodes{MCC: "999", MNC: []string{}},
+			},
+		},
+		{
+			name: "Update mobile network codes replacing the mcc",
+			update: models.UpdateFederationJSONBody{
+				ObjectType:             models.UpdateFederationJSONBodyObjectTypeMOBILENETWORKCODES,
+				OperationType:          models.UPDATECODES,
+				RemoveMobileNetworkIds: &models.MobileNetworkIds{Mcc: ptr("999"), Mncs: &[]string{"01"}},
+				AddMobileNetworkIds:    &models.MobileNetworkIds{Mcc: ptr("111"), Mncs: &[]string{"05"}},
+			},
+			want: opgv1beta1.Origin{
+				FixedNetworkCodes:  []string{"123"},
+				MobileNetworkCodes: opgv1beta1.MobileNetworkCodes{MCC: "111", MNC: []string{"05"}},
+			},
+		},
+		{
+			name: "Remove mobile network codes without ids",
+			update: models.UpdateFederationJSONBody{
+				ObjectType:          models.UpdateFederationJSONBodyObjectTypeMOBILENETWORKCODES,
+				OperationType:       models.REMOVECODES,
+				AddMobileNetworkIds: &models.MobileNetworkIds{Mncs: &[]string{"01"}},
+			},
+			wantErr: ErrBadRequest,
+		},
+		{
+			name: "Add and remove fixed network codes",
+			update: models.UpdateFederationJSONBody{
+				ObjectType:            models.UpdateFederationJSONBodyObjectTypeFIXEDNETWORKCODES,
+				OperationType:         models.UPDATECODES,
+				AddFixedNetworkIds:    &[]string{"456"},
+				RemoveFixedNetworkIds: &[]string{"123"},
+			},
+			want: opgv1beta1.Origin{
+				FixedNetworkCodes:  []string{"456"},
+				MobileNetworkCodes: opgv1beta1.MobileNetworkCodes{MCC: "999", MNC: []string{"01"}},
+			},
+		},
+		{
+			name: "Unsupported object type",
+			update: models.UpdateFederationJSONBody{
+				ObjectType:    "ZONES",
+				OperationType: models.ADDCODES,
+			},
+			wantErr: ErrBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			origin := opgv1beta1.Origin{
+				FixedNetworkCodes:  []string{"123"},
+				MobileNetworkCodes: opgv1beta1.MobileNetworkCodes{MCC: "999", MNC: []string{"01"}},
+			}
+			err := applyFederationUpdate(&origin, &tt.update)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, origin)
+		})
+	}
+}
+
+func Test_applyPartnerStatusUpdate(t *testing.T) {
+	ptr := func(s string) *string { return &s }
+	modificationDate := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	initialStatus := func() opgv1beta1.FederationStatus {
+		return opgv1beta1.FederationStatus{
+			OfferedAvailabilityZones: []opgv1beta1.ZoneDetails{
+				{ZoneId: "zone-1", Geolocation: "1,1", GeographyDetails: "Madrid"},
+			},
+			ZonesStatus: []opgv1beta1.ZoneStatus{
+				{ZoneId: "zone-1", State: opgv1beta1.FederationStateAvailable},
+			},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		update  models.PartnerStatusLinkJSONRequestBody
+		want    func(s *opgv1beta1.FederationStatus)
+		wantErr error
+	}{
+		{
+			name: "Add zones",
+			update: models.PartnerStatusLinkJSONRequestBody{
+				ObjectType:    models.PartnerStatusLinkJSONBodyObjectTypeZONES,
+				OperationType: models.PartnerStatusLinkJSONBodyOperationTypeADD,
+				AddZones: &[]models.ZoneDetails{
+					{ZoneId: "zone-1", Geolocation: "2,2", GeographyDetails: "Madrid"},
+					{ZoneId: "zone-2", Geolocation: "3,3", GeographyDetails: "Paris"},
+				},
+			},
+			want: func(s *opgv1beta1.FederationStatus) {
+				s.OfferedAvailabilityZones = []opgv1beta1.ZoneDetails{
+					{ZoneId: "zone-1", Geolocation: "2,2", GeographyDetails: "Madrid"},
+					{ZoneId: "zone-2", Geolocation: "3,3", GeographyDetails: "Paris"},
+				}
+			},
+		},
+		{
+			name: "Remove zones",
+			update: models.PartnerStatusLinkJSONRequestBody{
+				ObjectType:    models.PartnerStatusLinkJSONBodyObjectTypeZONES,
+				OperationType: models.PartnerStatusLinkJSONBodyOperationTypeREMOVE,
+				RemoveZones:   &[]models.ZoneIdentifier{"zone-1"},
+			},
+			want: func(s *opgv1beta1.FederationStatus) {
+				s.OfferedAvailabilityZones = []opgv1beta1.ZoneDetails{}
+				s.ZonesStatus = []opgv1beta1.ZoneStatus{}
+			},
+		},
+		{
+			name: "Update zones status",
+			update: models.PartnerStatusLinkJSONRequestBody{
+				ObjectType:       models.PartnerStatusLinkJSONBodyObjectTypeZONES,
+				OperationType:    models.PartnerStatusLinkJSONBodyOperationTypeSTATUS,
+				ModificationDate: modificationDate,
+				ZoneStatus: &[]struct {
+					Status models.Status         `json:"status"`
+					ZoneId models.ZoneIdentifier `json:"zoneId"`
+				}{{Status: models.StatusNOTAVAILABLE, ZoneId: "zone-1"}},
+			},
+			want: func(s *opgv1beta1.FederationStatus) {
+				s.ZonesStatus = []opgv1beta1.ZoneStatus{{
+					ZoneId:         "zone-1",
+					State:          opgv1beta1.FederationStateNotAvailable,
+					LastUpdateTime: metav1.NewTime(modificationDate),
+				}}
+			},
+		},
+		{
+			name: "Update status of a zone not offered",
+			update: models.PartnerStatusLinkJSONRequestBody{
+				ObjectType:    models.PartnerStatusLinkJSONBodyObjectTypeZONES,
+				OperationType: models.PartnerStatusLinkJSONBodyOperationTypeSTATUS,
+				ZoneStatus: &[]struct {
+					Status models.Status         `json:"status"`
+					ZoneId models.ZoneIdentifier `json:"zoneId"`
+				}{{Status: models.StatusAVAILABLE, ZoneId: "zone-9"}},
+			},
+			wantErr: ErrNotFound,
+		},
+		{
+			name: "Update edge discovery service endpoint",
+			update: models.PartnerStatusLinkJSONRequestBody{
+				ObjectType:               models.PartnerStatusLinkJSONBodyObjectTypeEDGEDISCOVERYSERVICE,
+				OperationType:            models.PartnerStatusLinkJSONBodyOperationTypeUPDATE,
+				EdgeDiscoverySvcEndPoint: &models.ServiceEndpoint{Fqdn: ptr("eds.partner.com"), Port: 443},
+			},
+			want: func(s *opgv1beta1.FederationStatus) {
+				s.EdgeDiscoveryServiceEndpoint = &opgv1beta1.ServiceEndpoint{Fqdn: "eds.partner.com", Port: 443}
+			},
+		},
+		{
+			name: "Update lcm service endpoint",
+			update: models.PartnerStatusLinkJSONRequestBody{
+				ObjectType:     models.PartnerStatusLinkJSONBodyObjectTypeLCMSERVICE,
+				OperationType:  models.PartnerStatusLinkJSONBodyOperationTypeUPDATE,
+				LcmSvcEndPoint: &models.ServiceEndpoint{Ipv4Addresses: &[]string{"10.0.0.1"}, Port: 8080},
+			},
+			want: func(s *opgv1beta1.FederationStatus) {
+				s.LcmServiceEndpoint = &opgv1beta1.ServiceEndpoint{Ipv4Addresses: []string{"10.0.0.1"}, Port: 8080}
+			},
+		},
+		{
+			name: "Lcm service without endpoint",
+			update: models.PartnerStatusLinkJSONRequestBody{
+				ObjectType:    models.PartnerStatusLinkJSONBodyObjectTypeLCMSERVICE,
+				OperationType: models.PartnerStatusLinkJSONBodyOperationTypeUPDATE,
+			},
+			wantErr: ErrBadRequest,
+		},
+		{
+			name: "Add mobile network codes",
+			update: models.PartnerStatusLinkJSONRequestBody{
+				ObjectType:          models.PartnerStatusLinkJSONBodyObjectTypeMOBILENETWORKCODES,
+				OperationType:       models.PartnerStatusLinkJSONBodyOperationTypeADD,
+				AddMobileNetworkIds: &models.MobileNetworkIds{Mcc: ptr("999"), Mncs: &[]string{"01"}},
+			},
+			want: func(s *opgv1beta1.FederationStatus) {
+				s.PartnerNetworkCodes = &opgv1beta1.NetworkCodes{
+					MobileNetworkCodes: opgv1beta1.MobileNetworkCodes{MCC: "999", MNC: []string{"01"}},
+				}
+			},
+		},
+		{
+			name: "Add fixed network codes",
+			update: models.PartnerStatusLinkJSONRequestBody{
+				ObjectType:         models.PartnerStatusLinkJSONBodyObjectTypeFIXEDNETWORKCODES,
+				OperationType:      models.PartnerStatusLinkJSONBodyOperationTypeADD,
+				AddFixedNetworkIds: &[]string{"123"},
+			},
+			want: func(s *opgv1beta1.FederationStatus) {
+				s.PartnerNetworkCodes = &opgv1beta1.NetworkCodes{FixedNetworkCodes: []string{"123"}}
+			},
+		},
+		{
+			name: "Unsupported operation type",
+			update: models.PartnerStatusLinkJSONRequestBody{
+				ObjectType:    models.PartnerStatusLinkJSONBodyObjectTypeFIXEDNETWORKCODES,
+				OperationType: models.PartnerStatusLinkJSONBodyOperationTypeSTATUS,
+			},
+			wantErr: ErrBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := initialStatus()
+			err := applyPartnerStatusUpdate(&status, &tt.update)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			want := initialStatus()
+			tt.want(&want)
+			require.Equal(t, want, status)
+		})
+	}
+}
+
+func Test_applyZoneAvailabilityUpdate(t *testing.T) {
+	earlier := metav1.NewTime(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	now := metav1.NewTime(time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC))
+	fedCtx := "fed-ctx-1"
+	otherFedCtx := "fed-ctx-2"
+	initialStatus := func() opgv1beta1.FederationStatus {
+		return opgv1beta1.FederationStatus{
+			FederationContextId: fedCtx,
+			OfferedAvailabilityZones: []opgv1beta1.ZoneDetails{
+				{ZoneId: "zone-1"}, {ZoneId: "zone-2"},
+			},
+			ZonesAvailability: []opgv1beta1.ZoneAvailability{
+				{ZoneId: "zone-1", State: opgv1beta1.ZoneAvailabilityStateAvailable, LastTransitionTime: earlier, LastUpdateTime: earlier},
+			},
+		}
+	}
+	update := func(zoneID string, resources ...models.ComputeResourceInfo) models.AvailZoneNotifLinkJSONRequestBody {
+		u := models.AvailZoneNotifLinkJSONRequestBody{ZoneId: zoneID}
+		u.ZoneResUpdInfo = append(u.ZoneResUpdInfo, struct {
+			AvailableCompResources *[]models.ComputeResourceInfo `json:"availableCompResources,omitempty"`
+			AvailableNetResources  *struct {
+				DedicatedNIC    *int32 `json:"dedicatedNIC,omitempty"`
+				EgressBandWidth *int32 `json:"egressBandWidth,omitempty"`
+				SupportDPDK     *bool  `json:"supportDPDK,omitempty"`
+				SupportSriov    *bool  `json:"supportSriov,omitempty"`
+			} `json:"availableNetResources,omitempty"`
+		}{AvailableCompResources: &resources})
+		return u
+	}
+
+	tests := []struct {
+		name    string
+		update  models.AvailZoneNotifLinkJSONRequestBody
+		want    func(s *opgv1beta1.FederationStatus)
+		wantErr error
+	}{
+		{
+			name:   "Still available",
+			update: update("zone-1", models.ComputeResourceInfo{NumCPU: "2", Memory: 1024}),
+			want: func(s *opgv1beta1.FederationStatus) {
+				s.ZonesAvailability[0].LastUpdateTime = now
+			},
+		},
+		{
+			name:   "No compute resources left",
+			update: update("zone-1", models.ComputeResourceInfo{NumCPU: "0", Memory: 1024}),
+			want: func(s *opgv1beta1.FederationStatus) {
+				s.ZonesAvailability[0] = opgv1beta1.ZoneAvailability{
+					ZoneId: "zone-1", State: opgv1beta1.ZoneAvailabilityStateNotAvailable, LastTransitionTime: now, LastUpdateTime: now,
+				}
+			},
+		},
+		{
+			name:   "First notification",
+			update: update("zone-2", models.ComputeResourceInfo{NumCPU: "500m", Memory: 512}),
+			want: func(s *opgv1beta1.FederationStatus) {
+				s.ZonesAvailability = append(s.ZonesAvailability, opgv1beta1.ZoneAvailability{
+					ZoneId: "zone-2", State: opgv1beta1.ZoneAvailabilityStateAvailable, LastTransitionTime: now, LastUpdateTime: now,
+				})
+			},
+		},
+		{
+			name:   "No resources notified",
+			update: models.AvailZoneNotifLinkJSONRequestBody{ZoneId: "zone-2", FederationContextId: &fedCtx},
+			want: func(s *opgv1beta1.FederationStatus) {
+				s.ZonesAvailability = append(s.ZonesAvailability, opgv1beta1.ZoneAvailability{
+					ZoneId: "zone-2", State: opgv1beta1.ZoneAvailabilityStateNotAvailable, LastTransitionTime: now, LastUpdateTime: now,
+				})
+			},
+		},
+		{
+			name:    "Zone not offered",
+			update:  update("zone-3", models.ComputeResourceInfo{NumCPU: "2", Memory: 1024}),
+			wantErr: ErrNotFound,
+		},
+		{
+			name:    "Other federation",
+			update:  models.AvailZoneNotifLinkJSONRequestBody{ZoneId: "zone-1", FederationContextId: &otherFedCtx},
+			wantErr: ErrBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := initialStatus()
+			err := applyZoneAvailabilityUpdate(&status, &tt.update, now)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			want := initialStatus()
+			tt.want(&want)
+			require.Equal(t, want, status)
+		})
+	}
+}
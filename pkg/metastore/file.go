@@ -23,9 +23,9 @@ func fileFromK8sCustomResource(fileID string, file opgv1beta1.File) (*File, erro
 			FileId:        fileID,
 			FileName:      file.Spec.FileName,
 			FileRepoLocation: &models.ObjectRepoLocation{
-				Password: &file.Spec.Repo.Password,
+				Password: redactedIfSet(file.Spec.Repo.PasswordSecretRef),
 				RepoURL:  &file.Spec.Repo.URL,
-				Token:    &file.Spec.Repo.Token,
+				Token:    redactedIfSet(file.Spec.Repo.TokenSecretRef),
 				UserName: &file.Spec.Repo.UserName,
 			},
 			FileType:        models.VirtImageType(file.Spec.FileType),
@@ -54,10 +54,22 @@ func (f *UploadFile) MarshalJSON() ([]byte, error) {
 	return json.Marshal(&cp)
 }
 
+// credentials returns the repo credentials of the file, kept out of the File spec.
+func (m *UploadFile) credentials() credentialsSecret {
+	creds := credentialsSecret{}
+	if m.FileRepoLocation != nil {
+		creds.set(passwordSecretKey, m.FileRepoLocation.Password)
+		creds.set(tokenSecretKey, m.FileRepoLocation.Token)
+	}
+	return creds
+}
+
 func (m *UploadFile) k8sCustomResource(namespace string, opts ...Opt) (*opgv1beta1.File, error) {
+	name := k8sCustomResourceNameFromFileID(m.FederationContextId, m.FileId)
+	creds := m.credentials()
 	obj := &opgv1beta1.File{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      k8sCustomResourceNameFromFileID(m.FederationContextId, m.FileId),
+			Name:      name,
 			Namespace: namespace,
 			Labels: map[string]string{
 				opgLabel(federationContextIDLabel): m.FederationContextId,
@@ -73,9 +85,10 @@ func (m *UploadFile) k8sCustomResource(namespace string, opts ...Opt) (*opgv1bet
 			Repo: opgv1beta1.Repo{
 				Type:     defaultIfNil((*string)(m.RepoType)),
 				URL:      defaultIfNil(m.FileRepoLocation.RepoURL),
-				Password: defaultIfNil(m.FileRepoLocation.Password),
-				Token:    defaultIfNil(m.FileRepoLocation.Token),
 				UserName: defaultIfNil(m.FileRepoLocation.UserName),
+
+				PasswordSecretRef: creds.ref(name, passwordSecretKey),
+				TokenSecretRef:    creds.ref(name, tokenSecretKey),
 			},
 			Image: opgv1beta1.Image{
 				InstructionSetArchitecture: string(m.ImgInsSetArch),
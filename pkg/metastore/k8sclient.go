@@ -2,8 +2,10 @@ package metastore
 
 import (
 	"context"
+	"slices"
 
 	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -19,8 +21,12 @@ type k8sClient struct {
 	namespace  string
 }
 
-func NewK8sClient(c k8scli.Client, namespace string) *k8sClient {
-	return &k8sClient{c, namespace}
+func NewK8sClient(c k8scli.Client, namespace string, opts ...K8sClientOpt) *k8sClient {
+	k := &k8sClient{c, namespace}
+	for _, o := range opts {
+		o(k)
+	}
+	return k
 }
 
 func (c *k8sClient) AddApplicationInstance(ctx context.Context, dep *ApplicationInstance) (*opgv1beta1.ApplicationInstance, error) {
@@ -29,7 +35,7 @@ func (c *k8sClient) AddApplicationInstance(ctx context.Context, dep *Application
 			return nil, errors.Wrap(ErrBadRequest, err.Error())
 		}
 	}
-	opt, err := c.buildOwnerReferenceOption(dep.FederationContextId)
+	opt, err := c.buildOwnerReferenceOption(ctx, dep.FederationContextId)
 	if err != nil {
 		return nil, err
 	}
@@ -37,15 +43,15 @@ func (c *k8sClient) AddApplicationInstance(ctx context.Context, dep *Application
 	if err != nil {
 		return nil, err
 	}
-	err = c.createK8sObject(obj)
+	err = c.createK8sObject(ctx, obj)
 	if err != nil {
 		return nil, err
 	}
 	return obj, nil
 }
 
-func (c *k8sClient) getFederation(federationContextID string) (*opgv1beta1.Federation, error) {
-	obj, err := c.getKubernetesObject(federationContextID, &opgv1beta1.FederationList{}, federationContextID)
+func (c *k8sClient) getFederation(ctx context.Context, federationContextID string) (*opgv1beta1.Federation, error) {
+	obj, err := c.getKubernetesObject(ctx, federationContextID, &opgv1beta1.FederationList{}, federationContextID)
 	if err != nil {
 		return nil, err
 	}
@@ -57,16 +63,16 @@ func (c *k8sClient) getFederation(federationContextID string) (*opgv1beta1.Feder
 }
 
 func (c *k8sClient) AddAvailabilityZones(ctx context.Context, federationContextID string, azs []string) error {
-	obj, err := c.getFederation(federationContextID)
+	obj, err := c.getFederation(ctx, federationContextID)
 	if err != nil {
 		return err
 	}
 	obj.Spec.AcceptedAvailabilityZones = mergeUnique(obj.Spec.AcceptedAvailabilityZones, azs)
-	return c.updateK8sObject(obj)
+	return c.updateK8sObject(ctx, obj)
 }
 
 func (c *k8sClient) CreateFederation(ctx context.Context, input *Federation) (*Federation, error) {
-	obj, err := c.searchKubernetesObject(&opgv1beta1.FederationList{}, labels.Set{
+	obj, err := c.searchKubernetesObject(ctx, &opgv1beta1.FederationList{}, labels.Set{
 		opgLabel(clientIDLabel):      input.ClientCredentials.ClientID,
 		opgLabel(federationRelation): host,
 	})
@@ -83,7 +89,7 @@ func (c *k8sClient) CreateFederation(ctx context.Context, input *Federation) (*F
 
 	cr := input.updatek8sCustomResource(fed)
 
-	if err := c.updateK8sObject(cr); err != nil {
+	if err := c.updateK8sObject(ctx, cr); err != nil {
 		return nil, err
 	}
 
@@ -95,7 +101,7 @@ func (c *k8sClient) CreateFederation(ctx context.Context, input *Federation) (*F
 }
 
 func (c *k8sClient) GetApplication(ctx context.Context, federationContextID, id string) (*Application, error) {
-	app, err := c.getKubernetesObject(id, &opgv1beta1.ApplicationList{}, federationContextID)
+	app, err := c.getKubernetesObject(ctx, id, &opgv1beta1.ApplicationList{}, federationContextID)
 	if err != nil {
 		return nil, err
 	}
@@ -107,7 +113,7 @@ func (c *k8sClient) GetApplication(ctx context.Context, federationContextID, id
 }
 
 func (c *k8sClient) GetArtefact(ctx context.Context, federationContextID, id string) (*Artefact, error) {
-	artefact, err := c.getKubernetesObject(id, &opgv1beta1.ArtefactList{}, federationContextID)
+	artefact, err := c.getKubernetesObject(ctx, id, &opgv1beta1.ArtefactList{}, federationContextID)
 	if err != nil {
 		return nil, err
 	}
@@ -120,7 +126,7 @@ func (c *k8sClient) GetArtefact(ctx context.Context, federationContextID, id str
 
 func (c *k8sClient) GetAvailabilityZone(ctx context.Context, federationContextID, id string) (*PartnerAvailabilityZone, error) {
 	obj := &opgv1beta1.AvailabilityZone{}
-	if err := c.kubernetes.Get(context.TODO(), types.NamespacedName{Name: id, Namespace: c.getNamespace()}, obj, &k8scli.GetOptions{}); err != nil {
+	if err := c.kubernetes.Get(ctx, types.NamespacedName{Name: id, Namespace: c.getNamespace()}, obj, &k8scli.GetOptions{}); err != nil {
 		return nil, errors.Wrapf(err, "unable to find the requested az")
 	}
 	paz, err := partnerAvailabilityZoneFromK8sAvailabilityZone(obj)
@@ -131,7 +137,7 @@ func (c *k8sClient) GetAvailabilityZone(ctx context.Context, federationContextID
 }
 
 func (c *k8sClient) GetFederation(ctx context.Context, federationContextID string) (*Federation, error) {
-	obj, err := c.getFederation(federationContextID)
+	obj, err := c.getFederation(ctx, federationContextID)
 	if err != nil {
 		return nil, err
 	}
@@ -144,7 +150,7 @@ func (c *k8sClient) GetFederation(ctx context.Context, federationContextID strin
 }
 
 func (c *k8sClient) GetFile(ctx context.Context, federationContextID, id string) (*File, error) {
-	file, err := c.getKubernetesObject(id, &opgv1beta1.FileList{}, federationContextID)
+	file, err := c.getKubernetesObject(ctx, id, &opgv1beta1.FileList{}, federationContextID)
 	if err != nil {
 		return nil, err
 	}
@@ -158,7 +164,7 @@ func (c *k8sClient) GetFile(ctx context.Context, federationContextID, id string)
 func (c *k8sClient) ListAvailabilityZones(ctx context.Context) ([]*PartnerAvailabilityZone, error) {
 	azList := &opgv1beta1.AvailabilityZoneList{}
 
-	if err := c.kubernetes.List(context.TODO(), azList, &k8scli.ListOptions{Namespace: c.getNamespace()}); err != nil {
+	if err := c.kubernetes.List(ctx, azList, &k8scli.ListOptions{Namespace: c.getNamespace()}); err != nil {
 		return nil, errors.Wrapf(err, "failed to list availability zones")
 	}
 	var pazs []*PartnerAvailabilityZone
@@ -181,24 +187,118 @@ func (c *k8sClient) OnboardApplication(ctx context.Context, app *OnboardApplicat
 			}
 		}
 	}
-	opt, err := c.buildOwnerReferenceOption(app.FederationContextId)
+	fed, err := c.getFederation(ctx, app.FederationContextId)
 	if err != nil {
 		return nil, err
 	}
-	obj, err := app.k8sCustomResource(c.getNamespace(), opt)
+	obj, err := app.k8sCustomResource(c.getNamespace(), WithOwnerReference(fed, c.getScheme()))
 	if err != nil {
 		return nil, err
 	}
-	err = c.createK8sObject(obj)
+	if len(obj.Spec.Zones) == 0 {
+		// without deployment zones the application is onboarded on every accepted zone
+		obj.Spec.Zones = mergeUnique(nil, fed.Spec.AcceptedAvailabilityZones)
+	}
+	err = c.createK8sObject(ctx, obj)
 	if err != nil {
 		return nil, err
 	}
+	if err := c.createCredentialsSecret(ctx, obj, app.credentials()); err != nil {
+		return nil, err
+	}
 	return obj, nil
 }
 
+func (c *k8sClient) UpdateApplication(ctx context.Context, federationContextID, id string, update *models.UpdateApplicationJSONBody) (*Application, error) {
+	app, err := c.getApplication(ctx, federationContextID, id)
+	if err != nil {
+		return nil, err
+	}
+	for _, artefact := range updatedArtefacts(update) {
+		if _, err := c.GetArtefact(ctx, federationContextID, artefact); err != nil {
+			if IsNotFoundError(err) {
+				return nil, errors.Wrap(ErrBadRequest, err.Error())
+			}
+			return nil, err
+		}
+	}
+	if err := applyApplicationUpdate(&app.Spec, update); err != nil {
+		return nil, err
+	}
+	if err := c.updateK8sObject(ctx, app); err != nil {
+		return nil, err
+	}
+	return applicationFromK8sCustomResource(*app)
+}
+
+func (c *k8sClient) getApplication(ctx context.Context, federationContextID, id string) (*opgv1beta1.Application, error) {
+	obj, err := c.getKubernetesObject(ctx, id, &opgv1beta1.ApplicationList{}, federationContextID)
+	if err != nil {
+		return nil, err
+	}
+	app, ok := obj.(*opgv1beta1.Application)
+	if !ok {
+		return nil, missMatchErr("application", id, federationContextID, &opgv1beta1.Application{}, obj)
+	}
+	return app, nil
+}
+
+func (c *k8sClient) AddApplicationZones(ctx context.Context, federationContextID, id string, zones []string) (*Application, error) {
+	app, err := c.getApplication(ctx, federationContextID, id)
+	if err != nil {
+		return nil, err
+	}
+	app.Spec.Zones = mergeUnique(app.Spec.Zones, zones)
+	if err := c.updateK8sObject(ctx, app); err != nil {
+		return nil, err
+	}
+	return applicationFromK8sCustomResource(*app)
+}
+
+func (c *k8sClient) RemoveApplicationZone(ctx context.Context, federationContextID, id, zoneID string) error {
+	app, err := c.getApplication(ctx, federationContextID, id)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(app.Spec.Zones, zoneID) {
+		return errors.Wrapf(ErrNotFound, "application '%s' not onboarded in zone '%s'", id, zoneID)
+	}
+
+	appInsts, err := c.ListApplicationInstances(ctx, federationContextID, id, app.Spec.AppProviderId)
+	if err != nil {
+		return err
+	}
+	for _, appInst := range appInsts {
+		if appInst.ZoneId == zoneID {
+			return errors.Wrapf(ErrConflict, "zone '%s' in use by application instance '%s'", zoneID, appInst.AppInstanceId)
+		}
+	}
+
+	app.Spec.Zones = removeValues(app.Spec.Zones, []string{zoneID})
+	app.Spec.ForbiddenZones = removeValues(app.Spec.ForbiddenZones, []string{zoneID})
+	if len(app.Spec.Zones) == 0 {
+		return c.RemoveApplication(ctx, federationContextID, id)
+	}
+	return c.updateK8sObject(ctx, app)
+}
+
+func (c *k8sClient) SetApplicationZoneForbids(ctx context.Context, federationContextID, id string, forbids []ApplicationZoneForbid) (*Application, error) {
+	app, err := c.getApplication(ctx, federationContextID, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyApplicationZoneForbids(&app.Spec, forbids); err != nil {
+		return nil, err
+	}
+	if err := c.updateK8sObject(ctx, app); err != nil {
+		return nil, err
+	}
+	return applicationFromK8sCustomResource(*app)
+}
+
 func (c *k8sClient) RemoveApplication(ctx context.Context, federationContextID, id string) error {
 	appId := k8sCustomResourceNameFromApplicationID(federationContextID, id)
-	if err := c.kubernetes.Delete(context.TODO(), &opgv1beta1.Application{
+	if err := c.kubernetes.Delete(ctx, &opgv1beta1.Application{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      appId,
 			Namespace: c.getNamespace(),
@@ -211,7 +311,7 @@ func (c *k8sClient) RemoveApplication(ctx context.Context, federationContextID,
 
 func (c *k8sClient) RemoveApplicationInstance(ctx context.Context, federationContextID, id string) error {
 	appIns := k8sCustomResourceNameFromApplicationInstance(federationContextID, id)
-	if err := c.kubernetes.Delete(context.TODO(), &opgv1beta1.ApplicationInstance{
+	if err := c.kubernetes.Delete(ctx, &opgv1beta1.ApplicationInstance{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      appIns,
 			Namespace: c.getNamespace(),
@@ -224,7 +324,7 @@ func (c *k8sClient) RemoveApplicationInstance(ctx context.Context, federationCon
 
 func (c *k8sClient) RemoveArtefact(ctx context.Context, federationContextID, id string) error {
 	appIns := k8sCustomResourceNameFromArtefactID(federationContextID, id)
-	if err := c.kubernetes.Delete(context.TODO(), &opgv1beta1.Artefact{
+	if err := c.kubernetes.Delete(ctx, &opgv1beta1.Artefact{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      appIns,
 			Namespace: c.getNamespace(),
@@ -235,12 +335,55 @@ func (c *k8sClient) RemoveArtefact(ctx context.Context, federationContextID, id
 	return nil
 }
 
+func (c *k8sClient) ListApplicationInstances(ctx context.Context, federationContextID, appID, appProviderID string) ([]*ApplicationInstanceInfo, error) {
+	if _, err := c.getFederation(ctx, federationContextID); err != nil {
+		return nil, err
+	}
+	items, err := c.applicationInstances(ctx, federationContextID, appID, appProviderID)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*ApplicationInstanceInfo, 0, len(items))
+	for _, appInst := range items {
+		res = append(res, applicationInstanceInfoFromK8sCustomResource(appInst))
+	}
+	return res, nil
+}
+
+func (c *k8sClient) RemoveAvailabilityZone(ctx context.Context, federationContextID, id string) error {
+	fed, err := c.getFederation(ctx, federationContextID)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(fed.Spec.AcceptedAvailabilityZones, id) {
+		return errors.Wrapf(ErrNotFound, "availability zone '%s' not accepted in federation context '%s'", id, federationContextID)
+	}
+
+	objs, err := c.searchKubernetesObjects(ctx, &opgv1beta1.ApplicationInstanceList{}, labels.Set{
+		opgLabel(federationContextIDLabel): federationContextID,
+		opgLabel(federationRelation):       host,
+	})
+	if err != nil {
+		return err
+	}
+	for _, appInst := range objs.(*opgv1beta1.ApplicationInstanceList).Items {
+		if appInst.Spec.ZoneInfo.ZoneId == id {
+			return errors.Wrapf(ErrConflict, "availability zone '%s' in use by application instance '%s'", id, appInst.Labels[opgLabel(idLabel)])
+		}
+	}
+
+	fed.Spec.AcceptedAvailabilityZones = slices.DeleteFunc(fed.Spec.AcceptedAvailabilityZones, func(az string) bool {
+		return az == id
+	})
+	return c.updateK8sObject(ctx, fed)
+}
+
 func (c *k8sClient) RemoveFederation(ctx context.Context, federationContextID string) error {
-	obj, err := c.getFederation(federationContextID)
+	obj, err := c.getFederation(ctx, federationContextID)
 	if err != nil {
 		return err
 	}
-	if err := c.kubernetes.Delete(context.TODO(), obj, &k8scli.DeleteOptions{}); err != nil {
+	if err := c.kubernetes.Delete(ctx, obj, &k8scli.DeleteOptions{}); err != nil {
 		return errors.Wrapf(err, "unable to remove federation")
 	}
 	return nil
@@ -248,7 +391,7 @@ func (c *k8sClient) RemoveFederation(ctx context.Context, federationContextID st
 
 func (c *k8sClient) RemoveFile(ctx context.Context, federationContextID, id string) error {
 	fileID := k8sCustomResourceNameFromFileID(federationContextID, id)
-	if err := c.kubernetes.Delete(context.TODO(), &opgv1beta1.File{
+	if err := c.kubernetes.Delete(ctx, &opgv1beta1.File{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      fileID,
 			Namespace: c.getNamespace(),
@@ -261,7 +404,7 @@ func (c *k8sClient) RemoveFile(ctx context.Context, federationContextID, id stri
 
 func (c *k8sClient) UpdateFileStatus(ctx context.Context, federationCallbackID string, updates *models.FileStatusCallbackLinkJSONRequestBody) error {
 	id := updates.FileId
-	obj, err := c.getKubernetesCallbackObject(id, &opgv1beta1.FileList{}, federationCallbackID)
+	obj, err := c.getKubernetesCallbackObject(ctx, id, &opgv1beta1.FileList{}, federationCallbackID)
 	if err != nil {
 		return err
 	}
@@ -271,14 +414,14 @@ func (c *k8sClient) UpdateFileStatus(ctx context.Context, federationCallbackID s
 	}
 	state := string(updates.UpdateStatus)
 	if isValidFileStatus(state) {
-		return c.updateK8sObjectStatus(res, state)
+		return c.updateK8sObjectStatus(ctx, res, state)
 	}
 	return nil
 }
 
 func (c *k8sClient) UpdateArtefactStatus(ctx context.Context, federationCallbackID string, updates *models.ArtefactStatusCallbackLinkJSONRequestBody) error {
 	id := updates.ArtefactId
-	obj, err := c.getKubernetesCallbackObject(id, &opgv1beta1.ArtefactList{}, federationCallbackID)
+	obj, err := c.getKubernetesCallbackObject(ctx, id, &opgv1beta1.ArtefactList{}, federationCallbackID)
 	if err != nil {
 		return err
 	}
@@ -288,14 +431,14 @@ func (c *k8sClient) UpdateArtefactStatus(ctx context.Context, federationCallback
 	}
 	state := string(updates.UpdateStatus)
 	if isValidArtefactStatus(state) {
-		return c.updateK8sObjectStatus(res, state)
+		return c.updateK8sObjectStatus(ctx, res, state)
 	}
 	return nil
 }
 
 func (c *k8sClient) UpdateApplicationStatus(ctx context.Context, federationCallbackID string, updates *models.AppStatusCallbackLinkJSONRequestBody) error {
 	id := updates.AppId
-	obj, err := c.getKubernetesCallbackObject(id, &opgv1beta1.ApplicationList{}, federationCallbackID)
+	obj, err := c.getKubernetesCallbackObject(ctx, id, &opgv1beta1.ApplicationList{}, federationCallbackID)
 	if err != nil {
 		return err
 	}
@@ -303,18 +446,15 @@ func (c *k8sClient) UpdateApplicationStatus(ctx context.Context, federationCallb
 	if !ok {
 		return missMatchErr("application", id, federationCallbackID, &opgv1beta1.ApplicationInstance{}, obj)
 	}
-	if len(updates.StatusInfo) > 0 {
-		state := string(updates.StatusInfo[0].OnboardStatusInfo)
-		if isValidApplicationStatus(state) {
-			return c.updateK8sObjectStatus(res, state)
-		}
+	if applyApplicationStatusUpdate(&res.Status, updates, metav1.Now()) {
+		return c.updateK8sObjectFullStatus(ctx, res)
 	}
 	return nil
 }
 
 func (c *k8sClient) UpdateApplicationInstanceStatus(ctx context.Context, federationCallbackID string, updates *models.AppInstCallbackLinkJSONRequestBody) error {
 	id := updates.AppInstanceId
-	obj, err := c.getKubernetesCallbackObject(id, &opgv1beta1.ApplicationInstanceList{}, federationCallbackID)
+	obj, err := c.getKubernetesCallbackObject(ctx, id, &opgv1beta1.ApplicationInstanceList{}, federationCallbackID)
 	if err != nil {
 		return err
 	}
@@ -325,32 +465,87 @@ func (c *k8sClient) UpdateApplicationInstanceStatus(ctx context.Context, federat
 	if updates.AppInstanceInfo.AppInstanceState != nil {
 		state := string(*updates.AppInstanceInfo.AppInstanceState)
 		if isValidApplicationInstanceStatus(state) {
-			return c.updateK8sObjectAppInstStatus(res, updates)
+			return c.updateK8sObjectAppInstStatus(ctx, res, updates)
 		}
 	}
 	return nil
 }
 
+func (c *k8sClient) UpdateFederation(ctx context.Context, federationContextID string, update *models.UpdateFederationJSONBody) (*Federation, error) {
+	obj, err := c.getFederation(ctx, federationContextID)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyFederationUpdate(&obj.Spec.OriginOP, update); err != nil {
+		return nil, err
+	}
+	if err := c.updateK8sObject(ctx, obj); err != nil {
+		return nil, err
+	}
+	return federationFromK8sCustomResource(obj)
+}
+
 func (c *k8sClient) UpdateFederationStatus(ctx context.Context, federationCallbackID string, status models.Status) error {
-	obj, err := c.searchKubernetesObject(&opgv1beta1.FederationList{}, labels.Set{
-		opgLabel(federationCallbackIDLabel): federationCallbackID,
-		opgLabel(federationRelation):        guest,
-	})
+	res, err := c.getGuestFederation(ctx, federationCallbackID)
 	if err != nil {
 		return err
 	}
-	res, ok := obj.(*opgv1beta1.Federation)
-	if !ok {
-		return missMatchErr("federation", federationCallbackID, federationCallbackID, &opgv1beta1.Federation{}, obj)
-	}
 
 	state := string(status)
 	if isValidFederationStatus(state) {
-		return c.updateK8sObjectStatus(res, state)
+		return c.updateK8sObjectStatus(ctx, res, state)
 	}
 	return nil
 }
 
+func (c *k8sClient) UpdateFederationPartnerStatus(
+	ctx context.Context,
+	federationCallbackID string,
+	update *models.PartnerStatusLinkJSONRequestBody,
+) error {
+	res, err := c.getGuestFederation(ctx, federationCallbackID)
+	if err != nil {
+		return err
+	}
+
+	if err := applyPartnerStatusUpdate(&res.Status, update); err != nil {
+		return err
+	}
+	return c.updateK8sObjectFullStatus(ctx, res)
+}
+
+func (c *k8sClient) UpdateFederationZoneAvailability(
+	ctx context.Context,
+	federationCallbackID string,
+	update *models.AvailZoneNotifLinkJSONRequestBody,
+) error {
+	res, err := c.getGuestFederation(ctx, federationCallbackID)
+	if err != nil {
+		return err
+	}
+
+	if err := applyZoneAvailabilityUpdate(&res.Status, update, metav1.Now()); err != nil {
+		return err
+	}
+	return c.updateK8sObjectFullStatus(ctx, res)
+}
+
+// getGuestFederation retrieves the guest federation identified by its callback id.
+func (c *k8sClient) getGuestFederation(ctx context.Context, federationCallbackID string) (*opgv1beta1.Federation, error) {
+	obj, err := c.searchKubernetesObject(ctx, &opgv1beta1.FederationList{}, labels.Set{
+		opgLabel(federationCallbackIDLabel): federationCallbackID,
+		opgLabel(federationRelation):        guest,
+	})
+	if err != nil {
+		return nil, err
+	}
+	res, ok := obj.(*opgv1beta1.Federation)
+	if !ok {
+		return nil, missMatchErr("federation", federationCallbackID, federationCallbackID, &opgv1beta1.Federation{}, obj)
+	}
+	return res, nil
+}
+
 func (c *k8sClient) UploadArtefact(ctx context.Context, artefact *UploadArtefact) (*opgv1beta1.Artefact, error) {
 	for _, file := range artefact.files() {
 		if _, err := c.GetFile(ctx, artefact.FederationContextId, file); err != nil {
@@ -359,7 +554,7 @@ func (c *k8sClient) UploadArtefact(ctx context.Context, artefact *UploadArtefact
 			}
 		}
 	}
-	opt, err := c.buildOwnerReferenceOption(artefact.FederationContextId)
+	opt, err := c.buildOwnerReferenceOption(ctx, artefact.FederationContextId)
 	if err != nil {
 		return nil, err
 	}
@@ -367,7 +562,7 @@ func (c *k8sClient) UploadArtefact(ctx context.Context, artefact *UploadArtefact
 	if err != nil {
 		return nil, err
 	}
-	err = c.createK8sObject(obj)
+	err = c.createK8sObject(ctx, obj)
 	if err != nil {
 		return nil, err
 	}
@@ -375,7 +570,7 @@ func (c *k8sClient) UploadArtefact(ctx context.Context, artefact *UploadArtefact
 }
 
 func (c *k8sClient) UploadFile(ctx context.Context, file *UploadFile) (*opgv1beta1.File, error) {
-	opt, err := c.buildOwnerReferenceOption(file.FederationContextId)
+	opt, err := c.buildOwnerReferenceOption(ctx, file.FederationContextId)
 	if err != nil {
 		return nil, err
 	}
@@ -383,10 +578,13 @@ func (c *k8sClient) UploadFile(ctx context.Context, file *UploadFile) (*opgv1bet
 	if err != nil {
 		return nil, err
 	}
-	err = c.createK8sObject(obj)
+	err = c.createK8sObject(ctx, obj)
 	if err != nil {
 		return nil, err
 	}
+	if err := c.createCredentialsSecret(ctx, obj, file.credentials()); err != nil {
+		return nil, err
+	}
 	return obj, nil
 }
 
@@ -398,15 +596,255 @@ func (c *k8sClient) getScheme() *runtime.Scheme {
 	return c.kubernetes.Scheme()
 }
 
+func (c *k8sClient) getApplicationInstance(ctx context.Context, federationContextID, id string) (*opgv1beta1.ApplicationInstance, error) {
+	obj, err := c.getKubernetesObject(ctx, id, &opgv1beta1.ApplicationInstanceList{}, federationContextID)
+	if err != nil {
+		return nil, err
+	}
+	res, ok := obj.(*opgv1beta1.ApplicationInstance)
+	if !ok {
+		return nil, missMatchErr("application instance", id, federationContextID, &opgv1beta1.ApplicationInstance{}, obj)
+	}
+	return res, nil
+}
+
+func (c *k8sClient) GetApplicationInstance(ctx context.Context, federationContextID, id string) (*ApplicationInstance, error) {
+	appInst, err := c.getApplicationInstance(ctx, federationContextID, id)
+	if err != nil {
+		return nil, err
+	}
+	return applicationInstanceFromK8sCustomResource(*appInst)
+}
+
 func (c *k8sClient) GetApplicationInstanceDetails(ctx context.Context, federationContextID, id string) (*ApplicationInstanceDetails, error) {
-	//return nil, errors.Errorf("method not implemented")
-	application, err := c.getKubernetesObject(id, &opgv1beta1.ApplicationInstanceList{}, federationContextID)
+	appInst, err := c.getApplicationInstance(ctx, federationContextID, id)
+	if err != nil {
+		return nil, err
+	}
+	return applicationInstanceDetailsFromK8sCustomResource(*appInst)
+}
+
+func (c *k8sClient) getResourcePool(ctx context.Context, federationContextID, id string) (*opgv1beta1.ResourcePool, error) {
+	obj, err := c.getKubernetesObject(ctx, id, &opgv1beta1.ResourcePoolList{}, federationContextID)
+	if err != nil {
+		return nil, err
+	}
+	pool, ok := obj.(*opgv1beta1.ResourcePool)
+	if !ok {
+		return nil, missMatchErr("resource pool", id, federationContextID, &opgv1beta1.ResourcePool{}, obj)
+	}
+	return pool, nil
+}
+
+// getISVResourcePool returns the pool reserved in the zone for the app provider.
+func (c *k8sClient) getISVResourcePool(ctx context.Context, federationContextID, zoneID, appProviderID, id string) (*opgv1beta1.ResourcePool, error) {
+	pool, err := c.getResourcePool(ctx, federationContextID, id)
+	if err != nil {
+		return nil, err
+	}
+	if pool.Spec.ZoneId != zoneID || pool.Spec.AppProviderId != appProviderID {
+		return nil, errors.Wrapf(ErrNotFound, "resource pool '%s' not reserved in zone '%s' for app provider '%s'", id, zoneID, appProviderID)
+	}
+	return pool, nil
+}
+
+// resourcePoolInstances returns the application instances using the resource pool.
+func (c *k8sClient) resourcePoolInstances(ctx context.Context, federationContextID, id string) ([]opgv1beta1.ApplicationInstance, error) {
+	objs, err := c.searchKubernetesObjects(ctx, &opgv1beta1.ApplicationInstanceList{}, labels.Set{
+		opgLabel(federationContextIDLabel): federationContextID,
+		opgLabel(federationRelation):       host,
+		opgLabel(resPoolIDLabel):           id,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return objs.(*opgv1beta1.ApplicationInstanceList).Items, nil
+}
+
+// applicationInstances returns the host instances of the application appID of appProviderID.
+func (c *k8sClient) applicationInstances(ctx context.Context, federationContextID, appID, appProviderID string) ([]opgv1beta1.ApplicationInstance, error) {
+	objs, err := c.searchKubernetesObjects(ctx, &opgv1beta1.ApplicationInstanceList{}, labels.Set{
+		opgLabel(federationContextIDLabel): federationContextID,
+		opgLabel(federationRelation):       host,
+		opgLabel(appIDLabel):               appID,
+		opgLabel(appProviderIDLabel):       appProviderID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return objs.(*opgv1beta1.ApplicationInstanceList).Items, nil
+}
+
+// LabelApplicationInstances sets the app-id and app-provider labels of the host application
+// instances of namespace created before they were added, the instances of an application
+// are selected on them. It is run on startup, before the instances are listed.
+func LabelApplicationInstances(ctx context.Context, c k8scli.Client, namespace string) error {
+	list := &opgv1beta1.ApplicationInstanceList{}
+	if err := c.List(ctx, list, k8scli.InNamespace(namespace), k8scli.MatchingLabels{
+		opgLabel(federationRelation): host,
+	}); err != nil {
+		return errors.Wrap(err, "failed to list application instances")
+	}
+	for i := range list.Items {
+		appInst := &list.Items[i]
+		if appInst.Labels[opgLabel(appIDLabel)] == appInst.Spec.AppId &&
+			appInst.Labels[opgLabel(appProviderIDLabel)] == appInst.Spec.AppProviderId {
+			continue
+		}
+		appInst.Labels[opgLabel(appIDLabel)] = appInst.Spec.AppId
+		appInst.Labels[opgLabel(appProviderIDLabel)] = appInst.Spec.AppProviderId
+		// a conflicting write comes from another replica labelling it as well
+		if err := c.Update(ctx, appInst); err != nil && !apierrors.IsConflict(err) {
+			return errors.Wrapf(err, "failed to label application instance %s", appInst.Name)
+		}
+	}
+	return nil
+}
+
+func (c *k8sClient) CreateResourcePool(ctx context.Context, pool *ResourcePool) (*opgv1beta1.ResourcePool, error) {
+	if err := pool.validate(); err != nil {
+		return nil, err
+	}
+	fed, err := c.getFederation(ctx, pool.FederationContextId)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(fed.Spec.AcceptedAvailabilityZones, pool.ZoneId) {
+		return nil, errors.Wrapf(ErrBadRequest, "zone '%s' not accepted in the federation", pool.ZoneId)
+	}
+	obj, err := pool.k8sCustomResource(c.getNamespace(), WithOwnerReference(fed, c.getScheme()))
+	if err != nil {
+		return nil, err
+	}
+	if err := c.createK8sObject(ctx, obj); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+func (c *k8sClient) ListResourcePools(ctx context.Context, federationContextID, zoneID, appProviderID string) ([]ResourcePoolDetails, error) {
+	if _, err := c.getFederation(ctx, federationContextID); err != nil {
+		return nil, err
+	}
+	objs, err := c.searchKubernetesObjects(ctx, &opgv1beta1.ResourcePoolList{}, labels.Set{
+		opgLabel(federationContextIDLabel): federationContextID,
+		opgLabel(federationRelation):       host,
+		opgLabel(zoneIDLabel):              zoneID,
+		opgLabel(appProviderIDLabel):       appProviderID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	items := objs.(*opgv1beta1.ResourcePoolList).Items
+	res := make([]ResourcePoolDetails, 0, len(items))
+	for _, pool := range items {
+		res = append(res, resourcePoolDetailsFromK8sCustomResource(pool))
+	}
+	return res, nil
+}
+
+func (c *k8sClient) UpdateResourcePool(ctx context.Context, federationContextID, zoneID, appProviderID, id string, update models.UpdateISVResPoolJSONBody) error {
+	pool, err := c.getISVResourcePool(ctx, federationContextID, zoneID, appProviderID, id)
+	if err != nil {
+		return err
+	}
+	// an expired reservation isn't renewed, a new pool is reserved instead
+	if pool.Status.State == opgv1beta1.ResourcePoolStateExpired {
+		return errors.Wrapf(ErrConflict, "reservation of resource pool '%s' expired", id)
+	}
+	if err := applyResourcePoolUpdate(&pool.Spec, update); err != nil {
+		return err
+	}
+	return c.updateK8sObject(ctx, pool)
+}
+
+func (c *k8sClient) RemoveResourcePool(ctx context.Context, federationContextID, zoneID, appProviderID, id string) error {
+	pool, err := c.getISVResourcePool(ctx, federationContextID, zoneID, appProviderID, id)
+	if err != nil {
+		return err
+	}
+	appInsts, err := c.resourcePoolInstances(ctx, federationContextID, id)
+	if err != nil {
+		return err
+	}
+	if len(appInsts) > 0 {
+		return errors.Wrapf(ErrConflict, "resource pool '%s' in use by application instance '%s'", id, getObjectID(&appInsts[0]))
+	}
+	if err := c.kubernetes.Delete(ctx, pool); err != nil {
+		return errors.Wrapf(err, "unable to delete resource pool '%s'", id)
+	}
+	return nil
+}
+
+func (c *k8sClient) GetResourcePoolUsage(ctx context.Context, federationContextID, id string) (*ResourcePoolUsage, error) {
+	pool, err := c.getResourcePool(ctx, federationContextID, id)
 	if err != nil {
 		return nil, err
 	}
-	res, ok := application.(*opgv1beta1.ApplicationInstance)
+	appInsts, err := c.resourcePoolInstances(ctx, federationContextID, id)
+	if err != nil {
+		return nil, err
+	}
+	usage := &ResourcePoolUsage{
+		ZoneId:        pool.Spec.ZoneId,
+		AppProviderId: pool.Spec.AppProviderId,
+		State:         pool.Status.State,
+		Granted:       map[models.FlavourId]int32{},
+		Used:          map[models.FlavourId]int32{},
+	}
+	for _, f := range pool.Status.GrantedFlavours {
+		usage.Granted[f.FlavourId] += f.NumFlavour
+	}
+	for _, appInst := range appInsts {
+		usage.Used[appInst.Spec.ZoneInfo.FlavourId]++
+	}
+	return usage, nil
+}
+
+func (c *k8sClient) UpdateResourcePoolStatus(ctx context.Context, federationCallbackID string, updates *models.ResourceReservationCallbackLinkJSONRequestBody) error {
+	id := updates.PoolId
+	obj, err := c.getKubernetesCallbackObject(ctx, id, &opgv1beta1.ResourcePoolList{}, federationCallbackID)
+	if err != nil {
+		return err
+	}
+	res, ok := obj.(*opgv1beta1.ResourcePool)
 	if !ok {
-		return nil, missMatchErr("application instance", id, federationContextID, &opgv1beta1.ApplicationInstance{}, application)
+		return missMatchErr("resource pool", id, federationCallbackID, &opgv1beta1.ResourcePool{}, obj)
+	}
+	applyResourcePoolCallback(&res.Status, updates, metav1.Now())
+	return c.updateK8sObjectFullStatus(ctx, res)
+}
+
+// GetCandidateZones returns the zones accepted in the federation where the application
+// is onboarded and not forbidden, ranked by their distance to the client.
+func (c *k8sClient) GetCandidateZones(ctx context.Context, federationContextID string, req *models.GetCandidateZonesJSONBody) (models.DiscoveredEdgeNodes, error) {
+	lat, long, located, err := clientGeoLocation(req)
+	if err != nil {
+		return nil, err
 	}
-	return applicationInstanceFromK8sCustomResource(id, *res)
+	fed, err := c.getFederation(ctx, federationContextID)
+	if err != nil {
+		return nil, err
+	}
+	app, err := c.getApplication(ctx, federationContextID, req.AppId)
+	if err != nil {
+		return nil, err
+	}
+	if app.Spec.AppProviderId != req.AppProviderId {
+		return nil, errors.Wrapf(ErrNotFound, "application '%s' not onboarded for app provider '%s'", req.AppId, req.AppProviderId)
+	}
+	zones := slices.DeleteFunc(slices.Clone(app.Spec.Zones), func(zoneID string) bool {
+		return !slices.Contains(fed.Spec.AcceptedAvailabilityZones, zoneID) || slices.Contains(app.Spec.ForbiddenZones, zoneID)
+	})
+
+	azList := &opgv1beta1.AvailabilityZoneList{}
+	if err := c.kubernetes.List(ctx, azList, &k8scli.ListOptions{Namespace: c.getNamespace()}); err != nil {
+		return nil, errors.Wrapf(err, "failed to list availability zones")
+	}
+	appInsts, err := c.applicationInstances(ctx, federationContextID, req.AppId, req.AppProviderId)
+	if err != nil {
+		return nil, err
+	}
+
+	return discoveredEdgeNodesFromCandidates(candidateZones(zones, azList.Items, appInsts, lat, long, located)), nil
 }
@@ -0,0 +1,156 @@
+package metastore
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	k8scli "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/neonephos-katalis/opg-ewbi-operator/api/ewbi/models"
+	opgv1beta1 "github.com/neonephos-katalis/opg-ewbi-operator/api/operator/v1beta1"
+)
+
+const (
+	testNamespace           = "federation"
+	testFederationContextID = "fed-ctx-1"
+)
+
+func newTestK8sClient(t *testing.T, objs ...k8scli.Object) *k8sClient {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, opgv1beta1.AddToScheme(scheme))
+	c := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objs...).
+		WithStatusSubresource(
+			&opgv1beta1.Federation{}, &opgv1beta1.File{}, &opgv1beta1.Artefact{},
+			&opgv1beta1.Application{}, &opgv1beta1.ApplicationInstance{}, &opgv1beta1.ResourcePool{},
+			&opgv1beta1.AvailabilityZone{},
+		).
+		Build()
+	return NewK8sClient(c, testNamespace)
+}
+
+func newTestHostFederation(federationContextID string) *opgv1beta1.Federation {
+	return &opgv1beta1.Federation{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "federation-" + federationContextID,
+			Namespace: testNamespace,
+			Labels: map[string]string{
+				opgLabel(federationContextIDLabel): federationContextID,
+				opgLabel(idLabel):                  federationContextID,
+				opgLabel(federationRelation):       host,
+			},
+		},
+	}
+}
+
+func newTestApplicationInstance(t *testing.T, id, appID, appProviderID, zoneID string, state opgv1beta1.ApplicationInstanceState) *opgv1beta1.ApplicationInstance {
+	appInst := &ApplicationInstance{
+		InstallAppJSONBody: &models.InstallAppJSONBody{
+			AppInstanceId: id,
+			AppId:         appID,
+			AppProviderId: appProviderID,
+		},
+		FederationContextId: testFederationContextID,
+	}
+	appInst.ZoneInfo.ZoneId = zoneID
+	obj, err := appInst.k8sCustomResource(testNamespace)
+	require.NoError(t, err)
+	obj.Status.State = state
+	return obj
+}
+
+func Test_k8sClient_ListApplicationInstances(t *testing.T) {
+	// created before the app-id and app-provider labels were added, labelled on startup
+	unlabelled := newTestApplicationInstance(t, "inst-5", "app-1", "provider-1", "zone-3", opgv1beta1.ApplicationInstanceStateReady)
+	delete(unlabelled.Labels, opgLabel(appIDLabel))
+	delete(unlabelled.Labels, opgLabel(appProviderIDLabel))
+	c := newTestK8sClient(t,
+		newTestHostFederation(testFederationContextID),
+		unlabelled,
+		newTestApplicationInstance(t, "inst-1", "app-1", "provider-1", "zone-1", opgv1beta1.ApplicationInstanceStateReady),
+		newTestApplicationInstance(t, "inst-2", "app-1", "provider-1", "zone-2", ""),
+		newTestApplicationInstance(t, "inst-3", "app-2", "provider-1", "zone-1", opgv1beta1.ApplicationInstanceStateReady),
+		newTestApplicationInstance(t, "inst-4", "app-1", "provider-2", "zone-1", opgv1beta1.ApplicationInstanceStateReady),
+	)
+	require.NoError(t, LabelApplicationInstances(context.Background(), c.kubernetes, testNamespace))
+
+	tests := []struct {
+		name                string
+		federationContextID string
+		appID               string
+		appProviderID       string
+		want                []*ApplicationInstanceInfo
+		wantErr             error
+	}{
+		{
+			name:                "Instances of the app and app provider",
+			federationContextID: testFederationContextID,
+			appID:               "app-1",
+			appProviderID:       "provider-1",
+			want: []*ApplicationInstanceInfo{
+				{AppInstanceId: "inst-1", ZoneId: "zone-1", State: models.InstanceStateREADY},
+				{AppInstanceId: "inst-2", ZoneId: "zone-2", State: models.InstanceStatePENDING},
+				{AppInstanceId: "inst-5", ZoneId: "zone-3", State: models.InstanceStateREADY},
+			},
+		},
+		{
+			name:                "No instances",
+			federationContextID: testFederationContextID,
+			appID:               "app-3",
+			appProviderID:       "provider-1",
+			want:                []*ApplicationInstanceInfo{},
+		},
+		{
+			name:                "Unknown federation",
+			federationContextID: "unknown",
+			appID:               "app-1",
+			appProviderID:       "provider-1",
+			wantErr:             ErrNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := c.ListApplicationInstances(context.Background(), tt.federationContextID, tt.appID, tt.appProviderID)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.ElementsMatch(t, tt.want, got)
+		})
+	}
+}
+
+func Test_k8sClient_GetApplicationInstance(t *testing.T) {
+	obj := newTestApplicationInstance(t, "inst-1", "app-1", "provider-1", "zone-1", opgv1beta1.ApplicationInstanceStateReady)
+	obj.Status.AccessPointInfo = []opgv1beta1.AccessPointInfo{{
+		InterfaceId:  "http",
+		AccessPoints: opgv1beta1.AccessPoints{Port: 8080, Fqdn: "app.zone-1.partner.com"},
+	}}
+	c := newTestK8sClient(t, newTestHostFederation(testFederationContextID), obj)
+
+	appInst, err := c.GetApplicationInstance(context.Background(), testFederationContextID, "inst-1")
+	require.NoError(t, err)
+	require.Equal(t, "app-1", appInst.AppId)
+	require.Equal(t, "provider-1", appInst.AppProviderId)
+	require.Equal(t, "zone-1", appInst.ZoneInfo.ZoneId)
+	require.Equal(t, testFederationContextID, appInst.FederationContextId)
+
+	details, err := c.GetApplicationInstanceDetails(context.Background(), testFederationContextID, "inst-1")
+	require.NoError(t, err)
+	require.Equal(t, models.InstanceStateREADY, *details.AppInstanceState)
+	require.Len(t, *details.AccessPointInfo, 1)
+	require.Equal(t, "http", (*details.AccessPointInfo)[0].InterfaceId)
+	require.Equal(t, 8080, (*details.AccessPointInfo)[0].AccessPoints.Port)
+
+	_, err = c.GetApplicationInstance(context.Background(), testFederationContextID, "inst-2")
+	require.ErrorIs(t, err, ErrNotFound)
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
@@ -32,16 +33,16 @@ func WithOwnerReference(owner metav1.Object, scheme *runtime.Scheme) Opt {
 
 // buildOwnerReferenceOption generates an Opt function that sets the owner reference
 // of a Kubernetes Custom Resource to the specified Federation in a k8s object.
-func (c *k8sClient) buildOwnerReferenceOption(federationContextID string) (Opt, error) {
-	federation, err := c.getKubernetesObject(federationContextID, &opgv1beta1.FederationList{}, federationContextID)
+func (c *k8sClient) buildOwnerReferenceOption(ctx context.Context, federationContextID string) (Opt, error) {
+	federation, err := c.getKubernetesObject(ctx, federationContextID, &opgv1beta1.FederationList{}, federationContextID)
 	if err != nil {
 		return nil, err
 	}
 	return WithOwnerReference(federation, c.getScheme()), nil
 }
 
-func (c *k8sClient) createK8sObject(object k8scli.Object) error {
-	if err := c.kubernetes.Create(context.TODO(), object, &k8scli.CreateOptions{}); err != nil {
+func (c *k8sClient) createK8sObject(ctx context.Context, object k8scli.Object) error {
+	if err := c.kubernetes.Create(ctx, object, &k8scli.CreateOptions{}); err != nil {
 		errDetails := fmt.Sprintf("Failed to create %s (ID: %s)", getObjectKind(object), getObjectID(object))
 		log.WithError(err).Error(errDetails)
 		if k8serrors.IsAlreadyExists(err) {
@@ -52,10 +53,33 @@ func (c *k8sClient) createK8sObject(object k8scli.Object) error {
 	return nil
 }
 
+// The API server shares the service account of the manager, it creates the Secrets
+// of the credentials received in the requests.
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=create,namespace=foo
+
+// createCredentialsSecret creates the Secret holding the credentials of owner,
+// owner is removed when the Secret can't be created so no reference is left dangling.
+func (c *k8sClient) createCredentialsSecret(ctx context.Context, owner k8scli.Object, creds credentialsSecret) error {
+	if len(creds) == 0 {
+		return nil
+	}
+	secret, err := creds.k8sSecret(owner, WithOwnerReference(owner, c.getScheme()))
+	if err == nil {
+		err = c.createK8sObject(ctx, secret)
+	}
+	if err != nil {
+		if delErr := c.kubernetes.Delete(ctx, owner); delErr != nil {
+			log.WithError(delErr).Errorf("Failed to remove %s (ID: %s)", getObjectKind(owner), getObjectID(owner))
+		}
+		return err
+	}
+	return nil
+}
+
 // getKubernetesCallbackObject retrieves a Kubernetes object by id and federation callback id.
 // It retrieve the objects searching for the id and federation callback labels.
-func (c *k8sClient) getKubernetesCallbackObject(identifier string, objectList k8scli.ObjectList, fedCallbackID string) (k8scli.Object, error) {
-	return c.searchKubernetesObject(objectList, labels.Set{
+func (c *k8sClient) getKubernetesCallbackObject(ctx context.Context, identifier string, objectList k8scli.ObjectList, fedCallbackID string) (k8scli.Object, error) {
+	return c.searchKubernetesObject(ctx, objectList, labels.Set{
 		opgLabel(federationCallbackIDLabel): fedCallbackID,
 		opgLabel(idLabel):                   identifier,
 		opgLabel(federationRelation):        guest,
@@ -64,8 +88,8 @@ func (c *k8sClient) getKubernetesCallbackObject(identifier string, objectList k8
 
 // getKubernetesObject retrieves a Kubernetes object by id and federation context id.
 // It retrieve the objects searching for the id and federation context labels.
-func (c *k8sClient) getKubernetesObject(identifier string, objectList k8scli.ObjectList, fedContextID string) (k8scli.Object, error) {
-	return c.searchKubernetesObject(objectList, labels.Set{
+func (c *k8sClient) getKubernetesObject(ctx context.Context, identifier string, objectList k8scli.ObjectList, fedContextID string) (k8scli.Object, error) {
+	return c.searchKubernetesObject(ctx, objectList, labels.Set{
 		opgLabel(federationContextIDLabel): fedContextID,
 		opgLabel(idLabel):                  identifier,
 		opgLabel(federationRelation):       host,
@@ -74,8 +98,8 @@ func (c *k8sClient) getKubernetesObject(identifier string, objectList k8scli.Obj
 
 // searchKubernetesObject searches for a Kubernetes object using the specified labels.
 // If multiple objects match, it returns the first one.
-func (c *k8sClient) searchKubernetesObject(objectList k8scli.ObjectList, searchLabels labels.Set) (k8scli.Object, error) {
-	objectList, err := c.searchKubernetesObjects(objectList, searchLabels)
+func (c *k8sClient) searchKubernetesObject(ctx context.Context, objectList k8scli.ObjectList, searchLabels labels.Set) (k8scli.Object, error) {
+	objectList, err := c.searchKubernetesObjects(ctx, objectList, searchLabels)
 	if err != nil {
 		log.Errorf("failed to searchKubernetesObjects with labels '%v'", searchLabels)
 		return nil, err
@@ -91,11 +115,11 @@ func (c *k8sClient) searchKubernetesObject(objectList k8scli.ObjectList, searchL
 }
 
 // searchKubernetesObject searches for a Kubernetes object using the specified labels.
-func (c *k8sClient) searchKubernetesObjects(objectList k8scli.ObjectList, searchLabels labels.Set) (k8scli.ObjectList, error) {
+func (c *k8sClient) searchKubernetesObjects(ctx context.Context, objectList k8scli.ObjectList, searchLabels labels.Set) (k8scli.ObjectList, error) {
 	kind := getListKind(objectList)
 	selector := labels.SelectorFromSet(searchLabels)
 
-	err := c.kubernetes.List(context.TODO(), objectList, &k8scli.ListOptions{
+	err := c.kubernetes.List(ctx, objectList, &k8scli.ListOptions{
 		Namespace:     c.getNamespace(),
 		LabelSelector: selector,
 	})
@@ -107,18 +131,18 @@ func (c *k8sClient) searchKubernetesObjects(objectList k8scli.ObjectList, search
 	return objectList, nil
 }
 
-func (c *k8sClient) updateK8sObject(object k8scli.Object) error {
-	if err := c.kubernetes.Update(context.TODO(), object, &k8scli.UpdateOptions{}); err != nil {
+func (c *k8sClient) updateK8sObject(ctx context.Context, object k8scli.Object) error {
+	if err := c.kubernetes.Update(ctx, object, &k8scli.UpdateOptions{}); err != nil {
 		return errors.Wrapf(err, "unable to update object %T", object)
 	}
 	return nil
 }
 
-func (c *k8sClient) updateK8sObjectStatus(object k8scli.Object, status string) error {
+func (c *k8sClient) updateK8sObjectStatus(ctx context.Context, object k8scli.Object, status string) error {
 	patch := []byte(fmt.Sprintf(`{"status":{"state":"%s"}}`, status)) // JSON Patch
 
 	if err := c.kubernetes.Status().Patch(
-		context.TODO(),
+		ctx,
 		object,
 		k8scli.RawPatch(k8scli.Merge.Type(), patch),
 		&k8scli.SubResourcePatchOptions{},
@@ -128,7 +152,15 @@ func (c *k8sClient) updateK8sObjectStatus(object k8scli.Object, status string) e
 	return nil
 }
 
-func (c *k8sClient) updateK8sObjectAppInstStatus(object k8scli.Object, updates *models.AppInstCallbackLinkJSONRequestBody) (err error) {
+// updateK8sObjectFullStatus replaces the whole status subresource of the object.
+func (c *k8sClient) updateK8sObjectFullStatus(ctx context.Context, object k8scli.Object) error {
+	if err := c.kubernetes.Status().Update(ctx, object, &k8scli.SubResourceUpdateOptions{}); err != nil {
+		return errors.Wrapf(err, "unable to update object status %T", object)
+	}
+	return nil
+}
+
+func (c *k8sClient) updateK8sObjectAppInstStatus(ctx context.Context, object k8scli.Object, updates *models.AppInstCallbackLinkJSONRequestBody) (err error) {
 	info := updates.AppInstanceInfo
 	var patch struct {
 		AccessPointInfo *models.AccessPointInfo `json:"accessPointInfo,omitempty"`
@@ -146,7 +178,7 @@ func (c *k8sClient) updateK8sObjectAppInstStatus(object k8scli.Object, updates *
 	}
 
 	if err := c.kubernetes.Status().Patch(
-		context.TODO(),
+		ctx,
 		object,
 		k8scli.RawPatch(types.MergePatchType, patchBytes), // Usa types.MergePatchType
 		&k8scli.SubResourcePatchOptions{},
@@ -190,6 +222,11 @@ func getFirstItemFromObjectList(list k8scli.ObjectList) (k8scli.Object, error) {
 			return nil, fmt.Errorf("no '%s' items found", kind)
 		}
 		return &typedList.Items[0], nil
+	case *opgv1beta1.ResourcePoolList:
+		if len(typedList.Items) == 0 {
+			return nil, fmt.Errorf("no '%s' items found", kind)
+		}
+		return &typedList.Items[0], nil
 	default:
 		return nil, fmt.Errorf("unsupported list type: %T", list)
 	}
@@ -209,6 +246,8 @@ func getListKind(list k8scli.ObjectList) string {
 		return federationKind
 	case *opgv1beta1.FileList:
 		return fileKind
+	case *opgv1beta1.ResourcePoolList:
+		return resourcePoolKind
 	default:
 		return "Unknown"
 	}
@@ -228,6 +267,10 @@ func getObjectKind(obj k8scli.Object) string {
 		return federationKind
 	case *opgv1beta1.File:
 		return fileKind
+	case *opgv1beta1.ResourcePool:
+		return resourcePoolKind
+	case *corev1.Secret:
+		return secretKind
 	default:
 		return "Unknown"
 	}
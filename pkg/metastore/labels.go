@@ -7,12 +7,16 @@ const (
 type labelKey string
 
 const (
+	appIDLabel                labelKey = "app-id"
+	appProviderIDLabel        labelKey = "app-provider"
 	clientIDLabel             labelKey = "origin-client-id"
 	federationCallbackIDLabel labelKey = "federation-callback-id"
 	federationContextIDLabel  labelKey = "federation-context-id"
 	federationRelation        labelKey = "federation-relation"
 	idLabel                   labelKey = "id"
 	kindLabel                 labelKey = "kind"
+	resPoolIDLabel            labelKey = "res-pool"
+	zoneIDLabel               labelKey = "zone-id"
 )
 
 const (
@@ -23,6 +27,8 @@ const (
 	availabilityZoneKind      string = "availabilityZone"
 	federationKind            string = "federation"
 	fileKind                  string = "file"
+	resourcePoolKind          string = "resourcePool"
+	resourcePoolPrefix        string = "resource-pool"
 )
 
 const (
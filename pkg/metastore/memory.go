@@ -0,0 +1,113 @@
+package metastore
+
+import (
+	"cmp"
+	"context"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+
+	k8scli "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// NewMemoryClient returns a Client keeping the resources of namespace in memory,
+// seeded with objs. The resources are lost when the process exits, it is meant
+// for tests and for running the API server without a Kubernetes cluster.
+func NewMemoryClient(namespace string, objs ...k8scli.Object) (*k8sClient, error) {
+	return newStoreClient(context.Background(), &memoryStore{objects: map[objectKey]storedObject{}}, namespace, objs...)
+}
+
+// memoryStore, an objectStore holding the objects in a map
+type memoryStore struct {
+	mutex           sync.Mutex
+	objects         map[objectKey]storedObject
+	resourceVersion int64
+}
+
+func (s *memoryStore) get(_ context.Context, key objectKey) (*storedObject, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	obj, ok := s.objects[key]
+	if !ok {
+		return nil, errStoreNotFound
+	}
+	return &obj, nil
+}
+
+func (s *memoryStore) list(_ context.Context, kind, namespace string, labels map[string]string) ([]*storedObject, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var res []*storedObject
+	for key, obj := range s.objects {
+		if kind != "" && key.kind != kind || namespace != "" && key.namespace != namespace {
+			continue
+		}
+		if !hasLabels(obj.labels, labels) {
+			continue
+		}
+		res = append(res, &obj)
+	}
+	slices.SortFunc(res, func(a, b *storedObject) int {
+		return compareObjectKeys(a.key, b.key)
+	})
+	return res, nil
+}
+
+func (s *memoryStore) create(_ context.Context, obj *storedObject) (int64, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, ok := s.objects[obj.key]; ok {
+		return 0, errStoreAlreadyExists
+	}
+	return s.write(obj), nil
+}
+
+func (s *memoryStore) update(_ context.Context, obj *storedObject, resourceVersion int64) (int64, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	current, ok := s.objects[obj.key]
+	if !ok {
+		return 0, errStoreNotFound
+	}
+	if current.resourceVersion != resourceVersion {
+		return 0, errStoreConflict
+	}
+	return s.write(obj), nil
+}
+
+func (s *memoryStore) delete(_ context.Context, key objectKey) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, ok := s.objects[key]; !ok {
+		return errStoreNotFound
+	}
+	delete(s.objects, key)
+	return nil
+}
+
+// write stores a copy of obj with the next resource version, the mutex must be held
+func (s *memoryStore) write(obj *storedObject) int64 {
+	s.resourceVersion++
+	s.objects[obj.key] = storedObject{
+		key:             obj.key,
+		resourceVersion: s.resourceVersion,
+		labels:          maps.Clone(obj.labels),
+		data:            slices.Clone(obj.data),
+	}
+	return s.resourceVersion
+}
+
+// hasLabels returns whether all of want are set in labels
+func hasLabels(labels, want map[string]string) bool {
+	for k, v := range want {
+		if l, ok := labels[k]; !ok || l != v {
+			return false
+		}
+	}
+	return true
+}
+
+func compareObjectKeys(a, b objectKey) int {
+	return cmp.Or(strings.Compare(a.kind, b.kind), strings.Compare(a.namespace, b.namespace), strings.Compare(a.name, b.name))
+}
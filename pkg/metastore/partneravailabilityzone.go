@@ -33,6 +33,22 @@ func mergeUnique(slice1, slice2 []string) []string {
 	return result
 }
 
+// removeValues returns the elements of slice not present in values,
+// preserving their order.
+func removeValues(slice, values []string) []string {
+	remove := make(map[string]bool, len(values))
+	for _, val := range values {
+		remove[val] = true
+	}
+	result := []string{}
+	for _, val := range slice {
+		if !remove[val] {
+			result = append(result, val)
+		}
+	}
+	return result
+}
+
 func partnerAvailabilityZoneFromK8sAvailabilityZone(az *opgv1beta1.AvailabilityZone) (*PartnerAvailabilityZone, error) {
 	return &PartnerAvailabilityZone{
 		ZoneDetails: &models.ZoneDetails{
@@ -0,0 +1,214 @@
+package metastore
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/neonephos-katalis/opg-ewbi-operator/api/ewbi/models"
+	opgv1beta1 "github.com/neonephos-katalis/opg-ewbi-operator/api/operator/v1beta1"
+)
+
+type ResourcePool struct {
+	*models.CreateResourcePoolsJSONBody
+	FederationContextId models.FederationContextId
+	ZoneId              models.ZoneIdentifier
+	AppProviderId       models.AppProviderId
+}
+
+// ResourcePoolDetails is an item of the ViewISVResPool response.
+type ResourcePoolDetails = struct {
+	PoolName         models.PoolName                     `json:"poolName"`
+	ReservationTime  *time.Time                          `json:"reservationTime,omitempty"`
+	ReserveDuration  *models.ResourceReservationDuration `json:"reserveDuration,omitempty"`
+	ReservedFlavours []struct {
+		Count     int32            `json:"count"`
+		FlavourId models.FlavourId `json:"flavourId"`
+	} `json:"reservedFlavours"`
+	ReservedPoolId models.PoolId `json:"reservedPoolId"`
+}
+
+// ResourcePoolUsage is the number of flavours of a resource pool granted by the
+// reservation and in use by application instances.
+type ResourcePoolUsage struct {
+	ZoneId        models.ZoneIdentifier
+	AppProviderId models.AppProviderId
+	State         opgv1beta1.ResourcePoolState
+	Granted       map[models.FlavourId]int32
+	Used          map[models.FlavourId]int32
+}
+
+// Remaining returns the number of flavours of the pool still available.
+func (u *ResourcePoolUsage) Remaining(flavourID models.FlavourId) int32 {
+	if u.State != opgv1beta1.ResourcePoolStateReserved {
+		return 0
+	}
+	return max(u.Granted[flavourID]-u.Used[flavourID], 0)
+}
+
+func (p *ResourcePool) validate() error {
+	req := p.ResRequest
+	if req.PoolId == "" {
+		return errors.Wrap(ErrBadRequest, "poolId is required")
+	}
+	if len(req.Flavours) == 0 {
+		return errors.Wrap(ErrBadRequest, "no flavours to reserve")
+	}
+	for _, f := range req.Flavours {
+		if f.NumFlavour <= 0 {
+			return errors.Wrapf(ErrBadRequest, "invalid number of flavours '%s'", f.FlavourId)
+		}
+		if f.MinNumOfFlavours != nil && *f.MinNumOfFlavours > f.NumFlavour {
+			return errors.Wrapf(ErrBadRequest, "minimum number of flavours '%s' above the number requested", f.FlavourId)
+		}
+	}
+	if reserveDurationFromModel(req.ReserveDuration) == (opgv1beta1.ReserveDuration{}) {
+		return errors.Wrap(ErrBadRequest, "reserveDuration is required")
+	}
+	return nil
+}
+
+func (p *ResourcePool) k8sCustomResource(namespace string, opts ...Opt) (*opgv1beta1.ResourcePool, error) {
+	flavours := make([]opgv1beta1.PoolFlavour, 0, len(p.ResRequest.Flavours))
+	for _, f := range p.ResRequest.Flavours {
+		flavours = append(flavours, opgv1beta1.PoolFlavour{
+			FlavourId:     f.FlavourId,
+			NumFlavour:    f.NumFlavour,
+			MinNumFlavour: defaultIfNil(f.MinNumOfFlavours),
+		})
+	}
+	obj := &opgv1beta1.ResourcePool{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      k8sCustomResourceNameFromResourcePoolID(p.FederationContextId, p.ResRequest.PoolId),
+			Namespace: namespace,
+			Labels: map[string]string{
+				opgLabel(federationContextIDLabel): p.FederationContextId,
+				opgLabel(idLabel):                  p.ResRequest.PoolId,
+				opgLabel(federationRelation):       host,
+				opgLabel(zoneIDLabel):              p.ZoneId,
+				opgLabel(appProviderIDLabel):       p.AppProviderId,
+			},
+		},
+		Spec: opgv1beta1.ResourcePoolSpec{
+			AppProviderId:   p.AppProviderId,
+			ZoneId:          p.ZoneId,
+			PoolName:        p.ResRequest.PoolName,
+			ReserveDuration: reserveDurationFromModel(p.ResRequest.ReserveDuration),
+			Flavours:        flavours,
+			CallBackLink:    p.ResourceReservationCallbackLink,
+		},
+	}
+	for _, opt := range opts {
+		if err := opt(&obj.ObjectMeta); err != nil {
+			return nil, err
+		}
+	}
+	return obj, nil
+}
+
+func k8sCustomResourceNameFromResourcePoolID(federationContextID, poolID string) string {
+	return fmt.Sprintf("%s-%s", resourcePoolPrefix, uuidV5Fn(federationContextID+"/"+poolID))
+}
+
+func reserveDurationFromModel(d models.ResourceReservationDuration) opgv1beta1.ReserveDuration {
+	return opgv1beta1.ReserveDuration{
+		Days:   defaultIfNil(d.NumOfDays),
+		Months: defaultIfNil(d.NumOfMonths),
+		Years:  defaultIfNil(d.NumOfYears),
+	}
+}
+
+func reserveDurationToModel(d opgv1beta1.ReserveDuration) *models.ResourceReservationDuration {
+	return &models.ResourceReservationDuration{
+		NumOfDays:   &d.Days,
+		NumOfMonths: &d.Months,
+		NumOfYears:  &d.Years,
+	}
+}
+
+// resourcePoolDetailsFromK8sCustomResource returns the flavours reserved for the pool,
+// none until the partner OP grants them.
+func resourcePoolDetailsFromK8sCustomResource(pool opgv1beta1.ResourcePool) ResourcePoolDetails {
+	details := ResourcePoolDetails{
+		PoolName:        pool.Spec.PoolName,
+		ReserveDuration: reserveDurationToModel(pool.Spec.ReserveDuration),
+		ReservedPoolId:  pool.Labels[opgLabel(idLabel)],
+	}
+	if pool.Status.ReservationTime != nil {
+		details.ReservationTime = &pool.Status.ReservationTime.Time
+	}
+	details.ReservedFlavours = make([]struct {
+		Count     int32            `json:"count"`
+		FlavourId models.FlavourId `json:"flavourId"`
+	}, 0, len(pool.Status.GrantedFlavours))
+	for _, f := range pool.Status.GrantedFlavours {
+		details.ReservedFlavours = append(details.ReservedFlavours, struct {
+			Count     int32            `json:"count"`
+			FlavourId models.FlavourId `json:"flavourId"`
+		}{Count: f.NumFlavour, FlavourId: f.FlavourId})
+	}
+	return details
+}
+
+// applyResourcePoolUpdate applies an UpdateISVResPool request to the pool spec. The count
+// of a flavour is its final number, flavours with count 0 are removed from the pool.
+func applyResourcePoolUpdate(spec *opgv1beta1.ResourcePoolSpec, update models.UpdateISVResPoolJSONBody) error {
+	for _, u := range update {
+		switch u.UpdateType {
+		case models.UpdateISVResPoolJSONBodyUpdateTypeDURATION:
+			if u.ReserveDuration == nil {
+				return errors.Wrap(ErrBadRequest, "reserveDuration is required")
+			}
+			spec.ReserveDuration = reserveDurationFromModel(*u.ReserveDuration)
+			continue
+		case models.UpdateISVResPoolJSONBodyUpdateTypeADD, models.UpdateISVResPoolJSONBodyUpdateTypeREMOVE:
+		default:
+			return errors.Wrapf(ErrBadRequest, "invalid updateType '%s'", u.UpdateType)
+		}
+		if u.Count < 0 {
+			return errors.Wrapf(ErrBadRequest, "invalid number of flavours '%s'", u.FlavourId)
+		}
+		i := slices.IndexFunc(spec.Flavours, func(f opgv1beta1.PoolFlavour) bool {
+			return f.FlavourId == u.FlavourId
+		})
+		switch {
+		case u.Count == 0 && i >= 0:
+			spec.Flavours = slices.Delete(spec.Flavours, i, i+1)
+		case u.Count == 0:
+		case i >= 0:
+			spec.Flavours[i].NumFlavour = u.Count
+			spec.Flavours[i].MinNumFlavour = min(spec.Flavours[i].MinNumFlavour, u.Count)
+		default:
+			spec.Flavours = append(spec.Flavours, opgv1beta1.PoolFlavour{FlavourId: u.FlavourId, NumFlavour: u.Count})
+		}
+	}
+	if len(spec.Flavours) == 0 {
+		return errors.Wrap(ErrBadRequest, "a resource pool can't be left without flavours, remove it instead")
+	}
+	return nil
+}
+
+// applyResourcePoolCallback applies the flavours granted by the partner OP to the pool
+// status, a pool without granted flavours is no longer reserved.
+func applyResourcePoolCallback(status *opgv1beta1.ResourcePoolStatus, updates *models.ResourceReservationCallbackLinkJSONRequestBody, now metav1.Time) {
+	status.GrantedFlavours = make([]opgv1beta1.GrantedFlavour, 0, len(updates.GrantedFlavours))
+	for _, f := range updates.GrantedFlavours {
+		if f.NumFlavour > 0 {
+			status.GrantedFlavours = append(status.GrantedFlavours, opgv1beta1.GrantedFlavour{
+				FlavourId:  f.FlavourId,
+				NumFlavour: f.NumFlavour,
+			})
+		}
+	}
+	if len(status.GrantedFlavours) == 0 {
+		status.State = opgv1beta1.ResourcePoolStateExpired
+		return
+	}
+	if status.State != opgv1beta1.ResourcePoolStateReserved {
+		status.ReservationTime = &now
+	}
+	status.State = opgv1beta1.ResourcePoolStateReserved
+}
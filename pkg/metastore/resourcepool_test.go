@@ -0,0 +1,220 @@
+package metastore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/neonephos-katalis/opg-ewbi-operator/api/ewbi/models"
+	opgv1beta1 "github.com/neonephos-katalis/opg-ewbi-operator/api/operator/v1beta1"
+)
+
+type testPoolFlavour = struct {
+	FlavourId        models.FlavourId `json:"flavourId"`
+	MinNumOfFlavours *int32           `json:"minNumOfFlavours,omitempty"`
+	NumFlavour       int32            `json:"numFlavour"`
+}
+
+type testPoolUpdate = struct {
+	Count           int32                                     `json:"count"`
+	FlavourId       models.FlavourId                          `json:"flavourId"`
+	ReserveDuration *models.ResourceReservationDuration       `json:"reserveDuration,omitempty"`
+	UpdateType      models.UpdateISVResPoolJSONBodyUpdateType `json:"updateType"`
+}
+
+func newTestResourcePool(poolID, zoneID, appProviderID string, flavours ...testPoolFlavour) *ResourcePool {
+	days := int32(10)
+	pool := &ResourcePool{
+		CreateResourcePoolsJSONBody: &models.CreateResourcePoolsJSONBody{},
+		FederationContextId:         testFederationContextID,
+		ZoneId:                      zoneID,
+		AppProviderId:               appProviderID,
+	}
+	pool.ResRequest.PoolId = poolID
+	pool.ResRequest.PoolName = "pool"
+	pool.ResRequest.ReserveDuration.NumOfDays = &days
+	pool.ResRequest.Flavours = flavours
+	return pool
+}
+
+func Test_applyResourcePoolUpdate(t *testing.T) {
+	spec := func() opgv1beta1.ResourcePoolSpec {
+		return opgv1beta1.ResourcePoolSpec{
+			ReserveDuration: opgv1beta1.ReserveDuration{Days: 10},
+			Flavours: []opgv1beta1.PoolFlavour{
+				{FlavourId: "small", NumFlavour: 4, MinNumFlavour: 2},
+				{FlavourId: "large", NumFlavour: 1},
+			},
+		}
+	}
+	months := int32(2)
+
+	tests := []struct {
+		name    string
+		update  models.UpdateISVResPoolJSONBody
+		want    func(s *opgv1beta1.ResourcePoolSpec)
+		wantErr error
+	}{
+		{
+			name: "Add flavours",
+			update: models.UpdateISVResPoolJSONBody{
+				{FlavourId: "small", Count: 6, UpdateType: models.UpdateISVResPoolJSONBodyUpdateTypeADD},
+				{FlavourId: "medium", Count: 2, UpdateType: models.UpdateISVResPoolJSONBodyUpdateTypeADD},
+			},
+			want: func(s *opgv1beta1.ResourcePoolSpec) {
+				s.Flavours[0].NumFlavour = 6
+				s.Flavours = append(s.Flavours, opgv1beta1.PoolFlavour{FlavourId: "medium", NumFlavour: 2})
+			},
+		},
+		{
+			name: "Remove flavours",
+			update: models.UpdateISVResPoolJSONBody{
+				{FlavourId: "small", Count: 1, UpdateType: models.UpdateISVResPoolJSONBodyUpdateTypeREMOVE},
+				{FlavourId: "large", Count: 0, UpdateType: models.UpdateISVResPoolJSONBodyUpdateTypeREMOVE},
+			},
+			want: func(s *opgv1beta1.ResourcePoolSpec) {
+				s.Flavours = []opgv1beta1.PoolFlavour{{FlavourId: "small", NumFlavour: 1, MinNumFlavour: 1}}
+			},
+		},
+		{
+			name: "Update duration",
+			update: models.UpdateISVResPoolJSONBody{
+				testPoolUpdate{
+					UpdateType:      models.UpdateISVResPoolJSONBodyUpdateTypeDURATION,
+					ReserveDuration: &models.ResourceReservationDuration{NumOfMonths: &months},
+				},
+			},
+			want: func(s *opgv1beta1.ResourcePoolSpec) {
+				s.ReserveDuration = opgv1beta1.ReserveDuration{Months: 2}
+			},
+		},
+		{
+			name: "Duration without value",
+			update: models.UpdateISVResPoolJSONBody{
+				{UpdateType: models.UpdateISVResPoolJSONBodyUpdateTypeDURATION},
+			},
+			wantErr: ErrBadRequest,
+		},
+		{
+			name: "Invalid update type",
+			update: models.UpdateISVResPoolJSONBody{
+				{FlavourId: "small", Count: 1, UpdateType: "RESIZE"},
+			},
+			wantErr: ErrBadRequest,
+		},
+		{
+			name: "Remove all flavours",
+			update: models.UpdateISVResPoolJSONBody{
+				{FlavourId: "small", UpdateType: models.UpdateISVResPoolJSONBodyUpdateTypeREMOVE},
+				{FlavourId: "large", UpdateType: models.UpdateISVResPoolJSONBodyUpdateTypeREMOVE},
+			},
+			wantErr: ErrBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := spec()
+			err := applyResourcePoolUpdate(&got, tt.update)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			want := spec()
+			tt.want(&want)
+			require.Equal(t, want, got)
+		})
+	}
+}
+
+func Test_applyResourcePoolCallback(t *testing.T) {
+	now := metav1.NewTime(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	callback := func(granted map[string]int32) *models.ResourceReservationCallbackLinkJSONRequestBody {
+		body := &models.ResourceReservationCallbackLinkJSONRequestBody{}
+		for id, n := range granted {
+			body.GrantedFlavours = append(body.GrantedFlavours, struct {
+				FlavourId  models.FlavourId `json:"flavourId"`
+				NumFlavour int32            `json:"numFlavour"`
+			}{FlavourId: id, NumFlavour: n})
+		}
+		return body
+	}
+
+	status := opgv1beta1.ResourcePoolStatus{State: opgv1beta1.ResourcePoolStatePending}
+	applyResourcePoolCallback(&status, callback(map[string]int32{"small": 3, "large": 0}), now)
+	require.Equal(t, opgv1beta1.ResourcePoolStateReserved, status.State)
+	require.Equal(t, &now, status.ReservationTime)
+	require.Equal(t, []opgv1beta1.GrantedFlavour{{FlavourId: "small", NumFlavour: 3}}, status.GrantedFlavours)
+
+	later := metav1.NewTime(now.Add(time.Hour))
+	applyResourcePoolCallback(&status, callback(map[string]int32{"small": 4}), later)
+	require.Equal(t, &now, status.ReservationTime, "reservation time kept while reserved")
+	require.Equal(t, []opgv1beta1.GrantedFlavour{{FlavourId: "small", NumFlavour: 4}}, status.GrantedFlavours)
+
+	applyResourcePoolCallback(&status, callback(nil), later)
+	require.Equal(t, opgv1beta1.ResourcePoolStateExpired, status.State)
+	require.Empty(t, status.GrantedFlavours)
+}
+
+func Test_k8sClient_ResourcePools(t *testing.T) {
+	fed := newTestHostFederation(testFederationContextID)
+	fed.Spec.AcceptedAvailabilityZones = []string{"zone-1"}
+	appInst := newTestApplicationInstance(t, "inst-1", "app-1", "provider-1", "zone-1", opgv1beta1.ApplicationInstanceStateReady)
+	appInst.Spec.ZoneInfo.FlavourId = "small"
+	appInst.Labels[opgLabel(resPoolIDLabel)] = "pool-1"
+	c := newTestK8sClient(t, fed, appInst)
+	ctx := context.Background()
+
+	_, err := c.CreateResourcePool(ctx, newTestResourcePool("pool-1", "zone-2", "provider-1", testPoolFlavour{FlavourId: "small", NumFlavour: 2}))
+	require.ErrorIs(t, err, ErrBadRequest)
+	_, err = c.CreateResourcePool(ctx, newTestResourcePool("pool-1", "zone-1", "provider-1"))
+	require.ErrorIs(t, err, ErrBadRequest)
+
+	_, err = c.CreateResourcePool(ctx, newTestResourcePool("pool-1", "zone-1", "provider-1", testPoolFlavour{FlavourId: "small", NumFlavour: 2}))
+	require.NoError(t, err)
+	_, err = c.CreateResourcePool(ctx, newTestResourcePool("pool-1", "zone-1", "provider-1", testPoolFlavour{FlavourId: "small", NumFlavour: 2}))
+	require.ErrorIs(t, err, ErrAlreadyExists)
+
+	pools, err := c.ListResourcePools(ctx, testFederationContextID, "zone-1", "provider-1")
+	require.NoError(t, err)
+	require.Len(t, pools, 1)
+	require.Equal(t, "pool-1", pools[0].ReservedPoolId)
+	require.Empty(t, pools[0].ReservedFlavours)
+	pools, err = c.ListResourcePools(ctx, testFederationContextID, "zone-1", "provider-2")
+	require.NoError(t, err)
+	require.Empty(t, pools)
+
+	require.ErrorIs(t, c.UpdateResourcePool(ctx, testFederationContextID, "zone-1", "provider-2", "pool-1", models.UpdateISVResPoolJSONBody{
+		{FlavourId: "small", Count: 3, UpdateType: models.UpdateISVResPoolJSONBodyUpdateTypeADD},
+	}), ErrNotFound)
+	require.NoError(t, c.UpdateResourcePool(ctx, testFederationContextID, "zone-1", "provider-1", "pool-1", models.UpdateISVResPoolJSONBody{
+		{FlavourId: "small", Count: 3, UpdateType: models.UpdateISVResPoolJSONBodyUpdateTypeADD},
+	}))
+
+	obj, err := c.getResourcePool(ctx, testFederationContextID, "pool-1")
+	require.NoError(t, err)
+	require.Equal(t, int32(3), obj.Spec.Flavours[0].NumFlavour)
+	obj.Status.State = opgv1beta1.ResourcePoolStateReserved
+	obj.Status.GrantedFlavours = []opgv1beta1.GrantedFlavour{{FlavourId: "small", NumFlavour: 3}}
+	require.NoError(t, c.kubernetes.Status().Update(ctx, obj))
+	usage, err := c.GetResourcePoolUsage(ctx, testFederationContextID, "pool-1")
+	require.NoError(t, err)
+	require.Equal(t, int32(2), usage.Remaining("small"))
+	require.Equal(t, int32(0), usage.Remaining("large"))
+
+	obj.Status.State = opgv1beta1.ResourcePoolStateExpired
+	require.NoError(t, c.kubernetes.Status().Update(ctx, obj))
+	require.ErrorIs(t, c.UpdateResourcePool(ctx, testFederationContextID, "zone-1", "provider-1", "pool-1", models.UpdateISVResPoolJSONBody{
+		{FlavourId: "small", Count: 1, UpdateType: models.UpdateISVResPoolJSONBodyUpdateTypeADD},
+	}), ErrConflict)
+
+	require.ErrorIs(t, c.RemoveResourcePool(ctx, testFederationContextID, "zone-1", "provider-1", "pool-1"), ErrConflict)
+	require.NoError(t, c.kubernetes.Delete(ctx, appInst))
+	require.NoError(t, c.RemoveResourcePool(ctx, testFederationContextID, "zone-1", "provider-1", "pool-1"))
+	_, err = c.GetResourcePoolUsage(ctx, testFederationContextID, "pool-1")
+	require.ErrorIs(t, err, ErrNotFound)
+}
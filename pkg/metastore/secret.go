@@ -0,0 +1,80 @@
+package metastore
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// keys of the credentials Secrets created alongside the custom resources
+	passwordSecretKey    = "password"
+	tokenSecretKey       = "token"
+	accessTokenSecretKey = "accessToken"
+
+	// redactedSecretValue replaces the secret values returned by the API
+	redactedSecretValue = "******"
+
+	secretKind string = "secret"
+)
+
+// credentialsSecretName returns the name of the Secret holding the credentials
+// of the custom resource named crName.
+func credentialsSecretName(crName string) string {
+	return crName + "-credentials"
+}
+
+// credentialsSecret collects the credentials received in a request, they are
+// stored in a Secret instead of the custom resource spec.
+type credentialsSecret map[string]string
+
+// set adds the value under key when it is not empty.
+func (s credentialsSecret) set(key string, value *string) {
+	if value != nil && *value != "" {
+		s[key] = *value
+	}
+}
+
+// ref returns the reference to key in the Secret of the custom resource
+// named crName, or nil when the request carried no value for it.
+func (s credentialsSecret) ref(crName, key string) *corev1.SecretKeySelector {
+	if _, ok := s[key]; !ok {
+		return nil
+	}
+	return &corev1.SecretKeySelector{
+		LocalObjectReference: corev1.LocalObjectReference{Name: credentialsSecretName(crName)},
+		Key:                  key,
+	}
+}
+
+// k8sSecret builds the Secret of the custom resource owner, it carries the
+// owner labels so it can be traced back to its federation.
+func (s credentialsSecret) k8sSecret(owner metav1.Object, opts ...Opt) (*corev1.Secret, error) {
+	obj := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      credentialsSecretName(owner.GetName()),
+			Namespace: owner.GetNamespace(),
+			Labels:    map[string]string{},
+		},
+		Type:       corev1.SecretTypeOpaque,
+		StringData: s,
+	}
+	for k, v := range owner.GetLabels() {
+		obj.Labels[k] = v
+	}
+	obj.Labels[opgLabel(kindLabel)] = secretKind
+	for _, opt := range opts {
+		if err := opt(&obj.ObjectMeta); err != nil {
+			return nil, err
+		}
+	}
+	return obj, nil
+}
+
+// redactedIfSet hides the value of a secret reference.
+func redactedIfSet(ref *corev1.SecretKeySelector) *string {
+	if ref == nil {
+		return nil
+	}
+	v := redactedSecretValue
+	return &v
+}
@@ -0,0 +1,68 @@
+package metastore
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/neonephos-katalis/opg-ewbi-operator/api/ewbi/models"
+)
+
+func Test_UploadFileCredentials(t *testing.T) {
+	ptr := func(s string) *string { return &s }
+
+	tests := []struct {
+		name     string
+		location models.ObjectRepoLocation
+		want     map[string]string
+	}{
+		{
+			name:     "Password and token",
+			location: models.ObjectRepoLocation{Password: ptr("pass"), Token: ptr("token"), UserName: ptr("user")},
+			want:     map[string]string{passwordSecretKey: "pass", tokenSecretKey: "token"},
+		},
+		{
+			name:     "Token only",
+			location: models.ObjectRepoLocation{Password: ptr(""), Token: ptr("token"), UserName: ptr("user")},
+			want:     map[string]string{tokenSecretKey: "token"},
+		},
+		{
+			name:     "No credentials",
+			location: models.ObjectRepoLocation{UserName: ptr("user")},
+			want:     map[string]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := &UploadFile{
+				UploadFileMultipartBody: &models.UploadFileMultipartBody{FileId: "file-1", FileRepoLocation: &tt.location},
+				FederationContextId:     "fed-1",
+			}
+			obj, err := file.k8sCustomResource("ns")
+			require.NoError(t, err)
+			require.Equal(t, "user", obj.Spec.Repo.UserName)
+
+			secret, err := file.credentials().k8sSecret(obj)
+			require.NoError(t, err)
+			require.Equal(t, credentialsSecretName(obj.Name), secret.Name)
+			require.Equal(t, tt.want, secret.StringData)
+			require.Equal(t, "file-1", secret.Labels[opgLabel(idLabel)])
+
+			for key, ref := range map[string]*string{
+				passwordSecretKey: redactedIfSet(obj.Spec.Repo.PasswordSecretRef),
+				tokenSecretKey:    redactedIfSet(obj.Spec.Repo.TokenSecretRef),
+			} {
+				if _, ok := tt.want[key]; ok {
+					require.Equal(t, redactedSecretValue, *ref)
+				} else {
+					require.Nil(t, ref)
+				}
+			}
+			if obj.Spec.Repo.PasswordSecretRef != nil {
+				require.Equal(t, secret.Name, obj.Spec.Repo.PasswordSecretRef.Name)
+				require.Equal(t, passwordSecretKey, obj.Spec.Repo.PasswordSecretRef.Key)
+			}
+		})
+	}
+}
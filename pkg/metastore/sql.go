@@ -0,0 +1,249 @@
+package metastore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+	k8scli "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// sqlDialect, the statements differing between the SQL databases holding the objects
+type sqlDialect struct {
+	// schema creates the tables of the objects and of the resource versions
+	schema string
+	// nextResourceVersion returns the resource version of the next write
+	nextResourceVersion string
+	// jsonCast is appended to the parameters written in the JSON columns
+	jsonCast string
+	// labelsMatch selects the objects having all the labels of the JSON object $3
+	labelsMatch string
+}
+
+// postgresDialect keeps the labels and the objects in JSONB columns, the labels are
+// indexed for the searches and the resource versions are taken from a sequence
+var postgresDialect = sqlDialect{
+	schema: `
+CREATE TABLE IF NOT EXISTS metastore_objects (
+	kind             TEXT   NOT NULL,
+	namespace        TEXT   NOT NULL,
+	name             TEXT   NOT NULL,
+	resource_version BIGINT NOT NULL,
+	labels           JSONB  NOT NULL,
+	data             JSONB  NOT NULL,
+	PRIMARY KEY (kind, namespace, name)
+);
+CREATE SEQUENCE IF NOT EXISTS metastore_resource_version;
+CREATE INDEX IF NOT EXISTS metastore_objects_labels ON metastore_objects USING GIN (labels);
+`,
+	nextResourceVersion: `SELECT nextval('metastore_resource_version')`,
+	jsonCast:            "::jsonb",
+	labelsMatch:         `labels @> $3::jsonb`,
+}
+
+// sqliteDialect keeps the labels and the objects as JSON text, the resource versions
+// are taken from a single row counter
+var sqliteDialect = sqlDialect{
+	schema: `
+CREATE TABLE IF NOT EXISTS metastore_objects (
+	kind             TEXT    NOT NULL,
+	namespace        TEXT    NOT NULL,
+	name             TEXT    NOT NULL,
+	resource_version INTEGER NOT NULL,
+	labels           TEXT    NOT NULL,
+	data             TEXT    NOT NULL,
+	PRIMARY KEY (kind, namespace, name)
+);
+CREATE TABLE IF NOT EXISTS metastore_resource_version (value INTEGER NOT NULL);
+INSERT INTO metastore_resource_version SELECT 0 WHERE NOT EXISTS (SELECT 1 FROM metastore_resource_version);
+`,
+	nextResourceVersion: `UPDATE metastore_resource_version SET value = value + 1 RETURNING value`,
+	labelsMatch: `NOT EXISTS (
+		SELECT 1 FROM json_each($3) AS want WHERE NOT EXISTS (
+			SELECT 1 FROM json_each(metastore_objects.labels) AS have
+			WHERE have.key = want.key AND have.value = want.value
+		)
+	)`,
+}
+
+// NewPostgresClient returns a Client keeping the resources of namespace in the PostgreSQL
+// database db, seeded with objs. The tables are created if they don't exist yet.
+func NewPostgresClient(ctx context.Context, db *sql.DB, namespace string, objs ...k8scli.Object) (*k8sClient, error) {
+	return newSQLClient(ctx, db, postgresDialect, namespace, objs...)
+}
+
+// NewSQLiteClient returns a Client keeping the resources of namespace in the SQLite
+// database db, seeded with objs. The tables are created if they don't exist yet.
+func NewSQLiteClient(ctx context.Context, db *sql.DB, namespace string, objs ...k8scli.Object) (*k8sClient, error) {
+	// SQLite has a single writer, sharing one connection avoids the busy errors
+	db.SetMaxOpenConns(1)
+	return newSQLClient(ctx, db, sqliteDialect, namespace, objs...)
+}
+
+func newSQLClient(ctx context.Context, db *sql.DB, dialect sqlDialect, namespace string, objs ...k8scli.Object) (*k8sClient, error) {
+	if _, err := db.ExecContext(ctx, dialect.schema); err != nil {
+		return nil, errors.Wrap(err, "unable to create metastore tables")
+	}
+	return newStoreClient(ctx, &sqlStore{db: db, dialect: dialect}, namespace, objs...)
+}
+
+// sqlStore, an objectStore holding the objects in a SQL table, every write takes a new
+// resource version in its transaction
+type sqlStore struct {
+	db      *sql.DB
+	dialect sqlDialect
+}
+
+func (s *sqlStore) get(ctx context.Context, key objectKey) (*storedObject, error) {
+	return getStoredObject(ctx, s.db, key)
+}
+
+func (s *sqlStore) list(ctx context.Context, kind, namespace string, labels map[string]string) ([]*storedObject, error) {
+	labelsJSON, err := encodeLabels(labels)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf(`
+		SELECT kind, namespace, name, resource_version, labels, data FROM metastore_objects
+		WHERE ($1 = '' OR kind = $1) AND ($2 = '' OR namespace = $2) AND %s
+		ORDER BY kind, namespace, name`, s.dialect.labelsMatch),
+		kind, namespace, labelsJSON,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list objects")
+	}
+	defer rows.Close()
+	var res []*storedObject
+	for rows.Next() {
+		obj, err := scanStoredObject(rows)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, obj)
+	}
+	return res, errors.Wrap(rows.Err(), "unable to list objects")
+}
+
+func (s *sqlStore) create(ctx context.Context, obj *storedObject) (int64, error) {
+	labelsJSON, err := encodeLabels(obj.labels)
+	if err != nil {
+		return 0, err
+	}
+	return s.write(ctx, func(tx *sql.Tx, resourceVersion int64) error {
+		res, err := tx.ExecContext(ctx, fmt.Sprintf(`
+			INSERT INTO metastore_objects (kind, namespace, name, resource_version, labels, data)
+			VALUES ($1, $2, $3, $4, $5%[1]s, $6%[1]s)
+			ON CONFLICT DO NOTHING`, s.dialect.jsonCast),
+			obj.key.kind, obj.key.namespace, obj.key.name, resourceVersion, labelsJSON, string(obj.data),
+		)
+		if err != nil {
+			return errors.Wrap(err, "unable to create object")
+		}
+		if n, err := res.RowsAffected(); err == nil && n == 0 {
+			return errStoreAlreadyExists
+		}
+		return nil
+	})
+}
+
+func (s *sqlStore) update(ctx context.Context, obj *storedObject, resourceVersion int64) (int64, error) {
+	labelsJSON, err := encodeLabels(obj.labels)
+	if err != nil {
+		return 0, err
+	}
+	return s.write(ctx, func(tx *sql.Tx, updated int64) error {
+		res, err := tx.ExecContext(ctx, fmt.Sprintf(`
+			UPDATE metastore_objects SET resource_version = $1, labels = $2%[1]s, data = $3%[1]s
+			WHERE kind = $4 AND namespace = $5 AND name = $6 AND resource_version = $7`, s.dialect.jsonCast),
+			updated, labelsJSON, string(obj.data), obj.key.kind, obj.key.namespace, obj.key.name, resourceVersion,
+		)
+		if err != nil {
+			return errors.Wrap(err, "unable to update object")
+		}
+		if n, err := res.RowsAffected(); err != nil || n > 0 {
+			return errors.Wrap(err, "unable to update object")
+		}
+		// the object was either removed or written by someone else
+		if _, err := getStoredObject(ctx, tx, obj.key); err != nil {
+			return err
+		}
+		return errStoreConflict
+	})
+}
+
+func (s *sqlStore) delete(ctx context.Context, key objectKey) error {
+	res, err := s.db.ExecContext(ctx, `
+		DELETE FROM metastore_objects WHERE kind = $1 AND namespace = $2 AND name = $3`,
+		key.kind, key.namespace, key.name,
+	)
+	if err != nil {
+		return errors.Wrap(err, "unable to delete object")
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return errStoreNotFound
+	}
+	return nil
+}
+
+// write runs fn in a transaction with the resource version of the written object,
+// returned when fn succeeds
+func (s *sqlStore) write(ctx context.Context, fn func(tx *sql.Tx, resourceVersion int64) error) (int64, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, errors.Wrap(err, "unable to begin transaction")
+	}
+	defer tx.Rollback()
+
+	var resourceVersion int64
+	if err := tx.QueryRowContext(ctx, s.dialect.nextResourceVersion).Scan(&resourceVersion); err != nil {
+		return 0, errors.Wrap(err, "unable to get resource version")
+	}
+	if err := fn(tx, resourceVersion); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, errors.Wrap(err, "unable to commit transaction")
+	}
+	return resourceVersion, nil
+}
+
+func getStoredObject(ctx context.Context, q interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}, key objectKey) (*storedObject, error) {
+	row := q.QueryRowContext(ctx, `
+		SELECT kind, namespace, name, resource_version, labels, data FROM metastore_objects
+		WHERE kind = $1 AND namespace = $2 AND name = $3`,
+		key.kind, key.namespace, key.name,
+	)
+	obj, err := scanStoredObject(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errStoreNotFound
+	}
+	return obj, err
+}
+
+// encodeLabels returns the JSON object of labels, empty when they are not set
+func encodeLabels(labels map[string]string) (string, error) {
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	data, err := json.Marshal(labels)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to encode labels")
+	}
+	return string(data), nil
+}
+
+func scanStoredObject(row interface{ Scan(...any) error }) (*storedObject, error) {
+	var obj storedObject
+	var labelsJSON []byte
+	if err := row.Scan(&obj.key.kind, &obj.key.namespace, &obj.key.name, &obj.resourceVersion, &labelsJSON, &obj.data); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(labelsJSON, &obj.labels); err != nil {
+		return nil, errors.Wrap(err, "unable to decode labels")
+	}
+	return &obj, nil
+}
@@ -0,0 +1,517 @@
+package metastore
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	k8scli "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+
+	opgv1beta1 "github.com/neonephos-katalis/opg-ewbi-operator/api/operator/v1beta1"
+)
+
+// storeScheme, the kinds the metastore backends outside of Kubernetes can hold
+var storeScheme = runtime.NewScheme()
+
+func init() {
+	utilruntime.Must(clientgoscheme.AddToScheme(storeScheme))
+	utilruntime.Must(opgv1beta1.AddToScheme(storeScheme))
+}
+
+var (
+	errStoreNotFound      = errors.New("object not found")
+	errStoreAlreadyExists = errors.New("object already exists")
+	errStoreConflict      = errors.New("object modified")
+)
+
+// objectKey identifies an object of an objectStore, kind is the group kind of the object
+type objectKey struct {
+	kind      string
+	namespace string
+	name      string
+}
+
+// storedObject, an object of an objectStore with its labels and JSON encoding
+type storedObject struct {
+	key             objectKey
+	resourceVersion int64
+	labels          map[string]string
+	data            []byte
+}
+
+// objectStore persists the objects of a storeClient. Every write gives the object
+// a new resource version, writes of an outdated version fail with errStoreConflict.
+type objectStore interface {
+	get(ctx context.Context, key objectKey) (*storedObject, error)
+	// list returns the objects of kind in namespace having all of labels sorted
+	// by key, an empty kind or namespace matches all of them
+	list(ctx context.Context, kind, namespace string, labels map[string]string) ([]*storedObject, error)
+	create(ctx context.Context, obj *storedObject) (int64, error)
+	update(ctx context.Context, obj *storedObject, resourceVersion int64) (int64, error)
+	delete(ctx context.Context, key objectKey) error
+}
+
+// newStoreClient returns a Client keeping the resources of namespace in store,
+// seeded with objs that are created in namespace unless they already exist
+func newStoreClient(ctx context.Context, store objectStore, namespace string, objs ...k8scli.Object) (*k8sClient, error) {
+	c := &storeClient{store: store, scheme: storeScheme}
+	for _, obj := range objs {
+		obj.SetNamespace(namespace)
+		if err := c.Create(ctx, obj); err != nil && !apierrors.IsAlreadyExists(err) {
+			return nil, errors.Wrapf(err, "unable to create %s %s", getObjectKind(obj), obj.GetName())
+		}
+	}
+	if err := LabelApplicationInstances(ctx, c, namespace); err != nil {
+		return nil, err
+	}
+	return NewK8sClient(c, namespace), nil
+}
+
+// DecodeObjects decodes the YAML or JSON manifests of r, e.g. the host federations
+// the backends outside of Kubernetes are seeded with
+func DecodeObjects(r io.Reader) ([]k8scli.Object, error) {
+	decoder := serializer.NewCodecFactory(storeScheme).UniversalDeserializer()
+	reader := yaml.NewYAMLReader(bufio.NewReader(r))
+	var objs []k8scli.Object
+	for {
+		doc, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return objs, nil
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to read manifest")
+		}
+		if len(bytes.TrimSpace(doc)) == 0 {
+			continue
+		}
+		ro, _, err := decoder.Decode(doc, nil, nil)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to decode manifest")
+		}
+		obj, ok := ro.(k8scli.Object)
+		if !ok {
+			return nil, errors.Errorf("unexpected manifest of %T", ro)
+		}
+		objs = append(objs, obj)
+	}
+}
+
+// storeClient implements the subset of the Kubernetes API the metastore uses on
+// top of an objectStore: the spec and status of the resources are written
+// separately, the owned objects are removed along with their owner.
+type storeClient struct {
+	store  objectStore
+	scheme *runtime.Scheme
+}
+
+var _ k8scli.Client = &storeClient{}
+
+func (c *storeClient) Get(ctx context.Context, key k8scli.ObjectKey, obj k8scli.Object, _ ...k8scli.GetOption) error {
+	k, gvk, err := c.objectKey(obj, key.Namespace, key.Name)
+	if err != nil {
+		return err
+	}
+	stored, err := c.store.get(ctx, k)
+	if err != nil {
+		return apiError(err, gvk, key.Name)
+	}
+	return decodeObject(stored, obj)
+}
+
+func (c *storeClient) List(ctx context.Context, list k8scli.ObjectList, opts ...k8scli.ListOption) error {
+	gvk, err := apiutil.GVKForObject(list, c.scheme)
+	if err != nil {
+		return err
+	}
+	gvk.Kind = strings.TrimSuffix(gvk.Kind, "List")
+	listOpts := (&k8scli.ListOptions{}).ApplyOptions(opts)
+	selector := listOpts.LabelSelector
+	if selector == nil {
+		selector = labels.Everything()
+	}
+
+	stored, err := c.store.list(ctx, gvk.GroupKind().String(), listOpts.Namespace, equalityLabels(selector))
+	if err != nil {
+		return err
+	}
+	items := make([]runtime.Object, 0, len(stored))
+	for _, s := range stored {
+		if !selector.Matches(labels.Set(s.labels)) {
+			continue
+		}
+		ro, err := c.scheme.New(gvk)
+		if err != nil {
+			return err
+		}
+		obj := ro.(k8scli.Object)
+		if err := decodeObject(s, obj); err != nil {
+			return err
+		}
+		items = append(items, obj)
+	}
+	return meta.SetList(list, items)
+}
+
+func (c *storeClient) Create(ctx context.Context, obj k8scli.Object, _ ...k8scli.CreateOption) error {
+	k, gvk, err := c.objectKey(obj, obj.GetNamespace(), obj.GetName())
+	if err != nil {
+		return err
+	}
+	if k.name == "" {
+		return apierrors.NewBadRequest("name is required")
+	}
+	if secret, ok := obj.(*corev1.Secret); ok {
+		mergeSecretStringData(secret)
+	}
+	doc, err := encodeObject(gvk, obj)
+	if err != nil {
+		return err
+	}
+	metadata := objectMetadata(doc)
+	metadata["uid"] = string(uuid.NewUUID())
+	metadata["creationTimestamp"] = metav1.Now().UTC().Format(time.RFC3339)
+	metadata["generation"] = 1
+	if hasStatusSubresource(gvk) {
+		delete(doc, "status")
+	}
+
+	stored, err := newStoredObject(k, doc)
+	if err != nil {
+		return err
+	}
+	if stored.resourceVersion, err = c.store.create(ctx, stored); err != nil {
+		return apiError(err, gvk, k.name)
+	}
+	return decodeObject(stored, obj)
+}
+
+func (c *storeClient) Update(ctx context.Context, obj k8scli.Object, _ ...k8scli.UpdateOption) error {
+	return c.update(ctx, obj, obj.GetResourceVersion(), false, func(gvk schema.GroupVersionKind, _ []byte) (map[string]any, error) {
+		return encodeObject(gvk, obj)
+	})
+}
+
+func (c *storeClient) Patch(ctx context.Context, obj k8scli.Object, patch k8scli.Patch, _ ...k8scli.PatchOption) error {
+	return c.update(ctx, obj, "", false, mergePatch(obj, patch))
+}
+
+func (c *storeClient) Delete(ctx context.Context, obj k8scli.Object, _ ...k8scli.DeleteOption) error {
+	k, gvk, err := c.objectKey(obj, obj.GetNamespace(), obj.GetName())
+	if err != nil {
+		return err
+	}
+	stored, err := c.store.get(ctx, k)
+	if err != nil {
+		return apiError(err, gvk, k.name)
+	}
+	var current metav1.PartialObjectMetadata
+	if err := json.Unmarshal(stored.data, &current); err != nil {
+		return errors.Wrapf(err, "unable to decode %s %s", k.kind, k.name)
+	}
+	if err := c.store.delete(ctx, k); err != nil {
+		return apiError(err, gvk, k.name)
+	}
+	return c.deleteDependents(ctx, k.namespace, current.UID)
+}
+
+// deleteDependents removes the objects owned by the object with uid, as the
+// garbage collector of Kubernetes does in the background
+func (c *storeClient) deleteDependents(ctx context.Context, namespace string, uid types.UID) error {
+	stored, err := c.store.list(ctx, "", namespace, nil)
+	if err != nil {
+		return err
+	}
+	for _, s := range stored {
+		var dependent metav1.PartialObjectMetadata
+		if err := json.Unmarshal(s.data, &dependent); err != nil {
+			return errors.Wrapf(err, "unable to decode %s %s", s.key.kind, s.key.name)
+		}
+		for _, ref := range dependent.OwnerReferences {
+			if ref.UID != uid {
+				continue
+			}
+			if err := c.store.delete(ctx, s.key); err != nil && !errors.Is(err, errStoreNotFound) {
+				return err
+			}
+			if err := c.deleteDependents(ctx, namespace, dependent.UID); err != nil {
+				return err
+			}
+			break
+		}
+	}
+	return nil
+}
+
+func (c *storeClient) DeleteAllOf(context.Context, k8scli.Object, ...k8scli.DeleteAllOfOption) error {
+	return errors.New("delete collection is not supported by the metastore store")
+}
+
+func (c *storeClient) Status() k8scli.SubResourceWriter {
+	return c.SubResource("status")
+}
+
+func (c *storeClient) SubResource(subResource string) k8scli.SubResourceClient {
+	return &storeSubResourceClient{client: c, subResource: subResource}
+}
+
+func (c *storeClient) Scheme() *runtime.Scheme {
+	return c.scheme
+}
+
+func (c *storeClient) RESTMapper() meta.RESTMapper {
+	return meta.NewDefaultRESTMapper(nil)
+}
+
+func (c *storeClient) GroupVersionKindFor(obj runtime.Object) (schema.GroupVersionKind, error) {
+	return apiutil.GVKForObject(obj, c.scheme)
+}
+
+func (c *storeClient) IsObjectNamespaced(runtime.Object) (bool, error) {
+	return true, nil
+}
+
+// update replaces the object with the version next builds from the stored one,
+// keeping the metadata set on creation and, unless status is set, the status of
+// the resources with a status subresource. With status only the status is written.
+// resourceVersion, when set, must match the stored version.
+func (c *storeClient) update(
+	ctx context.Context, obj k8scli.Object, resourceVersion string, status bool,
+	next func(gvk schema.GroupVersionKind, current []byte) (map[string]any, error),
+) error {
+	k, gvk, err := c.objectKey(obj, obj.GetNamespace(), obj.GetName())
+	if err != nil {
+		return err
+	}
+	stored, err := c.store.get(ctx, k)
+	if err != nil {
+		return apiError(err, gvk, k.name)
+	}
+	version := stored.resourceVersion
+	if resourceVersion != "" {
+		if version, err = strconv.ParseInt(resourceVersion, 10, 64); err != nil {
+			return apierrors.NewBadRequest("invalid resourceVersion " + resourceVersion)
+		}
+	}
+	current := map[string]any{}
+	if err := json.Unmarshal(stored.data, &current); err != nil {
+		return errors.Wrapf(err, "unable to decode %s %s", k.kind, k.name)
+	}
+	doc, err := next(gvk, stored.data)
+	if err != nil {
+		return err
+	}
+
+	if status {
+		setField(current, "status", doc["status"])
+		doc = current
+	} else {
+		if hasStatusSubresource(gvk) {
+			setField(doc, "status", current["status"])
+		}
+		metadata, currentMetadata := objectMetadata(doc), objectMetadata(current)
+		for _, field := range []string{"uid", "creationTimestamp", "generation"} {
+			setField(metadata, field, currentMetadata[field])
+		}
+		if generation, ok := currentMetadata["generation"].(float64); ok && !reflect.DeepEqual(doc["spec"], current["spec"]) {
+			metadata["generation"] = generation + 1
+		}
+	}
+
+	updated, err := newStoredObject(k, doc)
+	if err != nil {
+		return err
+	}
+	if updated.resourceVersion, err = c.store.update(ctx, updated, version); err != nil {
+		return apiError(err, gvk, k.name)
+	}
+	return decodeObject(updated, obj)
+}
+
+// objectKey returns the store key of the object obj named name in namespace
+func (c *storeClient) objectKey(obj runtime.Object, namespace, name string) (objectKey, schema.GroupVersionKind, error) {
+	gvk, err := apiutil.GVKForObject(obj, c.scheme)
+	if err != nil {
+		return objectKey{}, gvk, err
+	}
+	return objectKey{kind: gvk.GroupKind().String(), namespace: namespace, name: name}, gvk, nil
+}
+
+// storeSubResourceClient writes the status subresource of the storeClient objects
+type storeSubResourceClient struct {
+	client      *storeClient
+	subResource string
+}
+
+func (c *storeSubResourceClient) Get(context.Context, k8scli.Object, k8scli.Object, ...k8scli.SubResourceGetOption) error {
+	return errors.Errorf("subresource %s is not supported by the metastore store", c.subResource)
+}
+
+func (c *storeSubResourceClient) Create(context.Context, k8scli.Object, k8scli.Object, ...k8scli.SubResourceCreateOption) error {
+	return errors.Errorf("subresource %s is not supported by the metastore store", c.subResource)
+}
+
+func (c *storeSubResourceClient) Update(ctx context.Context, obj k8scli.Object, _ ...k8scli.SubResourceUpdateOption) error {
+	if c.subResource != "status" {
+		return errors.Errorf("subresource %s is not supported by the metastore store", c.subResource)
+	}
+	return c.client.update(ctx, obj, obj.GetResourceVersion(), true, func(gvk schema.GroupVersionKind, _ []byte) (map[string]any, error) {
+		return encodeObject(gvk, obj)
+	})
+}
+
+func (c *storeSubResourceClient) Patch(ctx context.Context, obj k8scli.Object, patch k8scli.Patch, _ ...k8scli.SubResourcePatchOption) error {
+	if c.subResource != "status" {
+		return errors.Errorf("subresource %s is not supported by the metastore store", c.subResource)
+	}
+	return c.client.update(ctx, obj, "", true, mergePatch(obj, patch))
+}
+
+// mergePatch applies the JSON merge patch of obj to the stored object, the only
+// kind of patch the metastore sends
+func mergePatch(obj k8scli.Object, patch k8scli.Patch) func(schema.GroupVersionKind, []byte) (map[string]any, error) {
+	return func(_ schema.GroupVersionKind, current []byte) (map[string]any, error) {
+		if patch.Type() != types.MergePatchType {
+			return nil, apierrors.NewBadRequest("unsupported patch type " + string(patch.Type()))
+		}
+		data, err := patch.Data(obj)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to build patch")
+		}
+		patched, err := jsonpatch.MergePatch(current, data)
+		if err != nil {
+			return nil, apierrors.NewBadRequest(err.Error())
+		}
+		doc := map[string]any{}
+		if err := json.Unmarshal(patched, &doc); err != nil {
+			return nil, apierrors.NewBadRequest(err.Error())
+		}
+		return doc, nil
+	}
+}
+
+// apiError returns the Kubernetes API error matching the store error err
+func apiError(err error, gvk schema.GroupVersionKind, name string) error {
+	resource := schema.GroupResource{Group: gvk.Group, Resource: strings.ToLower(gvk.Kind)}
+	switch {
+	case errors.Is(err, errStoreNotFound):
+		return apierrors.NewNotFound(resource, name)
+	case errors.Is(err, errStoreAlreadyExists):
+		return apierrors.NewAlreadyExists(resource, name)
+	case errors.Is(err, errStoreConflict):
+		return apierrors.NewConflict(resource, name, err)
+	}
+	return err
+}
+
+// hasStatusSubresource returns whether the status of the kind is written apart from its spec
+func hasStatusSubresource(gvk schema.GroupVersionKind) bool {
+	return gvk.Group == opgv1beta1.GroupVersion.Group
+}
+
+// equalityLabels returns the labels selector requires to have a single value,
+// filtered by the stores before the whole selector is matched
+func equalityLabels(selector labels.Selector) map[string]string {
+	requirements, _ := selector.Requirements()
+	res := map[string]string{}
+	for _, r := range requirements {
+		if r.Operator() == selection.Equals || r.Operator() == selection.DoubleEquals {
+			res[r.Key()] = r.Values().List()[0]
+		}
+	}
+	return res
+}
+
+// mergeSecretStringData moves the StringData of secret to its Data, as the API server does
+func mergeSecretStringData(secret *corev1.Secret) {
+	if len(secret.StringData) == 0 {
+		return
+	}
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+	for k, v := range secret.StringData {
+		secret.Data[k] = []byte(v)
+	}
+	secret.StringData = nil
+}
+
+// encodeObject returns the JSON document of obj of kind gvk, without its resource
+// version that is kept by the store
+func encodeObject(gvk schema.GroupVersionKind, obj k8scli.Object) (map[string]any, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to encode %s %s", gvk.Kind, obj.GetName())
+	}
+	doc := map[string]any{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, errors.Wrapf(err, "unable to encode %s %s", gvk.Kind, obj.GetName())
+	}
+	doc["apiVersion"], doc["kind"] = gvk.GroupVersion().String(), gvk.Kind
+	delete(objectMetadata(doc), "resourceVersion")
+	return doc, nil
+}
+
+// decodeObject replaces obj with the stored object
+func decodeObject(stored *storedObject, obj k8scli.Object) error {
+	v := reflect.ValueOf(obj).Elem()
+	v.Set(reflect.Zero(v.Type()))
+	if err := json.Unmarshal(stored.data, obj); err != nil {
+		return errors.Wrapf(err, "unable to decode %s %s", stored.key.kind, stored.key.name)
+	}
+	obj.SetResourceVersion(strconv.FormatInt(stored.resourceVersion, 10))
+	return nil
+}
+
+func newStoredObject(key objectKey, doc map[string]any) (*storedObject, error) {
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to encode %s %s", key.kind, key.name)
+	}
+	var m metav1.PartialObjectMetadata
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, errors.Wrapf(err, "unable to encode %s %s", key.kind, key.name)
+	}
+	return &storedObject{key: key, labels: m.Labels, data: data}, nil
+}
+
+// objectMetadata returns the metadata of the JSON document doc
+func objectMetadata(doc map[string]any) map[string]any {
+	metadata, ok := doc["metadata"].(map[string]any)
+	if !ok {
+		metadata = map[string]any{}
+		doc["metadata"] = metadata
+	}
+	return metadata
+}
+
+// setField sets field of the JSON object m to v, removing it when v is not set
+func setField(m map[string]any, field string, v any) {
+	if v == nil {
+		delete(m, field)
+		return
+	}
+	m[field] = v
+}
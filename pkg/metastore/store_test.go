@@ -0,0 +1,119 @@
+package metastore
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	k8scli "sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/neonephos-katalis/opg-ewbi-operator/api/ewbi/models"
+	opgv1beta1 "github.com/neonephos-katalis/opg-ewbi-operator/api/operator/v1beta1"
+)
+
+// storeBackends, the backends emulating the generations, the status subresource and
+// the garbage collection of the API server
+var storeBackends = []string{"memory", "sqlite", "postgres"}
+
+func Test_storeClient_Generation(t *testing.T) {
+	for _, name := range storeBackends {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			c := testBackends[name](t, newTestHostFederation(testFederationContextID))
+
+			fed := &opgv1beta1.Federation{}
+			key := k8scli.ObjectKey{Namespace: testNamespace, Name: "federation-" + testFederationContextID}
+			require.NoError(t, c.kubernetes.Get(ctx, key, fed))
+			assert.Equal(t, int64(1), fed.Generation)
+			assert.NotEmpty(t, fed.UID)
+
+			// the status writes and the metadata changes keep the generation
+			fed.Status.State = opgv1beta1.FederationStateAvailable
+			require.NoError(t, c.kubernetes.Status().Update(ctx, fed))
+			fed.Annotations = map[string]string{"note": "value"}
+			require.NoError(t, c.kubernetes.Update(ctx, fed))
+			assert.Equal(t, int64(1), fed.Generation)
+
+			fed.Spec.AcceptedAvailabilityZones = []string{"zone-1"}
+			require.NoError(t, c.kubernetes.Update(ctx, fed))
+			assert.Equal(t, int64(2), fed.Generation)
+			assert.Equal(t, opgv1beta1.FederationStateAvailable, fed.Status.State)
+
+			fed.ResourceVersion = "1"
+			require.True(t, apierrors.IsConflict(c.kubernetes.Update(ctx, fed)))
+		})
+	}
+}
+
+func Test_storeClient_DeleteDependents(t *testing.T) {
+	for _, name := range storeBackends {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			c := testBackends[name](t, newTestHostFederation(testFederationContextID))
+
+			token := "token"
+			file, err := c.UploadFile(ctx, &UploadFile{
+				UploadFileMultipartBody: &models.UploadFileMultipartBody{
+					FileId:           "file-1",
+					FileRepoLocation: &models.ObjectRepoLocation{Token: &token},
+				},
+				FederationContextId: testFederationContextID,
+			})
+			require.NoError(t, err)
+			secret := &corev1.Secret{}
+			secretKey := k8scli.ObjectKey{Namespace: testNamespace, Name: credentialsSecretName(file.Name)}
+			require.NoError(t, c.kubernetes.Get(ctx, secretKey, secret))
+			assert.Equal(t, []byte(token), secret.Data[tokenSecretKey])
+
+			_, err = c.UploadArtefact(ctx, &UploadArtefact{
+				UploadArtefactMultipartBody: &models.UploadArtefactMultipartBody{ArtefactId: "artefact-1"},
+				FederationContextId:         testFederationContextID,
+			})
+			require.NoError(t, err)
+			users := 1
+			_, err = c.OnboardApplication(ctx, &OnboardApplication{
+				OnboardApplicationJSONBody: &models.OnboardApplicationJSONBody{
+					AppId:             "app-1",
+					AppProviderId:     "provider-1",
+					AppComponentSpecs: models.AppComponentSpecs{{ArtefactId: "artefact-1"}},
+					AppQoSProfile:     models.AppQoSProfile{NoOfUsersPerAppInst: &users},
+				},
+				FederationContextId: testFederationContextID,
+			})
+			require.NoError(t, err)
+
+			// the resources are owned by the federation, the file credentials by the file
+			require.NoError(t, c.RemoveFederation(ctx, testFederationContextID))
+			_, err = c.GetFile(ctx, testFederationContextID, "file-1")
+			require.ErrorIs(t, err, ErrNotFound)
+			_, err = c.GetArtefact(ctx, testFederationContextID, "artefact-1")
+			require.ErrorIs(t, err, ErrNotFound)
+			_, err = c.GetApplication(ctx, testFederationContextID, "app-1")
+			require.ErrorIs(t, err, ErrNotFound)
+			require.True(t, apierrors.IsNotFound(c.kubernetes.Get(ctx, secretKey, secret)))
+		})
+	}
+}
+
+func Test_DecodeObjects(t *testing.T) {
+	f, err := os.Open("../../config/samples/federationHostAuth.yaml")
+	require.NoError(t, err)
+	defer f.Close()
+
+	objs, err := DecodeObjects(f)
+	require.NoError(t, err)
+	require.Len(t, objs, 1)
+	fed, ok := objs[0].(*opgv1beta1.Federation)
+	require.True(t, ok)
+	assert.Equal(t, host, fed.Labels[opgLabel(federationRelation)])
+
+	c, err := NewMemoryClient(testNamespace, objs...)
+	require.NoError(t, err)
+	creds, err := c.GetClientCredentials(context.Background(), fed.Spec.GuestPartnerCredentials.ClientId)
+	require.NoError(t, err)
+	assert.Equal(t, fed.Spec.GuestPartnerCredentials.ClientId, creds.ClientID)
+}
@@ -2,8 +2,11 @@ package mock
 
 import (
 	"context"
+	"fmt"
 	"io"
 	"net/http"
+	"slices"
+	"strings"
 
 	opgewbiv1beta1 "github.com/neonephos-katalis/opg-ewbi-operator/api/operator/v1beta1"
 
@@ -26,6 +29,15 @@ type MockedOpgAPI struct {
 	Apps        map[string]*opgewbiv1beta1.Application
 	AppInsts    map[string]*opgewbiv1beta1.ApplicationInstance
 	AZs         map[string]*opgewbiv1beta1.AvailabilityZone
+	// FileCallbacks, callbacks received, answered with CallbackStatusCodes in
+	// order and 204 once they run out
+	FileCallbacks       []opgmodels.FileStatusCallbackLinkJSONRequestBody
+	CallbackStatusCodes []int
+	// AppRequests, the application update, zone onboard, deboard and forbid
+	// requests received, answered with AppStatusCodes in order and 200 once
+	// they run out
+	AppRequests    []string
+	AppStatusCodes []int
 }
 
 func MakeMokedOpgAPI() *MockedOpgAPI {
@@ -459,30 +471,32 @@ func (c *MockedOpgAPI) ZoneSubscribeWithResponse(
 	reqEditors ...opgc.RequestEditorFn,
 ) (*opgc.ZoneSubscribeResponse, error) {
 
-	aName := body.AcceptedAvailabilityZones[0]
-
 	var res *opgc.ZoneSubscribeResponse
-	// if az already exists return conflict
-	_, ok := c.AZs[aName]
-	if ok {
-		detail := alreadyExistsMsg
-		res = &opgc.ZoneSubscribeResponse{
-			HTTPResponse: &http.Response{
-				StatusCode: 409,
-			},
-			ApplicationproblemJSON409: &opgmodels.ProblemDetails{
-				Detail: &detail,
-			},
+	// if any az already exists return conflict
+	for _, aName := range body.AcceptedAvailabilityZones {
+		if _, ok := c.AZs[aName]; ok {
+			detail := alreadyExistsMsg
+			res = &opgc.ZoneSubscribeResponse{
+				HTTPResponse: &http.Response{
+					StatusCode: 409,
+				},
+				ApplicationproblemJSON409: &opgmodels.ProblemDetails{
+					Detail: &detail,
+				},
+			}
+			return res, nil
 		}
-	} else {
+	}
+
+	for _, aName := range body.AcceptedAvailabilityZones {
 		// for now we are not storing it... we are using the map as a set
 		c.AZs[aName] = &opgewbiv1beta1.AvailabilityZone{}
-		res = &opgc.ZoneSubscribeResponse{
-			Body: []byte{},
-			HTTPResponse: &http.Response{
-				StatusCode: 200,
-			},
-		}
+	}
+	res = &opgc.ZoneSubscribeResponse{
+		Body: []byte{},
+		HTTPResponse: &http.Response{
+			StatusCode: 200,
+		},
 	}
 
 	return res, nil
@@ -521,6 +535,60 @@ func (c *MockedOpgAPI) ZoneUnsubscribeWithResponse(
 	return res, nil
 }
 
+// UpdateFederationWithResponse request returning *UpdateFederationResponse
+func (c *MockedOpgAPI) UpdateFederationWithResponse(
+	ctx context.Context,
+	federationContextId opgmodels.FederationContextId,
+	body opgmodels.UpdateFederationJSONRequestBody,
+	reqEditors ...opgc.RequestEditorFn,
+) (*opgc.UpdateFederationResponse, error) {
+	var res *opgc.UpdateFederationResponse
+	f, ok := c.Federations[federationContextId]
+	if !ok {
+		detail := "unable to update federation, not found"
+		res = &opgc.UpdateFederationResponse{
+			HTTPResponse: &http.Response{
+				StatusCode: 404,
+			},
+			ApplicationproblemJSON404: &opgmodels.ProblemDetails{
+				Detail: &detail,
+			},
+		}
+		return res, nil
+	}
+
+	origin := &f.Spec.OriginOP
+	if body.RemoveMobileNetworkIds != nil && body.RemoveMobileNetworkIds.Mncs != nil {
+		origin.MobileNetworkCodes.MNC = slices.DeleteFunc(origin.MobileNetworkCodes.MNC, func(mnc string) bool {
+			return slices.Contains(*body.RemoveMobileNetworkIds.Mncs, mnc)
+		})
+	}
+	if body.AddMobileNetworkIds != nil {
+		if body.AddMobileNetworkIds.Mcc != nil {
+			origin.MobileNetworkCodes.MCC = *body.AddMobileNetworkIds.Mcc
+		}
+		if body.AddMobileNetworkIds.Mncs != nil {
+			origin.MobileNetworkCodes.MNC = append(origin.MobileNetworkCodes.MNC, *body.AddMobileNetworkIds.Mncs...)
+		}
+	}
+	if body.RemoveFixedNetworkIds != nil {
+		origin.FixedNetworkCodes = slices.DeleteFunc(origin.FixedNetworkCodes, func(code string) bool {
+			return slices.Contains(*body.RemoveFixedNetworkIds, code)
+		})
+	}
+	if body.AddFixedNetworkIds != nil {
+		origin.FixedNetworkCodes = append(origin.FixedNetworkCodes, *body.AddFixedNetworkIds...)
+	}
+
+	res = &opgc.UpdateFederationResponse{
+		Body: []byte{},
+		HTTPResponse: &http.Response{
+			StatusCode: 200,
+		},
+	}
+	return res, nil
+}
+
 // mocks not yet implemented
 
 // CreateFederationWithBodyWithResponse request with arbitrary body returning *CreateFederationResponse
@@ -564,7 +632,34 @@ func (c *MockedOpgAPI) GetAppInstanceDetailsWithResponse(
 	zoneId opgmodels.ZoneIdentifier,
 	reqEditors ...opgc.RequestEditorFn,
 ) (*opgc.GetAppInstanceDetailsResponse, error) {
-	panic(notImplementedMsg)
+	a, ok := c.AppInsts[appInstanceId]
+	if !ok {
+		detail := "application instance not found"
+		return &opgc.GetAppInstanceDetailsResponse{
+			HTTPResponse: &http.Response{
+				StatusCode: 404,
+			},
+			ApplicationproblemJSON404: &opgmodels.ProblemDetails{
+				Detail: &detail,
+			},
+		}, nil
+	}
+	res := &opgc.GetAppInstanceDetailsResponse{
+		Body: []byte{},
+		HTTPResponse: &http.Response{
+			StatusCode: 200,
+		},
+		JSON200: &struct {
+			AccessPointInfo  *opgmodels.AccessPointInfo `json:"accessPointInfo,omitempty"`
+			AppInstanceState *opgmodels.InstanceState   `json:"appInstanceState,omitempty"`
+		}{},
+	}
+	// the state reported is the one set in the stored instance, if any
+	if a.Status.State != "" {
+		state := opgmodels.InstanceState(a.Status.State)
+		res.JSON200.AppInstanceState = &state
+	}
+	return res, nil
 }
 
 // OnboardApplicationWithBodyWithResponse request with arbitrary body returning *OnboardApplicationResponse
@@ -585,7 +680,23 @@ func (c *MockedOpgAPI) ViewApplicationWithResponse(
 	appId opgmodels.AppIdentifier,
 	reqEditors ...opgc.RequestEditorFn,
 ) (*opgc.ViewApplicationResponse, error) {
-	panic(notImplementedMsg)
+	if _, ok := c.Apps[appId]; !ok {
+		detail := "application not found"
+		return &opgc.ViewApplicationResponse{
+			HTTPResponse: &http.Response{
+				StatusCode: 404,
+			},
+			ApplicationproblemJSON404: &opgmodels.ProblemDetails{
+				Detail: &detail,
+			},
+		}, nil
+	}
+	return &opgc.ViewApplicationResponse{
+		Body: []byte{},
+		HTTPResponse: &http.Response{
+			StatusCode: 200,
+		},
+	}, nil
 }
 
 // UpdateApplicationWithBodyWithResponse request with arbitrary body returning *UpdateApplicationResponse
@@ -607,7 +718,10 @@ func (c *MockedOpgAPI) UpdateApplicationWithResponse(
 	body opgmodels.UpdateApplicationJSONRequestBody,
 	reqEditors ...opgc.RequestEditorFn,
 ) (*opgc.UpdateApplicationResponse, error) {
-	panic(notImplementedMsg)
+	return &opgc.UpdateApplicationResponse{
+		Body:         []byte{},
+		HTTPResponse: c.appResponse("update " + appId),
+	}, nil
 }
 
 // OnboardExistingAppNewZonesWithBodyWithResponse request with arbitrary body
@@ -630,7 +744,23 @@ func (c *MockedOpgAPI) OnboardExistingAppNewZonesWithResponse(
 	body opgmodels.OnboardExistingAppNewZonesJSONRequestBody,
 	reqEditors ...opgc.RequestEditorFn,
 ) (*opgc.OnboardExistingAppNewZonesResponse, error) {
-	panic(notImplementedMsg)
+	return &opgc.OnboardExistingAppNewZonesResponse{
+		Body:         []byte{},
+		HTTPResponse: c.appResponse("onboard " + strings.Join(body, ",")),
+	}, nil
+}
+
+// appResponse records the application request and returns its response
+func (c *MockedOpgAPI) appResponse(request string) *http.Response {
+	c.AppRequests = append(c.AppRequests, request)
+	statusCode := http.StatusOK
+	if len(c.AppStatusCodes) > 0 {
+		statusCode = c.AppStatusCodes[0]
+		c.AppStatusCodes = c.AppStatusCodes[1:]
+	}
+	return &http.Response{
+		StatusCode: statusCode,
+	}
 }
 
 // DeboardApplicationWithResponse request returning *DeboardApplicationResponse
@@ -641,7 +771,10 @@ func (c *MockedOpgAPI) DeboardApplicationWithResponse(
 	zoneId opgmodels.ZoneIdentifier,
 	reqEditors ...opgc.RequestEditorFn,
 ) (*opgc.DeboardApplicationResponse, error) {
-	panic(notImplementedMsg)
+	return &opgc.DeboardApplicationResponse{
+		Body:         []byte{},
+		HTTPResponse: c.appResponse("deboard " + zoneId),
+	}, nil
 }
 
 // LockUnlockApplicationZoneWithBodyWithResponse request with arbitrary body
@@ -664,7 +797,14 @@ func (c *MockedOpgAPI) LockUnlockApplicationZoneWithResponse(
 	body opgmodels.LockUnlockApplicationZoneJSONRequestBody,
 	reqEditors ...opgc.RequestEditorFn,
 ) (*opgc.LockUnlockApplicationZoneResponse, error) {
-	panic(notImplementedMsg)
+	zones := make([]string, 0, len(body))
+	for _, z := range body {
+		zones = append(zones, fmt.Sprintf("%v=%v", z["zoneId"], z["forbid"]))
+	}
+	return &opgc.LockUnlockApplicationZoneResponse{
+		Body:         []byte{},
+		HTTPResponse: c.appResponse("forbid " + strings.Join(zones, ",")),
+	}, nil
 }
 
 // GetArtefactWithResponse request returning *GetArtefactResponse
@@ -674,7 +814,23 @@ func (c *MockedOpgAPI) GetArtefactWithResponse(
 	artefactId opgmodels.ArtefactId,
 	reqEditors ...opgc.RequestEditorFn,
 ) (*opgc.GetArtefactResponse, error) {
-	panic(notImplementedMsg)
+	if _, ok := c.Artefacts[artefactId]; !ok {
+		detail := "artefact not found"
+		return &opgc.GetArtefactResponse{
+			HTTPResponse: &http.Response{
+				StatusCode: 404,
+			},
+			ApplicationproblemJSON404: &opgmodels.ProblemDetails{
+				Detail: &detail,
+			},
+		}, nil
+	}
+	return &opgc.GetArtefactResponse{
+		Body: []byte{},
+		HTTPResponse: &http.Response{
+			StatusCode: 200,
+		},
+	}, nil
 }
 
 // GetCandidateZonesWithBodyWithResponse request with arbitrary body returning *GetCandidateZonesResponse
@@ -704,7 +860,23 @@ func (c *MockedOpgAPI) ViewFileWithResponse(
 	fileId opgmodels.FileId,
 	reqEditors ...opgc.RequestEditorFn,
 ) (*opgc.ViewFileResponse, error) {
-	panic(notImplementedMsg)
+	if _, ok := c.Files[fileId]; !ok {
+		detail := "file not found"
+		return &opgc.ViewFileResponse{
+			HTTPResponse: &http.Response{
+				StatusCode: 404,
+			},
+			ApplicationproblemJSON404: &opgmodels.ProblemDetails{
+				Detail: &detail,
+			},
+		}, nil
+	}
+	return &opgc.ViewFileResponse{
+		Body: []byte{},
+		HTTPResponse: &http.Response{
+			StatusCode: 200,
+		},
+	}, nil
 }
 
 // ViewISVResPoolWithResponse request returning *ViewISVResPoolResponse
@@ -800,15 +972,6 @@ func (c *MockedOpgAPI) UpdateFederationWithBodyWithResponse(
 	panic(notImplementedMsg)
 }
 
-func (c *MockedOpgAPI) UpdateFederationWithResponse(
-	ctx context.Context,
-	federationContextId opgmodels.FederationContextId,
-	body opgmodels.UpdateFederationJSONRequestBody,
-	reqEditors ...opgc.RequestEditorFn,
-) (*opgc.UpdateFederationResponse, error) {
-	panic(notImplementedMsg)
-}
-
 // AuthenticateDeviceWithResponse request returning *AuthenticateDeviceResponse
 func (c *MockedOpgAPI) AuthenticateDeviceWithResponse(
 	ctx context.Context,
@@ -1297,7 +1460,18 @@ func (c *MockedOpgAPI) FileStatusCallbackLinkWithResponse(
 	body models.FileStatusCallbackLinkJSONRequestBody,
 	reqEditors ...opgc.RequestEditorFn,
 ) (*opgc.FileStatusCallbackLinkResponse, error) {
-	panic(notImplementedMsg)
+	c.FileCallbacks = append(c.FileCallbacks, body)
+	statusCode := http.StatusNoContent
+	if len(c.CallbackStatusCodes) > 0 {
+		statusCode = c.CallbackStatusCodes[0]
+		c.CallbackStatusCodes = c.CallbackStatusCodes[1:]
+	}
+	return &opgc.FileStatusCallbackLinkResponse{
+		Body: []byte{},
+		HTTPResponse: &http.Response{
+			StatusCode: statusCode,
+		},
+	}, nil
 }
 
 // AppStatusCallbackLinkWithBodyWithResponse request with arbitrary body returning *AppStatusCallbackLinkResponse